@@ -0,0 +1,145 @@
+// gomcp-server 参考服务端：从 manifest 加载工具、提示词、资源并启动 MCP 服务。
+//
+//	gomcp-server --config gomcp.yaml --port 8074 --log-level info
+//
+// 不指定 --config 时启动内置的 geo 演示服务。
+// --stdio 时不监听端口，通过 stdin/stdout 提供服务（供 mcpclient.StdioClient、桌面客户端启动），日志写到 stderr。
+// manifest 中的脚本工具可以使用 Starlark（lang: starlark）。
+// SIGHUP 重新加载 manifest，SIGINT / SIGTERM 优雅退出。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"mcptool/mcpserver"
+)
+
+func main() {
+	config := flag.String("config", "", "path to the YAML manifest (empty: built-in geo demo)")
+	port := flag.Int("port", 0, "listen port, overrides the manifest")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn, error")
+	stdio := flag.Bool("stdio", false, "serve over stdin/stdout instead of listening")
+	flag.Parse()
+
+	if err := mcpserver.SetLogLevel(*logLevel); err != nil {
+		log.Fatalln("Error:", err)
+	}
+	mcpserver.RegisterScriptEngine("starlark", mcpserver.StarlarkEngine{})
+
+	server, err := newServer(*config, *port)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+	if *stdio {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+			log.Println("Error:", err)
+		}
+		return
+	}
+	printSummary(server)
+
+	done := make(chan struct{})
+	go func() {
+		if err := server.Start(context.Background()); err != nil {
+			log.Println("Error:", err)
+		}
+		close(done)
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				reload(*config)
+				continue
+			}
+			log.Printf("received %s, shutting down", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := server.Stop(ctx); err != nil {
+				log.Println("Stop error:", err)
+			}
+			cancel()
+			<-done
+			return
+		}
+	}
+}
+
+func newServer(config string, port int) (*mcpserver.McpServer, error) {
+	m := &mcpserver.Manifest{
+		Server:   mcpserver.McpConf{Addr: "localhost", Port: 8074},
+		Builtins: []string{"geo"},
+	}
+	baseDir := "."
+	if config != "" {
+		var err error
+		if m, err = mcpserver.LoadManifest(config); err != nil {
+			return nil, err
+		}
+		baseDir = filepath.Dir(config)
+	}
+	if port != 0 {
+		m.Server.Port = port
+	}
+	if err := m.Apply(baseDir); err != nil {
+		return nil, err
+	}
+	server := mcpserver.NewMcpServer(m.Server)
+	if err := m.WatchResources(server, baseDir); err != nil {
+		return nil, err
+	}
+	if err := m.WatchPrompts(server, baseDir); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+func reload(config string) {
+	if config == "" {
+		log.Println("SIGHUP ignored: no --config given")
+		return
+	}
+	if err := mcpserver.ReloadManifest(config); err != nil {
+		log.Println("Reload error:", err)
+		return
+	}
+	log.Printf("reloaded %s (server section changes need a restart)", config)
+}
+
+// printSummary 打印已注册的工具、资源、提示词
+func printSummary(server *mcpserver.McpServer) {
+	conf := server.Conf()
+	fmt.Printf("gomcp-server %s:%d\n", conf.Addr, conf.Port)
+
+	tools := server.Tools().List()
+	fmt.Printf("  tools (%d):\n", len(tools))
+	for _, t := range tools {
+		fmt.Printf("    - %s\n", t.Name)
+	}
+
+	resources := mcpserver.ListResources()
+	fmt.Printf("  resources (%d):\n", len(resources))
+	for _, r := range resources {
+		fmt.Printf("    - %s (%s)\n", r["uri"], r["mimeType"])
+	}
+
+	prompts := mcpserver.ListPrompts()
+	fmt.Printf("  prompts (%d):\n", len(prompts))
+	for _, p := range prompts {
+		fmt.Printf("    - %s\n", p.Name)
+	}
+}
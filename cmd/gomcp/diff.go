@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"mcptool/mcpclient"
+)
+
+// runDiff 对比两个 MCP 服务的目录，输出 JSON 格式的差异；
+// --exit-code 时有差异返回状态码 1，方便在发布流水线中使用
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	exitCode := fs.Bool("exit-code", false, "exit with status 1 when the catalogs differ")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout for fetching both catalogs")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: gomcp diff [flags] <url-a> <url-b>")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	a, err := fetchInventory(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := fetchInventory(ctx, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diff := mcpclient.DiffInventory(a, b)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(diff); err != nil {
+		return err
+	}
+	if *exitCode && !diff.Empty() {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// fetchInventory 按 URL scheme 选择 HTTP 或 WebSocket 客户端
+func fetchInventory(ctx context.Context, url string) (*mcpclient.Inventory, error) {
+	var client *mcpclient.UnifiedClient
+	if strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://") {
+		var err error
+		if client, err = mcpclient.NewUnifiedClientWS(url); err != nil {
+			return nil, err
+		}
+	} else {
+		client = mcpclient.NewUnifiedClientHTTP(url)
+	}
+	defer client.Close()
+
+	inv, err := mcpclient.FetchInventory(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return inv, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// projectFiles 模板文件 -> 生成的文件名
+var projectFiles = map[string]string{
+	"templates/main.go.tmpl":       "main.go",
+	"templates/tools.go.tmpl":      "tools.go",
+	"templates/tools_test.go.tmpl": "tools_test.go",
+	"templates/gomcp.yaml.tmpl":    "gomcp.yaml",
+	"templates/Makefile.tmpl":      "Makefile",
+	"templates/go.mod.tmpl":        "go.mod",
+}
+
+type projectData struct {
+	Name      string
+	Module    string
+	Port      int
+	GomcpPath string
+}
+
+// runInit 生成一个新的 MCP 服务项目，已存在的文件不会被覆盖
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	module := fs.String("module", "", "Go module path (default: directory name)")
+	port := fs.Int("port", 8074, "server port written to gomcp.yaml")
+	gomcpPath := fs.String("gomcp-path", "", "local path of the gomcp module used in the replace directive")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: gomcp init [flags] <dir>")
+	}
+
+	dir := fs.Arg(0)
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	data := projectData{
+		Name:      filepath.Base(abs),
+		Module:    *module,
+		Port:      *port,
+		GomcpPath: *gomcpPath,
+	}
+	if data.Module == "" {
+		data.Module = data.Name
+	}
+	if data.GomcpPath == "" {
+		if data.GomcpPath, err = findGomcpRoot(); err != nil {
+			return err
+		}
+	}
+
+	for name := range projectFiles {
+		if _, err := os.Stat(filepath.Join(dir, projectFiles[name])); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, projectFiles[name]))
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for name, out := range projectFiles {
+		tmpl, err := template.ParseFS(templates, name)
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(filepath.Join(dir, out))
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(f, data)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("created %s\n\n  cd %s\n  go mod tidy\n  make run\n", dir, dir)
+	return nil
+}
+
+// findGomcpRoot 从当前目录向上查找 gomcp 模块（module mcptool）的根目录
+func findGomcpRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil && strings.HasPrefix(strings.TrimSpace(string(data)), "module mcptool") {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("gomcp module not found, pass --gomcp-path")
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,43 @@
+// gomcp 命令行工具。
+//
+//	gomcp init [--module name] [--port 8074] [--gomcp-path path] <dir>
+//	gomcp diff [--exit-code] <url-a> <url-b>
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gomcp <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  init    generate a new MCP server project")
+	fmt.Fprintln(os.Stderr, "  diff    compare the catalogs of two MCP servers")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "init":
+		err = runInit(os.Args[2:])
+	case "diff":
+		err = runDiff(os.Args[2:])
+	case "help", "-h", "--help":
+		usage()
+		return
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,141 @@
+// agent 一个不依赖大模型的 agent 循环：按目标规划下一步工具调用，执行后把结果作为观察交回规划器，
+// 直到规划器给出答案。演示 UnifiedClient 的常见用法：检查工具是否存在、给每步设置超时、
+// 用 UUIDv7 作为请求 id 与服务端日志关联、通过 MetaHook 汇总成本（服务端需开启 response_meta）。
+//
+//	go run ./cmd/gomcp-server            # 另一个终端
+//	go run ./examples/agent -from 天安门 -to 颐和园
+//
+// 换成真正的模型时，只需要把 planner 换成调用模型的实现：工具列表作为模型可用的函数，观察作为函数结果
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"mcptool/mcpclient"
+)
+
+// step 一次工具调用
+type step struct {
+	Tool string
+	Args map[string]interface{}
+}
+
+// observation 工具调用的结果
+type observation struct {
+	Step   step
+	Result json.RawMessage
+	Err    error
+}
+
+// planner 根据目标和已有的观察决定下一步；返回 nil 表示已经可以回答
+type planner interface {
+	Next(history []observation) (*step, string)
+}
+
+// routePlanner 先分别解析起点和终点，再查询距离
+type routePlanner struct {
+	from, to string
+}
+
+func (p *routePlanner) Next(history []observation) (*step, string) {
+	if len(history) > 0 && history[len(history)-1].Err != nil {
+		last := history[len(history)-1]
+		return nil, fmt.Sprintf("无法完成：%s 失败：%v", last.Step.Tool, last.Err)
+	}
+	switch len(history) {
+	case 0:
+		return &step{Tool: "geocode", Args: map[string]interface{}{"address": p.from}}, ""
+	case 1:
+		return &step{Tool: "geocode", Args: map[string]interface{}{"address": p.to}}, ""
+	case 2:
+		return &step{Tool: "distance_matrix", Args: map[string]interface{}{
+			"origins":      []string{p.from},
+			"destinations": []string{p.to},
+		}}, ""
+	}
+	var matrix struct {
+		Rows [][]struct {
+			DistanceMeters  int `json:"distance_meters"`
+			DurationSeconds int `json:"duration_seconds"`
+		} `json:"rows"`
+	}
+	if err := json.Unmarshal(history[2].Result, &matrix); err != nil || len(matrix.Rows) == 0 || len(matrix.Rows[0]) == 0 {
+		return nil, fmt.Sprintf("无法解析距离：%s", history[2].Result)
+	}
+	cell := matrix.Rows[0][0]
+	return nil, fmt.Sprintf("%s 到 %s 约 %.1f 公里，需要 %d 分钟", p.from, p.to,
+		float64(cell.DistanceMeters)/1000, cell.DurationSeconds/60)
+}
+
+func main() {
+	url := flag.String("url", "ws://localhost:8074/ws", "MCP WebSocket endpoint")
+	from := flag.String("from", "天安门", "origin address")
+	to := flag.String("to", "颐和园", "destination address")
+	stepTimeout := flag.Duration("step-timeout", 10*time.Second, "timeout of each tool call")
+	maxSteps := flag.Int("max-steps", 8, "stop after this many tool calls")
+	flag.Parse()
+
+	client, err := mcpclient.NewUnifiedClientWS(*url)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+	defer client.Close()
+	client.SetIDGenerator(mcpclient.UUIDv7IDs())
+	var cost float64
+	client.SetMetaHook(func(method string, meta *mcpclient.ResponseMeta) {
+		cost += meta.CostUnits
+	})
+
+	ctx := context.Background()
+	tools, err := availableTools(ctx, client)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+
+	var p planner = &routePlanner{from: *from, to: *to}
+	var history []observation
+	for i := 0; i < *maxSteps; i++ {
+		next, answer := p.Next(history)
+		if next == nil {
+			fmt.Println(answer)
+			fmt.Printf("(%d 次工具调用，成本 %.0f)\n", len(history), cost)
+			return
+		}
+		obs := observation{Step: *next}
+		if !tools[next.Tool] {
+			obs.Err = fmt.Errorf("server has no tool %q", next.Tool)
+		} else {
+			obs.Result, obs.Err = call(ctx, client, *next, *stepTimeout)
+		}
+		log.Printf("step %d: %s %v -> %s %v", i+1, next.Tool, next.Args, obs.Result, obs.Err)
+		history = append(history, obs)
+	}
+	log.Fatalf("gave up after %d steps", *maxSteps)
+}
+
+// availableTools 服务端提供的工具名
+func availableTools(ctx context.Context, client *mcpclient.UnifiedClient) (map[string]bool, error) {
+	list, err := client.ServerToolsList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tools := make(map[string]bool, len(list.Tools))
+	for _, t := range list.Tools {
+		tools[t.Name] = true
+	}
+	return tools, nil
+}
+
+// call 调用一次工具，每步单独设置超时，服务端据此给工具设置相同的截止时间
+func call(ctx context.Context, client *mcpclient.UnifiedClient, s step, timeout time.Duration) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var result json.RawMessage
+	err := client.CallTool(ctx, s.Tool, s.Args, &result)
+	return result, err
+}
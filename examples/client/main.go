@@ -0,0 +1,78 @@
+// client 最小的客户端示例：连接 gomcp-server，列出工具、查询服务信息、调用 geocode，并监听事件。
+//
+//	go run ./cmd/gomcp-server            # 另一个终端
+//	go run ./examples/client -url ws://localhost:8074/ws
+//
+// -url 以 http:// 开头时使用 HTTP，以 ws:// 开头时使用 WebSocket，以 /sse 结尾时只监听事件
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"mcptool/mcpclient"
+)
+
+type GeocodeResult struct {
+	Address string  `json:"address"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	City    string  `json:"city"`
+}
+
+func main() {
+	url := flag.String("url", "ws://localhost:8074/ws", "MCP endpoint (http://, ws:// or .../sse)")
+	flag.Parse()
+	ctx := context.Background()
+
+	client, err := newClient(*url)
+	if err != nil {
+		log.Fatalln("Error:", err)
+	}
+	defer client.Close()
+
+	if strings.HasSuffix(*url, "/sse") {
+		// SSE 客户端只能订阅事件，阻塞直到连接断开
+		err := client.WatchEvents(func(event string, data json.RawMessage) {
+			fmt.Println("SSE Event:", event, "Data:", string(data))
+		})
+		log.Println("SSE closed:", err)
+		return
+	}
+
+	if out, err := client.ServerToolsList(ctx); err != nil {
+		log.Println("Error:", err)
+	} else {
+		fmt.Println("Server tools list:", out)
+	}
+
+	if out, err := client.ServerInfo(ctx); err != nil {
+		log.Println("Error:", err)
+	} else {
+		fmt.Println("Server Info:", out)
+	}
+
+	// 调用 geocode 工具
+	var geoRes GeocodeResult
+	err = client.CallTool(ctx, "geocode", map[string]interface{}{"address": "天安门"}, &geoRes)
+	if err != nil {
+		log.Println("Error:", err)
+	} else {
+		fmt.Println("Geocode Result:", geoRes)
+	}
+}
+
+func newClient(url string) (*mcpclient.UnifiedClient, error) {
+	switch {
+	case strings.HasSuffix(url, "/sse"):
+		return mcpclient.NewUnifiedClientSSE(url), nil
+	case strings.HasPrefix(url, "ws://"), strings.HasPrefix(url, "wss://"):
+		return mcpclient.NewUnifiedClientWS(url)
+	default:
+		return mcpclient.NewUnifiedClientHTTP(url), nil
+	}
+}
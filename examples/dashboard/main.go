@@ -0,0 +1,104 @@
+// dashboard 一个只读的运维看板：定期通过 MCP 查询服务信息、工具状态和成本，渲染成网页。
+// 管理接口默认关闭，需要在服务端开启 admin.costs / admin.tools（manifest 中 methods: {"admin.*": true}），
+// 未开启时对应面板显示为不可用。
+//
+//	go run ./cmd/gomcp-server            # 另一个终端
+//	go run ./examples/dashboard -url http://localhost:8074/mcp -listen localhost:8090
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"mcptool/mcpclient"
+)
+
+// snapshot 一次查询的结果，每个面板单独记录错误
+type snapshot struct {
+	At       time.Time
+	Info     *mcpclient.ServerInfoResp
+	InfoErr  error
+	Tools    json.RawMessage
+	ToolsErr error
+	Costs    json.RawMessage
+	CostsErr error
+}
+
+var page = template.Must(template.New("dashboard").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="5"><title>MCP dashboard</title>
+<style>body{font-family:sans-serif;margin:2em}pre{background:#f5f5f5;padding:1em;overflow:auto}.err{color:#b00}</style>
+</head><body>
+<h1>MCP dashboard</h1>
+<p>updated {{.At.Format "15:04:05"}}</p>
+<h2>server</h2>
+{{if .InfoErr}}<p class="err">{{.InfoErr}}</p>{{else}}<p>{{.Info.Name}} {{.Info.Version}}, {{len .Info.Tools}} tools</p>{{end}}
+<h2>tools</h2>
+{{if .ToolsErr}}<p class="err">unavailable: {{.ToolsErr}}</p>{{else}}<pre>{{printf "%s" .Tools}}</pre>{{end}}
+<h2>costs</h2>
+{{if .CostsErr}}<p class="err">unavailable: {{.CostsErr}}</p>{{else}}<pre>{{printf "%s" .Costs}}</pre>{{end}}
+</body></html>`))
+
+func main() {
+	url := flag.String("url", "http://localhost:8074/mcp", "MCP HTTP endpoint")
+	listen := flag.String("listen", "localhost:8090", "dashboard listen address")
+	interval := flag.Duration("interval", 5*time.Second, "poll interval")
+	flag.Parse()
+
+	client := mcpclient.NewUnifiedClientHTTP(*url)
+	defer client.Close()
+
+	var (
+		mu   sync.Mutex
+		last = poll(client)
+	)
+	go func() {
+		for range time.Tick(*interval) {
+			s := poll(client)
+			mu.Lock()
+			last = s
+			mu.Unlock()
+		}
+	}()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		s := last
+		mu.Unlock()
+		if err := page.Execute(w, s); err != nil {
+			log.Println("render:", err)
+		}
+	})
+	log.Printf("dashboard on http://%s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+// poll 查询一次，单个面板失败不影响其他面板
+func poll(client *mcpclient.UnifiedClient) *snapshot {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	s := &snapshot{At: time.Now()}
+	s.Info, s.InfoErr = client.ServerInfo(ctx)
+	s.ToolsErr = call(ctx, client, "admin.tools", &s.Tools)
+	s.CostsErr = call(ctx, client, "admin.costs", &s.Costs)
+	return s
+}
+
+// call 调用管理接口，结果缩进后展示
+func call(ctx context.Context, client *mcpclient.UnifiedClient, method string, out *json.RawMessage) error {
+	var raw json.RawMessage
+	if err := client.Call(ctx, method, map[string]interface{}{}, &raw); err != nil {
+		return err
+	}
+	indented, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	*out = indented
+	return nil
+}
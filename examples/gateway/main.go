@@ -0,0 +1,125 @@
+// gateway 把两个上游 MCP 服务的工具聚合到一个端点：启动时列出上游工具，以 "<前缀>_<工具名>" 注册为本地工具，
+// 调用时原样转发参数和 _meta，并把上游的内容块、结构化结果和 isError 转换回来。
+//
+//	go run ./cmd/gomcp-server --port 8074   # 上游 a
+//	go run ./cmd/gomcp-server --port 8075   # 上游 b
+//	go run ./examples/gateway -a http://localhost:8074/mcp -b http://localhost:8075/mcp -port 8080
+//
+// 上游的工具列表只在启动时读取，上游新增工具后需要重启网关
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+
+	"mcptool/mcpclient"
+	"mcptool/mcpctx"
+	"mcptool/mcpserver"
+)
+
+// upstream 一个上游服务
+type upstream struct {
+	prefix string
+	client *mcpclient.UnifiedClient
+}
+
+func main() {
+	a := flag.String("a", "http://localhost:8074/mcp", "first upstream HTTP endpoint")
+	b := flag.String("b", "http://localhost:8075/mcp", "second upstream HTTP endpoint")
+	port := flag.Int("port", 8080, "listen port")
+	flag.Parse()
+
+	ctx := context.Background()
+	registry := mcpserver.NewToolRegistry()
+	for _, up := range []upstream{
+		{prefix: "a", client: mcpclient.NewUnifiedClientHTTP(*a)},
+		{prefix: "b", client: mcpclient.NewUnifiedClientHTTP(*b)},
+	} {
+		n, err := mount(ctx, registry, up)
+		if err != nil {
+			log.Fatalf("upstream %s: %v", up.prefix, err)
+		}
+		log.Printf("upstream %s: %d tools", up.prefix, n)
+	}
+
+	server := mcpserver.NewMcpServer(mcpserver.McpConf{Addr: "localhost", Port: *port},
+		mcpserver.WithToolRegistry(registry))
+	if err := server.Start(ctx); err != nil {
+		log.Fatalln("Error:", err)
+	}
+}
+
+// mount 把上游的工具注册到 registry，返回注册的数量
+func mount(ctx context.Context, registry *mcpserver.ToolRegistry, up upstream) (int, error) {
+	list, err := up.client.ServerToolsList(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range list.Tools {
+		name := t.Name // 循环变量在闭包中使用前复制
+		if _, err := registry.Register(&mcpserver.Tool{
+			Name:        up.prefix + "_" + name,
+			Description: fmt.Sprintf("[%s] %s", up.prefix, t.Description),
+			InputSchema: t.InputSchema,
+			Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+				res, err := up.client.CallToolContent(forwardMeta(ctx), name, args)
+				if err != nil {
+					return nil, err
+				}
+				return convertResult(res)
+			},
+		}); err != nil {
+			return 0, err
+		}
+	}
+	return len(list.Tools), nil
+}
+
+// forwardMeta 把请求的 _meta 交给上游；截止时间随 ctx 传递，由客户端重新计算 timeoutMs
+func forwardMeta(ctx context.Context) context.Context {
+	meta := mcpctx.MetaFromContext(ctx)
+	if len(meta) == 0 {
+		return ctx
+	}
+	fields := make(map[string]interface{}, len(meta))
+	for k, v := range meta {
+		fields[k] = v
+	}
+	return mcpclient.WithMeta(ctx, fields)
+}
+
+// convertResult 把上游的结果转换为本地的 CallToolResult
+func convertResult(res *mcpclient.ToolResult) (*mcpserver.CallToolResult, error) {
+	out := mcpserver.NewToolResult()
+	out.IsError = res.IsError
+	if len(res.StructuredContent) > 0 {
+		out.StructuredContent = res.StructuredContent
+	}
+	for _, block := range res.Content {
+		switch block.Type {
+		case "text":
+			out.Content = append(out.Content, mcpserver.NewTextContent(block.Text))
+		case "image":
+			data, err := block.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			out.Content = append(out.Content, mcpserver.NewImageContent(data, block.MimeType))
+		case "resource":
+			r := block.Resource
+			if r.Blob == "" {
+				out.Content = append(out.Content, mcpserver.NewTextResource(r.URI, r.MimeType, r.Text))
+				continue
+			}
+			data, err := block.Bytes()
+			if err != nil {
+				return nil, err
+			}
+			out.Content = append(out.Content, mcpserver.NewBlobResource(r.URI, r.MimeType, data))
+		}
+	}
+	return out, nil
+}
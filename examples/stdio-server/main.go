@@ -0,0 +1,96 @@
+// stdio-server 作为桌面客户端（如 Claude Desktop）子进程运行的 MCP 服务：通过 stdin/stdout 收发消息，
+// 日志只能写到 stderr。构建后在桌面客户端的配置中添加：
+//
+//	{
+//	  "mcpServers": {
+//	    "notes": {"command": "/path/to/stdio-server", "args": ["-dir", "/path/to/notes"]}
+//	  }
+//	}
+//
+// 提供两个工具：列出目录中的笔记、读取一篇笔记
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"mcptool/mcpserver"
+)
+
+type listInput struct {
+	Contains string `json:"contains,omitempty" description:"only notes whose name contains this text"`
+}
+
+type readInput struct {
+	Name string `json:"name" jsonschema:"minLength=1"`
+}
+
+type note struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+func main() {
+	dir := flag.String("dir", ".", "directory containing *.md / *.txt notes")
+	flag.Parse()
+
+	// stdout 用于协议消息，日志改写到 stderr
+	logger := log.New(os.Stderr, "stdio-server ", log.LstdFlags)
+	mcpserver.SetLogger(logger)
+
+	registry := mcpserver.NewToolRegistry()
+	registry.Register(mcpserver.NewTypedTool("list_notes", "List note files in the notes directory",
+		func(ctx context.Context, in listInput) ([]string, error) {
+			return listNotes(*dir, in.Contains)
+		}))
+	registry.Register(mcpserver.NewTypedTool("read_note", "Read a note by name",
+		func(ctx context.Context, in readInput) (*note, error) {
+			return readNote(*dir, in.Name)
+		}))
+
+	server := mcpserver.NewMcpServer(mcpserver.McpConf{}, mcpserver.WithToolRegistry(registry))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && err != context.Canceled {
+		logger.Println("Error:", err)
+	}
+}
+
+func listNotes(dir, contains string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".md" && ext != ".txt") {
+			continue
+		}
+		if contains == "" || strings.Contains(e.Name(), contains) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func readNote(dir, name string) (*note, error) {
+	// 只允许读取目录下的文件
+	if name != filepath.Base(name) {
+		return nil, fmt.Errorf("invalid note name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &note{Name: name, Text: string(data)}, nil
+}
@@ -3,3 +3,11 @@ module mcptool
 go 1.19
 
 require github.com/gorilla/websocket v1.5.3
+
+require (
+	github.com/fsnotify/fsnotify v1.9.0
+	go.starlark.net v0.0.0-20240725214946-42030a7cedce
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.13.0 // indirect
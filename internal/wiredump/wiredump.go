@@ -0,0 +1,146 @@
+// Package wiredump 是 mcpserver.SetWireDump 与 mcpclient.SetWireDump 共用的报文转储和脱敏实现，
+// 两端的输出格式和脱敏规则因此始终一致。每行格式：
+//
+//	2026-01-02T15:04:05.000Z http 127.0.0.1:52344 recv {"jsonrpc":"2.0",...}
+//
+// 写出前脱敏：JSON 中名为 token、password、secret 等的字段值，以及任意位置的 Bearer 令牌和 JWT
+// 都替换为 [REDACTED]
+package wiredump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRedactKeys 默认脱敏的字段名（不区分大小写）
+var DefaultRedactKeys = []string{
+	"authorization", "token", "access_token", "refresh_token", "id_token",
+	"api_key", "apikey", "x-api-key", "password", "secret", "client_secret",
+}
+
+// redactedText 替换被脱敏的值
+const redactedText = "[REDACTED]"
+
+// bearerOrJWT 字符串中的 Bearer 令牌和 JWT
+var bearerOrJWT = regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]+|eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// Dumper 一个转储目标，零值为关闭
+type Dumper struct {
+	mu   sync.Mutex // 同时串行化写入
+	w    io.Writer
+	keys map[string]bool
+}
+
+// Set 开始把报文转储到 w，w 为 nil 时关闭；redactKeys 追加需要脱敏的字段名
+func (d *Dumper) Set(w io.Writer, redactKeys ...string) {
+	keys := make(map[string]bool)
+	for _, k := range append(append([]string(nil), DefaultRedactKeys...), redactKeys...) {
+		keys[strings.ToLower(k)] = true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.w, d.keys = w, keys
+}
+
+// Enabled 是否正在转储
+func (d *Dumper) Enabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.w != nil
+}
+
+// Frame 转储一条报文，dir 为 recv / send；未开启时什么都不做
+func (d *Dumper) Frame(transport, conn, dir string, data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.w == nil {
+		return
+	}
+	fmt.Fprintf(d.w, "%s %s %s %s %s\n", time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		transport, conn, dir, Redact(data, d.keys))
+}
+
+// JSON 转储一个将要编码发送的值
+func (d *Dumper) JSON(transport, conn string, v interface{}) {
+	if !d.Enabled() {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	d.Frame(transport, conn, "send", data)
+}
+
+// Redact 脱敏一条报文：JSON 按字段名处理，SSE 逐个处理 data 行，换行转义为 \n 以保持一行一条
+func Redact(data []byte, keys map[string]bool) []byte {
+	data = bytes.TrimRight(data, "\n")
+	if lines := bytes.Split(data, []byte("\n")); len(lines) > 1 {
+		for i, line := range lines {
+			if rest, ok := cutPrefix(line, []byte("data: ")); ok {
+				lines[i] = append([]byte("data: "), redactJSON(rest, keys)...)
+			}
+		}
+		return bytes.Join(lines, []byte(`\n`))
+	}
+	return redactJSON(data, keys)
+}
+
+func cutPrefix(b, prefix []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(b, prefix) {
+		return b, false
+	}
+	return b[len(prefix):], true
+}
+
+// redactJSON 只有发生了脱敏才重新编码，其余报文原样输出
+func redactJSON(data []byte, keys map[string]bool) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return bearerOrJWT.ReplaceAll(data, []byte(redactedText))
+	}
+	v, changed := redactValue(v, keys)
+	if !changed {
+		return data
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+func redactValue(v interface{}, keys map[string]bool) (interface{}, bool) {
+	changed := false
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			if keys[strings.ToLower(k)] {
+				x[k], changed = redactedText, true
+				continue
+			}
+			var c bool
+			if x[k], c = redactValue(val, keys); c {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i, val := range x {
+			var c bool
+			if x[i], c = redactValue(val, keys); c {
+				changed = true
+			}
+		}
+	case string:
+		if replaced := bearerOrJWT.ReplaceAllString(x, redactedText); replaced != x {
+			return replaced, true
+		}
+	}
+	return v, changed
+}
@@ -0,0 +1,199 @@
+// Package jsonschema 实现 MCP 工具常用的 JSON Schema 子集校验，客户端和服务端共用：
+// type、properties、required、additionalProperties、items、enum、
+// minimum / maximum、minLength / maxLength、minItems / maxItems。
+// 不认识的关键字会被忽略。
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema 一个 JSON Schema 文档（解码后的对象）
+type Schema map[string]interface{}
+
+// Parse 解析 JSON 格式的 schema
+func Parse(data []byte) (Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return s, nil
+}
+
+// ValidationError 校验失败，包含所有不符合的位置
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "schema validation failed: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateJSON 用 schema 校验一段 JSON
+func ValidateJSON(schema Schema, data []byte) error {
+	var v interface{}
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return &ValidationError{Problems: []string{"invalid JSON: " + err.Error()}}
+	}
+	return Validate(schema, v)
+}
+
+// Validate 用 schema 校验一个解码后的 JSON 值（map / []interface{} / float64 / string / bool / nil）
+func Validate(schema Schema, v interface{}) error {
+	var problems []string
+	validate(schema, v, "$", &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validate(schema map[string]interface{}, v interface{}, path string, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, v) {
+		fail("expected %v, got %s", t, typeOf(v))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %v is not one of %v", v, enum)
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		if req, ok := schema["required"].([]interface{}); ok {
+			for _, r := range req {
+				name, _ := r.(string)
+				if _, ok := val[name]; !ok {
+					fail("missing required property %q", name)
+				}
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if sub, ok := props[k].(map[string]interface{}); ok {
+				validate(sub, val[k], path+"."+k, problems)
+				continue
+			}
+			switch ap := schema["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					fail("unexpected property %q", k)
+				}
+			case map[string]interface{}:
+				validate(ap, val[k], path+"."+k, problems)
+			}
+		}
+
+	case []interface{}:
+		if n, ok := number(schema["minItems"]); ok && float64(len(val)) < n {
+			fail("expected at least %v items, got %d", n, len(val))
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(val)) > n {
+			fail("expected at most %v items, got %d", n, len(val))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+
+	case string:
+		l := float64(len([]rune(val)))
+		if n, ok := number(schema["minLength"]); ok && l < n {
+			fail("expected length >= %v", n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && l > n {
+			fail("expected length <= %v", n)
+		}
+
+	case float64:
+		if n, ok := number(schema["minimum"]); ok && val < n {
+			fail("expected >= %v, got %v", n, val)
+		}
+		if n, ok := number(schema["maximum"]); ok && val > n {
+			fail("expected <= %v, got %v", n, val)
+		}
+	}
+}
+
+// matchesType 判断值是否符合 type（字符串或字符串数组）
+func matchesType(t interface{}, v interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		return matchesOne(t, v)
+	case []interface{}:
+		for _, one := range t {
+			if s, ok := one.(string); ok && matchesOne(s, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesOne(t string, v interface{}) bool {
+	switch t {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return typeOf(v) == t
+	}
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func number(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,149 @@
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FromType 根据 Go 类型推导 schema，规则与 encoding/json 的编解码一致：
+//   - 字段名取 json tag，"-" 和未导出字段忽略，匿名嵌入的结构体字段展开到外层
+//   - 没有 omitempty 的字段为 required，可用 jsonschema:"required" / "optional" 覆盖
+//   - jsonschema tag 还可以写约束，逗号分隔：minimum=0,maximum=50,minLength=1,
+//     maxLength=、minItems=、maxItems=、enum=a|b|c
+//   - description tag 作为字段说明
+//
+// 实现了 json.Marshaler 的类型无法推导，生成不限类型的空 schema。
+func FromType(t reflect.Type) Schema {
+	return Schema(typeSchema(t, map[reflect.Type]bool{}))
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// typeSchema 生成的 schema 只使用 map[string]interface{} / []interface{} / float64，
+// 与 Parse 的结果一致，可以直接交给 Validate
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return map[string]interface{}{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			// []byte 按 base64 字符串编码
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), seen)}
+	case reflect.Map:
+		s := map[string]interface{}{"type": "object"}
+		if t.Key().Kind() == reflect.String {
+			s["additionalProperties"] = typeSchema(t.Elem(), seen)
+		}
+		return s
+	case reflect.Struct:
+		if seen[t] {
+			// 递归类型不再展开
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		props := map[string]interface{}{}
+		required := []interface{}{}
+		structFields(t, seen, props, &required)
+		s := map[string]interface{}{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	}
+	// interface{} 等无法推导的类型不限制
+	return map[string]interface{}{}
+}
+
+func structFields(t reflect.Type, seen map[reflect.Type]bool, props map[string]interface{}, required *[]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				structFields(ft, seen, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s := typeSchema(f.Type, seen)
+		if desc := f.Tag.Get("description"); desc != "" {
+			s["description"] = desc
+		}
+		isRequired := !strings.Contains(","+opts+",", ",omitempty,")
+		for _, item := range strings.Split(f.Tag.Get("jsonschema"), ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+			switch key {
+			case "required":
+				isRequired = true
+			case "optional":
+				isRequired = false
+			case "minimum", "maximum", "minLength", "maxLength", "minItems", "maxItems":
+				if n, err := strconv.ParseFloat(value, 64); err == nil {
+					s[key] = n
+				}
+			case "enum":
+				s["enum"] = enumValues(s["type"], strings.Split(value, "|"))
+			}
+		}
+		props[name] = s
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// enumValues 按字段类型转换 enum 取值，数字类型的枚举需要是 JSON 数字才能匹配
+func enumValues(typ interface{}, values []string) []interface{} {
+	enum := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if typ == "integer" || typ == "number" {
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				enum = append(enum, n)
+				continue
+			}
+		}
+		enum = append(enum, v)
+	}
+	return enum
+}
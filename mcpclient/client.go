@@ -3,17 +3,37 @@ package mcpclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"sync"
+
+	"mcptool/version"
 )
 
+// ErrWebSocketUnavailable 用 -tags nowebsocket 编译时，创建 WS 客户端返回该错误
+var ErrWebSocketUnavailable = errors.New("mcpclient: built without websocket support (nowebsocket)")
+
 // ----------------------
 // UnifiedClient
 // ----------------------
 type UnifiedClient struct {
-	mode string // "http", "ws", "sse"
-	http *HTTPClient
-	ws   *WSClient
-	sse  *SSEClient
+	mode  string // "http", "ws", "sse", "stdio"
+	http  *HTTPClient
+	ws    *WSClient
+	sse   *SSEClient
+	stdio *StdioClient
+
+	validator resultValidator
+
+	// roots 公开给服务端的根，见 SetRoots
+	rootsMu  sync.Mutex
+	roots    []Root
+	rootsSet bool
+
+	// pinger 定期 ping，见 StartPing
+	pingMu sync.Mutex
+	pinger *pinger
 }
 
 // NewUnifiedClientHTTP 创建 HTTP 方式的 MCP 客户端
@@ -36,6 +56,59 @@ func NewUnifiedClientWS(url string) (*UnifiedClient, error) {
 	}, nil
 }
 
+// NewUnifiedClientAuto 先通过 HTTP 调用 httpURL 的 server.info，按服务端声明的传输选择通道：
+// 服务端启用了 WS 且本客户端支持时使用 WS（地址由 httpURL 的 scheme、host 和 WS 的路径组成），
+// 否则或 WS 连接失败时使用 HTTP。旧版服务端不返回 transports，使用 HTTP
+func NewUnifiedClientAuto(ctx context.Context, httpURL string) (*UnifiedClient, error) {
+	httpClient := NewUnifiedClientHTTP(httpURL)
+	info, err := httpClient.ServerInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range info.Transports {
+		if t.Name != "ws" {
+			continue
+		}
+		u, err := url.Parse(httpURL)
+		if err != nil {
+			break
+		}
+		if u.Scheme == "https" {
+			u.Scheme = "wss"
+		} else {
+			u.Scheme = "ws"
+		}
+		u.Path, u.RawQuery = t.Path, ""
+		if ws, err := NewUnifiedClientWS(u.String()); err == nil {
+			return ws, nil
+		}
+		break
+	}
+	return httpClient, nil
+}
+
+// NewUnifiedClientWSWithTokenSource 创建需要认证的 WebSocket 客户端
+func NewUnifiedClientWSWithTokenSource(url string, ts TokenSource) (*UnifiedClient, error) {
+	ws, err := NewWSClientWithTokenSource(url, ts)
+	if err != nil {
+		return nil, err
+	}
+	return &UnifiedClient{
+		mode: "ws",
+		ws:   ws,
+	}, nil
+}
+
+// SetTokenSource 设置访问令牌来源：HTTP 立即生效，WS 在下次 Reconnect 时生效
+func (c *UnifiedClient) SetTokenSource(ts TokenSource) {
+	if c.http != nil {
+		c.http.TokenSource = ts
+	}
+	if c.ws != nil {
+		c.ws.TokenSource = ts
+	}
+}
+
 // NewUnifiedClientSSE 创建 SSE 方式的 MCP 客户端
 func NewUnifiedClientSSE(url string) *UnifiedClient {
 	return &UnifiedClient{
@@ -44,18 +117,44 @@ func NewUnifiedClientSSE(url string) *UnifiedClient {
 	}
 }
 
-// CallTool 调用工具
+// NewUnifiedClientStdio 启动子进程，以 stdio 方式连接 MCP 服务
+func NewUnifiedClientStdio(command string, args []string, env []string) (*UnifiedClient, error) {
+	stdio, err := NewStdioClient(command, args, env)
+	if err != nil {
+		return nil, err
+	}
+	return &UnifiedClient{
+		mode:  "stdio",
+		stdio: stdio,
+	}, nil
+}
+
+// CallTool 调用工具；开启结果校验时先按输出 schema 校验，再解码到 result
 func (c *UnifiedClient) CallTool(ctx context.Context, toolName string, args interface{}, result interface{}) error {
+	var raw json.RawMessage
+	var err error
 	switch c.mode {
 	case "http":
-		return c.http.CallTool(ctx, toolName, args, result)
+		err = c.http.CallTool(ctx, toolName, args, &raw)
 	case "ws":
-		return c.ws.CallTool(ctx, toolName, args, result)
+		err = c.ws.CallTool(ctx, toolName, args, &raw)
+	case "stdio":
+		err = c.stdio.CallTool(ctx, toolName, args, &raw)
 	case "sse":
 		return fmt.Errorf("SSE client does not support RPC calls")
 	default:
 		return fmt.Errorf("unknown client mode")
 	}
+	if err != nil {
+		return err
+	}
+	if err := c.validator.check(toolName, raw); err != nil {
+		return err
+	}
+	if result != nil {
+		return json.Unmarshal(raw, result)
+	}
+	return nil
 }
 
 func (c *UnifiedClient) Call(ctx context.Context, method string, args interface{}, result interface{}) error {
@@ -64,6 +163,8 @@ func (c *UnifiedClient) Call(ctx context.Context, method string, args interface{
 		return c.http.Call(ctx, method, args, result)
 	case "ws":
 		return c.ws.Call(ctx, method, args, result)
+	case "stdio":
+		return c.stdio.Call(ctx, method, args, result)
 	case "sse":
 		return fmt.Errorf("SSE client does not support RPC calls")
 	default:
@@ -71,6 +172,16 @@ func (c *UnifiedClient) Call(ctx context.Context, method string, args interface{
 	}
 }
 
+// ServerVersion 获取服务端的版本信息（system.version）：JSON-RPC 版本、支持的 MCP 协议版本、库版本和能力。
+// 旧版服务端只返回 "2.0"，此时只有 JSONRPC 有值
+func (c *UnifiedClient) ServerVersion(ctx context.Context) (*version.Info, error) {
+	var out version.Info
+	if err := c.Call(ctx, "system.version", map[string]any{}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
 // ServerInfo 获取服务信息
 func (c *UnifiedClient) ServerInfo(ctx context.Context) (*ServerInfoResp, error) {
 	switch c.mode {
@@ -82,6 +193,8 @@ func (c *UnifiedClient) ServerInfo(ctx context.Context) (*ServerInfoResp, error)
 		var out ServerInfoResp
 		err := c.ws.Call(ctx, "server.info", map[string]any{}, &out)
 		return &out, err
+	case "stdio":
+		return c.stdio.ServerInfo(ctx)
 	case "sse":
 		return nil, fmt.Errorf("SSE client does not support RPC calls")
 	default:
@@ -89,21 +202,57 @@ func (c *UnifiedClient) ServerInfo(ctx context.Context) (*ServerInfoResp, error)
 	}
 }
 
-// ServerToolsList 获取服务工具列表
+// ServerToolsList 获取服务工具列表，服务端分页时依次请求全部页
 func (c *UnifiedClient) ServerToolsList(ctx context.Context) (*ServerListResp, error) {
+	var out ServerListResp
+	if err := listInto(ctx, c, "tools.list", "tools", &out.Tools); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubscribeEvents 订阅通知主题（方法名的 glob，如 "notifications/tools/*"），返回会话当前订阅的全部主题。
+// 订阅绑定在连接的会话上，WS、stdio 可用；未订阅任何主题时接收全部通知
+func (c *UnifiedClient) SubscribeEvents(ctx context.Context, topics ...string) ([]string, error) {
+	return c.eventTopics(ctx, "events.subscribe", topics)
+}
+
+// UnsubscribeEvents 取消订阅通知主题，不带参数时取消全部，恢复接收全部通知；返回仍在订阅的主题
+func (c *UnifiedClient) UnsubscribeEvents(ctx context.Context, topics ...string) ([]string, error) {
+	return c.eventTopics(ctx, "events.unsubscribe", topics)
+}
+
+func (c *UnifiedClient) eventTopics(ctx context.Context, method string, topics []string) ([]string, error) {
+	var out struct {
+		Topics []string `json:"topics"`
+	}
+	if err := c.Call(ctx, method, map[string]any{"topics": topics}, &out); err != nil {
+		return nil, err
+	}
+	return out.Topics, nil
+}
+
+// SetMetaHook 设置响应 meta 回调（SSE 模式没有 RPC 响应，忽略）
+func (c *UnifiedClient) SetMetaHook(hook MetaHook) {
 	switch c.mode {
 	case "http":
-		var out ServerListResp
-		err := c.http.Call(ctx, "tools.list", map[string]any{}, &out)
-		return &out, err
+		c.http.MetaHook = hook
 	case "ws":
-		var out ServerListResp
-		err := c.ws.Call(ctx, "tools.list", map[string]any{}, &out)
-		return &out, err
-	case "sse":
-		return nil, fmt.Errorf("SSE client does not support RPC calls")
-	default:
-		return nil, fmt.Errorf("unknown client mode")
+		c.ws.MetaHook = hook
+	case "stdio":
+		c.stdio.MetaHook = hook
+	}
+}
+
+// SetIDGenerator 设置请求 id 的生成方式（SSE 模式不发送请求，忽略）
+func (c *UnifiedClient) SetIDGenerator(gen IDGenerator) {
+	switch c.mode {
+	case "http":
+		c.http.IDs = gen
+	case "ws":
+		c.ws.IDs = gen
+	case "stdio":
+		c.stdio.IDs = gen
 	}
 }
 
@@ -116,6 +265,8 @@ func (c *UnifiedClient) WatchEvents(handler func(event string, data json.RawMess
 		return fmt.Errorf("WebSocket client does not support SSE")
 	case "sse":
 		return c.sse.ListenSSE(handler)
+	case "stdio":
+		return c.stdio.WatchEvents(handler)
 	default:
 		return fmt.Errorf("unknown client mode")
 	}
@@ -123,6 +274,7 @@ func (c *UnifiedClient) WatchEvents(handler func(event string, data json.RawMess
 
 // Close 关闭客户端
 func (c *UnifiedClient) Close() {
+	c.stopPing()
 	switch c.mode {
 	case "http":
 		c.http.Close()
@@ -130,5 +282,7 @@ func (c *UnifiedClient) Close() {
 		c.ws.Close()
 	case "sse":
 		c.sse.Close()
+	case "stdio":
+		c.stdio.Close()
 	}
 }
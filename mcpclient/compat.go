@@ -0,0 +1,95 @@
+package mcpclient
+
+import "encoding/json"
+
+// ----------------------
+// 规范方法名迁移
+// ----------------------
+
+// specMethods 旧版 dotted 方法名 -> MCP 规范方法名
+var specMethods = map[string]string{
+	"tools.run":      "tools/call",
+	"tools.list":     "tools/list",
+	"resources.get":  "resources/read",
+	"resources.list": "resources/list",
+	"prompts.get":    "prompts/get",
+	"prompts.list":   "prompts/list",
+}
+
+// SpecMethod 返回旧版方法对应的规范方法名，没有对应关系时原样返回。
+// 客户端开启 SpecMethods 后会自动转换，便于逐步迁移到规范方法名
+func SpecMethod(method string) string {
+	if m, ok := specMethods[method]; ok {
+		return m
+	}
+	return method
+}
+
+// ----------------------
+// tools.run 结果形态
+// ----------------------
+
+// ToolError 工具已执行但失败（结果带 isError），区别于工具不存在、参数错误等协议错误
+type ToolError struct {
+	Message string
+}
+
+func (e *ToolError) Error() string {
+	return "tool error: " + e.Message
+}
+
+// isToolResult 判断结果是否为 CallToolResult 形态（带 content 数组）
+func isToolResult(raw json.RawMessage) bool {
+	var probe map[string]json.RawMessage
+	if json.Unmarshal(raw, &probe) != nil {
+		return false
+	}
+	content := probe["content"]
+	return len(content) > 0 && content[0] == '['
+}
+
+// toolResultPayload 从 tools.run 的结果中取出工具输出：
+// 新版服务端返回 CallToolResult，优先取 structuredContent，否则取第一个 text 块
+// （内容是 JSON 时按 JSON 使用，否则作为 JSON 字符串）；
+// 旧版服务端直接返回工具结果（没有 content 数组），原样使用
+func toolResultPayload(raw json.RawMessage) (json.RawMessage, error) {
+	if !isToolResult(raw) {
+		return raw, nil
+	}
+	var res ToolResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	if err := res.decodeContent(); err != nil {
+		return nil, err
+	}
+	text := ""
+	for _, c := range res.Content {
+		if c.Type == "text" {
+			text = c.Text
+			break
+		}
+	}
+	if res.IsError {
+		return nil, &ToolError{Message: text}
+	}
+	if len(res.StructuredContent) > 0 && string(res.StructuredContent) != "null" {
+		return res.StructuredContent, nil
+	}
+	if json.Valid([]byte(text)) {
+		return json.RawMessage(text), nil
+	}
+	return json.Marshal(text)
+}
+
+// decodeToolResult 解出工具输出并写入 result（为 nil 时丢弃）
+func decodeToolResult(raw json.RawMessage, result interface{}) error {
+	payload, err := toolResultPayload(raw)
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		return json.Unmarshal(payload, result)
+	}
+	return nil
+}
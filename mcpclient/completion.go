@@ -0,0 +1,41 @@
+package mcpclient
+
+import "context"
+
+// ----------------------
+// 参数补全
+// ----------------------
+
+// Completion completion/complete 的结果：Values 最多 100 个，HasMore 表示还有更多
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
+
+// CompletePromptArgument prompt 参数的补全建议，value 为已输入的部分，args 为已填写的其他参数（可以为 nil）
+func (c *UnifiedClient) CompletePromptArgument(ctx context.Context, prompt, argument, value string, args map[string]string) (*Completion, error) {
+	return c.complete(ctx, map[string]any{"type": "ref/prompt", "name": prompt}, argument, value, args)
+}
+
+// CompleteResourceVariable 资源模板变量的补全建议，uriTemplate 为 resources/templates/list 中的模板
+func (c *UnifiedClient) CompleteResourceVariable(ctx context.Context, uriTemplate, variable, value string, args map[string]string) (*Completion, error) {
+	return c.complete(ctx, map[string]any{"type": "ref/resource", "uri": uriTemplate}, variable, value, args)
+}
+
+func (c *UnifiedClient) complete(ctx context.Context, ref map[string]any, name, value string, args map[string]string) (*Completion, error) {
+	params := map[string]any{
+		"ref":      ref,
+		"argument": map[string]any{"name": name, "value": value},
+	}
+	if len(args) > 0 {
+		params["context"] = map[string]any{"arguments": args}
+	}
+	var out struct {
+		Completion Completion `json:"completion"`
+	}
+	if err := c.Call(ctx, "completion/complete", params, &out); err != nil {
+		return nil, err
+	}
+	return &out.Completion, nil
+}
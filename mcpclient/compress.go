@@ -0,0 +1,109 @@
+package mcpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ----------------------
+// 内容块解压
+// ----------------------
+
+// ContentDecoder 解压算法，与服务端的 mcpserver.RegisterContentEncoding 对应
+type ContentDecoder func(data []byte) ([]byte, error)
+
+var (
+	contentDecoders     = map[string]ContentDecoder{"gzip": gzipDecode}
+	contentDecoderNames = []string{"gzip"} // 按注册顺序，即优先级
+	contentDecLock      sync.RWMutex
+)
+
+// RegisterContentDecoder 注册解压算法，后注册的优先，如 zstd：
+//
+//	dec, _ := zstd.NewReader(nil)
+//	mcpclient.RegisterContentDecoder("zstd", func(b []byte) ([]byte, error) {
+//		return dec.DecodeAll(b, nil)
+//	})
+func RegisterContentDecoder(name string, dec ContentDecoder) {
+	contentDecLock.Lock()
+	defer contentDecLock.Unlock()
+	if _, ok := contentDecoders[name]; !ok {
+		contentDecoderNames = append([]string{name}, contentDecoderNames...)
+	}
+	contentDecoders[name] = dec
+}
+
+// acceptContentEncoding 随 tools.run 请求发送的 _meta.acceptContentEncoding
+func acceptContentEncoding() []string {
+	contentDecLock.RLock()
+	defer contentDecLock.RUnlock()
+	return append([]string(nil), contentDecoderNames...)
+}
+
+func gzipDecode(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// decodeContent 就地解压结果中带 contentEncoding 的内容块，调用方拿到的都是原始内容
+func (r *ToolResult) decodeContent() error {
+	for i := range r.Content {
+		if err := r.Content[i].decode(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *ContentBlock) decode() error {
+	if b.ContentEncoding == "" {
+		return nil
+	}
+	contentDecLock.RLock()
+	dec, ok := contentDecoders[b.ContentEncoding]
+	contentDecLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("mcpclient: unsupported content encoding %q", b.ContentEncoding)
+	}
+	unpack := func(encoded string) ([]byte, error) {
+		packed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		return dec(packed)
+	}
+
+	var err error
+	var raw []byte
+	switch {
+	case b.Type == "text":
+		if raw, err = unpack(b.Text); err == nil {
+			b.Text = string(raw)
+		}
+	case b.Type == "image":
+		if raw, err = unpack(b.Data); err == nil {
+			b.Data = base64.StdEncoding.EncodeToString(raw)
+		}
+	case b.Type == "resource" && b.Resource != nil && b.Resource.Blob != "":
+		if raw, err = unpack(b.Resource.Blob); err == nil {
+			b.Resource.Blob = base64.StdEncoding.EncodeToString(raw)
+		}
+	case b.Type == "resource" && b.Resource != nil:
+		if raw, err = unpack(b.Resource.Text); err == nil {
+			b.Resource.Text = string(raw)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("mcpclient: decode %s content (%s): %w", b.Type, b.ContentEncoding, err)
+	}
+	b.ContentEncoding = ""
+	return nil
+}
@@ -0,0 +1,71 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// ----------------------
+// 工具结果内容块
+// ----------------------
+
+// ToolResult MCP 规范的 tools/call 结果
+type ToolResult struct {
+	Content           []ContentBlock  `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+	// Meta 服务端回传的 _meta，包括请求时通过 WithMeta 附带的字段
+	Meta map[string]json.RawMessage `json:"_meta,omitempty"`
+}
+
+// ContentBlock 内容块：type 为 text / image / resource
+type ContentBlock struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"` // image，base64
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+	// ContentEncoding 服务端压缩了该块时的算法，客户端收到结果后已自动解压，调用方看到的总是空
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+}
+
+// ResourceContents 嵌入资源，Text 和 Blob（base64）二选一
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// Bytes 解码 image 块的 Data 或 resource 块的 Blob
+func (b ContentBlock) Bytes() ([]byte, error) {
+	switch {
+	case b.Type == "image":
+		return base64.StdEncoding.DecodeString(b.Data)
+	case b.Type == "resource" && b.Resource != nil && b.Resource.Blob != "":
+		return base64.StdEncoding.DecodeString(b.Resource.Blob)
+	}
+	return nil, fmt.Errorf("content block %q has no binary data", b.Type)
+}
+
+// CallToolContent 调用工具并返回完整的内容块，适合返回图片、嵌入资源的工具；
+// 旧版服务端返回的裸结果会被包装成一个 text 块
+func (c *UnifiedClient) CallToolContent(ctx context.Context, toolName string, args interface{}) (*ToolResult, error) {
+	var raw json.RawMessage
+	if err := c.Call(ctx, "tools.run", toolCallParams(ctx, toolName, args), &raw); err != nil {
+		return nil, err
+	}
+	if !isToolResult(raw) {
+		return &ToolResult{Content: []ContentBlock{{Type: "text", Text: string(raw)}}}, nil
+	}
+	var res ToolResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	if err := res.decodeContent(); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
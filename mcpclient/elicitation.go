@@ -0,0 +1,41 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ----------------------
+// Elicitation
+// 服务端的工具可以通过 elicitation/create 请用户补充信息，
+// 客户端用 SetElicitationHandler 按 schema 向用户展示表单并返回回答；没有设置时服务端收到 -32601
+// ----------------------
+
+// ElicitRequest elicitation/create 的参数，RequestedSchema 为属性都是基本类型的扁平对象 schema
+type ElicitRequest struct {
+	Message         string          `json:"message"`
+	RequestedSchema json.RawMessage `json:"requestedSchema"`
+}
+
+// ElicitResult 用户的回答：Action 为 "accept"（Content 为填写的对象）、"decline" 或 "cancel"
+type ElicitResult struct {
+	Action  string                 `json:"action"`
+	Content map[string]interface{} `json:"content,omitempty"`
+}
+
+// ElicitationHandler 处理服务端的 elicitation 请求；服务端取消请求时 ctx 被取消
+type ElicitationHandler func(ctx context.Context, req *ElicitRequest) (*ElicitResult, error)
+
+// SetElicitationHandler 设置 elicitation/create 的处理函数，h 为 nil 时移除；只有 WS、stdio 客户端支持
+func (c *UnifiedClient) SetElicitationHandler(h ElicitationHandler) error {
+	if h == nil {
+		return c.HandleRequest("elicitation/create", nil)
+	}
+	return c.HandleRequest("elicitation/create", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var req ElicitRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &RequestError{Code: -32602, Message: "Invalid params: " + err.Error()}
+		}
+		return h(ctx, &req)
+	})
+}
@@ -0,0 +1,118 @@
+package mcpclient
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ----------------------
+// 请求 ID
+// 默认每个客户端从 1 开始计数，进程重启后重复，服务端按 id 关联日志 / 审计时会混淆。
+// 设置客户端的 IDs 字段可以改用 UUIDv7 或 snowflake 等跨重启唯一的 ID；
+// 响应 meta 的 RequestID 和服务端的 mcpctx.RequestIDFromContext 都是这个 ID
+// ----------------------
+
+// IDGenerator 生成 JSON-RPC 请求 id，返回数字或字符串，需要并发安全
+type IDGenerator func() interface{}
+
+// CounterIDs 从 1 开始递增的数字 id（客户端的默认方式）
+func CounterIDs() IDGenerator {
+	var n uint64
+	return func() interface{} {
+		return atomic.AddUint64(&n, 1)
+	}
+}
+
+// UUIDv7IDs 按时间排序的 UUIDv7 字符串 id（RFC 9562）
+func UUIDv7IDs() IDGenerator {
+	return func() interface{} {
+		return newUUIDv7(time.Now())
+	}
+}
+
+func newUUIDv7(now time.Time) string {
+	var b [16]byte
+	rand.Read(b[6:])
+	ms := uint64(now.UnixMilli())
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	b[6] = b[6]&0x0f | 0x70 // version 7
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
+
+// snowflakeEpoch snowflake id 的时间起点（2020-01-01 UTC）
+var snowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// SnowflakeIDs snowflake id：41 位毫秒时间戳、10 位节点号（node 取低 10 位）、12 位序号。
+// 以十进制字符串发送，避免超过 2^53 的数字在 JavaScript 宿主中丢失精度
+func SnowflakeIDs(node int64) IDGenerator {
+	var (
+		mu   sync.Mutex
+		last int64
+		seq  int64
+	)
+	node &= 0x3ff
+	return func() interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		ms := time.Now().UnixMilli() - snowflakeEpoch
+		if ms < last {
+			// 时钟回拨时沿用上一个时间戳，依靠序号保证唯一
+			ms = last
+		}
+		if ms == last {
+			seq = (seq + 1) & 0xfff
+			if seq == 0 {
+				// 同一毫秒内序号用完，等到下一毫秒
+				for ms <= last {
+					time.Sleep(time.Millisecond / 10)
+					ms = time.Now().UnixMilli() - snowflakeEpoch
+				}
+			}
+		} else {
+			seq = 0
+		}
+		last = ms
+		return strconv.FormatInt(ms<<22|node<<12|seq, 10)
+	}
+}
+
+// nextRequestID 按 gen 生成 id，gen 为 nil 时使用 counter 计数；同时返回与 idKey 一致的匹配键
+func nextRequestID(gen IDGenerator, counter *uint64) (interface{}, string) {
+	if gen == nil {
+		n := atomic.AddUint64(counter, 1)
+		return n, strconv.FormatUint(n, 10)
+	}
+	id := gen()
+	switch v := id.(type) {
+	case string:
+		return v, v
+	case uint64:
+		return v, strconv.FormatUint(v, 10)
+	case int64:
+		return v, strconv.FormatInt(v, 10)
+	case int:
+		return v, strconv.Itoa(v)
+	default:
+		// 其他类型按 JSON 编码后的文本匹配
+		raw, _ := json.Marshal(v)
+		return v, idKey(raw)
+	}
+}
@@ -0,0 +1,157 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ----------------------
+// 服务目录快照与对比
+// ----------------------
+
+// Inventory 一个服务暴露的目录：工具、提示词、资源。
+// 每一项保存服务端返回的原始 JSON，所以 schema 等新增字段也会参与对比
+type Inventory struct {
+	Tools     map[string]json.RawMessage `json:"tools"`
+	Prompts   map[string]json.RawMessage `json:"prompts"`
+	Resources map[string]json.RawMessage `json:"resources"`
+}
+
+// FetchInventory 通过 tools.list / prompts.list / resources.list 获取服务目录
+func FetchInventory(ctx context.Context, client MCPClient) (*Inventory, error) {
+	inv := &Inventory{}
+	var err error
+	if inv.Tools, err = fetchCatalog(ctx, client, "tools.list", "tools"); err != nil {
+		return nil, err
+	}
+	if inv.Prompts, err = fetchCatalog(ctx, client, "prompts.list", "prompts"); err != nil {
+		return nil, err
+	}
+	if inv.Resources, err = fetchCatalog(ctx, client, "resources.list", "resources"); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// fetchCatalog 调用列表方法（依次请求全部页），按名字建立索引；列表项可以是对象（含 name）或者字符串
+func fetchCatalog(ctx context.Context, client MCPClient, method, field string) (map[string]json.RawMessage, error) {
+	list, err := ListAll(ctx, client, method, field)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	items := make(map[string]json.RawMessage)
+	for _, raw := range list {
+		var name string
+		if err := json.Unmarshal(raw, &name); err != nil {
+			var obj struct {
+				Name string `json:"name"`
+				URI  string `json:"uri"`
+			}
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, fmt.Errorf("%s: unexpected item %s", method, raw)
+			}
+			name = obj.Name
+			if name == "" {
+				name = obj.URI
+			}
+		}
+		items[name] = raw
+	}
+	return items, nil
+}
+
+// ItemChange 两边都存在但内容不同的条目
+type ItemChange struct {
+	Name   string          `json:"name"`
+	Fields []string        `json:"fields"` // 发生变化的顶层字段
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// CatalogDiff 某一类目录的差异
+type CatalogDiff struct {
+	Added   []string     `json:"added,omitempty"`
+	Removed []string     `json:"removed,omitempty"`
+	Changed []ItemChange `json:"changed,omitempty"`
+}
+
+// Empty 没有任何差异
+func (d CatalogDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// InventoryDiff 两个服务目录的差异，Added 表示只在 b 中存在
+type InventoryDiff struct {
+	Tools     CatalogDiff `json:"tools"`
+	Prompts   CatalogDiff `json:"prompts"`
+	Resources CatalogDiff `json:"resources"`
+}
+
+// Empty 两个目录完全一致
+func (d *InventoryDiff) Empty() bool {
+	return d.Tools.Empty() && d.Prompts.Empty() && d.Resources.Empty()
+}
+
+// DiffInventory 对比两个目录快照
+func DiffInventory(a, b *Inventory) *InventoryDiff {
+	return &InventoryDiff{
+		Tools:     diffCatalog(a.Tools, b.Tools),
+		Prompts:   diffCatalog(a.Prompts, b.Prompts),
+		Resources: diffCatalog(a.Resources, b.Resources),
+	}
+}
+
+func diffCatalog(a, b map[string]json.RawMessage) CatalogDiff {
+	var d CatalogDiff
+	for name, before := range a {
+		after, ok := b[name]
+		if !ok {
+			d.Removed = append(d.Removed, name)
+			continue
+		}
+		if fields := changedFields(before, after); len(fields) > 0 {
+			d.Changed = append(d.Changed, ItemChange{Name: name, Fields: fields, Before: before, After: after})
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			d.Added = append(d.Added, name)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+	return d
+}
+
+// changedFields 按语义（忽略 key 顺序和空白）比较两项，返回不同的顶层字段；非对象时返回 ["value"]
+func changedFields(a, b json.RawMessage) []string {
+	var va, vb interface{}
+	json.Unmarshal(a, &va)
+	json.Unmarshal(b, &vb)
+	if reflect.DeepEqual(va, vb) {
+		return nil
+	}
+
+	ma, okA := va.(map[string]interface{})
+	mb, okB := vb.(map[string]interface{})
+	if !okA || !okB {
+		return []string{"value"}
+	}
+	var fields []string
+	for k, v := range ma {
+		if !reflect.DeepEqual(v, mb[k]) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range mb {
+		if _, ok := ma[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
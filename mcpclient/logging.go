@@ -0,0 +1,38 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ----------------------
+// 服务端日志
+// 工具可以向调用方发送日志（notifications/message），客户端用 SetLogLevel 设置接收的最低级别，
+// 用 SetLogHandler 处理收到的日志
+// ----------------------
+
+// LogMessage 服务端发来的一条日志
+type LogMessage struct {
+	// Level debug / info / notice / warning / error / critical / alert / emergency
+	Level  string          `json:"level"`
+	Logger string          `json:"logger,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// SetLogLevel 设置会话接收的最低日志级别（logging/setLevel），服务端默认为 info
+func (c *UnifiedClient) SetLogLevel(ctx context.Context, level string) error {
+	return c.Call(ctx, "logging/setLevel", map[string]any{"level": level}, nil)
+}
+
+// SetLogHandler 处理服务端发来的日志，h 为 nil 时移除；h 在读循环中执行，不要阻塞。只有 WS、stdio 客户端支持
+func (c *UnifiedClient) SetLogHandler(h func(msg LogMessage)) error {
+	if h == nil {
+		return c.HandleNotification("notifications/message", nil)
+	}
+	return c.HandleNotification("notifications/message", func(params json.RawMessage) {
+		var msg LogMessage
+		if json.Unmarshal(params, &msg) == nil {
+			h(msg)
+		}
+	})
+}
@@ -1,15 +1,16 @@
 package mcpclient
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"sync/atomic"
+	"sync"
+	"time"
 
-	"github.com/gorilla/websocket"
+	"mcptool/version"
 )
 
 // ----------------------
@@ -17,11 +18,18 @@ import (
 // ----------------------
 type rpcRequest struct {
 	JsonRPC string      `json:"jsonrpc"`
-	ID      uint64      `json:"id"`
+	ID      interface{} `json:"id"` // 数字或字符串
 	Method  string      `json:"method"`
 	Params  interface{} `json:"params"`
 }
 
+// rpcNotification 客户端发出的通知（没有 id）
+type rpcNotification struct {
+	JsonRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 type rpcError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -29,26 +37,79 @@ type rpcError struct {
 
 type rpcResponse struct {
 	JsonRPC string          `json:"jsonrpc"`
-	ID      uint64          `json:"id"`
+	ID      json.RawMessage `json:"id"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *rpcError       `json:"error,omitempty"`
+	Meta    *ResponseMeta   `json:"meta,omitempty"`
+}
+
+// idKey 把响应中的 id（数字或字符串）规范化为字符串，用于匹配请求
+func idKey(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(bytes.TrimSpace(raw))
+}
+
+// rpcMessage 连接上收到的任意消息：响应或通知
+type rpcMessage struct {
+	rpcResponse
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
 }
 
+// ResponseMeta 服务端开启 response_meta 后随响应返回的元信息
+type ResponseMeta struct {
+	ProcessingMs float64 `json:"processing_ms"`
+	ServerID     string  `json:"server_id,omitempty"`
+	CacheHit     bool    `json:"cache_hit,omitempty"`
+	CostUnits    float64 `json:"cost_units,omitempty"`
+	// Steps 工具记录的子步骤
+	Steps []StepRecord `json:"steps,omitempty"`
+	// RequestID 对应请求的 id（客户端填写），用于与服务端日志关联
+	RequestID string `json:"-"`
+}
+
+// StepRecord 工具内部的一个子步骤
+type StepRecord struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// MetaHook 收到带 meta 的响应时回调，可用于成本归集、耗时统计
+type MetaHook func(method string, meta *ResponseMeta)
+
 // ----------------------
 // ServerInfo 类型
 // ----------------------
 type ServerInfoResp struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
-	Tools   []struct {
+	// Instructions 服务端给模型的使用说明，没有配置时为空
+	Instructions string `json:"instructions,omitempty"`
+	// Capabilities 服务端声明的能力，与 initialize 返回的相同；旧版服务端不返回
+	Capabilities map[string]interface{} `json:"capabilities,omitempty"`
+	Tools        []struct {
 		Name string `json:"name"`
 	} `json:"tools"`
+	// Transports 服务端实际启用的传输，按推荐顺序；旧版服务端不返回
+	Transports []TransportInfo `json:"transports,omitempty"`
+}
+
+// TransportInfo 服务端启用的一种传输：Name 为 "ws" / "streamable-http" / "http+sse" / "sse" / "stdio"，
+// Path 为端点路径
+type TransportInfo struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
 }
 
 type ServerListResp struct {
 	Tools []struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		InputSchema json.RawMessage `json:"inputSchema,omitempty"`
 	} `json:"tools"`
 }
 
@@ -67,8 +128,23 @@ type MCPClient interface {
 // HTTPClient
 // ----------------------
 type HTTPClient struct {
-	URL     string
+	URL      string
+	MetaHook MetaHook
+	// SpecMethods 为 true 时发送 MCP 规范方法名（tools/call 等）而不是旧版 dotted 方法名
+	SpecMethods bool
+	// TokenSource 非空时每个请求带上 Authorization 头
+	TokenSource TokenSource
+	// Client 为 nil 时使用 http.DefaultClient；连接 mTLS 服务端时在 Transport 中配置客户端证书
+	Client *http.Client
+	// IDs 请求 id 的生成方式，nil 时从 1 开始计数，见 IDGenerator
+	IDs     IDGenerator
 	counter uint64
+
+	// sessionID / protocol Streamable HTTP 会话：initialize 的响应带 Mcp-Session-Id 时记下，
+	// 之后的请求都带上它和协商的协议版本（MCP-Protocol-Version），见 Session
+	mu        sync.Mutex
+	sessionID string
+	protocol  string
 }
 
 func NewHTTPClient(url string) *HTTPClient {
@@ -76,28 +152,54 @@ func NewHTTPClient(url string) *HTTPClient {
 }
 
 func (c *HTTPClient) Call(ctx context.Context, method string, args interface{}, result interface{}) error {
-	reqID := atomic.AddUint64(&c.counter, 1)
+	reqID, key := nextRequestID(c.IDs, &c.counter)
+	wireMethod := method
+	if c.SpecMethods {
+		wireMethod = SpecMethod(method)
+	}
 	reqBody := rpcRequest{
-		JsonRPC: "2.0",
+		JsonRPC: version.JSONRPC,
 		ID:      reqID,
-		Method:  method, // "tools.run", "tools.list", "server.info" 等
-		Params:  args,   // 如果是 tools.run，则传 map{name:"", arguments:...}
+		Method:  wireMethod, // "tools.run", "tools.list", "server.info" 等
+		Params:  args,       // 如果是 tools.run，则传 map{name:"", arguments:...}
 	}
 
 	data, _ := json.Marshal(reqBody)
-	req, _ := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewReader(data))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
+	dumpFrame("http", c.URL, "send", data)
+	resp, err := c.post(ctx, data)
 	if err != nil {
 		return err
 	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" && method == "initialize" {
+		c.mu.Lock()
+		c.sessionID = id
+		c.mu.Unlock()
+	}
+	// 令牌被服务端拒绝时强制刷新后重试一次
+	if resp.StatusCode == http.StatusUnauthorized {
+		if inv, ok := c.TokenSource.(invalidator); ok {
+			resp.Body.Close()
+			inv.Invalidate()
+			if resp, err = c.post(ctx, data); err != nil {
+				return err
+			}
+		}
+	}
 	defer resp.Body.Close()
+	if wireDumpEnabled() {
+		body, _ := io.ReadAll(resp.Body)
+		dumpFrame("http", c.URL, "recv", body)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
 
 	var rpcResp rpcResponse
 	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
 		return err
 	}
+	if rpcResp.Meta != nil && c.MetaHook != nil {
+		rpcResp.Meta.RequestID = key
+		c.MetaHook(method, rpcResp.Meta)
+	}
 	if rpcResp.Error != nil {
 		return fmt.Errorf("MCP Error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
 	}
@@ -107,81 +209,85 @@ func (c *HTTPClient) Call(ctx context.Context, method string, args interface{},
 	return nil
 }
 
-func (c *HTTPClient) CallTool(ctx context.Context, toolName string, args interface{}, result interface{}) error {
-	return c.Call(ctx, "tools.run", map[string]interface{}{"name": toolName, "arguments": args}, result)
-}
-
-func (c *HTTPClient) ListenSSE(handler func(event string, data json.RawMessage)) error {
-	return fmt.Errorf("HTTP client does not support SSE")
-}
-
-func (c *HTTPClient) Close() {}
-
-// ----------------------
-// WSClient
-// ----------------------
-type WSClient struct {
-	URL     string
-	conn    *websocket.Conn
-	counter uint64
-}
-
-func NewWSClient(url string) (*WSClient, error) {
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+func (c *HTTPClient) post(ctx context.Context, data []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.URL, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
-	return &WSClient{URL: url, conn: conn}, nil
-}
-func (c *WSClient) Call(ctx context.Context, method string, args interface{}, result interface{}) error {
-	reqID := atomic.AddUint64(&c.counter, 1)
-	req := rpcRequest{
-		JsonRPC: "2.0",
-		ID:      reqID,
-		Method:  method,
-		Params:  args,
+	req.Header.Set("Content-Type", "application/json")
+	c.mu.Lock()
+	if c.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", c.sessionID)
 	}
-
-	if err := c.conn.WriteJSON(req); err != nil {
-		return err
+	if c.protocol != "" {
+		req.Header.Set("MCP-Protocol-Version", c.protocol)
 	}
+	c.mu.Unlock()
+	if err := authHeader(ctx, c.TokenSource, req.Header); err != nil {
+		return nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
 
-	var rpcResp rpcResponse
-	if err := c.conn.ReadJSON(&rpcResp); err != nil {
+// Notify 向服务端发送通知（没有 id 的请求），服务端不返回结果
+func (c *HTTPClient) Notify(ctx context.Context, method string, params interface{}) error {
+	data, err := json.Marshal(rpcNotification{JsonRPC: version.JSONRPC, Method: method, Params: params})
+	if err != nil {
 		return err
 	}
-
-	if rpcResp.Error != nil {
-		return fmt.Errorf("MCP Error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	dumpFrame("http", c.URL, "send", data)
+	resp, err := c.post(ctx, data)
+	if err != nil {
+		return err
 	}
-	if result != nil {
-		return json.Unmarshal(rpcResp.Result, result)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify %s: %s", method, resp.Status)
 	}
 	return nil
 }
-func (c *WSClient) CallTool(ctx context.Context, toolName string, args interface{}, result interface{}) error {
-	return c.Call(ctx, "tools.run", map[string]interface{}{"name": toolName, "arguments": args}, result)
+
+// SessionID Streamable HTTP 的会话 ID，没有 initialize 过时为空
+func (c *HTTPClient) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
 }
 
-func (c *WSClient) ListenSSE(handler func(event string, data json.RawMessage)) error {
-	return fmt.Errorf("WebSocket client does not support SSE")
+// setProtocol 握手后设置之后每个请求带的 MCP-Protocol-Version
+func (c *HTTPClient) setProtocol(protocol string) {
+	c.mu.Lock()
+	c.protocol = protocol
+	c.mu.Unlock()
 }
 
-func (c *WSClient) Close() {
-	if c.conn != nil {
-		// 先发送 Close 帧，告诉服务器“我准备关闭了”。
-		// 服务器收到 Close 帧，可以返回 CloseNormalClosure，不会报 1006 错误。
-		// 然后再真正关闭 TCP 连接。
-		c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
-		c.conn.Close()
+func (c *HTTPClient) CallTool(ctx context.Context, toolName string, args interface{}, result interface{}) error {
+	var raw json.RawMessage
+	if err := c.Call(ctx, "tools.run", toolCallParams(ctx, toolName, args), &raw); err != nil {
+		return err
 	}
+	return decodeToolResult(raw, result)
 }
 
+func (c *HTTPClient) ListenSSE(handler func(event string, data json.RawMessage)) error {
+	return fmt.Errorf("HTTP client does not support SSE")
+}
+
+func (c *HTTPClient) Close() {}
+
 // ----------------------
 // SSEClient
 // ----------------------
 type SSEClient struct {
 	URL string
+	// LastEventID 最近收到的事件 id，ListenSSE 断开后再次调用时随 Last-Event-ID 发送，服务端补发错过的事件
+	LastEventID string
+	// Retry 服务端 retry: 字段建议的重连间隔，没有收到时为 0
+	Retry time.Duration
 }
 
 func NewSSEClient(url string) *SSEClient {
@@ -194,31 +300,33 @@ func (c *SSEClient) CallTool(ctx context.Context, toolName string, args interfac
 	return c.Call(ctx, toolName, args, result)
 }
 
+// ListenSSE 监听事件直到连接断开，handler 的 event 为事件类型（没有 event 行时为 "message"），
+// data 为多个 data 行以换行连接的内容
 func (c *SSEClient) ListenSSE(handler func(event string, data json.RawMessage)) error {
 	req, _ := http.NewRequest("GET", c.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	if c.LastEventID != "" {
+		req.Header.Set("Last-Event-ID", c.LastEventID)
+	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	reader := bufio.NewReader(resp.Body)
-	var eventName string
+	parser := newSSEParser(resp.Body)
+	parser.lastID = c.LastEventID
+	parser.raw = func(line []byte) { dumpFrame("sse", c.URL, "recv", line) }
 	for {
-		line, err := reader.ReadBytes('\n')
+		ev, err := parser.Next()
+		if parser.retry > 0 {
+			c.Retry = parser.retry
+		}
 		if err != nil {
 			return err
 		}
-		line = bytes.TrimSpace(line)
-		if len(line) == 0 {
-			continue
-		}
-		if bytes.HasPrefix(line, []byte("event: ")) {
-			eventName = string(line[7:])
-		} else if bytes.HasPrefix(line, []byte("data: ")) {
-			data := line[6:]
-			handler(eventName, data)
-		}
+		c.LastEventID = ev.ID
+		handler(ev.Event, ev.Data)
 	}
 }
 
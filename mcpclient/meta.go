@@ -0,0 +1,58 @@
+package mcpclient
+
+import (
+	"context"
+	"time"
+)
+
+// ----------------------
+// 请求 _meta
+// ----------------------
+
+type metaKey struct{}
+
+// WithMeta 返回携带 _meta 的 context，之后用它调用工具时 _meta 随请求发送，
+// 服务端原样放入工具的 context（mcpctx.MetaFromContext）并回传到结果的 _meta。
+// 多次调用会合并，同名字段以后设置的为准
+func WithMeta(ctx context.Context, meta map[string]interface{}) context.Context {
+	merged := make(map[string]interface{}, len(meta))
+	for k, v := range metaFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range meta {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metaKey{}, merged)
+}
+
+func metaFromContext(ctx context.Context) map[string]interface{} {
+	m, _ := ctx.Value(metaKey{}).(map[string]interface{})
+	return m
+}
+
+// timeoutMetaKey 剩余超时（毫秒）在 _meta 中的字段名。发送剩余时长而不是截止时刻，不受两端时钟偏差影响
+const timeoutMetaKey = "timeoutMs"
+
+// acceptEncodingMetaKey 客户端支持的内容块压缩算法在 _meta 中的字段名
+const acceptEncodingMetaKey = "acceptContentEncoding"
+
+// toolCallParams tools.run 的参数，ctx 中有 _meta 时一并带上；
+// ctx 有截止时间时把剩余超时放进 _meta，服务端据此给工具设置相同的截止时间；
+// 同时声明支持的内容块压缩算法，服务端据此压缩大的内容块
+func toolCallParams(ctx context.Context, toolName string, args interface{}) map[string]interface{} {
+	params := map[string]interface{}{"name": toolName, "arguments": args}
+	meta := make(map[string]interface{}, len(metaFromContext(ctx))+2)
+	for k, v := range metaFromContext(ctx) {
+		meta[k] = v
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := time.Until(deadline).Milliseconds()
+		if ms < 1 {
+			ms = 1
+		}
+		meta[timeoutMetaKey] = ms
+	}
+	meta[acceptEncodingMetaKey] = acceptContentEncoding()
+	params["_meta"] = meta
+	return params
+}
@@ -0,0 +1,69 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ----------------------
+// 列表分页
+// ----------------------
+
+// maxListPages 一次列举最多请求的页数，防止服务端返回循环的游标
+const maxListPages = 10000
+
+// ListAll 调用列表方法（tools.list、resources/list 等）并按 nextCursor 依次请求后续页，返回 field 中的全部列表项。
+// 不分页的服务端只返回一页，结果相同
+func ListAll(ctx context.Context, client MCPClient, method, field string) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	cursor := ""
+	seen := make(map[string]bool)
+	for page := 0; page < maxListPages; page++ {
+		args := map[string]any{}
+		if cursor != "" {
+			args["cursor"] = cursor
+		}
+		var out map[string]json.RawMessage
+		if err := client.Call(ctx, method, args, &out); err != nil {
+			return nil, err
+		}
+		var list []json.RawMessage
+		if raw, ok := out[field]; ok {
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return nil, fmt.Errorf("%s: %w", method, err)
+			}
+		}
+		items = append(items, list...)
+
+		var next string
+		if raw, ok := out["nextCursor"]; ok {
+			json.Unmarshal(raw, &next)
+		}
+		if next == "" {
+			return items, nil
+		}
+		if seen[next] {
+			return nil, fmt.Errorf("%s: server repeated cursor %q", method, next)
+		}
+		seen[next] = true
+		cursor = next
+	}
+	return nil, fmt.Errorf("%s: more than %d pages", method, maxListPages)
+}
+
+// listInto 列出全部列表项并解码到 out（切片指针）
+func listInto(ctx context.Context, client MCPClient, method, field string, out interface{}) error {
+	items, err := ListAll(ctx, client, method, field)
+	if err != nil {
+		return err
+	}
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	data, _ := json.Marshal(items)
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
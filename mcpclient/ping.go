@@ -0,0 +1,125 @@
+package mcpclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ----------------------
+// Ping 保活
+// Ping 测量到服务端的往返时间；StartPing 按间隔发送 ping，连续失败时认为连接已半开：
+// WS 客户端断开当前连接（开启了 AutoReconnect 时随后重连），其他客户端只通过 OnPing 报告。
+// 与 WS 的 ping/pong 控制帧不同，它确认的是服务端仍在处理请求
+// ----------------------
+
+// PingPolicy 定期 ping 的参数，零值字段使用默认值
+type PingPolicy struct {
+	Interval    time.Duration // ping 间隔，默认 30s
+	Timeout     time.Duration // 每次 ping 的超时，默认 10s
+	MaxFailures int           // 连续失败多少次后断开 WS 连接，默认 3，-1 表示从不断开
+	// OnPing 每次 ping 后回调，成功时 err 为 nil、latency 为往返时间
+	OnPing func(latency time.Duration, err error)
+}
+
+// pinger 运行中的定期 ping
+type pinger struct {
+	mu      sync.Mutex
+	latency time.Duration // 最近一次成功的往返时间
+	stop    chan struct{}
+}
+
+// Ping 发送 ping，返回往返时间
+func (c *UnifiedClient) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if err := c.Call(ctx, "ping", map[string]any{}, nil); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// StartPing 开始定期 ping，已在运行时先停止原来的；Close 时自动停止。返回停止函数
+func (c *UnifiedClient) StartPing(policy PingPolicy) (stop func()) {
+	if policy.Interval <= 0 {
+		policy.Interval = 30 * time.Second
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = 10 * time.Second
+	}
+	if policy.MaxFailures == 0 {
+		policy.MaxFailures = 3
+	}
+	p := &pinger{stop: make(chan struct{})}
+	c.pingMu.Lock()
+	if c.pinger != nil {
+		c.pinger.halt()
+	}
+	c.pinger = p
+	c.pingMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), policy.Timeout)
+			latency, err := c.Ping(ctx)
+			cancel()
+			if err == nil {
+				failures = 0
+				p.mu.Lock()
+				p.latency = latency
+				p.mu.Unlock()
+			} else {
+				failures++
+			}
+			if policy.OnPing != nil {
+				policy.OnPing(latency, err)
+			}
+			if policy.MaxFailures > 0 && failures >= policy.MaxFailures && c.mode == "ws" {
+				failures = 0
+				c.ws.dropConn()
+			}
+		}
+	}()
+	return p.halt
+}
+
+// Latency 最近一次成功 ping 的往返时间，没有运行 StartPing 或还没有成功过时为 0
+func (c *UnifiedClient) Latency() time.Duration {
+	c.pingMu.Lock()
+	p := c.pinger
+	c.pingMu.Unlock()
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latency
+}
+
+// stopPing 停止定期 ping
+func (c *UnifiedClient) stopPing() {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	if c.pinger != nil {
+		c.pinger.halt()
+		c.pinger = nil
+	}
+}
+
+// halt 停止，可以重复调用
+func (p *pinger) halt() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+}
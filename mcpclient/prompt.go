@@ -0,0 +1,55 @@
+package mcpclient
+
+import "context"
+
+// ----------------------
+// Prompt
+// ----------------------
+
+// PromptMessage prompts/get 结果中的一条消息，Role 为 "user" 或 "assistant"
+type PromptMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
+// PromptResult prompts/get 的结果
+type PromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptArgument prompt 声明的参数
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptInfo prompts/list 中的一项
+type PromptInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// ListPrompts 列出调用方可以使用的 prompt 及其描述、参数（prompts/list），服务端分页时依次请求全部页
+func (c *UnifiedClient) ListPrompts(ctx context.Context) ([]PromptInfo, error) {
+	var out []PromptInfo
+	if err := listInto(ctx, c, "prompts/list", "prompts", &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetPrompt 用参数渲染 prompt（prompts/get），返回消息；消息可以包含图片和嵌入资源
+func (c *UnifiedClient) GetPrompt(ctx context.Context, name string, args map[string]string) (*PromptResult, error) {
+	params := map[string]any{"name": name}
+	if len(args) > 0 {
+		params["arguments"] = args
+	}
+	var out PromptResult
+	if err := c.Call(ctx, "prompts/get", params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,55 @@
+package mcpclient
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ----------------------
+// WS 自动重连
+// WSClient.AutoReconnect 非空时，连接意外断开后按指数退避重连（携带 resume token），
+// 成功后重放 Subscribe 注册的订阅。断线时未完成的调用返回 ErrConnectionLost
+// ----------------------
+
+// resubscribeTimeout 重放每个订阅的超时
+const resubscribeTimeout = 10 * time.Second
+
+// ErrConnectionLost 连接在调用完成前断开，可以在重连后重试；
+// 请求可能已被服务端执行，非幂等的工具调用重试前需自行判断
+var ErrConnectionLost = errors.New("mcpclient: connection lost")
+
+// ReconnectPolicy 自动重连的退避策略，零值字段使用默认值
+type ReconnectPolicy struct {
+	InitialDelay time.Duration // 第一次重连前的等待，默认 500ms
+	MaxDelay     time.Duration // 等待的上限，默认 30s
+	MaxAttempts  int           // 连续失败多少次后放弃，0 表示不限
+	// OnReconnect 自动重连结束时回调：成功且订阅已重放时 err 为 nil，
+	// 放弃重连时为最后一次的错误，重放订阅失败时为该错误
+	OnReconnect func(err error)
+}
+
+// delay 第 attempt 次（从 0 开始）重连前的等待，带 ±20% 抖动，避免大量客户端同时重连
+func (p *ReconnectPolicy) delay(attempt int) time.Duration {
+	initial, max := p.InitialDelay, p.MaxDelay
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	d := initial
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d + time.Duration((rand.Float64()*0.4-0.2)*float64(d))
+}
+
+// subscription Subscribe 注册的请求，重连后按注册顺序重放
+type subscription struct {
+	method string
+	params interface{}
+}
@@ -0,0 +1,70 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+// ----------------------
+// 资源
+// ----------------------
+
+// ResourceInfo resources/list 中的一项
+type ResourceInfo struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResources 列出调用方可以读取的资源（resources/list），服务端分页时依次请求全部页
+func (c *UnifiedClient) ListResources(ctx context.Context) ([]ResourceInfo, error) {
+	var out []ResourceInfo
+	if err := listInto(ctx, c, "resources/list", "resources", &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResourceTemplate resources/templates/list 中的一项，URITemplate 为 RFC 6570 URI 模板
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResourceTemplates 列出参数化资源的模板（resources/templates/list），展开后的 URI 用 ReadResource 读取
+func (c *UnifiedClient) ListResourceTemplates(ctx context.Context) ([]ResourceTemplate, error) {
+	var out struct {
+		ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+	}
+	if err := c.Call(ctx, "resources/templates/list", map[string]any{}, &out); err != nil {
+		return nil, err
+	}
+	return out.ResourceTemplates, nil
+}
+
+// ReadResource 按 URI 读取资源（resources/read），文本在 Text 中，二进制内容用 ResourceContents.Bytes 解码
+func (c *UnifiedClient) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var out struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	if err := c.Call(ctx, "resources/read", map[string]any{"uri": uri}, &out); err != nil {
+		return nil, err
+	}
+	return out.Contents, nil
+}
+
+// Bytes 资源内容的字节：Blob 解码 base64，否则为 Text
+func (r ResourceContents) Bytes() ([]byte, error) {
+	if r.Blob != "" {
+		b, err := base64.StdEncoding.DecodeString(r.Blob)
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %w", r.URI, err)
+		}
+		return b, nil
+	}
+	return []byte(r.Text), nil
+}
@@ -0,0 +1,57 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+)
+
+// ----------------------
+// Roots
+// 客户端向服务端公开的根（通常是用户打开的项目目录），服务端通过 roots/list 获取；
+// 用 SetRoots 设置，之后再次调用时向服务端发送 notifications/roots/list_changed
+// ----------------------
+
+// Root 公开给服务端的一个根
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// FileRoot 本地目录对应的根，path 转换为绝对路径的 file:// URI
+func FileRoot(path, name string) (Root, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Root{}, err
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	if u.Path[0] != '/' {
+		u.Path = "/" + u.Path // Windows 盘符路径
+	}
+	return Root{URI: u.String(), Name: name}, nil
+}
+
+// SetRoots 设置公开给服务端的根并响应 roots/list；已经设置过时通知服务端根已变化。只有 WS、stdio 客户端支持
+func (c *UnifiedClient) SetRoots(roots []Root) error {
+	c.rootsMu.Lock()
+	changed := c.rootsSet
+	c.rootsMu.Unlock()
+	if !changed {
+		err := c.HandleRequest("roots/list", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			c.rootsMu.Lock()
+			defer c.rootsMu.Unlock()
+			return map[string]interface{}{"roots": c.roots}, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.rootsMu.Lock()
+	c.roots, c.rootsSet = append([]Root{}, roots...), true
+	c.rootsMu.Unlock()
+	if changed {
+		return c.Notify("notifications/roots/list_changed", nil)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package mcpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ----------------------
+// 表格结果
+// ----------------------
+
+// tableSummary 服务端表格结果的 structuredContent
+type tableSummary struct {
+	Columns  []string `json:"columns"`
+	Format   string   `json:"format"`
+	Rows     int64    `json:"rows"`
+	Streamed bool     `json:"streamed"`
+}
+
+// rowChunk 一块表格数据
+type rowChunk struct {
+	Columns []string `json:"columns"`
+	Format  string   `json:"format"`
+	Data    string   `json:"data"`
+}
+
+var rowsTokenSeq uint64
+
+// RowIterator 逐行读取表格结果：
+//
+//	it, err := client.CallToolRows(ctx, "query", args)
+//	if err != nil { ... }
+//	defer it.Close()
+//	for it.Next() {
+//		row := it.Row()
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// ndjson 格式的值按 JSON 解码，csv 格式的值都是字符串
+type RowIterator struct {
+	chunks  <-chan rowChunk
+	final   func() (*ToolResult, error) // 等待并返回最终结果
+	columns []string
+	format  string
+	pending [][]interface{}
+	row     []interface{}
+	err     error
+	done    bool
+}
+
+// Columns 列名；流式传输且没有任何数据行时，在 Next 返回 false 后才可用
+func (it *RowIterator) Columns() []string {
+	return it.columns
+}
+
+// Row 当前行
+func (it *RowIterator) Row() []interface{} {
+	return it.row
+}
+
+// Err 迭代结束后的错误，包括工具执行失败（*ToolError）
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// Next 前进到下一行，没有更多数据或出错时返回 false
+func (it *RowIterator) Next() bool {
+	for len(it.pending) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		chunk, ok := <-it.chunks
+		if !ok {
+			it.finish()
+			return false
+		}
+		if it.columns == nil {
+			it.columns = chunk.Columns
+		}
+		if chunk.Format != "" {
+			it.format = chunk.Format
+		}
+		if it.pending, it.err = parseRows(it.format, chunk.Data); it.err != nil {
+			return false
+		}
+	}
+	it.row, it.pending = it.pending[0], it.pending[1:]
+	return true
+}
+
+// finish 数据读完后检查最终结果
+func (it *RowIterator) finish() {
+	it.done = true
+	res, err := it.final()
+	if err != nil {
+		it.err = err
+		return
+	}
+	if res.IsError {
+		text := ""
+		if len(res.Content) > 0 {
+			text = res.Content[0].Text
+		}
+		it.err = &ToolError{Message: text}
+		return
+	}
+	var summary tableSummary
+	if json.Unmarshal(res.StructuredContent, &summary) == nil && it.columns == nil {
+		it.columns = summary.Columns
+	}
+}
+
+// Close 丢弃剩余数据并等待调用结束，WS 连接随后可以继续使用
+func (it *RowIterator) Close() error {
+	for range it.chunks {
+	}
+	if !it.done {
+		it.done = true
+		if _, err := it.final(); err != nil && it.err == nil {
+			it.err = err
+		}
+	}
+	return nil
+}
+
+// parseRows 解析一块数据中的所有行
+func parseRows(format, data string) ([][]interface{}, error) {
+	var rows [][]interface{}
+	if format == "csv" {
+		r := csv.NewReader(strings.NewReader(data))
+		r.FieldsPerRecord = -1
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				return rows, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("mcpclient: parse csv rows: %w", err)
+			}
+			row := make([]interface{}, len(record))
+			for i, v := range record {
+				row[i] = v
+			}
+			rows = append(rows, row)
+		}
+	}
+	sc := bufio.NewScanner(strings.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var row []interface{}
+		if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("mcpclient: parse ndjson rows: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, sc.Err()
+}
+
+// CallToolRows 调用返回表格的工具并逐行迭代。WS 模式下数据块以 notifications/rows 流式到达，
+// 边接收边迭代；其他模式下数据块在结果的嵌入资源中。迭代结束后需要调用 Close
+func (c *UnifiedClient) CallToolRows(ctx context.Context, toolName string, args interface{}) (*RowIterator, error) {
+	if c.mode != "ws" {
+		res, err := c.CallToolContent(ctx, toolName, args)
+		if err != nil {
+			return nil, err
+		}
+		return resultRows(res), nil
+	}
+
+	token := fmt.Sprintf("rows-%d", atomic.AddUint64(&rowsTokenSeq, 1))
+	chunks := make(chan rowChunk, 16)
+	stop := make(chan struct{})
+	c.ws.addRowSink(token, func(chunk rowChunk) {
+		// 调用因 ctx 提前返回时不再阻塞读循环
+		select {
+		case chunks <- chunk:
+		case <-stop:
+		}
+	})
+
+	var (
+		res    *ToolResult
+		err    error
+		called = make(chan struct{})
+	)
+	go func() {
+		defer close(called)
+		defer close(chunks)
+		defer c.ws.removeRowSink(token)
+		defer close(stop)
+		res, err = c.CallToolContent(WithMeta(ctx, map[string]interface{}{"rowsToken": token}), toolName, args)
+	}()
+	final := func() (*ToolResult, error) {
+		<-called
+		return res, err
+	}
+	return &RowIterator{chunks: chunks, final: final}, nil
+}
+
+// resultRows 数据块在结果中（非流式）
+func resultRows(res *ToolResult) *RowIterator {
+	it := &RowIterator{final: func() (*ToolResult, error) { return res, nil }}
+	var summary tableSummary
+	json.Unmarshal(res.StructuredContent, &summary)
+	it.columns, it.format = summary.Columns, summary.Format
+
+	chunks := make(chan rowChunk, len(res.Content))
+	for _, b := range res.Content {
+		if b.Type == "resource" && b.Resource != nil && strings.HasPrefix(b.Resource.URI, "table://") {
+			chunks <- rowChunk{Format: summary.Format, Data: b.Resource.Text}
+		}
+	}
+	close(chunks)
+	it.chunks = chunks
+	return it
+}
+
+// ---------------------- WS 分块路由 ----------------------
+
+// rowSinks 按 rowsToken 把 notifications/rows 交给对应的 RowIterator
+type rowSinks struct {
+	mu    sync.Mutex
+	sinks map[string]func(rowChunk)
+}
+
+func (c *WSClient) addRowSink(token string, fn func(rowChunk)) {
+	c.rows.mu.Lock()
+	defer c.rows.mu.Unlock()
+	if c.rows.sinks == nil {
+		c.rows.sinks = make(map[string]func(rowChunk))
+	}
+	c.rows.sinks[token] = fn
+}
+
+func (c *WSClient) removeRowSink(token string) {
+	c.rows.mu.Lock()
+	defer c.rows.mu.Unlock()
+	delete(c.rows.sinks, token)
+}
+
+// deliverRows 处理 notifications/rows，返回是否已被某个 RowIterator 接收
+func (c *WSClient) deliverRows(params json.RawMessage) bool {
+	var chunk struct {
+		rowChunk
+		Token string `json:"rowsToken"`
+	}
+	if json.Unmarshal(params, &chunk) != nil {
+		return false
+	}
+	// 持锁调用，removeRowSink 返回后不会再有投递，RowIterator 可以安全关闭通道
+	c.rows.mu.Lock()
+	defer c.rows.mu.Unlock()
+	fn, ok := c.rows.sinks[chunk.Token]
+	if ok {
+		fn(chunk.rowChunk)
+	}
+	return ok
+}
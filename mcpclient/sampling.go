@@ -0,0 +1,65 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ----------------------
+// Sampling
+// 服务端的工具可以通过 sampling/createMessage 请求客户端调用 LLM，
+// 客户端用 SetSamplingHandler 接入自己的模型；没有设置时服务端收到 -32601
+// ----------------------
+
+// CreateMessageRequest sampling/createMessage 的参数
+type CreateMessageRequest struct {
+	Messages         []SamplingMessage `json:"messages"`
+	ModelPreferences *ModelPreferences `json:"modelPreferences,omitempty"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`
+	IncludeContext   string            `json:"includeContext,omitempty"` // "none" / "thisServer" / "allServers"
+	Temperature      *float64          `json:"temperature,omitempty"`
+	MaxTokens        int               `json:"maxTokens"`
+	StopSequences    []string          `json:"stopSequences,omitempty"`
+	Metadata         json.RawMessage   `json:"metadata,omitempty"`
+}
+
+// SamplingMessage 服务端要求发给 LLM 的一条消息，Content 为 text 或 image 块
+type SamplingMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
+// ModelPreferences 服务端对模型的偏好，优先级取 0~1
+type ModelPreferences struct {
+	Hints []struct {
+		Name string `json:"name"`
+	} `json:"hints,omitempty"`
+	CostPriority         float64 `json:"costPriority,omitempty"`
+	SpeedPriority        float64 `json:"speedPriority,omitempty"`
+	IntelligencePriority float64 `json:"intelligencePriority,omitempty"`
+}
+
+// CreateMessageResult 返回给服务端的补全
+type CreateMessageResult struct {
+	Role       string       `json:"role"` // 通常为 "assistant"
+	Content    ContentBlock `json:"content"`
+	Model      string       `json:"model"`
+	StopReason string       `json:"stopReason,omitempty"` // "endTurn" / "stopSequence" / "maxTokens"
+}
+
+// SamplingHandler 处理服务端的 sampling 请求；服务端取消请求时 ctx 被取消
+type SamplingHandler func(ctx context.Context, req *CreateMessageRequest) (*CreateMessageResult, error)
+
+// SetSamplingHandler 设置 sampling/createMessage 的处理函数，h 为 nil 时移除；只有 WS、stdio 客户端支持
+func (c *UnifiedClient) SetSamplingHandler(h SamplingHandler) error {
+	if h == nil {
+		return c.HandleRequest("sampling/createMessage", nil)
+	}
+	return c.HandleRequest("sampling/createMessage", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var req CreateMessageRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &RequestError{Code: -32602, Message: "Invalid params: " + err.Error()}
+		}
+		return h(ctx, &req)
+	})
+}
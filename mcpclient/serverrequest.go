@@ -0,0 +1,238 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"mcptool/version"
+)
+
+// ----------------------
+// 服务端发来的请求
+// WS、stdio 连接上服务端可以向客户端发起请求（sampling/createMessage 等），
+// 用 HandleRequest 按方法注册处理函数；没有注册的方法回复 -32601，ping 默认回复空结果。
+// 处理函数在独立的 goroutine 中执行，可以在其中调用 Call；服务端发送 notifications/cancelled 时取消其 ctx。
+// 按方法处理服务端通知用 HandleNotification
+// ----------------------
+
+// RequestHandler 处理服务端发来的请求，返回值作为响应的 result；
+// 返回 *RequestError 时按其错误码回复，其他错误回复 -32603
+type RequestHandler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// RequestError 带 JSON-RPC 错误码的处理错误
+type RequestError struct {
+	Code    int
+	Message string
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("MCP Error %d: %s", e.Code, e.Message)
+}
+
+// NotificationHandler 处理服务端发来的通知，在读循环中执行，不要阻塞或同步调用 Call
+type NotificationHandler func(params json.RawMessage)
+
+// requestHandlers 按方法注册的请求、通知处理函数和进行中的请求
+type requestHandlers struct {
+	mu       sync.Mutex
+	handlers map[string]RequestHandler
+	notifies map[string]NotificationHandler
+	inflight map[string]context.CancelFunc // 按请求 id
+}
+
+// has 是否注册了 method 的处理函数
+func (r *requestHandlers) has(method string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.handlers[method]
+	return ok
+}
+
+// set 注册处理函数，h 为 nil 时移除
+func (r *requestHandlers) set(method string, h RequestHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h == nil {
+		delete(r.handlers, method)
+		return
+	}
+	if r.handlers == nil {
+		r.handlers = make(map[string]RequestHandler)
+	}
+	r.handlers[method] = h
+}
+
+// setNotification 注册通知处理函数，h 为 nil 时移除
+func (r *requestHandlers) setNotification(method string, h NotificationHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h == nil {
+		delete(r.notifies, method)
+		return
+	}
+	if r.notifies == nil {
+		r.notifies = make(map[string]NotificationHandler)
+	}
+	r.notifies[method] = h
+}
+
+// notify 把通知交给注册的处理函数，并处理服务端的 notifications/cancelled
+func (r *requestHandlers) notify(method string, params json.RawMessage) {
+	if method == "notifications/cancelled" {
+		r.cancel(params)
+	}
+	r.mu.Lock()
+	h := r.notifies[method]
+	r.mu.Unlock()
+	if h != nil {
+		h(params)
+	}
+}
+
+// isServerRequest 消息是否为服务端发来的请求（同时有 method 和 id）
+func isServerRequest(msg *rpcMessage) bool {
+	return msg.Method != "" && len(msg.ID) > 0 && string(msg.ID) != "null"
+}
+
+// serve 在新的 goroutine 中处理一条服务端请求，reply 写出响应
+func (r *requestHandlers) serve(msg rpcMessage, reply func(data []byte) error) {
+	key := idKey(msg.ID)
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	h := r.handlers[msg.Method]
+	if r.inflight == nil {
+		r.inflight = make(map[string]context.CancelFunc)
+	}
+	r.inflight[key] = cancel
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.inflight, key)
+			r.mu.Unlock()
+			cancel()
+		}()
+		resp := rpcResponse{JsonRPC: version.JSONRPC, ID: msg.ID}
+		if h == nil && msg.Method == "ping" {
+			// 服务端的保活 ping，没有注册处理函数时回复空结果
+			resp.Result = json.RawMessage("{}")
+		} else if h == nil {
+			resp.Error = &rpcError{Code: -32601, Message: "Method not found: " + msg.Method}
+		} else if result, err := h(ctx, msg.Params); err != nil {
+			var reqErr *RequestError
+			if errors.As(err, &reqErr) {
+				resp.Error = &rpcError{Code: reqErr.Code, Message: reqErr.Message}
+			} else {
+				resp.Error = &rpcError{Code: -32603, Message: err.Error()}
+			}
+		} else if resp.Result, err = json.Marshal(result); err != nil {
+			resp.Result = nil
+			resp.Error = &rpcError{Code: -32603, Message: err.Error()}
+		} else if string(resp.Result) == "null" {
+			resp.Result = json.RawMessage("{}")
+		}
+		if ctx.Err() != nil {
+			// 服务端已取消，不再回复
+			return
+		}
+		data, err := json.Marshal(resp)
+		if err == nil {
+			err = reply(data)
+		}
+		if err != nil {
+			log.Printf("mcpclient: reply to %s: %v", msg.Method, err)
+		}
+	}()
+}
+
+// cancel 处理服务端的 notifications/cancelled
+func (r *requestHandlers) cancel(params json.RawMessage) {
+	var p struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(params, &p) != nil || len(p.RequestID) == 0 {
+		return
+	}
+	r.mu.Lock()
+	cancel, ok := r.inflight[idKey(p.RequestID)]
+	r.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// HandleRequest 注册服务端请求 method 的处理函数，h 为 nil 时移除
+func (c *WSClient) HandleRequest(method string, h RequestHandler) {
+	c.requests.set(method, h)
+}
+
+// HandleRequest 注册服务端请求 method 的处理函数，h 为 nil 时移除
+func (c *StdioClient) HandleRequest(method string, h RequestHandler) {
+	c.requests.set(method, h)
+}
+
+// HandleNotification 注册服务端通知 method 的处理函数，h 为 nil 时移除
+func (c *WSClient) HandleNotification(method string, h NotificationHandler) {
+	c.requests.setNotification(method, h)
+}
+
+// HandleNotification 注册服务端通知 method 的处理函数，h 为 nil 时移除
+func (c *StdioClient) HandleNotification(method string, h NotificationHandler) {
+	c.requests.setNotification(method, h)
+}
+
+// HandleNotification 注册服务端通知 method 的处理函数；只有 WS、stdio 客户端支持
+func (c *UnifiedClient) HandleNotification(method string, h NotificationHandler) error {
+	switch c.mode {
+	case "ws":
+		c.ws.HandleNotification(method, h)
+	case "stdio":
+		c.stdio.HandleNotification(method, h)
+	default:
+		return fmt.Errorf("%s client cannot receive notifications by method", c.mode)
+	}
+	return nil
+}
+
+// HandleRequest 注册服务端请求 method 的处理函数；只有 WS、stdio 客户端能收到服务端请求
+func (c *UnifiedClient) HandleRequest(method string, h RequestHandler) error {
+	switch c.mode {
+	case "ws":
+		c.ws.HandleRequest(method, h)
+	case "stdio":
+		c.stdio.HandleRequest(method, h)
+	default:
+		return fmt.Errorf("%s client cannot receive server requests", c.mode)
+	}
+	return nil
+}
+
+// handlesRequest 是否注册了服务端请求 method 的处理函数
+func (c *UnifiedClient) handlesRequest(method string) bool {
+	switch c.mode {
+	case "ws":
+		return c.ws.requests.has(method)
+	case "stdio":
+		return c.stdio.requests.has(method)
+	}
+	return false
+}
+
+// Notify 向服务端发送通知；SSE 客户端不支持
+func (c *UnifiedClient) Notify(method string, params interface{}) error {
+	switch c.mode {
+	case "http":
+		return c.http.Notify(context.Background(), method, params)
+	case "ws":
+		return c.ws.Notify(method, params)
+	case "stdio":
+		return c.stdio.Notify(method, params)
+	default:
+		return fmt.Errorf("%s client cannot send notifications", c.mode)
+	}
+}
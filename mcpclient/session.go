@@ -0,0 +1,310 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mcptool/version"
+)
+
+// ----------------------
+// Session
+// NewSession 与服务端完成 initialize / notifications/initialized 握手，记下协商的协议版本、
+// 服务端信息和能力，之后通过类型化的方法调用服务端；服务端没有声明的能力直接返回 ErrNotSupported，不发出请求。
+// 客户端的能力（roots、sampling、elicitation）按握手时已设置的处理函数声明，
+// 所以 SetRoots、SetSamplingHandler、SetElicitationHandler 要在 NewSession 之前调用。
+// WS 断线重连且没有恢复原会话时，新会话不会重新握手，服务端按最新协议版本处理
+// ----------------------
+
+// ErrNotSupported 服务端没有声明调用需要的能力
+var ErrNotSupported = errors.New("mcpclient: not supported by server")
+
+// ErrProtocolVersion 服务端选择的协议版本本客户端不支持
+var ErrProtocolVersion = errors.New("mcpclient: unsupported protocol version")
+
+// Implementation initialize 中的 clientInfo / serverInfo
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ListChangedCapability tools / prompts 能力
+type ListChangedCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ResourcesCapability resources 能力，Subscribe 表示支持 resources/subscribe
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ServerCapabilities 服务端在 initialize 中声明的能力，没有声明的为 nil
+type ServerCapabilities struct {
+	Tools        *ListChangedCapability     `json:"tools,omitempty"`
+	Resources    *ResourcesCapability       `json:"resources,omitempty"`
+	Prompts      *ListChangedCapability     `json:"prompts,omitempty"`
+	Logging      *struct{}                  `json:"logging,omitempty"`
+	Completions  *struct{}                  `json:"completions,omitempty"`
+	Experimental map[string]json.RawMessage `json:"experimental,omitempty"`
+}
+
+// SessionOptions NewSession 的参数，零值可用
+type SessionOptions struct {
+	// ClientInfo 发给服务端的 clientInfo，为空时为 mcpclient 和库版本
+	ClientInfo Implementation
+	// ProtocolVersion 请求的协议版本，默认最新版本；服务端不支持时会选择其他版本，
+	// 只要在 version.ProtocolVersions 中就接受（降级）
+	ProtocolVersion string
+}
+
+// Session 完成握手的 MCP 会话
+type Session struct {
+	client       *UnifiedClient
+	protocol     string
+	serverInfo   Implementation
+	capabilities ServerCapabilities
+	instructions string
+}
+
+// NewSession 在 c 上完成握手。失败时 c 仍可使用，由调用方决定是否关闭
+func NewSession(ctx context.Context, c *UnifiedClient, opts SessionOptions) (*Session, error) {
+	if opts.ClientInfo.Name == "" {
+		opts.ClientInfo = Implementation{Name: "mcpclient", Version: version.Library}
+	}
+	if opts.ProtocolVersion == "" {
+		opts.ProtocolVersion = version.Latest()
+	}
+	params := map[string]any{
+		"protocolVersion": opts.ProtocolVersion,
+		"clientInfo":      opts.ClientInfo,
+		"capabilities":    c.clientCapabilities(),
+	}
+	var out struct {
+		ProtocolVersion string             `json:"protocolVersion"`
+		ServerInfo      Implementation     `json:"serverInfo"`
+		Capabilities    ServerCapabilities `json:"capabilities"`
+		Instructions    string             `json:"instructions"`
+	}
+	if err := c.Call(ctx, "initialize", params, &out); err != nil {
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	if !version.Supports(out.ProtocolVersion) {
+		return nil, fmt.Errorf("%w: server chose %q", ErrProtocolVersion, out.ProtocolVersion)
+	}
+	if c.http != nil {
+		c.http.setProtocol(out.ProtocolVersion)
+	}
+	if err := c.Notify("notifications/initialized", map[string]any{}); err != nil {
+		return nil, fmt.Errorf("initialized: %w", err)
+	}
+	return &Session{
+		client:       c,
+		protocol:     out.ProtocolVersion,
+		serverInfo:   out.ServerInfo,
+		capabilities: out.Capabilities,
+		instructions: out.Instructions,
+	}, nil
+}
+
+// clientCapabilities 按已设置的处理函数声明客户端能力
+func (c *UnifiedClient) clientCapabilities() map[string]any {
+	caps := map[string]any{}
+	c.rootsMu.Lock()
+	if c.rootsSet {
+		caps["roots"] = map[string]any{"listChanged": true}
+	}
+	c.rootsMu.Unlock()
+	if c.handlesRequest("sampling/createMessage") {
+		caps["sampling"] = map[string]any{}
+	}
+	if c.handlesRequest("elicitation/create") {
+		caps["elicitation"] = map[string]any{}
+	}
+	return caps
+}
+
+// Client 底层客户端，用于 Session 没有封装的调用
+func (s *Session) Client() *UnifiedClient {
+	return s.client
+}
+
+// ProtocolVersion 协商的协议版本
+func (s *Session) ProtocolVersion() string {
+	return s.protocol
+}
+
+// ServerInfo 服务端的名称和版本
+func (s *Session) ServerInfo() Implementation {
+	return s.serverInfo
+}
+
+// Capabilities 服务端声明的能力
+func (s *Session) Capabilities() ServerCapabilities {
+	return s.capabilities
+}
+
+// Instructions 服务端给模型的使用说明，可以放进系统提示词
+func (s *Session) Instructions() string {
+	return s.instructions
+}
+
+// Close 关闭底层客户端
+func (s *Session) Close() {
+	s.client.Close()
+}
+
+// methodCapabilities 方法 -> 需要的服务端能力
+var methodCapabilities = map[string]string{
+	"tools/list":               "tools",
+	"tools/call":               "tools",
+	"resources/list":           "resources",
+	"resources/read":           "resources",
+	"resources/templates/list": "resources",
+	"resources/subscribe":      "resources.subscribe",
+	"resources/unsubscribe":    "resources.subscribe",
+	"prompts/list":             "prompts",
+	"prompts/get":              "prompts",
+	"logging/setLevel":         "logging",
+	"completion/complete":      "completions",
+}
+
+// Supports 服务端是否声明了方法需要的能力，不需要能力的方法（ping 等）总是支持
+func (s *Session) Supports(method string) bool {
+	caps := s.capabilities
+	switch methodCapabilities[SpecMethod(method)] {
+	case "tools":
+		return caps.Tools != nil
+	case "resources":
+		return caps.Resources != nil
+	case "resources.subscribe":
+		return caps.Resources != nil && caps.Resources.Subscribe
+	case "prompts":
+		return caps.Prompts != nil
+	case "logging":
+		return caps.Logging != nil
+	case "completions":
+		return caps.Completions != nil
+	}
+	return true
+}
+
+// require 服务端没有声明方法需要的能力时返回 ErrNotSupported
+func (s *Session) require(method string) error {
+	if !s.Supports(method) {
+		return fmt.Errorf("%w: %s", ErrNotSupported, method)
+	}
+	return nil
+}
+
+// Call 调用任意方法，服务端没有声明需要的能力时返回 ErrNotSupported
+func (s *Session) Call(ctx context.Context, method string, params, result interface{}) error {
+	if err := s.require(method); err != nil {
+		return err
+	}
+	return s.client.Call(ctx, method, params, result)
+}
+
+// ToolInfo tools/list 中的一项
+type ToolInfo struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description,omitempty"`
+	InputSchema  json.RawMessage `json:"inputSchema,omitempty"`
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+}
+
+// ListTools 列出服务端的工具，服务端分页时依次请求全部页
+func (s *Session) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	if err := s.require("tools/list"); err != nil {
+		return nil, err
+	}
+	var out []ToolInfo
+	if err := listInto(ctx, s.client, "tools/list", "tools", &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CallTool 调用工具，返回完整的内容块，见 UnifiedClient.CallToolContent
+func (s *Session) CallTool(ctx context.Context, name string, args interface{}) (*ToolResult, error) {
+	if err := s.require("tools/call"); err != nil {
+		return nil, err
+	}
+	return s.client.CallToolContent(ctx, name, args)
+}
+
+// ListResources 列出资源
+func (s *Session) ListResources(ctx context.Context) ([]ResourceInfo, error) {
+	if err := s.require("resources/list"); err != nil {
+		return nil, err
+	}
+	return s.client.ListResources(ctx)
+}
+
+// ListResourceTemplates 列出资源模板
+func (s *Session) ListResourceTemplates(ctx context.Context) ([]ResourceTemplate, error) {
+	if err := s.require("resources/templates/list"); err != nil {
+		return nil, err
+	}
+	return s.client.ListResourceTemplates(ctx)
+}
+
+// ReadResource 读取资源
+func (s *Session) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	if err := s.require("resources/read"); err != nil {
+		return nil, err
+	}
+	return s.client.ReadResource(ctx, uri)
+}
+
+// SubscribeResource 订阅资源的 notifications/resources/updated，需要服务端声明 resources.subscribe
+func (s *Session) SubscribeResource(ctx context.Context, uri string) error {
+	return s.Call(ctx, "resources/subscribe", map[string]any{"uri": uri}, nil)
+}
+
+// UnsubscribeResource 取消订阅资源
+func (s *Session) UnsubscribeResource(ctx context.Context, uri string) error {
+	return s.Call(ctx, "resources/unsubscribe", map[string]any{"uri": uri}, nil)
+}
+
+// ListPrompts 列出 prompt
+func (s *Session) ListPrompts(ctx context.Context) ([]PromptInfo, error) {
+	if err := s.require("prompts/list"); err != nil {
+		return nil, err
+	}
+	return s.client.ListPrompts(ctx)
+}
+
+// GetPrompt 渲染 prompt
+func (s *Session) GetPrompt(ctx context.Context, name string, args map[string]string) (*PromptResult, error) {
+	if err := s.require("prompts/get"); err != nil {
+		return nil, err
+	}
+	return s.client.GetPrompt(ctx, name, args)
+}
+
+// CompletePromptArgument prompt 参数的补全建议
+func (s *Session) CompletePromptArgument(ctx context.Context, prompt, argument, value string, args map[string]string) (*Completion, error) {
+	if err := s.require("completion/complete"); err != nil {
+		return nil, err
+	}
+	return s.client.CompletePromptArgument(ctx, prompt, argument, value, args)
+}
+
+// CompleteResourceVariable 资源模板变量的补全建议
+func (s *Session) CompleteResourceVariable(ctx context.Context, uriTemplate, variable, value string, args map[string]string) (*Completion, error) {
+	if err := s.require("completion/complete"); err != nil {
+		return nil, err
+	}
+	return s.client.CompleteResourceVariable(ctx, uriTemplate, variable, value, args)
+}
+
+// SetLogLevel 设置会话接收日志的最低级别
+func (s *Session) SetLogLevel(ctx context.Context, level string) error {
+	if err := s.require("logging/setLevel"); err != nil {
+		return err
+	}
+	return s.client.SetLogLevel(ctx, level)
+}
@@ -0,0 +1,134 @@
+package mcpclient
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ----------------------
+// SSE 解析
+// ----------------------
+// 按 WHATWG HTML 规范（9.2 Server-sent events）解析事件流：
+//   - 行结束符可以是 CRLF、LF 或单独的 CR，流开头的 BOM 被忽略；
+//   - 以 ":" 开头的行是注释；冒号后的一个空格（如果有）不属于值，没有冒号的行整行为字段名、值为空；
+//   - 多个 data 行以 "\n" 连接；空行派发事件，没有 data 的事件不派发；
+//   - id 在事件之间保留，值中含 NUL 时忽略；retry 只接受纯数字；
+//   - 流在事件中途结束时丢弃该事件；未知字段忽略。
+
+// sseMaxLine 单行的上限，超出时返回 bufio.ErrTooLong
+const sseMaxLine = 16 << 20
+
+// SSEEvent 一个已派发的事件
+type SSEEvent struct {
+	// ID 派发时的 last event id，事件没有 id 行时沿用之前的值
+	ID string
+	// Event 事件类型，没有 event 行时为 "message"
+	Event string
+	Data  []byte
+}
+
+// sseParser 从流中逐个读取事件
+type sseParser struct {
+	scanner *bufio.Scanner
+	first   bool
+	// raw 非空时每读到一个非空行回调一次，用于报文转储
+	raw func(line []byte)
+
+	lastID string
+	retry  time.Duration // 最近一次 retry 字段，0 表示没有收到
+}
+
+func newSSEParser(r io.Reader) *sseParser {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), sseMaxLine)
+	sc.Split(scanSSELines)
+	return &sseParser{scanner: sc, first: true}
+}
+
+// Next 读取下一个事件，流结束时返回 io.EOF
+func (p *sseParser) Next() (*SSEEvent, error) {
+	var (
+		event   string
+		data    bytes.Buffer
+		hasData bool
+	)
+	for p.scanner.Scan() {
+		line := p.scanner.Bytes()
+		if p.first {
+			line = bytes.TrimPrefix(line, []byte("\xEF\xBB\xBF"))
+			p.first = false
+		}
+		if len(line) == 0 {
+			if !hasData {
+				event = ""
+				continue
+			}
+			if event == "" {
+				event = "message"
+			}
+			payload := data.Bytes()
+			return &SSEEvent{ID: p.lastID, Event: event, Data: payload[:len(payload)-1]}, nil
+		}
+		if p.raw != nil {
+			p.raw(line)
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+		switch string(field) {
+		case "event":
+			event = string(value)
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				p.lastID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				p.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// scanSSELines bufio.SplitFunc：按 CRLF、LF 或单独的 CR 分行
+func scanSSELines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, b := range data {
+		switch b {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// CR 在缓冲末尾，需要看下一个字节才知道是不是 CRLF
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		// 最后一行没有结束符，之后不会再有空行，所在的事件不会派发
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
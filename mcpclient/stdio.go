@@ -0,0 +1,237 @@
+package mcpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"mcptool/version"
+)
+
+// ----------------------
+// StdioClient
+// ----------------------
+
+// StdioClient 启动一个子进程，通过它的 stdin/stdout 按行收发 JSON-RPC 消息
+type StdioClient struct {
+	Command  string
+	Args     []string
+	Env      []string // 追加到当前进程环境变量之后
+	MetaHook MetaHook
+	// SpecMethods 为 true 时发送 MCP 规范方法名
+	SpecMethods bool
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// IDs 请求 id 的生成方式，nil 时从 1 开始计数，见 IDGenerator
+	IDs IDGenerator
+
+	writeMu sync.Mutex
+	counter uint64
+
+	mu       sync.Mutex
+	pending  map[string]chan rpcResponse
+	handlers []func(event string, data json.RawMessage)
+	requests requestHandlers // 服务端发来的请求，见 HandleRequest
+	done     chan struct{}
+	err      error // 读循环退出的原因
+}
+
+var _ MCPClient = (*StdioClient)(nil)
+
+// NewStdioClient 启动子进程并返回客户端
+func NewStdioClient(command string, args []string, env []string) (*StdioClient, error) {
+	c := &StdioClient{
+		Command: command,
+		Args:    args,
+		Env:     env,
+		pending: make(map[string]chan rpcResponse),
+		done:    make(chan struct{}),
+	}
+
+	c.cmd = exec.Command(command, args...)
+	c.cmd.Env = append(os.Environ(), env...)
+	c.cmd.Stderr = os.Stderr
+
+	stdin, err := c.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, err
+	}
+	c.stdin = stdin
+
+	go c.readLoop(stdout)
+	return c, nil
+}
+
+// readLoop 读取子进程输出：响应按 id 交给等待中的调用，通知交给 WatchEvents 的回调，
+// 服务端请求交给 HandleRequest 注册的处理函数
+func (c *StdioClient) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		dumpFrame("stdio", c.Command, "recv", line)
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			// 非 JSON 行（例如子进程打印的日志）直接忽略
+			continue
+		}
+		if isServerRequest(&msg) {
+			c.requests.serve(msg, c.writeLine)
+			continue
+		}
+		if msg.Method != "" {
+			c.requests.notify(msg.Method, msg.Params)
+		}
+
+		c.mu.Lock()
+		if msg.Method != "" {
+			handlers := append([]func(string, json.RawMessage){}, c.handlers...)
+			c.mu.Unlock()
+			for _, h := range handlers {
+				h(msg.Method, msg.Params)
+			}
+			continue
+		}
+		key := idKey(msg.ID)
+		ch, ok := c.pending[key]
+		delete(c.pending, key)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg.rpcResponse
+		}
+	}
+
+	c.mu.Lock()
+	c.err = scanner.Err()
+	if c.err == nil {
+		c.err = fmt.Errorf("stdio server exited")
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *StdioClient) Call(ctx context.Context, method string, args interface{}, result interface{}) error {
+	reqID, key := nextRequestID(c.IDs, &c.counter)
+	wireMethod := method
+	if c.SpecMethods {
+		wireMethod = SpecMethod(method)
+	}
+	data, err := json.Marshal(rpcRequest{
+		JsonRPC: version.JSONRPC,
+		ID:      reqID,
+		Method:  wireMethod,
+		Params:  args,
+	})
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan rpcResponse, 1)
+	c.mu.Lock()
+	c.pending[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.writeLine(data); err != nil {
+		return err
+	}
+
+	var rpcResp rpcResponse
+	select {
+	case rpcResp = <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return c.err
+	}
+
+	if rpcResp.Meta != nil && c.MetaHook != nil {
+		rpcResp.Meta.RequestID = key
+		c.MetaHook(method, rpcResp.Meta)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("MCP Error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	if result != nil {
+		return json.Unmarshal(rpcResp.Result, result)
+	}
+	return nil
+}
+
+// Notify 向服务端发送通知（如 notifications/roots/list_changed），不等待响应
+func (c *StdioClient) Notify(method string, params interface{}) error {
+	data, err := json.Marshal(rpcNotification{JsonRPC: version.JSONRPC, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return c.writeLine(data)
+}
+
+// writeLine 向子进程写一行消息
+func (c *StdioClient) writeLine(data []byte) error {
+	dumpFrame("stdio", c.Command, "send", data)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (c *StdioClient) CallTool(ctx context.Context, toolName string, args interface{}, result interface{}) error {
+	var raw json.RawMessage
+	if err := c.Call(ctx, "tools.run", toolCallParams(ctx, toolName, args), &raw); err != nil {
+		return err
+	}
+	return decodeToolResult(raw, result)
+}
+
+func (c *StdioClient) ServerInfo(ctx context.Context) (*ServerInfoResp, error) {
+	var out ServerInfoResp
+	err := c.Call(ctx, "server.info", map[string]any{}, &out)
+	return &out, err
+}
+
+// WatchEvents 接收子进程推送的通知，阻塞直到子进程退出或客户端关闭
+func (c *StdioClient) WatchEvents(handler func(event string, data json.RawMessage)) error {
+	c.mu.Lock()
+	c.handlers = append(c.handlers, handler)
+	c.mu.Unlock()
+	<-c.done
+	return c.err
+}
+
+// Close 关闭 stdin 通知子进程退出，超时后强制结束
+func (c *StdioClient) Close() {
+	c.stdin.Close()
+	exited := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		c.cmd.Process.Kill()
+		<-exited
+	}
+}
@@ -0,0 +1,256 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------
+// 访问凭证
+// ----------------------
+
+// Token 访问令牌
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"` // 为空时按 Bearer
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // 零值表示不过期
+}
+
+// expiresWithin 令牌在 d 之内过期（或已过期）
+func (t *Token) expiresWithin(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Until(t.Expiry) < d
+}
+
+// authorization Authorization 请求头的值
+func (t *Token) authorization() string {
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// TokenSource 提供访问令牌，客户端每次请求（WS 每次建连）前调用
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenFunc 回调形式的 TokenSource，如从宿主应用获取令牌
+type TokenFunc func(ctx context.Context) (*Token, error)
+
+func (f TokenFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// StaticTokenSource 固定令牌
+func StaticTokenSource(accessToken string) TokenSource {
+	t := &Token{AccessToken: accessToken}
+	return TokenFunc(func(context.Context) (*Token, error) { return t, nil })
+}
+
+// authHeader 从 TokenSource 取令牌并设置 Authorization 头，ts 为 nil 时不做任何事
+func authHeader(ctx context.Context, ts TokenSource, header http.Header) error {
+	if ts == nil {
+		return nil
+	}
+	t, err := ts.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("mcpclient: get token: %w", err)
+	}
+	header.Set("Authorization", t.authorization())
+	return nil
+}
+
+// ----------------------
+// 自动刷新
+// ----------------------
+
+// RefreshingTokenSource 缓存令牌，在过期前 Skew 时间内提前刷新，避免长时间运行的 agent 中途失败。
+// 可选 Store 用于持久化令牌（如系统钥匙串），启动时先从 Store 读取
+type RefreshingTokenSource struct {
+	// Refresh 获取新令牌，参数为当前令牌（可能为 nil），OAuth 场景下用其中的 RefreshToken 换取新令牌
+	Refresh func(ctx context.Context, current *Token) (*Token, error)
+	// Skew 提前刷新的时间，默认 1 分钟
+	Skew  time.Duration
+	Store TokenStore
+
+	mu     sync.Mutex
+	token  *Token
+	loaded bool
+}
+
+func (s *RefreshingTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loaded && s.Store != nil {
+		s.loaded = true
+		if t, err := s.Store.Load(); err == nil && t != nil {
+			s.token = t
+		}
+	}
+	skew := s.Skew
+	if skew <= 0 {
+		skew = time.Minute
+	}
+	if s.token != nil && s.token.AccessToken != "" && !s.token.expiresWithin(skew) {
+		return s.token, nil
+	}
+	t, err := s.Refresh(ctx, s.token)
+	if err != nil {
+		return nil, err
+	}
+	// 刷新响应可能不带新的 refresh token，沿用旧的
+	if t.RefreshToken == "" && s.token != nil {
+		t.RefreshToken = s.token.RefreshToken
+	}
+	s.token = t
+	if s.Store != nil {
+		if err := s.Store.Save(t); err != nil {
+			return nil, fmt.Errorf("mcpclient: save token: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// Invalidate 丢弃缓存的访问令牌（保留 refresh token），下次调用时强制刷新。
+// 服务端返回 401 时 HTTPClient 会调用它并重试一次
+func (s *RefreshingTokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != nil {
+		t := *s.token
+		t.AccessToken = ""
+		s.token = &t
+	}
+}
+
+// invalidator 支持强制刷新的 TokenSource
+type invalidator interface {
+	Invalidate()
+}
+
+// OAuthRefresh 返回用 OAuth2 refresh_token 授权换取新令牌的刷新函数，
+// initial 为首次使用的 refresh token（Store 中已有令牌时以 Store 为准）
+func OAuthRefresh(tokenURL, clientID, clientSecret, initial string, scopes ...string) func(ctx context.Context, current *Token) (*Token, error) {
+	return func(ctx context.Context, current *Token) (*Token, error) {
+		refresh := initial
+		if current != nil && current.RefreshToken != "" {
+			refresh = current.RefreshToken
+		}
+		if refresh == "" {
+			return nil, errors.New("mcpclient: no refresh token")
+		}
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refresh},
+			"client_id":     {clientID},
+		}
+		if clientSecret != "" {
+			form.Set("client_secret", clientSecret)
+		}
+		if len(scopes) > 0 {
+			form.Set("scope", strings.Join(scopes, " "))
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		var body struct {
+			AccessToken  string `json:"access_token"`
+			TokenType    string `json:"token_type"`
+			RefreshToken string `json:"refresh_token"`
+			ExpiresIn    int64  `json:"expires_in"`
+			Error        string `json:"error"`
+			Description  string `json:"error_description"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("mcpclient: token endpoint returned %s: %w", resp.Status, err)
+		}
+		if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+			return nil, fmt.Errorf("mcpclient: token refresh failed (%s): %s %s", resp.Status, body.Error, body.Description)
+		}
+		t := &Token{AccessToken: body.AccessToken, TokenType: body.TokenType, RefreshToken: body.RefreshToken}
+		if body.ExpiresIn > 0 {
+			t.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+		}
+		return t, nil
+	}
+}
+
+// ----------------------
+// 令牌存储
+// ----------------------
+
+// TokenStore 令牌持久化
+type TokenStore interface {
+	Load() (*Token, error)
+	Save(t *Token) error
+}
+
+// KeychainStore 把令牌保存在系统钥匙串：macOS 使用 security 命令，
+// Linux 使用 libsecret 的 secret-tool，其他系统返回错误
+type KeychainStore struct {
+	Service string
+	Account string
+}
+
+func (k KeychainStore) Load() (*Token, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", k.Service, "-a", k.Account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", k.Service, "account", k.Account)
+	default:
+		return nil, fmt.Errorf("keychain is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("keychain: load %s/%s: %w", k.Service, k.Account, err)
+	}
+	var t Token
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(out))), &t); err != nil {
+		return nil, fmt.Errorf("keychain: decode token: %w", err)
+	}
+	return &t, nil
+}
+
+func (k KeychainStore) Save(t *Token) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// -U 覆盖已有条目
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", k.Service, "-a", k.Account, "-w", string(data))
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", k.Service+" ("+k.Account+")", "service", k.Service, "account", k.Account)
+		cmd.Stdin = strings.NewReader(string(data))
+	default:
+		return fmt.Errorf("keychain is not supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keychain: save %s/%s: %v: %s", k.Service, k.Account, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
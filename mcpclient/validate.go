@@ -0,0 +1,97 @@
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"mcptool/jsonschema"
+)
+
+// ----------------------
+// 工具结果 schema 校验
+// ----------------------
+
+// ValidationMode 工具结果与声明的输出 schema 不符时的处理方式
+type ValidationMode int
+
+const (
+	ValidateOff   ValidationMode = iota // 不校验
+	ValidateWarn                        // 打印警告，照常返回结果
+	ValidateError                       // 返回错误，不把结果交给调用方
+)
+
+// resultValidator 按工具名保存输出 schema
+type resultValidator struct {
+	mu      sync.RWMutex
+	mode    ValidationMode
+	schemas map[string]jsonschema.Schema
+}
+
+func (v *resultValidator) setSchema(tool string, schema jsonschema.Schema) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.schemas == nil {
+		v.schemas = make(map[string]jsonschema.Schema)
+	}
+	v.schemas[tool] = schema
+}
+
+// check 校验结果；警告模式下只打印日志
+func (v *resultValidator) check(tool string, result json.RawMessage) error {
+	v.mu.RLock()
+	mode, schema := v.mode, v.schemas[tool]
+	v.mu.RUnlock()
+	if mode == ValidateOff || schema == nil {
+		return nil
+	}
+	err := jsonschema.ValidateJSON(schema, result)
+	if err == nil {
+		return nil
+	}
+	if mode == ValidateWarn {
+		log.Printf("mcpclient: result of tool %q does not match its output schema: %v", tool, err)
+		return nil
+	}
+	return fmt.Errorf("tool %q: %w", tool, err)
+}
+
+// SetResultValidation 设置工具结果校验模式
+func (c *UnifiedClient) SetResultValidation(mode ValidationMode) {
+	c.validator.mu.Lock()
+	c.validator.mode = mode
+	c.validator.mu.Unlock()
+}
+
+// SetResultSchema 为工具指定期望的输出 schema（JSON）
+func (c *UnifiedClient) SetResultSchema(tool string, schema json.RawMessage) error {
+	s, err := jsonschema.Parse(schema)
+	if err != nil {
+		return err
+	}
+	c.validator.setSchema(tool, s)
+	return nil
+}
+
+// LoadResultSchemas 从 tools.list 中读取服务端声明的 outputSchema，返回加载的数量
+func (c *UnifiedClient) LoadResultSchemas(ctx context.Context) (int, error) {
+	var out struct {
+		Tools []struct {
+			Name         string            `json:"name"`
+			OutputSchema jsonschema.Schema `json:"outputSchema"`
+		} `json:"tools"`
+	}
+	if err := c.Call(ctx, "tools.list", map[string]any{}, &out); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, t := range out.Tools {
+		if t.OutputSchema != nil {
+			c.validator.setSchema(t.Name, t.OutputSchema)
+			n++
+		}
+	}
+	return n, nil
+}
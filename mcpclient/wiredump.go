@@ -0,0 +1,36 @@
+package mcpclient
+
+import (
+	"io"
+
+	"mcptool/internal/wiredump"
+)
+
+// ----------------------
+// 报文转储
+// ----------------------
+
+// 与 mcpserver.SetWireDump 对应：把客户端收发的原始报文（HTTP 请求 / 响应体、WS 帧、SSE 行、stdio 行）
+// 脱敏后逐条写到一个 writer，格式和脱敏规则与服务端相同（见 internal/wiredump），便于对照两端
+
+// wire 客户端的转储目标
+var wire wiredump.Dumper
+
+// SetWireDump 开始把客户端收发的报文转储到 w，w 为 nil 时关闭；redactKeys 追加需要脱敏的字段名
+func SetWireDump(w io.Writer, redactKeys ...string) {
+	wire.Set(w, redactKeys...)
+}
+
+func wireDumpEnabled() bool {
+	return wire.Enabled()
+}
+
+// dumpFrame 转储一条报文，dir 为 recv / send；未开启时什么都不做
+func dumpFrame(transport, conn, dir string, data []byte) {
+	wire.Frame(transport, conn, dir, data)
+}
+
+// dumpJSON 转储一个将要编码发送的值
+func dumpJSON(transport, conn string, v interface{}) {
+	wire.JSON(transport, conn, v)
+}
@@ -0,0 +1,411 @@
+//go:build !nowebsocket
+
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"mcptool/version"
+)
+
+// ----------------------
+// WSClient
+// 依赖 gorilla/websocket，用 -tags nowebsocket 编译时替换为 ws_stub.go 中的占位实现
+// ----------------------
+type WSClient struct {
+	URL      string
+	MetaHook MetaHook
+	// OnNotification 收到服务端推送的通知时回调，在读循环中执行，不要在其中阻塞或同步调用 Call
+	OnNotification func(method string, params json.RawMessage)
+	// SpecMethods 为 true 时发送 MCP 规范方法名
+	SpecMethods bool
+	// TokenSource 非空时建连（含 Reconnect）时带上 Authorization 头
+	TokenSource TokenSource
+	// AutoReconnect 非空时连接意外断开后自动重连，见 ReconnectPolicy；创建后立即设置
+	AutoReconnect *ReconnectPolicy
+	// Dialer 为 nil 时使用 websocket.DefaultDialer；连接 mTLS 服务端时在 TLSClientConfig 中配置客户端证书
+	Dialer *websocket.Dialer
+	// IDs 请求 id 的生成方式，nil 时从 1 开始计数，见 IDGenerator
+	IDs      IDGenerator
+	counter  uint64
+	rows     rowSinks
+	requests requestHandlers // 服务端发来的请求，见 HandleRequest
+
+	// 以下随连接替换（Reconnect），由 mu 保护
+	mu          sync.Mutex
+	conn        *websocket.Conn
+	writeMu     *sync.Mutex                 // 串行化对当前连接的写入
+	pending     map[string]chan rpcResponse // 等待响应的请求，按 id
+	done        chan struct{}               // 当前连接的读循环退出后关闭
+	err         error                       // 读循环退出的原因
+	resumeToken string
+	sessionID   string
+	subs        []subscription
+	redialing   bool          // 正在重连（自动或 Reconnect），期间断开不再触发自动重连
+	closed      bool          // 已调用 Close
+	closing     chan struct{} // Close 时关闭，结束自动重连
+}
+
+var errClientClosed = errors.New("mcpclient: client closed")
+
+func NewWSClient(url string) (*WSClient, error) {
+	return NewWSClientWithTokenSource(url, nil)
+}
+
+// NewWSClientWithTokenSource 创建需要认证的 WS 客户端
+func NewWSClientWithTokenSource(url string, ts TokenSource) (*WSClient, error) {
+	return NewWSClientWithDialer(url, nil, ts)
+}
+
+// NewWSClientWithDialer 使用自定义 Dialer 创建 WS 客户端，如连接 mTLS 服务端时提供客户端证书；ts 可以为 nil
+func NewWSClientWithDialer(url string, dialer *websocket.Dialer, ts TokenSource) (*WSClient, error) {
+	c := &WSClient{URL: url, TokenSource: ts, Dialer: dialer, closing: make(chan struct{})}
+	if err := c.dial(http.Header{}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *WSClient) dial(header http.Header) error {
+	if err := authHeader(context.Background(), c.TokenSource, header); err != nil {
+		return err
+	}
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, resp, err := dialer.Dial(c.URL, header)
+	if err != nil {
+		return err
+	}
+	pending := make(map[string]chan rpcResponse)
+	done := make(chan struct{})
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return errClientClosed
+	}
+	writeMu := &sync.Mutex{}
+	c.conn, c.writeMu, c.pending, c.done, c.err = conn, writeMu, pending, done, nil
+	c.resumeToken = resp.Header.Get("Mcp-Resume-Token")
+	c.sessionID = resp.Header.Get("Mcp-Session-Id")
+	c.mu.Unlock()
+	go c.readLoop(conn, writeMu, pending, done)
+	return nil
+}
+
+// readLoop 读取一条连接上的消息：响应按 id 交给等待中的调用，通知交给 OnNotification / RowIterator，
+// 服务端请求交给 HandleRequest 注册的处理函数。
+// 连接断开后等待中的调用返回 ErrConnectionLost，开启了 AutoReconnect 时开始重连
+func (c *WSClient) readLoop(conn *websocket.Conn, writeMu *sync.Mutex, pending map[string]chan rpcResponse, done chan struct{}) {
+	var err error
+	for {
+		var msg rpcMessage
+		var data []byte
+		if _, data, err = conn.ReadMessage(); err != nil {
+			break
+		}
+		dumpFrame("ws", c.URL, "recv", data)
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		if isServerRequest(&msg) {
+			c.requests.serve(msg, func(data []byte) error {
+				dumpFrame("ws", c.URL, "send", data)
+				writeMu.Lock()
+				defer writeMu.Unlock()
+				return conn.WriteMessage(websocket.TextMessage, data)
+			})
+			continue
+		}
+		if msg.Method != "" {
+			c.requests.notify(msg.Method, msg.Params)
+			if msg.Method == "notifications/rows" && c.deliverRows(msg.Params) {
+				continue
+			}
+			if c.OnNotification != nil {
+				c.OnNotification(msg.Method, msg.Params)
+			}
+			continue
+		}
+		key := idKey(msg.ID)
+		c.mu.Lock()
+		ch, ok := pending[key]
+		delete(pending, key)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg.rpcResponse
+		}
+	}
+
+	c.mu.Lock()
+	current := c.done == done
+	if current {
+		c.err = err
+	}
+	policy := c.AutoReconnect
+	reconnect := current && policy != nil && !c.redialing && !c.closed
+	if reconnect {
+		c.redialing = true
+	}
+	c.mu.Unlock()
+	close(done)
+	if reconnect {
+		go c.reconnectLoop(policy, done)
+	}
+}
+
+// reconnectLoop 按 AutoReconnect 退避重连，lost 为断开的连接；其间被 Reconnect 抢先重连或 Close 时停止
+func (c *WSClient) reconnectLoop(policy *ReconnectPolicy, lost chan struct{}) {
+	var err error
+	for attempt := 0; policy.MaxAttempts <= 0 || attempt < policy.MaxAttempts; attempt++ {
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-c.closing:
+			timer.Stop()
+			c.setRedialing(false)
+			return
+		case <-timer.C:
+		}
+		c.mu.Lock()
+		replaced := c.done != lost
+		c.mu.Unlock()
+		if replaced {
+			c.setRedialing(false)
+			return
+		}
+		if err = c.redial(); err == nil {
+			c.setRedialing(false)
+			err = c.resubscribe()
+			if policy.OnReconnect != nil {
+				policy.OnReconnect(err)
+			}
+			return
+		}
+		if err == errClientClosed {
+			c.setRedialing(false)
+			return
+		}
+	}
+	c.setRedialing(false)
+	if policy.OnReconnect != nil {
+		policy.OnReconnect(err)
+	}
+}
+
+func (c *WSClient) setRedialing(v bool) {
+	c.mu.Lock()
+	c.redialing = v
+	c.mu.Unlock()
+}
+
+// redial 携带 resume token 重新建连
+func (c *WSClient) redial() error {
+	c.mu.Lock()
+	token := c.resumeToken
+	c.mu.Unlock()
+	header := http.Header{}
+	if token != "" {
+		header.Set("Mcp-Resume-Token", token)
+	}
+	return c.dial(header)
+}
+
+// resubscribe 按注册顺序重放订阅，遇到错误停止
+func (c *WSClient) resubscribe() error {
+	c.mu.Lock()
+	subs := append([]subscription(nil), c.subs...)
+	c.mu.Unlock()
+	for _, sub := range subs {
+		ctx, cancel := context.WithTimeout(context.Background(), resubscribeTimeout)
+		err := c.Call(ctx, sub.method, sub.params, nil)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("mcpclient: resubscribe %s: %w", sub.method, err)
+		}
+	}
+	return nil
+}
+
+// Subscribe 发送订阅请求（如 "resources/subscribe"），成功后记下，自动重连后按注册顺序重放
+func (c *WSClient) Subscribe(ctx context.Context, method string, params interface{}) error {
+	if err := c.Call(ctx, method, params, nil); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.subs = append(c.subs, subscription{method: method, params: params})
+	c.mu.Unlock()
+	return nil
+}
+
+// SessionID 服务端分配的会话 ID
+func (c *WSClient) SessionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID
+}
+
+// Reconnect 断线后重新连接，并携带 resume token 尝试恢复原会话；
+// 服务端开启了恢复窗口时，断线期间的通知会在重连后补发。旧连接上未完成的调用返回 ErrConnectionLost。
+// 不重放订阅
+func (c *WSClient) Reconnect() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.redialing = true
+	c.mu.Unlock()
+	defer c.setRedialing(false)
+	if conn != nil {
+		conn.Close()
+	}
+	return c.redial()
+}
+
+// Call 发送请求并等待对应 id 的响应，可以在多个 goroutine 中并发调用
+func (c *WSClient) Call(ctx context.Context, method string, args interface{}, result interface{}) error {
+	reqID, key := nextRequestID(c.IDs, &c.counter)
+	wireMethod := method
+	if c.SpecMethods {
+		wireMethod = SpecMethod(method)
+	}
+	req := rpcRequest{
+		JsonRPC: version.JSONRPC,
+		ID:      reqID,
+		Method:  wireMethod,
+		Params:  args,
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan rpcResponse, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errClientClosed
+	}
+	conn, writeMu, pending, done := c.conn, c.writeMu, c.pending, c.done
+	pending[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(pending, key)
+		c.mu.Unlock()
+	}()
+
+	dumpFrame("ws", c.URL, "send", data)
+	writeMu.Lock()
+	err = conn.WriteMessage(websocket.TextMessage, data)
+	writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConnectionLost, err)
+	}
+
+	var rpcResp rpcResponse
+	select {
+	case rpcResp = <-ch:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return c.connLost(done)
+	}
+	if rpcResp.Meta != nil && c.MetaHook != nil {
+		rpcResp.Meta.RequestID = key
+		c.MetaHook(method, rpcResp.Meta)
+	}
+
+	if rpcResp.Error != nil {
+		return fmt.Errorf("MCP Error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	if result != nil {
+		return json.Unmarshal(rpcResp.Result, result)
+	}
+	return nil
+}
+
+// Notify 向服务端发送通知（如 notifications/roots/list_changed），不等待响应
+func (c *WSClient) Notify(method string, params interface{}) error {
+	data, err := json.Marshal(rpcNotification{JsonRPC: version.JSONRPC, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errClientClosed
+	}
+	conn, writeMu := c.conn, c.writeMu
+	c.mu.Unlock()
+	dumpFrame("ws", c.URL, "send", data)
+	writeMu.Lock()
+	err = conn.WriteMessage(websocket.TextMessage, data)
+	writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConnectionLost, err)
+	}
+	return nil
+}
+
+// dropConn 断开当前连接而不关闭客户端，用于放弃半开的连接；开启了 AutoReconnect 时随后重连
+func (c *WSClient) dropConn() {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// connLost done 对应的连接断开时未完成调用的错误
+func (c *WSClient) connLost(done chan struct{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClientClosed
+	}
+	if c.done == done && c.err != nil {
+		return fmt.Errorf("%w: %v", ErrConnectionLost, c.err)
+	}
+	return ErrConnectionLost
+}
+
+func (c *WSClient) CallTool(ctx context.Context, toolName string, args interface{}, result interface{}) error {
+	var raw json.RawMessage
+	if err := c.Call(ctx, "tools.run", toolCallParams(ctx, toolName, args), &raw); err != nil {
+		return err
+	}
+	return decodeToolResult(raw, result)
+}
+
+func (c *WSClient) ListenSSE(handler func(event string, data json.RawMessage)) error {
+	return fmt.Errorf("WebSocket client does not support SSE")
+}
+
+func (c *WSClient) Close() {
+	c.mu.Lock()
+	conn, writeMu := c.conn, c.writeMu
+	if !c.closed && c.closing != nil {
+		close(c.closing)
+	}
+	c.closed = true
+	c.mu.Unlock()
+	if conn != nil {
+		// 先发送 Close 帧，告诉服务器“我准备关闭了”。
+		// 服务器收到 Close 帧，可以返回 CloseNormalClosure，不会报 1006 错误。
+		// 然后再真正关闭 TCP 连接。
+		writeMu.Lock()
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+		writeMu.Unlock()
+		conn.Close()
+	}
+}
@@ -0,0 +1,69 @@
+//go:build nowebsocket
+
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ----------------------
+// WSClient 占位
+// 用 -tags nowebsocket 编译时没有 WebSocket 传输，创建客户端返回 ErrWebSocketUnavailable
+// ----------------------
+type WSClient struct {
+	URL            string
+	MetaHook       MetaHook
+	OnNotification func(method string, params json.RawMessage)
+	SpecMethods    bool
+	TokenSource    TokenSource
+	AutoReconnect  *ReconnectPolicy
+	IDs            IDGenerator
+	rows           rowSinks
+	requests       requestHandlers
+}
+
+func NewWSClient(url string) (*WSClient, error) {
+	return NewWSClientWithTokenSource(url, nil)
+}
+
+// NewWSClientWithTokenSource 创建需要认证的 WS 客户端
+func NewWSClientWithTokenSource(url string, ts TokenSource) (*WSClient, error) {
+	return nil, ErrWebSocketUnavailable
+}
+
+// SessionID 服务端分配的会话 ID
+func (c *WSClient) SessionID() string {
+	return ""
+}
+
+// Reconnect 见 ws.go
+func (c *WSClient) Reconnect() error {
+	return ErrWebSocketUnavailable
+}
+
+// Subscribe 见 ws.go
+func (c *WSClient) Subscribe(ctx context.Context, method string, params interface{}) error {
+	return ErrWebSocketUnavailable
+}
+
+func (c *WSClient) Call(ctx context.Context, method string, args interface{}, result interface{}) error {
+	return ErrWebSocketUnavailable
+}
+
+func (c *WSClient) dropConn() {}
+
+// Notify 见 ws.go
+func (c *WSClient) Notify(method string, params interface{}) error {
+	return ErrWebSocketUnavailable
+}
+
+func (c *WSClient) CallTool(ctx context.Context, toolName string, args interface{}, result interface{}) error {
+	return ErrWebSocketUnavailable
+}
+
+func (c *WSClient) ListenSSE(handler func(event string, data json.RawMessage)) error {
+	return ErrWebSocketUnavailable
+}
+
+func (c *WSClient) Close() {}
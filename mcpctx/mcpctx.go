@@ -0,0 +1,236 @@
+// Package mcpctx 定义工具处理函数从 context.Context 中获取请求级设施的标准方式，
+// 服务端在调用工具前把会话、调用方、日志、进度上报等放入 context，
+// 工具作者只需使用这里导出的 XxxFromContext 函数，不依赖任何未导出的 key。
+package mcpctx
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// Session 请求所属的会话（WS 连接、带 Mcp-Session-Id 的 HTTP 会话等）
+type Session interface {
+	ID() string
+}
+
+// ClientInfo initialize 时客户端声明的信息
+type ClientInfo struct {
+	Name            string          `json:"name"`
+	Version         string          `json:"version"`
+	ProtocolVersion string          `json:"protocolVersion"` // 协商后的协议版本
+	Capabilities    json.RawMessage `json:"capabilities,omitempty"`
+}
+
+// SessionState 会话级的状态，WS、HTTP+SSE、Streamable HTTP、stdio 会话都有，随会话结束丢弃
+type SessionState interface {
+	// Initialized 会话是否已完成 initialize；未完成时 Client 为零值
+	Initialized() bool
+	// Client initialize 时客户端声明的信息
+	Client() ClientInfo
+	// Principal initialize 时认证的主体，未开启认证时为空
+	Principal() string
+	// Subscriptions 会话通过 resources/subscribe 订阅的资源 URI，按字母排序
+	Subscriptions() []string
+	// Value / SetValue 工具在会话中保存的值，同一会话的后续调用可以读到
+	Value(key string) (interface{}, bool)
+	SetValue(key string, v interface{})
+}
+
+// Caller 调用方身份
+type Caller struct {
+	Transport  string `json:"transport"`           // "http" / "ws" / "sse" / "stdio"
+	RemoteAddr string `json:"remote_addr"`         // 对端地址
+	Tenant     string `json:"tenant,omitempty"`    // 租户
+	APIKey     string `json:"api_key,omitempty"`   // API Key 指纹，不是明文
+	Principal  string `json:"principal,omitempty"` // 认证后的主体
+}
+
+// Logger 工具可用的日志接口，*log.Logger 即满足
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// ProgressReporter 进度上报函数，total <= 0 表示总量未知
+type ProgressReporter func(progress, total float64, message string)
+
+// StepRecorder 记录工具内部的子步骤（如 fetch→parse→rank）：Step 在步骤开始时调用，
+// 返回的函数在步骤结束时调用，传入步骤的错误（成功传 nil）：
+//
+//	done := mcpctx.StepsFromContext(ctx).Step("fetch")
+//	body, err := fetch(ctx, url)
+//	done(err)
+type StepRecorder interface {
+	Step(name string) func(err error)
+}
+
+// ClientLogger 发给客户端的日志（MCP 的 notifications/message），与只写到服务端的 Logger 不同，
+// 客户端可以展示给用户。level 为 debug / info / notice / warning / error / critical / alert / emergency，
+// logger 为可选的来源名，data 为可以序列化为 JSON 的任意值。低于客户端用 logging/setLevel 设置的级别的日志被丢弃
+type ClientLogger interface {
+	Log(level, logger string, data interface{})
+}
+
+// Meta 请求 _meta 中的字段，值为原始 JSON。宿主可在其中附带会话 ID、用户 ID 等关联信息
+type Meta map[string]json.RawMessage
+
+// Get 把 key 对应的值解码到 v，不存在或解码失败时返回 false
+func (m Meta) Get(key string, v interface{}) bool {
+	raw, ok := m[key]
+	return ok && json.Unmarshal(raw, v) == nil
+}
+
+// Flags 针对当前调用方求值的功能开关
+type Flags interface {
+	Enabled(flag string) bool
+}
+
+type ctxKey int
+
+const (
+	sessionKey ctxKey = iota
+	callerKey
+	loggerKey
+	progressKey
+	flagsKey
+	metaKey
+	requestIDKey
+	stepsKey
+	clientLoggerKey
+)
+
+// WithSession 返回携带会话的 context
+func WithSession(ctx context.Context, s Session) context.Context {
+	return context.WithValue(ctx, sessionKey, s)
+}
+
+// SessionFromContext 获取当前会话
+func SessionFromContext(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(sessionKey).(Session)
+	return s, ok && s != nil
+}
+
+// SessionStateFromContext 获取当前会话的状态，请求不属于会话或会话没有状态时返回 false
+func SessionStateFromContext(ctx context.Context) (SessionState, bool) {
+	s, ok := SessionFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	holder, ok := s.(interface{ State() SessionState })
+	if !ok {
+		return nil, false
+	}
+	st := holder.State()
+	return st, st != nil
+}
+
+// WithCaller 返回携带调用方身份的 context
+func WithCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerKey, c)
+}
+
+// CallerFromContext 获取调用方身份
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	c, ok := ctx.Value(callerKey).(Caller)
+	return c, ok
+}
+
+// WithLogger 返回携带日志的 context
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// LoggerFromContext 获取请求级日志，没有设置时返回标准库默认 logger，永不为 nil
+func LoggerFromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey).(Logger); ok && l != nil {
+		return l
+	}
+	return log.Default()
+}
+
+// WithProgress 返回携带进度上报函数的 context
+func WithProgress(ctx context.Context, p ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey, p)
+}
+
+// ProgressFromContext 获取进度上报函数；请求没有要求进度时返回空操作函数，永不为 nil
+func ProgressFromContext(ctx context.Context) ProgressReporter {
+	if p, ok := ctx.Value(progressKey).(ProgressReporter); ok && p != nil {
+		return p
+	}
+	return func(float64, float64, string) {}
+}
+
+// WithFlags 返回携带功能开关的 context
+func WithFlags(ctx context.Context, f Flags) context.Context {
+	return context.WithValue(ctx, flagsKey, f)
+}
+
+// FlagsFromContext 获取功能开关；没有设置时所有开关均为关闭，永不为 nil
+func FlagsFromContext(ctx context.Context) Flags {
+	if f, ok := ctx.Value(flagsKey).(Flags); ok && f != nil {
+		return f
+	}
+	return noFlags{}
+}
+
+type noFlags struct{}
+
+func (noFlags) Enabled(string) bool { return false }
+
+// WithMeta 返回携带请求 _meta 的 context
+func WithMeta(ctx context.Context, m Meta) context.Context {
+	return context.WithValue(ctx, metaKey, m)
+}
+
+// MetaFromContext 获取请求的 _meta；请求没有 _meta 时返回空 Meta，可直接读取
+func MetaFromContext(ctx context.Context) Meta {
+	m, _ := ctx.Value(metaKey).(Meta)
+	return m
+}
+
+// WithRequestID 返回携带 JSON-RPC 请求 id 的 context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext 获取当前请求的 id（字符串 id 去掉引号，数字 id 为其文本），
+// 可用于日志和审计与客户端关联；不在请求中时返回空串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithSteps 返回携带子步骤记录器的 context
+func WithSteps(ctx context.Context, r StepRecorder) context.Context {
+	return context.WithValue(ctx, stepsKey, r)
+}
+
+// StepsFromContext 获取子步骤记录器；没有设置时返回空操作的记录器，永不为 nil
+func StepsFromContext(ctx context.Context) StepRecorder {
+	if r, ok := ctx.Value(stepsKey).(StepRecorder); ok && r != nil {
+		return r
+	}
+	return noSteps{}
+}
+
+type noSteps struct{}
+
+func (noSteps) Step(string) func(error) { return func(error) {} }
+
+// WithClientLogger 返回携带客户端日志的 context
+func WithClientLogger(ctx context.Context, l ClientLogger) context.Context {
+	return context.WithValue(ctx, clientLoggerKey, l)
+}
+
+// ClientLoggerFromContext 获取客户端日志；请求无法向客户端发送通知时返回丢弃日志的实现，永不为 nil
+func ClientLoggerFromContext(ctx context.Context) ClientLogger {
+	if l, ok := ctx.Value(clientLoggerKey).(ClientLogger); ok && l != nil {
+		return l
+	}
+	return noClientLogger{}
+}
+
+type noClientLogger struct{}
+
+func (noClientLogger) Log(string, string, interface{}) {}
@@ -0,0 +1,312 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 访问控制 ----------------------
+// Methods 是全局开关，对所有调用方生效。这里按调用方（认证主体、租户、传输）或按连接
+// 允许 / 拒绝具体的方法和工具，支持默认拒绝。一次请求依次经过：
+//   - Policy / McpConf.Scopes（按 JWT 声明）；
+//   - McpConf.ACL 静态规则；
+//   - SetSessionACL 设置的连接级规则；
+//   - WithAuthorizer 接入的自定义授权。
+// 任一环节拒绝即返回 -32003 Forbidden。initialize / ping 不经过授权；
+// tools.list 只列出调用方可以调用的工具（见 visibleTools），prompts.list / resources.list
+// 只列出调用方可以读取的 prompt / 资源（见 visibleNames）
+
+// AccessRequest 一次授权判断的输入
+type AccessRequest struct {
+	Caller mcpctx.Caller
+	// Session 连接级会话 ID（WS、HTTP+SSE），普通 HTTP 请求为空
+	Session string
+	// Claims JWT 声明，其他方式认证时为 nil
+	Claims Claims
+	// Method 内部方法名，如 "tools.run"；Tool 为 tools.run 调用的工具名，
+	// prompts.get / resources.get 时为 prompt 名 / 资源名，其他方法为空
+	Method string
+	Tool   string
+}
+
+// Authorizer 自定义授权，返回 false 表示拒绝
+type Authorizer interface {
+	Authorize(ctx context.Context, req AccessRequest) bool
+}
+
+// AuthorizerFunc 函数形式的 Authorizer
+type AuthorizerFunc func(ctx context.Context, req AccessRequest) bool
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, req AccessRequest) bool {
+	return f(ctx, req)
+}
+
+// WithAuthorizer 追加自定义授权，可多次使用，全部允许才放行
+func WithAuthorizer(a Authorizer) Option {
+	return func(s *McpServer) {
+		s.authorizers = append(s.authorizers, a)
+	}
+}
+
+// ACLConfig 静态访问控制：
+//
+//	acl:
+//	  default: deny              # 没有规则允许时拒绝；默认 allow
+//	  rules:
+//	    - principals: [ci-bot]
+//	      allow: [tools.list, "tools.run:weather"]
+//	    - transports: [ws]
+//	      deny: ["tools.run:shell"]
+//	    - allow: [tools.list, "resources.*", "prompts.*", server.info]
+//	    - principals: [public]
+//	      deny: ["prompts.get:system_*", "resources.get:internal/*"]
+//
+// 对一次请求，所有匹配调用方的规则中任一 deny 命中即拒绝，否则任一 allow 命中即允许，
+// 都没有命中时按 default。方法模式与 ScopePolicy 相同
+type ACLConfig struct {
+	Default string    `yaml:"default"`
+	Rules   []ACLRule `yaml:"rules"`
+}
+
+// ACL 默认策略
+const (
+	ACLAllow = "allow"
+	ACLDeny  = "deny"
+)
+
+// ACLRule 一条规则；Principals / Tenants / Transports 为空表示不限，
+// Principals 中的 "*" 匹配任意已认证的主体。Tenants 只匹配已认证调用方的租户（见 callerFromRequest），
+// 未认证的请求即使带了 X-Tenant-ID 也不会命中限定了租户的规则
+type ACLRule struct {
+	Principals []string `yaml:"principals" json:"principals,omitempty"`
+	Tenants    []string `yaml:"tenants" json:"tenants,omitempty"`
+	Transports []string `yaml:"transports" json:"transports,omitempty"`
+	Allow      []string `yaml:"allow" json:"allow,omitempty"`
+	Deny       []string `yaml:"deny" json:"deny,omitempty"`
+}
+
+// appliesTo 规则是否适用于调用方
+func (r *ACLRule) appliesTo(caller mcpctx.Caller) bool {
+	if len(r.Principals) > 0 && !containsString(r.Principals, caller.Principal) &&
+		!(caller.Principal != "" && containsString(r.Principals, "*")) {
+		return false
+	}
+	if len(r.Tenants) > 0 && (caller.Principal == "" || !containsString(r.Tenants, caller.Tenant)) {
+		return false
+	}
+	return len(r.Transports) == 0 || containsString(r.Transports, caller.Transport)
+}
+
+// decide 规则对请求的判断：deny 命中返回 (false, true)，allow 命中返回 (true, true)，都没有命中时 decided 为 false
+func (r *ACLRule) decide(method, tool string) (allowed, decided bool) {
+	for _, p := range r.Deny {
+		if matchMethodPattern(p, method, tool) {
+			return false, true
+		}
+	}
+	for _, p := range r.Allow {
+		if matchMethodPattern(p, method, tool) {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// Authorize 实现 Authorizer
+func (c *ACLConfig) Authorize(ctx context.Context, req AccessRequest) bool {
+	allowed := false
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if !rule.appliesTo(req.Caller) {
+			continue
+		}
+		ok, decided := rule.decide(req.Method, req.Tool)
+		if decided && !ok {
+			return false
+		}
+		allowed = allowed || ok
+	}
+	return allowed || c.Default != ACLDeny
+}
+
+// ---------------------- 连接级规则 ----------------------
+
+// SetSessionACL 为一个连接（WS 会话或 HTTP+SSE 会话，ID 即 mcpctx.Session.ID()）设置附加规则，
+// 如临时禁止某个连接调用写操作工具；rule 的选择条件被忽略，Allow 非空时只允许其中的方法。
+// rule 为 nil 时清除。会话结束后规则随之清除
+func (s *McpServer) SetSessionACL(sessionID string, rule *ACLRule) {
+	s.aclMu.Lock()
+	defer s.aclMu.Unlock()
+	if rule == nil {
+		delete(s.sessionACLs, sessionID)
+		return
+	}
+	if s.sessionACLs == nil {
+		s.sessionACLs = make(map[string]*ACLRule)
+	}
+	copied := *rule
+	s.sessionACLs[sessionID] = &copied
+}
+
+// forgetSession 会话结束，清除连接级规则和调用预算
+func (s *McpServer) forgetSession(sessionID string) {
+	s.SetSessionACL(sessionID, nil)
+	if s.budget != nil {
+		s.budget.forget(sessionID)
+	}
+}
+
+func (s *McpServer) sessionAllows(req AccessRequest) bool {
+	if req.Session == "" {
+		return true
+	}
+	s.aclMu.Lock()
+	rule := s.sessionACLs[req.Session]
+	s.aclMu.Unlock()
+	if rule == nil {
+		return true
+	}
+	ok, decided := rule.decide(req.Method, req.Tool)
+	if decided {
+		return ok
+	}
+	return len(rule.Allow) == 0
+}
+
+// ---------------------- 授权 ----------------------
+
+// accessRequest 由请求上下文构造授权输入，Method / Tool 由调用方填写
+func accessRequest(ctx context.Context, caller mcpctx.Caller, session mcpctx.Session) AccessRequest {
+	req := AccessRequest{Caller: caller}
+	req.Claims, _ = ClaimsFromContext(ctx)
+	if session != nil {
+		req.Session = session.ID()
+	}
+	return req
+}
+
+// allowed 依次经过 Policy、连接级规则和各个 Authorizer
+func (s *McpServer) allowed(ctx context.Context, req AccessRequest) bool {
+	if s.policy != nil && !s.policy(req.Claims, req.Method, req.Tool) {
+		return false
+	}
+	if !s.sessionAllows(req) {
+		return false
+	}
+	for _, a := range s.authorizers {
+		if !a.Authorize(ctx, req) {
+			return false
+		}
+	}
+	return true
+}
+
+// authorize 检查方法调用，拒绝时返回 Forbidden
+func (s *McpServer) authorize(ctx context.Context, req AccessRequest, method string, params json.RawMessage) *RPCError {
+	if method == "" || method == "initialize" || method == "ping" {
+		return nil
+	}
+	req.Method = method
+	req.Tool = requestTarget(method, params)
+	if s.allowed(ctx, req) {
+		return nil
+	}
+	logf(LevelInfo, "forbidden: principal=%q session=%q id=%s %s %s",
+		req.Caller.Principal, req.Session, mcpctx.RequestIDFromContext(ctx), req.Method, req.Tool)
+	return &RPCError{Code: -32003, Message: "Forbidden"}
+}
+
+// requestTool tools.run 调用的工具名，其他方法返回空
+func requestTool(method string, params json.RawMessage) string {
+	if method != "tools.run" {
+		return ""
+	}
+	return requestTarget(method, params)
+}
+
+// requestTarget 授权判断的对象：tools.run 的工具名，prompts.get / resources.get 的 prompt 名 / 资源名
+// （resources/read 使用 uri，按 uri 找到资源或模板后取其名称），其他方法返回空
+func requestTarget(method string, params json.RawMessage) string {
+	switch method {
+	case "tools.run", "prompts.get", "resources.get":
+	default:
+		return ""
+	}
+	var p struct {
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	}
+	json.Unmarshal(params, &p)
+	if p.Name == "" {
+		p.Name = p.URI
+	}
+	// 资源按名称授权，用 URI 读取时换成资源名（模板生成的资源为模板名）
+	if method == "resources.get" {
+		return resourceName(p.Name)
+	}
+	return p.Name
+}
+
+// visiblePrompts prompts.list 的结果：只列出调用方可以读取的 prompt
+func (s *McpServer) visiblePrompts(ctx context.Context, req AccessRequest) []PromptSummary {
+	list := ListPrompts()
+	names := make([]string, len(list))
+	for i, p := range list {
+		names[i] = p.Name
+	}
+	visible := make(map[string]bool, len(names))
+	for _, name := range s.visibleNames(ctx, req, "prompts.get", names) {
+		visible[name] = true
+	}
+	kept := list[:0]
+	for _, p := range list {
+		if visible[p.Name] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// visibleTools tools.list 的结果：去掉被方法开关关闭的工具和调用方无权调用的工具。
+// 与 visibleNames 一样，不能调用任何工具的调用方得到空列表
+func (s *McpServer) visibleTools(ctx context.Context, req AccessRequest) []ToolSummary {
+	tools := s.tools.List()
+	enabled := tools[:0:0]
+	for _, t := range tools {
+		if s.methodEnabled("tools.run", t.Name) {
+			enabled = append(enabled, t)
+		}
+	}
+	tools = enabled
+	if s.policy == nil && len(s.authorizers) == 0 && req.Session == "" {
+		return tools
+	}
+	req.Method = "tools.run"
+	visible := make([]ToolSummary, 0, len(tools))
+	for _, t := range tools {
+		req.Tool = t.Name
+		if s.allowed(ctx, req) {
+			visible = append(visible, t)
+		}
+	}
+	return visible
+}
+
+// visibleNames prompts.list / resources.list 的结果：只保留调用方可以用 method（prompts.get / resources.get）
+// 读取的名称。与工具不同，没有可读项时返回空列表，敏感的 prompt 和资源不应出现在列表中
+func (s *McpServer) visibleNames(ctx context.Context, req AccessRequest, method string, names []string) []string {
+	if s.policy == nil && len(s.authorizers) == 0 && req.Session == "" {
+		return names
+	}
+	req.Method = method
+	visible := make([]string, 0, len(names))
+	for _, name := range names {
+		req.Tool = name
+		if s.allowed(ctx, req) {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
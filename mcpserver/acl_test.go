@@ -0,0 +1,120 @@
+package mcpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mcptool/mcpctx"
+)
+
+func TestACLPrecedence(t *testing.T) {
+	rules := []ACLRule{
+		{Principals: []string{"ci-bot"}, Allow: []string{"tools.list", "tools.run:weather"}},
+		{Principals: []string{"admin"}, Allow: []string{"*"}},
+		{Transports: []string{"ws"}, Deny: []string{"tools.run:shell"}},
+		{Principals: []string{"*"}, Allow: []string{"prompts.*"}},
+		{Tenants: []string{"acme"}, Allow: []string{"resources.*"}},
+	}
+	tests := []struct {
+		name       string
+		def        string
+		caller     mcpctx.Caller
+		method     string
+		tool       string
+		wantResult bool
+	}{
+		{"allow rule", ACLDeny, mcpctx.Caller{Principal: "ci-bot"}, "tools.run", "weather", true},
+		{"default deny", ACLDeny, mcpctx.Caller{Principal: "ci-bot"}, "tools.run", "shell", false},
+		{"default allow", ACLAllow, mcpctx.Caller{Principal: "ci-bot"}, "tools.run", "shell", true},
+		{"deny wins over allow", ACLDeny, mcpctx.Caller{Principal: "admin", Transport: "ws"}, "tools.run", "shell", false},
+		{"deny only on its transport", ACLDeny, mcpctx.Caller{Principal: "admin", Transport: "http"}, "tools.run", "shell", true},
+		{"wildcard principal", ACLDeny, mcpctx.Caller{Principal: "someone"}, "prompts.get", "greet", true},
+		{"wildcard needs a principal", ACLDeny, mcpctx.Caller{}, "prompts.get", "greet", false},
+		{"tenant rule", ACLDeny, mcpctx.Caller{Principal: "someone", Tenant: "acme"}, "resources.get", "report", true},
+		{"other tenant", ACLDeny, mcpctx.Caller{Principal: "someone", Tenant: "globex"}, "resources.get", "report", false},
+		{"unauthenticated tenant", ACLDeny, mcpctx.Caller{Tenant: "acme"}, "resources.get", "report", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl := &ACLConfig{Default: tt.def, Rules: rules}
+			req := AccessRequest{Caller: tt.caller, Method: tt.method, Tool: tt.tool}
+			if got := acl.Authorize(context.Background(), req); got != tt.wantResult {
+				t.Errorf("Authorize(%+v, %s:%s) = %v, want %v", tt.caller, tt.method, tt.tool, got, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestSessionACL(t *testing.T) {
+	s := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	s.SetSessionACL("s1", &ACLRule{Deny: []string{"tools.run:shell"}})
+	s.SetSessionACL("s2", &ACLRule{Allow: []string{"tools.list"}})
+
+	tests := []struct {
+		session, method, tool string
+		want                  bool
+	}{
+		{"s1", "tools.run", "shell", false},
+		{"s1", "tools.run", "weather", true},
+		{"s2", "tools.list", "", true},
+		{"s2", "tools.run", "weather", false},
+		{"s3", "tools.run", "shell", true},
+	}
+	for _, tt := range tests {
+		req := AccessRequest{Session: tt.session, Method: tt.method, Tool: tt.tool}
+		if got := s.sessionAllows(req); got != tt.want {
+			t.Errorf("sessionAllows(%s, %s:%s) = %v, want %v", tt.session, tt.method, tt.tool, got, tt.want)
+		}
+	}
+	s.SetSessionACL("s1", nil)
+	if !s.sessionAllows(AccessRequest{Session: "s1", Method: "tools.run", Tool: "shell"}) {
+		t.Error("rule still applied after SetSessionACL(nil)")
+	}
+}
+
+func TestCallerTenantFromIdentity(t *testing.T) {
+	s := NewMcpServerWithTools(McpConf{
+		APIKeys: map[string]string{"k1": "ci-bot", "k2": ""},
+	}, NewToolRegistry(), WithPrincipalTenants(map[string]string{"ci-bot": "acme"}))
+
+	var got mcpctx.Caller
+	h := s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		got = s.callerFromRequest(r, "http")
+	})
+	call := func(header http.Header) int {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		got = mcpctx.Caller{}
+		h(w, r)
+		return w.Code
+	}
+
+	// 认证后请求头中的租户和 API Key 被忽略
+	code := call(http.Header{"Authorization": {"Bearer k1"}, "X-Tenant-Id": {"globex"}})
+	if code != http.StatusOK || got.Principal != "ci-bot" || got.Tenant != "acme" || got.APIKey != apiKeyFingerprint("k1") {
+		t.Errorf("bearer key: code %d, caller %+v", code, got)
+	}
+	code = call(http.Header{"X-Api-Key": {"k2"}, "X-Tenant-Id": {"acme"}})
+	if code != http.StatusOK || got.Tenant != "" || got.Principal != "key:"+apiKeyFingerprint("k2") {
+		t.Errorf("unmapped key: code %d, caller %+v", code, got)
+	}
+	if code = call(http.Header{"X-Tenant-Id": {"acme"}}); code != http.StatusUnauthorized {
+		t.Errorf("no credentials: code %d, want 401", code)
+	}
+}
+
+func TestCallerTenantWithoutAuth(t *testing.T) {
+	s := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	r.Header.Set("X-Tenant-ID", "acme")
+	r.Header.Set("X-API-Key", "k1")
+	caller := s.callerFromRequest(r, "http")
+	if caller.Principal != "" || caller.Tenant != "acme" || caller.APIKey != apiKeyFingerprint("k1") {
+		t.Errorf("caller %+v", caller)
+	}
+}
@@ -0,0 +1,174 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mcptool/version"
+)
+
+// ---------------------- 认证 ----------------------
+// 配置了 API Key、JWT 或 TokenValidator 后，所有端点（HTTP、WS 升级、SSE、只读、Inspector）都要求认证。
+// 凭证依次从 Authorization: Bearer、X-API-Key 头读取；浏览器的 WebSocket / EventSource 无法设置请求头，
+// 因此 GET 请求也接受 access_token 查询参数。认证得到的主体通过 mcpctx.Caller.Principal 传给工具。
+// 开启认证后租户只取自认证结果：JWT 的租户声明（McpConf.JWTTenantClaim，默认 tenant），
+// 其次是 McpConf.PrincipalTenants 中主体对应的租户；请求头 X-Tenant-ID / X-API-Key 被忽略，
+// 调用方不能自行声明租户和 API Key
+
+// ErrUnauthorized 凭证无效，TokenValidator 可以返回它或包装它的错误
+var ErrUnauthorized = errors.New("unauthorized")
+
+// TokenValidator 校验凭证并返回主体（用户、服务账号等），凭证无效时返回错误
+type TokenValidator func(ctx context.Context, token string) (principal string, err error)
+
+// WithAPIKeys 设置静态 API Key，key -> 主体名，与 McpConf.APIKeys 合并
+func WithAPIKeys(keys map[string]string) Option {
+	return func(s *McpServer) {
+		if s.conf.APIKeys == nil {
+			s.conf.APIKeys = make(map[string]string, len(keys))
+		}
+		for k, v := range keys {
+			s.conf.APIKeys[k] = v
+		}
+	}
+}
+
+// WithTokenValidator 设置自定义的凭证校验（如校验 JWT、调用内部认证服务），
+// 同时配置了 API Key 时先匹配 API Key
+func WithTokenValidator(v TokenValidator) Option {
+	return func(s *McpServer) {
+		s.validator = v
+	}
+}
+
+// WithPrincipalTenants 设置主体所属的租户，主体 -> 租户，与 McpConf.PrincipalTenants 合并
+func WithPrincipalTenants(tenants map[string]string) Option {
+	return func(s *McpServer) {
+		merged := make(map[string]string, len(s.conf.PrincipalTenants)+len(tenants))
+		for k, v := range s.conf.PrincipalTenants {
+			merged[k] = v
+		}
+		for k, v := range tenants {
+			merged[k] = v
+		}
+		s.conf.PrincipalTenants = merged
+	}
+}
+
+// authEnabled 是否要求认证
+func (s *McpServer) authEnabled() bool {
+	return len(s.conf.APIKeys) > 0 || s.validator != nil || s.jwt != nil
+}
+
+// identity 认证得到的调用方身份
+type identity struct {
+	principal string
+	tenant    string // 见 tenantOf
+	apiKey    string // 使用 API Key 认证时 key 的指纹，其他方式为空
+	claims    Claims // JWT 认证时的声明
+}
+
+type identityKey struct{}
+
+// identityFromRequest 认证中间件放入请求 context 的身份
+func identityFromRequest(r *http.Request) (identity, bool) {
+	id, ok := r.Context().Value(identityKey{}).(identity)
+	return id, ok
+}
+
+// principalFromRequest 认证中间件放入请求 context 的主体
+func principalFromRequest(r *http.Request) (string, bool) {
+	id, ok := identityFromRequest(r)
+	return id.principal, ok
+}
+
+// tenantOf 已认证主体的租户：JWT 的租户声明优先，其次是 PrincipalTenants
+func (s *McpServer) tenantOf(principal string, claims Claims) string {
+	if claims != nil {
+		if tenant := claims.String(s.conf.JWTTenantClaim); tenant != "" {
+			return tenant
+		}
+	}
+	return s.conf.PrincipalTenants[principal]
+}
+
+// requestToken 从请求中取出凭证
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if r.Method == http.MethodGet {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+// authenticate 校验凭证，返回调用方身份
+func (s *McpServer) authenticate(r *http.Request) (identity, error) {
+	token := requestToken(r)
+	if token == "" {
+		return identity{}, ErrUnauthorized
+	}
+	var id identity
+	for key, principal := range s.conf.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			id.apiKey = apiKeyFingerprint(key)
+			if principal == "" {
+				principal = "key:" + id.apiKey
+			}
+			id.principal = principal
+		}
+	}
+	switch {
+	case id.principal != "":
+	case s.jwt != nil && looksLikeJWT(token):
+		principal, claims, err := s.jwt.verify(r.Context(), token)
+		if err != nil {
+			return identity{}, err
+		}
+		id.principal, id.claims = principal, claims
+	case s.validator != nil:
+		principal, err := s.validator(r.Context(), token)
+		if err != nil {
+			return identity{}, err
+		}
+		id.principal = principal
+	default:
+		return identity{}, ErrUnauthorized
+	}
+	id.tenant = s.tenantOf(id.principal, id.claims)
+	return id, nil
+}
+
+// requireAuth 认证中间件，未开启认证时原样返回 h
+func (s *McpServer) requireAuth(h http.HandlerFunc) http.HandlerFunc {
+	if !s.authEnabled() {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.authenticate(r)
+		if err != nil {
+			logf(LevelInfo, "auth failed from %s %s: %v", r.RemoteAddr, r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(RPCResponse{
+				JsonRPC: version.JSONRPC,
+				Error:   &RPCError{Code: -32001, Message: "Unauthorized"},
+			})
+			return
+		}
+		ctx := context.WithValue(r.Context(), identityKey{}, id)
+		if id.claims != nil {
+			ctx = context.WithValue(ctx, claimsKey{}, id.claims)
+		}
+		h(w, r.WithContext(ctx))
+	}
+}
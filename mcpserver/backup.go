@@ -0,0 +1,523 @@
+package mcpserver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------- 备份与恢复 ----------------------
+// 服务端本身没有持久化存储：会话、连接只在内存中，工具实现是代码，都不能也不需要备份。
+// 备份的是运行中通过管理接口改过、重建实例后需要找回的状态，由若干部分（BackupPart）组成：
+//   - tools：工具开关（admin.tools、SetEnabled）；
+//   - methods：运行中设置的方法开关规则（SetMethodEnabled）；
+//   - costs：成本账本（admin.costs）。
+// 嵌入方自己的存储（审计记录、外部注册表等）用 RegisterBackupPart 加入同一份备份。
+// 密钥环（Keyring）不进备份：备份用它加密，密钥需要单独保管，否则丢失数据时也无法解密备份。
+//
+// 归档为 tar.gz：manifest.json 记录格式版本、实例、时间和各部分的 SHA-256，各部分在 parts/<name>。
+// 用 WithBackupSealer 设置 Sealer 后各部分加密保存，恢复时需要能解密的密钥环。
+// 恢复先校验全部内容再依次应用；归档中有、本服务未注册的部分跳过，便于在不同部署之间迁移
+
+// backupFormatVersion 归档格式版本，格式不兼容时递增
+const backupFormatVersion = 1
+
+// 定期备份的默认保留份数和文件名
+const (
+	defaultBackupKeep = 7
+	backupFilePrefix  = "backup-"
+	backupFileSuffix  = ".tar.gz"
+
+	// 恢复时单个文件和解压后合计的上限，防止构造的归档耗尽内存
+	maxBackupEntrySize = 64 << 20
+	maxBackupTotalSize = 256 << 20
+)
+
+// BackupPart 可备份的一部分状态
+type BackupPart interface {
+	// Backup 导出当前状态
+	Backup() ([]byte, error)
+	// Restore 用 Backup 导出的数据替换当前状态
+	Restore(data []byte) error
+}
+
+// BackupInfo 一份备份的描述，即归档中的 manifest.json
+type BackupInfo struct {
+	Version  int              `json:"version"`
+	ServerID string           `json:"server_id"`
+	Created  time.Time        `json:"created"`
+	Sealed   bool             `json:"sealed"`
+	Parts    []BackupPartInfo `json:"parts"`
+}
+
+// BackupPartInfo 备份中的一部分
+type BackupPartInfo struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Skipped 恢复时本服务没有注册该部分，未应用
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+type namedBackupPart struct {
+	name string
+	part BackupPart
+}
+
+type backups struct {
+	mu     sync.Mutex
+	parts  []namedBackupPart
+	sealer Sealer     // 非空时加密各部分，见 WithBackupSealer
+	run    sync.Mutex // 同一时间只进行一次备份或恢复
+}
+
+// backupFuncs 用一对函数实现 BackupPart
+type backupFuncs struct {
+	backup  func() ([]byte, error)
+	restore func([]byte) error
+}
+
+func (b backupFuncs) Backup() ([]byte, error)   { return b.backup() }
+func (b backupFuncs) Restore(data []byte) error { return b.restore(data) }
+
+// WithBackupSealer 用 sealer（通常是 Keyring）加密备份中的各部分
+func WithBackupSealer(sealer Sealer) Option {
+	return func(s *McpServer) {
+		s.backups.sealer = sealer
+	}
+}
+
+// RegisterBackupPart 把一部分状态加入备份，同名时替换；name 只能包含字母、数字、-、_ 和 .
+func (s *McpServer) RegisterBackupPart(name string, part BackupPart) error {
+	if !validBackupPartName(name) {
+		return fmt.Errorf("invalid backup part name %q", name)
+	}
+	b := &s.backups
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range b.parts {
+		if b.parts[i].name == name {
+			b.parts[i].part = part
+			return nil
+		}
+	}
+	b.parts = append(b.parts, namedBackupPart{name: name, part: part})
+	return nil
+}
+
+func validBackupPartName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *McpServer) backupParts() []namedBackupPart {
+	s.backups.mu.Lock()
+	defer s.backups.mu.Unlock()
+	return append([]namedBackupPart(nil), s.backups.parts...)
+}
+
+// registerBuiltinBackupParts 注册内置的备份部分
+func (s *McpServer) registerBuiltinBackupParts() {
+	s.RegisterBackupPart("tools", backupFuncs{
+		backup: func() ([]byte, error) {
+			return json.Marshal(s.tools.States())
+		},
+		restore: func(data []byte) error {
+			var states []ToolState
+			if err := json.Unmarshal(data, &states); err != nil {
+				return err
+			}
+			// 备份中的工具可能稍后才注册，与 DisabledTools 一样不检查是否存在
+			for _, st := range states {
+				s.tools.setEnabled(st.Name, st.Enabled)
+			}
+			s.tools.changed()
+			return nil
+		},
+	})
+	s.RegisterBackupPart("methods", backupFuncs{
+		backup: func() ([]byte, error) {
+			return json.Marshal(s.methodToggles())
+		},
+		restore: func(data []byte) error {
+			var toggles []MethodToggle
+			if err := json.Unmarshal(data, &toggles); err != nil {
+				return err
+			}
+			return s.restoreMethodToggles(toggles)
+		},
+	})
+	s.RegisterBackupPart("costs", backupFuncs{
+		backup: func() ([]byte, error) {
+			return json.Marshal(CostReport())
+		},
+		restore: func(data []byte) error {
+			var entries []CostEntry
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return err
+			}
+			restoreCosts(entries)
+			return nil
+		},
+	})
+}
+
+// Backup 把全部备份部分写成归档
+func (s *McpServer) Backup(w io.Writer) (*BackupInfo, error) {
+	s.backups.run.Lock()
+	defer s.backups.run.Unlock()
+
+	sealer := s.backups.sealer
+	info := &BackupInfo{
+		Version:  backupFormatVersion,
+		ServerID: s.conf.ServerID,
+		Created:  time.Now().UTC(),
+		Sealed:   sealer != nil,
+	}
+	var contents [][]byte
+	for _, p := range s.backupParts() {
+		data, err := p.part.Backup()
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %w", p.name, err)
+		}
+		if sealer != nil {
+			if data, err = sealer.Seal(data); err != nil {
+				return nil, fmt.Errorf("backup %s: %w", p.name, err)
+			}
+		}
+		sum := sha256.Sum256(data)
+		info.Parts = append(info.Parts, BackupPartInfo{Name: p.name, Size: len(data), SHA256: hex.EncodeToString(sum[:])})
+		contents = append(contents, data)
+	}
+	manifest, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: info.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write("manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	for i, p := range info.Parts {
+		if err := write("parts/"+p.Name, contents[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Restore 从归档恢复：先读出并校验全部部分（校验和、解密），再按归档中的顺序应用。
+// 某部分应用失败时返回错误，之前已应用的部分不回滚
+func (s *McpServer) Restore(r io.Reader) (*BackupInfo, error) {
+	s.backups.run.Lock()
+	defer s.backups.run.Unlock()
+
+	info, files, err := readBackup(r)
+	if err != nil {
+		return nil, err
+	}
+	if info.Sealed && s.backups.sealer == nil {
+		return nil, errors.New("backup is sealed but no backup sealer is configured")
+	}
+
+	registered := make(map[string]BackupPart)
+	for _, p := range s.backupParts() {
+		registered[p.name] = p.part
+	}
+	plain := make([][]byte, len(info.Parts))
+	for i, p := range info.Parts {
+		data, ok := files["parts/"+p.Name]
+		if !ok {
+			return nil, fmt.Errorf("backup is missing part %s", p.Name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != p.SHA256 {
+			return nil, fmt.Errorf("backup part %s: checksum mismatch", p.Name)
+		}
+		if _, ok := registered[p.Name]; !ok {
+			info.Parts[i].Skipped = true
+			continue
+		}
+		if info.Sealed {
+			if data, err = s.backups.sealer.Open(data); err != nil {
+				return nil, fmt.Errorf("backup part %s: %w", p.Name, err)
+			}
+		}
+		plain[i] = data
+	}
+	for i, p := range info.Parts {
+		if p.Skipped {
+			logf(LevelWarn, "restore: part %s is not registered, skipped", p.Name)
+			continue
+		}
+		if err := registered[p.Name].Restore(plain[i]); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", p.Name, err)
+		}
+	}
+	return info, nil
+}
+
+// readBackup 解开归档，返回 manifest 和各文件内容
+func readBackup(r io.Reader) (*BackupInfo, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read backup: %w", err)
+	}
+	defer gz.Close()
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read backup: %w", err)
+		}
+		if !validBackupEntry(hdr) {
+			return nil, nil, fmt.Errorf("read backup: unexpected entry %q", hdr.Name)
+		}
+		if _, dup := files[hdr.Name]; dup {
+			return nil, nil, fmt.Errorf("read backup: duplicate entry %q", hdr.Name)
+		}
+		if hdr.Size > maxBackupEntrySize {
+			return nil, nil, fmt.Errorf("read backup: %s is larger than %d bytes", hdr.Name, maxBackupEntrySize)
+		}
+		// 不信任头中的大小，按实际读到的字节数限制
+		limit := int64(maxBackupEntrySize)
+		if rest := maxBackupTotalSize - total; rest < limit {
+			limit = rest
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, limit+1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("read backup: %w", err)
+		}
+		if int64(len(data)) > limit {
+			return nil, nil, fmt.Errorf("read backup: %s exceeds the size limit (%d bytes per file, %d in total)", hdr.Name, maxBackupEntrySize, maxBackupTotalSize)
+		}
+		total += int64(len(data))
+		files[hdr.Name] = data
+	}
+	manifest, ok := files["manifest.json"]
+	if !ok {
+		return nil, nil, errors.New("read backup: manifest.json not found")
+	}
+	var info BackupInfo
+	if err := json.Unmarshal(manifest, &info); err != nil {
+		return nil, nil, fmt.Errorf("read backup manifest: %w", err)
+	}
+	if info.Version != backupFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported backup version %d", info.Version)
+	}
+	return &info, files, nil
+}
+
+// validBackupEntry 归档中只能有 manifest.json 和 parts/<name> 两种普通文件，
+// 其他名称（包括 ../、绝对路径、子目录）和链接、目录等类型一律拒绝
+func validBackupEntry(hdr *tar.Header) bool {
+	if hdr.Typeflag != tar.TypeReg {
+		return false
+	}
+	if hdr.Name == "manifest.json" {
+		return true
+	}
+	return strings.HasPrefix(hdr.Name, "parts/") && validBackupPartName(strings.TrimPrefix(hdr.Name, "parts/"))
+}
+
+// backupToDir 在 BackupDir 中写入一份备份并清理超出 BackupKeep 的旧备份，返回文件名。
+// 先写临时文件再改名，中途失败不会留下不完整的备份
+func (s *McpServer) backupToDir() (string, *BackupInfo, error) {
+	dir := s.conf.BackupDir
+	if dir == "" {
+		return "", nil, errors.New("backup_dir is not configured")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", nil, err
+	}
+	tmp, err := os.CreateTemp(dir, ".backup-*")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.Remove(tmp.Name())
+	info, err := s.Backup(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	name := backupFilePrefix + info.Created.Format("20060102T150405.000Z") + backupFileSuffix
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", nil, err
+	}
+	s.pruneBackups()
+	return name, info, nil
+}
+
+// listBackups BackupDir 中的备份文件名，按时间从旧到新
+func (s *McpServer) listBackups() ([]string, error) {
+	entries, err := os.ReadDir(s.conf.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupFilePrefix) && strings.HasSuffix(e.Name(), backupFileSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// pruneBackups 只保留最近 BackupKeep 份
+func (s *McpServer) pruneBackups() {
+	names, err := s.listBackups()
+	if err != nil {
+		logf(LevelWarn, "backup: %v", err)
+		return
+	}
+	for len(names) > s.conf.BackupKeep {
+		if err := os.Remove(filepath.Join(s.conf.BackupDir, names[0])); err != nil {
+			logf(LevelWarn, "backup: %v", err)
+		}
+		names = names[1:]
+	}
+}
+
+// runBackups 定期备份
+func (s *McpServer) runBackups() {
+	ticker := time.NewTicker(s.conf.BackupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if name, _, err := s.backupToDir(); err != nil {
+				logf(LevelError, "scheduled backup: %v", err)
+			} else {
+				logf(LevelInfo, "scheduled backup written to %s", name)
+			}
+		}
+	}
+}
+
+// adminBackup admin.backup：默认以 base64 返回归档；save 为 true 时写入 BackupDir 并返回文件名，
+// list 为 true 时只列出 BackupDir 中的备份
+func (s *McpServer) adminBackup(raw json.RawMessage) (interface{}, *RPCError) {
+	var params struct {
+		Save bool `json:"save"`
+		List bool `json:"list"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+	}
+	switch {
+	case params.List:
+		if s.conf.BackupDir == "" {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: "backup_dir is not configured"}
+		}
+		names, err := s.listBackups()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, s.sanitizeError(-32603, "Backup failed", err)
+		}
+		if names == nil {
+			names = []string{}
+		}
+		return map[string]interface{}{"files": names}, nil
+	case params.Save:
+		if s.conf.BackupDir == "" {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: "backup_dir is not configured"}
+		}
+		name, info, err := s.backupToDir()
+		if err != nil {
+			return nil, s.sanitizeError(-32603, "Backup failed", err)
+		}
+		return map[string]interface{}{"file": name, "backup": info}, nil
+	default:
+		var buf bytes.Buffer
+		info, err := s.Backup(&buf)
+		if err != nil {
+			return nil, s.sanitizeError(-32603, "Backup failed", err)
+		}
+		return map[string]interface{}{
+			"archive": base64.StdEncoding.EncodeToString(buf.Bytes()),
+			"backup":  info,
+		}, nil
+	}
+}
+
+// adminRestore admin.restore：archive 为 admin.backup 返回的 base64 归档，或 file 为 BackupDir 中的备份文件名
+func (s *McpServer) adminRestore(raw json.RawMessage) (interface{}, *RPCError) {
+	var params struct {
+		Archive string `json:"archive"`
+		File    string `json:"file"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil || (params.Archive == "") == (params.File == "") {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: "exactly one of archive / file is required"}
+	}
+	var r io.Reader
+	if params.Archive != "" {
+		data, err := base64.StdEncoding.DecodeString(params.Archive)
+		if err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: "archive is not valid base64"}
+		}
+		r = bytes.NewReader(data)
+	} else {
+		// 只接受 BackupDir 中的文件名，不接受路径
+		if s.conf.BackupDir == "" || filepath.Base(params.File) != params.File || !strings.HasPrefix(params.File, backupFilePrefix) {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: "file must be a backup in backup_dir"}
+		}
+		f, err := os.Open(filepath.Join(s.conf.BackupDir, params.File))
+		if err != nil {
+			return nil, s.sanitizeError(-32602, "Invalid params", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	info, err := s.Restore(r)
+	if err != nil {
+		return nil, s.sanitizeError(-32602, "Restore failed", err)
+	}
+	logf(LevelWarn, "state restored from backup created %s by %s via admin.restore", info.Created.Format(time.RFC3339), info.ServerID)
+	return map[string]interface{}{"backup": info}, nil
+}
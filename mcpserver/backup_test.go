@@ -0,0 +1,187 @@
+package mcpserver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryPart 保存在内存中的备份部分
+type memoryPart struct {
+	data []byte
+}
+
+func (p *memoryPart) Backup() ([]byte, error) { return p.data, nil }
+
+func (p *memoryPart) Restore(data []byte) error {
+	p.data = append([]byte(nil), data...)
+	return nil
+}
+
+// archiveEntry 构造归档用的一个条目，size 大于 len(data) 时用 0 补齐
+type archiveEntry struct {
+	name     string
+	typeflag byte
+	data     []byte
+	size     int64
+}
+
+// buildArchive 按给定条目构造归档，manifest 中列出 parts 下的各部分
+func buildArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	info := BackupInfo{Version: backupFormatVersion, ServerID: "test", Created: time.Now().UTC()}
+	for _, e := range entries {
+		if name := strings.TrimPrefix(e.name, "parts/"); name != e.name {
+			sum := sha256.Sum256(e.data)
+			info.Parts = append(info.Parts, BackupPartInfo{Name: name, Size: len(e.data), SHA256: hex.EncodeToString(sum[:])})
+		}
+	}
+	manifest, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append([]archiveEntry{{name: "manifest.json", data: manifest}}, entries...)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		size := e.size
+		if size < int64(len(e.data)) {
+			size = int64(len(e.data))
+		}
+		hdr := &tar.Header{Name: e.name, Mode: 0o600, Size: size, Typeflag: e.typeflag}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			hdr.Size, size = 0, 0
+			hdr.Linkname = "/etc/passwd"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			t.Fatal(err)
+		}
+		if pad := size - int64(len(e.data)); pad > 0 {
+			if _, err := io.CopyN(tw, zeroReader{}, pad); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	keyring, err := NewKeyring(1, bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewMcpServerWithTools(McpConf{}, NewToolRegistry(), WithBackupSealer(keyring))
+	if err := src.RegisterBackupPart("notes", &memoryPart{data: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := src.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewMcpServerWithTools(McpConf{}, NewToolRegistry(), WithBackupSealer(keyring))
+	part := &memoryPart{}
+	if err := dst.RegisterBackupPart("notes", part); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.Restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if string(part.data) != "hello" {
+		t.Errorf("restored %q, want %q", part.data, "hello")
+	}
+
+	unsealed := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	if _, err := unsealed.Restore(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("sealed backup restored without a sealer")
+	}
+}
+
+func TestRestoreRejectsPathEscapingEntries(t *testing.T) {
+	tests := []archiveEntry{
+		{name: "../evil", data: []byte("x")},
+		{name: "/etc/cron.d/evil", data: []byte("x")},
+		{name: "parts/../../evil", data: []byte("x")},
+		{name: "parts/a/b", data: []byte("x")},
+		{name: "parts/..", data: []byte("x")},
+		{name: "parts/link", typeflag: tar.TypeSymlink},
+		{name: "parts/", typeflag: tar.TypeDir},
+	}
+	for _, entry := range tests {
+		t.Run(entry.name, func(t *testing.T) {
+			s := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+			archive := buildArchive(t, entry)
+			_, err := s.Restore(bytes.NewReader(archive))
+			if err == nil || !strings.Contains(err.Error(), "unexpected entry") {
+				t.Errorf("Restore error = %v, want unexpected entry", err)
+			}
+		})
+	}
+}
+
+func TestRestoreRejectsDuplicateEntries(t *testing.T) {
+	s := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	archive := buildArchive(t, archiveEntry{name: "parts/notes", data: []byte("a")}, archiveEntry{name: "parts/notes", data: []byte("b")})
+	if _, err := s.Restore(bytes.NewReader(archive)); err == nil || !strings.Contains(err.Error(), "duplicate entry") {
+		t.Errorf("Restore error = %v, want duplicate entry", err)
+	}
+}
+
+func TestRestoreRejectsOversizedEntries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a 64MB archive entry")
+	}
+	s := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	part := &memoryPart{}
+	if err := s.RegisterBackupPart("notes", part); err != nil {
+		t.Fatal(err)
+	}
+	archive := buildArchive(t, archiveEntry{name: "parts/notes", size: maxBackupEntrySize + 1})
+	_, err := s.Restore(bytes.NewReader(archive))
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Restore error = %v, want size limit error", err)
+	}
+	if part.data != nil {
+		t.Error("oversized part was applied")
+	}
+}
+
+func TestAdminRestoreRejectsPaths(t *testing.T) {
+	s := NewMcpServerWithTools(McpConf{BackupDir: t.TempDir()}, NewToolRegistry())
+	for _, file := range []string{"../backup-1.tar.gz", "/tmp/backup-1.tar.gz", "sub/backup-1.tar.gz", "evil.tar.gz"} {
+		raw, _ := json.Marshal(map[string]string{"file": file})
+		if _, rpcErr := s.adminRestore(raw); rpcErr == nil || rpcErr.Code != -32602 {
+			t.Errorf("adminRestore(%q) = %v, want invalid params", file, rpcErr)
+		}
+	}
+}
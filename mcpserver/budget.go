@@ -0,0 +1,146 @@
+package mcpserver
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ---------------------- 会话调用预算 ----------------------
+// 按会话（WS 连接、HTTP+SSE / stdio 会话、Streamable HTTP 会话）限制 tools.run 的频率，
+// 令牌桶：每分钟补充 McpConf.SessionCallRate 次，最多攒 SessionCallBurst 次。
+// 失控的 agent 循环只会耗尽自己会话的预算，不影响同一 IP / 租户下的其他对话。
+// 超出时返回 -32005，Data 中带 retryAfterMs 和当前预算；server.info 的 budget 字段返回调用方会话的预算。
+// 没有会话的 HTTP 请求不受限制
+
+// sweepBudgetsAt 会话数超过该值时清理已补满的桶（空闲的会话）
+const sweepBudgetsAt = 4096
+
+// BudgetStatus 会话的调用预算
+type BudgetStatus struct {
+	LimitPerMinute int `json:"limitPerMinute"`
+	Burst          int `json:"burst"`
+	Remaining      int `json:"remaining"`
+}
+
+type budgetBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// callBudget 所有会话的令牌桶
+type callBudget struct {
+	perMinute int
+	burst     int
+
+	mu       sync.Mutex
+	sessions map[string]*budgetBucket
+}
+
+// newCallBudget perMinute <= 0 时返回 nil，表示不限；burst <= 0 时等于 perMinute
+func newCallBudget(perMinute, burst int) *callBudget {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &callBudget{perMinute: perMinute, burst: burst, sessions: make(map[string]*budgetBucket)}
+}
+
+// WithSessionCallBudget 每个会话每分钟最多 perMinute 次 tools.run，允许攒 burst 次突发
+func WithSessionCallBudget(perMinute, burst int) Option {
+	return func(s *McpServer) {
+		s.conf.SessionCallRate = perMinute
+		s.conf.SessionCallBurst = burst
+	}
+}
+
+// refill 按经过的时间补充令牌，调用时持有锁
+func (b *callBudget) refill(session string, now time.Time) *budgetBucket {
+	bucket, ok := b.sessions[session]
+	if !ok {
+		if len(b.sessions) >= sweepBudgetsAt {
+			b.sweep(now)
+		}
+		bucket = &budgetBucket{tokens: float64(b.burst), last: now}
+		b.sessions[session] = bucket
+		return bucket
+	}
+	elapsed := now.Sub(bucket.last).Minutes()
+	bucket.tokens = math.Min(float64(b.burst), bucket.tokens+elapsed*float64(b.perMinute))
+	bucket.last = now
+	return bucket
+}
+
+// sweep 删除已补满的桶，它们与新建的桶没有区别，返回删除的个数
+func (b *callBudget) sweep(now time.Time) int {
+	removed := 0
+	for id, bucket := range b.sessions {
+		if bucket.tokens+now.Sub(bucket.last).Minutes()*float64(b.perMinute) >= float64(b.burst) {
+			delete(b.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// expire 定期清理时调用，见 janitor.go
+func (b *callBudget) expire(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sweep(now)
+}
+
+// take 消耗一次调用，预算不足时返回 false 和需要等待的时长
+func (b *callBudget) take(session string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bucket := b.refill(session, time.Now())
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / float64(b.perMinute) * float64(time.Minute))
+	return false, wait
+}
+
+// status 会话当前的预算
+func (b *callBudget) status(session string) BudgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bucket := b.refill(session, time.Now())
+	return BudgetStatus{LimitPerMinute: b.perMinute, Burst: b.burst, Remaining: int(bucket.tokens)}
+}
+
+// forget 会话结束，释放它的桶
+func (b *callBudget) forget(session string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, session)
+}
+
+// takeCallBudget tools.run 前检查调用方会话的预算
+func (s *McpServer) takeCallBudget(session string) *RPCError {
+	if s.budget == nil || session == "" {
+		return nil
+	}
+	ok, wait := s.budget.take(session)
+	if ok {
+		return nil
+	}
+	logf(LevelInfo, "session %s exceeded its tool call budget", session)
+	return &RPCError{Code: -32005, Message: "Tool call budget exceeded", Data: map[string]interface{}{
+		"retryAfterMs": wait.Milliseconds(),
+		"budget":       s.budget.status(session),
+	}}
+}
+
+// budgetStatus server.info 中调用方会话的预算，不限制时返回 nil
+func (s *McpServer) budgetStatus(session string) *BudgetStatus {
+	if s.budget == nil || session == "" {
+		return nil
+	}
+	status := s.budget.status(session)
+	return &status
+}
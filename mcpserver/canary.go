@@ -0,0 +1,220 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// ---------------------- 金丝雀发布 ----------------------
+// 同一个工具挂两套实现：按 Percent 把一部分流量切给 canary，分别统计两边的错误率和耗时，
+// canary 错误率比 stable 高出 MaxErrorRateDelta 时自动回滚（之后所有流量回到 stable）
+
+// Canary 工具的金丝雀实现
+type Canary struct {
+	Handler func(ctx context.Context, args json.RawMessage) (interface{}, error)
+	// Percent 切给 canary 的流量百分比，0-100
+	Percent float64
+	// MaxErrorRateDelta canary 错误率超过 stable 的幅度上限，默认 0.05（5 个百分点）
+	MaxErrorRateDelta float64
+	// MinCalls canary 至少调用这么多次后才评估是否回滚，默认 20
+	MinCalls int64
+}
+
+// CanaryArmStats 一侧实现的调用统计
+type CanaryArmStats struct {
+	Calls     int64   `json:"calls"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	AvgMs     float64 `json:"avg_ms"`
+	totalMs   float64
+}
+
+// CanaryStatus 金丝雀当前状态
+type CanaryStatus struct {
+	Tool       string         `json:"tool"`
+	Percent    float64        `json:"percent"`
+	RolledBack bool           `json:"rolled_back"`
+	Stable     CanaryArmStats `json:"stable"`
+	Canary     CanaryArmStats `json:"canary"`
+}
+
+type canaryState struct {
+	conf       Canary
+	rolledBack bool
+	stable     CanaryArmStats
+	canary     CanaryArmStats
+}
+
+// SetCanary 为已注册的工具设置金丝雀实现，重复设置会清空之前的统计
+func (r *ToolRegistry) SetCanary(tool string, c Canary) error {
+	if _, ok := r.Get(tool); !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, tool)
+	}
+	if c.Handler == nil {
+		return fmt.Errorf("canary for %s: handler is required", tool)
+	}
+	if c.Percent < 0 || c.Percent > 100 {
+		return fmt.Errorf("canary for %s: percent must be within 0-100", tool)
+	}
+	if c.MaxErrorRateDelta <= 0 {
+		c.MaxErrorRateDelta = 0.05
+	}
+	if c.MinCalls <= 0 {
+		c.MinCalls = 20
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.canaries[tool] = &canaryState{conf: c}
+	return nil
+}
+
+// RemoveCanary 移除金丝雀，流量全部回到 stable
+func (r *ToolRegistry) RemoveCanary(tool string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.canaries, tool)
+}
+
+// PromoteCanary 用 canary 实现替换工具的 Handler 并移除金丝雀
+func (r *ToolRegistry) PromoteCanary(tool string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.canaries[tool]
+	if !ok {
+		return fmt.Errorf("no canary for tool %s", tool)
+	}
+	t, ok := r.tools[tool]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, tool)
+	}
+	t.Handler = st.conf.Handler
+	delete(r.canaries, tool)
+	logf(LevelInfo, "canary for tool %s promoted", tool)
+	return nil
+}
+
+// CanaryStatuses 返回所有金丝雀的状态，按工具名排序
+func (r *ToolRegistry) CanaryStatuses() []CanaryStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]CanaryStatus, 0, len(r.canaries))
+	for name, st := range r.canaries {
+		list = append(list, CanaryStatus{
+			Tool:       name,
+			Percent:    st.conf.Percent,
+			RolledBack: st.rolledBack,
+			Stable:     st.stable,
+			Canary:     st.canary,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Tool < list[j].Tool })
+	return list
+}
+
+// pickHandler 选择本次调用的实现，返回是否走 canary
+func (r *ToolRegistry) pickHandler(tool *Tool) (func(context.Context, json.RawMessage) (interface{}, error), bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	st, ok := r.canaries[tool.Name]
+	if !ok || st.rolledBack || rand.Float64()*100 >= st.conf.Percent {
+		return tool.Handler, false
+	}
+	return st.conf.Handler, true
+}
+
+// recordCanaryCall 记录一次调用结果，必要时回滚
+func (r *ToolRegistry) recordCanaryCall(tool string, useCanary bool, elapsed time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.canaries[tool]
+	if !ok {
+		return
+	}
+	arm := &st.stable
+	if useCanary {
+		arm = &st.canary
+	}
+	arm.Calls++
+	if failed {
+		arm.Errors++
+	}
+	arm.totalMs += float64(elapsed.Microseconds()) / 1000
+	arm.ErrorRate = float64(arm.Errors) / float64(arm.Calls)
+	arm.AvgMs = arm.totalMs / float64(arm.Calls)
+
+	if useCanary && !st.rolledBack && st.canary.Calls >= st.conf.MinCalls &&
+		st.canary.ErrorRate-st.stable.ErrorRate > st.conf.MaxErrorRateDelta {
+		st.rolledBack = true
+		logf(LevelWarn, "canary for tool %s rolled back: error rate %.2f vs stable %.2f",
+			tool, st.canary.ErrorRate, st.stable.ErrorRate)
+	}
+}
+
+// invoke 执行工具，配置了金丝雀时按比例分流并统计，配置了影子实现时异步比对
+func (r *ToolRegistry) invoke(ctx context.Context, tool *Tool, args json.RawMessage) (interface{}, error) {
+	handler, useCanary := r.pickHandler(tool)
+	start := time.Now()
+	result, err := handler(ctx, args)
+	r.recordCanaryCall(tool.Name, useCanary, time.Since(start), isFailedCall(result, err))
+	r.startShadow(ctx, tool.Name, args, result, err)
+	return result, err
+}
+
+// isFailedCall 返回错误或 isError 结果都算失败
+func isFailedCall(result interface{}, err error) bool {
+	if err != nil {
+		return true
+	}
+	r, ok := result.(*CallToolResult)
+	return ok && r != nil && r.IsError
+}
+
+// adminCanaries admin.canaries 方法：查看金丝雀状态；
+// action 为 promote / remove 时对 tool 执行对应操作
+func (r *ToolRegistry) adminCanaries(raw json.RawMessage) (interface{}, *RPCError) {
+	var params struct {
+		Tool   string `json:"tool"`
+		Action string `json:"action"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+	}
+	switch params.Action {
+	case "":
+	case "promote":
+		if err := r.PromoteCanary(params.Tool); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: map[string]interface{}{"problems": []string{err.Error()}}}
+		}
+	case "remove":
+		r.RemoveCanary(params.Tool)
+	default:
+		return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: map[string]interface{}{"problems": []string{"unknown action " + params.Action}}}
+	}
+	return map[string]interface{}{"canaries": r.CanaryStatuses()}, nil
+}
+
+// SetCanary 见 ToolRegistry.SetCanary
+func SetCanary(tool string, c Canary) error {
+	return DefaultToolRegistry.SetCanary(tool, c)
+}
+
+// RemoveCanary 见 ToolRegistry.RemoveCanary
+func RemoveCanary(tool string) {
+	DefaultToolRegistry.RemoveCanary(tool)
+}
+
+// PromoteCanary 见 ToolRegistry.PromoteCanary
+func PromoteCanary(tool string) error {
+	return DefaultToolRegistry.PromoteCanary(tool)
+}
+
+// CanaryStatuses 见 ToolRegistry.CanaryStatuses
+func CanaryStatuses() []CanaryStatus {
+	return DefaultToolRegistry.CanaryStatuses()
+}
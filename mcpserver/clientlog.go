@@ -0,0 +1,105 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 发给客户端的日志 ----------------------
+// 工具通过 mcpctx.ClientLoggerFromContext 向调用方的会话发送 notifications/message：
+//
+//	log := mcpctx.ClientLoggerFromContext(ctx)
+//	log.Log("info", "indexer", map[string]interface{}{"indexed": n, "skipped": skipped})
+//
+// 客户端用 logging/setLevel（{"level": "debug"}）设置会话接收的最低级别，没有设置时为 info。
+// 日志只发给调用方所在的会话（WS、stdio、Streamable HTTP 的 SSE 流），没有会话的 HTTP 请求丢弃日志
+
+// clientLogLevels MCP（RFC 5424）的日志级别，按严重程度递增
+var clientLogLevels = map[string]int{
+	"debug":     0,
+	"info":      1,
+	"notice":    2,
+	"warning":   3,
+	"error":     4,
+	"critical":  5,
+	"alert":     6,
+	"emergency": 7,
+}
+
+// defaultClientLogLevel 会话没有 logging/setLevel 时接收的最低级别
+const defaultClientLogLevel = "info"
+
+// clientLogger 向一个会话发送日志
+type clientLogger struct {
+	s      *McpServer
+	target notifyTarget
+	st     *sessionState
+}
+
+// withClientLogger 有可以接收通知的会话时，在 ctx 中放入发往该会话的日志
+func (s *McpServer) withClientLogger(ctx context.Context, target notifyTarget, session mcpctx.Session) context.Context {
+	if target == nil {
+		return ctx
+	}
+	return mcpctx.WithClientLogger(ctx, &clientLogger{s: s, target: target, st: stateOf(session)})
+}
+
+// Log 实现 mcpctx.ClientLogger，未知的级别按 info 处理
+func (l *clientLogger) Log(level, logger string, data interface{}) {
+	severity, ok := clientLogLevels[level]
+	if !ok {
+		level, severity = "info", clientLogLevels["info"]
+	}
+	if severity < clientLogLevels[l.st.clientLogLevel()] {
+		return
+	}
+	params := map[string]interface{}{"level": level, "data": data}
+	if logger != "" {
+		params["logger"] = logger
+	}
+	method := "notifications/message"
+	l.s.notifier.Push(&notification{
+		Method:   method,
+		Params:   params,
+		Priority: notificationPriority(method),
+		Deliver: func(method string, params interface{}) {
+			l.target.notify(newRPCNotification(NextEventStamp(), method, params))
+		},
+	})
+}
+
+// clientLogLevel 会话接收的最低级别，st 为 nil 时为默认级别
+func (st *sessionState) clientLogLevel() string {
+	if st == nil {
+		return defaultClientLogLevel
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.logLevel == "" {
+		return defaultClientLogLevel
+	}
+	return st.logLevel
+}
+
+// setLogLevel logging/setLevel
+func (s *McpServer) setLogLevel(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+	var params struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(c.params, &params); err != nil {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	if _, ok := clientLogLevels[params.Level]; !ok {
+		return nil, &RPCError{Code: -32602, Message: "Invalid log level: " + params.Level}
+	}
+	st := stateOf(c.session)
+	if st == nil {
+		return nil, &RPCError{Code: -32602, Message: "Logging requires a session"}
+	}
+	st.mu.Lock()
+	st.logLevel = params.Level
+	st.mu.Unlock()
+	return map[string]interface{}{}, nil
+}
@@ -0,0 +1,165 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"mcptool/mcpctx"
+	"mcptool/version"
+)
+
+// ---------------------- 服务端发往客户端的请求 ----------------------
+// sampling/createMessage 等方法由服务端向客户端发起请求，只能走双向传输（WS、stdio）：
+// 请求 id 以 "srv-" 开头，与客户端自己的请求 id 区分；客户端的响应由读循环按 id 交给等待中的调用。
+// 等待期间 context 结束时放弃等待，并向客户端发送 notifications/cancelled
+
+// ErrClientRequestUnsupported 当前请求的传输不能向客户端发起请求（普通 HTTP、SSE）
+var ErrClientRequestUnsupported = errors.New("transport does not support server-to-client requests")
+
+// ClientError 客户端对服务端请求返回的 JSON-RPC 错误
+type ClientError struct {
+	Method  string
+	Code    int
+	Message string
+}
+
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("client error for %s: %d %s", e.Method, e.Code, e.Message)
+}
+
+// clientCaller 可以向客户端发起请求的会话
+type clientCaller interface {
+	callClient(ctx context.Context, method string, params, result interface{}) error
+}
+
+// clientCalls 会话中等待客户端响应的请求，嵌入 wsSession / stdioSession
+type clientCalls struct {
+	seq     uint64
+	mu      sync.Mutex
+	pending map[string]chan *clientResponse
+}
+
+// clientRequest 服务端发出的请求
+type clientRequest struct {
+	JsonRPC string      `json:"jsonrpc"`
+	ID      string      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// clientResponse 客户端的响应
+type clientResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// isClientResponse 收到的消息是否为客户端对服务端请求的响应（有 id、没有 method）
+func isClientResponse(req *RPCRequest) bool {
+	return req.Method == "" && len(req.ID) > 0
+}
+
+// call 发送请求并等待响应，send 写出一条消息
+func (cc *clientCalls) call(ctx context.Context, send func(v interface{}) error, method string, params, result interface{}) error {
+	id := "srv-" + strconv.FormatUint(atomic.AddUint64(&cc.seq, 1), 10)
+	ch := make(chan *clientResponse, 1)
+	cc.mu.Lock()
+	if cc.pending == nil {
+		cc.pending = make(map[string]chan *clientResponse)
+	}
+	cc.pending[id] = ch
+	cc.mu.Unlock()
+	defer func() {
+		cc.mu.Lock()
+		delete(cc.pending, id)
+		cc.mu.Unlock()
+	}()
+
+	if err := send(&clientRequest{JsonRPC: version.JSONRPC, ID: id, Method: method, Params: params}); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return &ClientError{Method: method, Code: resp.Error.Code, Message: resp.Error.Message}
+		}
+		if result != nil {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return fmt.Errorf("%s: decode client result: %w", method, err)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		send(&RPCNotification{
+			JsonRPC: version.JSONRPC,
+			Method:  "notifications/cancelled",
+			Params:  map[string]interface{}{"requestId": id, "reason": ctx.Err().Error()},
+		})
+		return ctx.Err()
+	}
+}
+
+// resolve 把客户端的响应交给等待中的请求，不是在等待的 id 时返回 false
+func (cc *clientCalls) resolve(data []byte) bool {
+	var resp clientResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return false
+	}
+	var id string
+	if json.Unmarshal(resp.ID, &id) != nil {
+		return false
+	}
+	cc.mu.Lock()
+	ch, ok := cc.pending[id]
+	delete(cc.pending, id)
+	cc.mu.Unlock()
+	if ok {
+		ch <- &resp
+	}
+	return ok
+}
+
+// callClient 通过当前请求所在的会话向客户端发起请求
+func callClient(ctx context.Context, method string, params, result interface{}) error {
+	sess, _ := mcpctx.SessionFromContext(ctx)
+	caller, ok := sess.(clientCaller)
+	if !ok {
+		return fmt.Errorf("%s: %w", method, ErrClientRequestUnsupported)
+	}
+	return caller.callClient(ctx, method, params, result)
+}
+
+// canCallClient 当前请求所在的会话能否向客户端发起请求
+func canCallClient(ctx context.Context) bool {
+	sess, _ := mcpctx.SessionFromContext(ctx)
+	_, ok := sess.(clientCaller)
+	return ok
+}
+
+// clientCapability 会话的客户端在 initialize 时是否声明了某项能力。
+// 未 initialize 的旧版客户端无从判断，视为支持
+func clientCapability(ctx context.Context, name string) bool {
+	st, ok := mcpctx.SessionStateFromContext(ctx)
+	if !ok || !st.Initialized() {
+		return true
+	}
+	var caps map[string]json.RawMessage
+	if err := json.Unmarshal(st.Client().Capabilities, &caps); err != nil {
+		return false
+	}
+	_, ok = caps[name]
+	return ok
+}
+
+func (sess *wsSession) callClient(ctx context.Context, method string, params, result interface{}) error {
+	return sess.calls.call(ctx, sess.writeJSON, method, params, result)
+}
+
+func (sess *stdioSession) callClient(ctx context.Context, method string, params, result interface{}) error {
+	return sess.calls.call(ctx, sess.write, method, params, result)
+}
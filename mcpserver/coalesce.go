@@ -0,0 +1,135 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ---------------------- 并发请求合并 ----------------------
+// 同时到达的相同请求只执行一次，结果由所有请求共享（singleflight）：
+//   - tools.run：只对 Tool.ReadOnly 的工具，按工具名和参数合并。ReadOnly 表示工具没有副作用、
+//     结果只取决于参数，不同调用方的相同请求也会合并；每个请求仍各自经过授权和调用预算。
+//     带 progressToken / rowsToken 的请求需要自己的通知流，不参与合并。共享结果的请求在 meta 中
+//     标记 cache_hit，成本只计入实际执行的那次调用；
+//   - tools.list / resources.list / prompts.list：按调用方身份（认证主体、声明、会话、租户等）合并。
+// 实际执行的请求被取消时，其他仍在等待的请求各自重新执行。合并次数见 system.stats 的 coalesced
+
+// flightCall 一次正在执行的调用
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// flightGroup 按 key 合并并发调用
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+	// shared 共享了其他请求结果的次数
+	shared int64
+}
+
+// do 执行 fn，同一 key 已有调用在执行时等待其结果；shared 为 true 表示结果来自其他请求
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (val interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		atomic.AddInt64(&g.shared, 1)
+		return c.val, c.err, true
+	}
+	c := &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err, false
+}
+
+// coalesce 以 ctx 所属请求的身份执行 fn：结果来自一个已被取消的请求而本请求仍有效时，自己重新执行
+func (g *flightGroup) coalesce(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error, bool) {
+	val, err, shared := g.do(key, fn)
+	if shared && ctx.Err() == nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		val, err = fn()
+		return val, err, false
+	}
+	return val, err, shared
+}
+
+// toolFlightKey tools.run 的合并 key，不参与合并时返回 false；
+// 参数按 JSON 重新编码，字段顺序、空白不同的相同参数得到同一个 key
+func (s *McpServer) toolFlightKey(name string, args json.RawMessage, meta RequestMeta) (string, bool) {
+	if tool, ok := s.tools.Get(name); !ok || !tool.ReadOnly {
+		return "", false
+	}
+	if len(meta.ProgressToken) > 0 || len(meta.Fields["rowsToken"]) > 0 {
+		return "", false
+	}
+	var v interface{}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &v); err != nil {
+			return "", false
+		}
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return "tools.run\x00" + name + "\x00" + string(canonical), true
+}
+
+// listFlightKey 列表方法的合并 key：可见性由调用方身份决定，对端地址只保留主机部分
+func listFlightKey(method string, access AccessRequest) (string, bool) {
+	if host, _, err := net.SplitHostPort(access.Caller.RemoteAddr); err == nil {
+		access.Caller.RemoteAddr = host
+	}
+	identity, err := json.Marshal(access)
+	if err != nil {
+		return "", false
+	}
+	return method + "\x00" + string(identity), true
+}
+
+// coalesceList 合并列表方法
+func (s *McpServer) coalesceList(method string, fn methodFunc) methodFunc {
+	return func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		key, ok := listFlightKey(method, c.access)
+		if !ok {
+			return fn(ctx, c)
+		}
+		val, err, _ := s.flights.coalesce(ctx, key, func() (interface{}, error) {
+			result, rpcErr := fn(ctx, c)
+			if rpcErr != nil {
+				return nil, flightRPCError{rpcErr}
+			}
+			return result, nil
+		})
+		var rpcErr flightRPCError
+		if errors.As(err, &rpcErr) {
+			return nil, rpcErr.err
+		}
+		return val, nil
+	}
+}
+
+// flightRPCError 在合并的调用间传递 *RPCError
+type flightRPCError struct {
+	err *RPCError
+}
+
+func (e flightRPCError) Error() string {
+	return e.err.Message
+}
@@ -0,0 +1,116 @@
+package mcpserver
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------- 旧版方法兼容与弃用统计 ----------------------
+
+// Deprecation 旧版方法的弃用说明
+type Deprecation struct {
+	Method      string `json:"method"`
+	Replacement string `json:"replacement"`
+	Note        string `json:"note,omitempty"`
+}
+
+// deprecatedMethods 计划弃用的旧版 dotted 方法名，参数形状与替代方法一致，只需改名
+var deprecatedMethods = map[string]Deprecation{
+	"tools.run":      {Method: "tools.run", Replacement: "tools/call"},
+	"tools.list":     {Method: "tools.list", Replacement: "tools/list"},
+	"resources.get":  {Method: "resources.get", Replacement: "resources/read", Note: "pass uri instead of name"},
+	"resources.list": {Method: "resources.list", Replacement: "resources/list"},
+	"prompts.get":    {Method: "prompts.get", Replacement: "prompts/get"},
+	"prompts.list":   {Method: "prompts.list", Replacement: "prompts/list"},
+}
+
+// ListDeprecations 返回所有弃用说明
+func ListDeprecations() []Deprecation {
+	list := []Deprecation{}
+	for _, d := range deprecatedMethods {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Method < list[j].Method })
+	return list
+}
+
+// WireShapeStat 某种请求形状（客户端实际发送的方法名）的使用次数
+type WireShapeStat struct {
+	Method     string    `json:"method"`    // 客户端发送的方法名
+	Canonical  string    `json:"canonical"` // 映射后的内部方法名
+	Shape      string    `json:"shape"`     // "spec" / "legacy" / "native"
+	Count      int64     `json:"count"`
+	LastSeen   time.Time `json:"last_seen"`
+	Deprecated bool      `json:"deprecated"`
+}
+
+// unknownMethod 未注册的方法统一计入的统计项，客户端可以随意构造方法名，不逐个统计
+const unknownMethod = "unknown"
+
+// wireStats 一个服务的请求形状统计，只统计已注册的方法（含别名和旧版方法名），其余计入 unknownMethod
+type wireStats struct {
+	mu    sync.Mutex
+	stats map[string]*WireShapeStat
+}
+
+// translateMethod 兼容层入口：把规范 / 旧版方法名统一映射到内部方法，
+// 同时统计各形状的使用量，旧版方法第一次出现时打印弃用提示
+func (s *McpServer) translateMethod(method string) string {
+	canonical := CanonicalMethod(method)
+	shape := "native"
+	if _, ok := methodAliases[method]; ok {
+		shape = "spec"
+	}
+	_, deprecated := deprecatedMethods[method]
+	if deprecated {
+		shape = "legacy"
+	}
+	key, stat := method, WireShapeStat{Method: method, Canonical: canonical, Shape: shape, Deprecated: deprecated}
+	if _, ok := s.dispatcher.lookup(canonical); !ok {
+		key, stat = unknownMethod, WireShapeStat{Method: unknownMethod, Shape: unknownMethod}
+	}
+
+	w := &s.wireStats
+	w.mu.Lock()
+	if w.stats == nil {
+		w.stats = make(map[string]*WireShapeStat)
+	}
+	st, ok := w.stats[key]
+	if !ok {
+		st = &stat
+		w.stats[key] = st
+		if st.Deprecated {
+			d := deprecatedMethods[method]
+			logf(LevelWarn, "deprecated method %q used by a client, migrate to %q", method, d.Replacement)
+		}
+	}
+	st.Count++
+	st.LastSeen = time.Now()
+	w.mu.Unlock()
+
+	return canonical
+}
+
+// WireShapeStats 返回本服务各请求形状的使用统计，用于判断何时可以下线旧版方法；
+// 未注册的方法合计为一项，Method 和 Shape 为 "unknown"
+func (s *McpServer) WireShapeStats() []WireShapeStat {
+	w := &s.wireStats
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list := []WireShapeStat{}
+	for _, st := range w.stats {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Count > list[j].Count })
+	return list
+}
+
+// adminWireStats admin.wireStats 方法
+func (s *McpServer) adminWireStats(json.RawMessage) (interface{}, *RPCError) {
+	return map[string]interface{}{
+		"shapes":       s.WireShapeStats(),
+		"deprecations": ListDeprecations(),
+	}, nil
+}
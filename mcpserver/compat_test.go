@@ -0,0 +1,31 @@
+package mcpserver
+
+import "testing"
+
+func TestWireShapeStatsBounded(t *testing.T) {
+	a := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	b := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+
+	for _, m := range []string{"tools/list", "tools.list", "tools.list", "no/such/method", "another.bogus"} {
+		a.translateMethod(m)
+	}
+	stats := map[string]WireShapeStat{}
+	for _, st := range a.WireShapeStats() {
+		stats[st.Method] = st
+	}
+	if len(stats) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(stats), stats)
+	}
+	if st := stats["tools/list"]; st.Shape != "spec" || st.Canonical != "tools.list" || st.Count != 1 {
+		t.Errorf("tools/list: %+v", st)
+	}
+	if st := stats["tools.list"]; st.Shape != "legacy" || !st.Deprecated || st.Count != 2 {
+		t.Errorf("tools.list: %+v", st)
+	}
+	if st := stats[unknownMethod]; st.Shape != unknownMethod || st.Count != 2 {
+		t.Errorf("unknown: %+v", st)
+	}
+	if got := b.WireShapeStats(); len(got) != 0 {
+		t.Errorf("server b has stats from server a: %+v", got)
+	}
+}
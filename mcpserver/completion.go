@@ -0,0 +1,115 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ---------------------- 参数补全 ----------------------
+// completion/complete 为 prompt 参数和资源模板变量提供补全建议，宿主在用户输入时调用：
+//
+//	{"ref": {"type": "ref/prompt", "name": "review"}, "argument": {"name": "language", "value": "py"}}
+//	{"ref": {"type": "ref/resource", "uri": "file:///logs/{date}.log"}, "argument": {"name": "date", "value": "2024-"}}
+//
+// 补全函数设置在 PromptArgument.Complete 和 ResourceTemplate.Complete 上，
+// 没有设置补全函数的参数返回空列表。调用方无权使用该 prompt / 资源模板时返回 -32003
+
+// maxCompletionValues 一次最多返回的补全值，规范的上限
+const maxCompletionValues = 100
+
+// CompletionFunc 给出参数的补全建议：value 为用户已输入的部分，args 为已填写的其他参数（可能为空）。
+// 返回的值按顺序展示，超过 100 个时只返回前 100 个，并告知客户端还有更多
+type CompletionFunc func(ctx context.Context, value string, args map[string]string) ([]string, error)
+
+// CompleteFromList 按前缀（不区分大小写）从固定的候选值中补全
+func CompleteFromList(values ...string) CompletionFunc {
+	return func(ctx context.Context, value string, args map[string]string) ([]string, error) {
+		prefix := strings.ToLower(value)
+		matched := []string{}
+		for _, v := range values {
+			if strings.HasPrefix(strings.ToLower(v), prefix) {
+				matched = append(matched, v)
+			}
+		}
+		return matched, nil
+	}
+}
+
+// completeParams completion/complete 的参数
+type completeParams struct {
+	Ref struct {
+		Type string `json:"type"` // "ref/prompt" / "ref/resource"
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+	Context struct {
+		Arguments map[string]string `json:"arguments"`
+	} `json:"context"`
+}
+
+// complete completion/complete
+func (s *McpServer) complete(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+	var params completeParams
+	if err := json.Unmarshal(c.params, &params); err != nil || params.Argument.Name == "" {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	var fn CompletionFunc
+	switch params.Ref.Type {
+	case "ref/prompt":
+		p, err := GetPrompt(params.Ref.Name)
+		if err != nil {
+			return nil, s.lookupError(err)
+		}
+		if len(s.visibleNames(ctx, c.access, "prompts.get", []string{p.Name})) == 0 {
+			return nil, &RPCError{Code: -32003, Message: "Forbidden"}
+		}
+		found := false
+		for _, a := range p.Arguments {
+			if a.Name == params.Argument.Name {
+				fn, found = a.Complete, true
+				break
+			}
+		}
+		if !found {
+			return nil, &RPCError{Code: -32602, Message: fmt.Sprintf("Prompt %s has no argument %q", p.Name, params.Argument.Name)}
+		}
+	case "ref/resource":
+		t, ok := findResourceTemplate(params.Ref.URI)
+		if !ok {
+			return nil, s.lookupError(fmt.Errorf("%w: %s", ErrResourceNotFound, params.Ref.URI))
+		}
+		if len(s.visibleNames(ctx, c.access, "resources.get", []string{t.Name})) == 0 {
+			return nil, &RPCError{Code: -32003, Message: "Forbidden"}
+		}
+		if !containsString(t.vars, params.Argument.Name) {
+			return nil, &RPCError{Code: -32602, Message: fmt.Sprintf("Template %s has no variable %q", t.URITemplate, params.Argument.Name)}
+		}
+		fn = t.Complete[params.Argument.Name]
+	default:
+		return nil, &RPCError{Code: -32602, Message: fmt.Sprintf("Unsupported ref type %q", params.Ref.Type)}
+	}
+
+	values := []string{}
+	if fn != nil {
+		list, err := fn(ctx, params.Argument.Value, params.Context.Arguments)
+		if err != nil {
+			return nil, s.sanitizeError(-32603, "Internal error", err)
+		}
+		if list != nil {
+			values = list
+		}
+	}
+	completion := map[string]interface{}{"values": values}
+	if len(values) > maxCompletionValues {
+		completion["values"] = values[:maxCompletionValues]
+		completion["total"] = len(values)
+		completion["hasMore"] = true
+	}
+	return map[string]interface{}{"completion": completion}, nil
+}
@@ -0,0 +1,177 @@
+package mcpserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+)
+
+// ---------------------- 内容块压缩 ----------------------
+// 超过 McpConf.CompressThreshold 的内容块在内容层压缩，不依赖传输层（stdio、部分代理后的 WS 没有压缩）。
+// 只有在请求 _meta.acceptContentEncoding 中声明了支持的客户端（mcpclient 会自动声明）才会收到压缩块，
+// 其他客户端看到的结果不变。压缩块带 contentEncoding 字段，数据为压缩后字节的 base64：
+//   - text 块：text 字段；
+//   - image 块：data 字段（压缩前为图片原始字节）；
+//   - resource 块：原来的 resource.text 或 resource.blob 字段。
+// 内置 gzip，zstd 等算法用 RegisterContentEncoding 接入
+
+// ContentEncoder 压缩算法
+type ContentEncoder func(data []byte) ([]byte, error)
+
+var (
+	contentEncoders = map[string]ContentEncoder{"gzip": gzipEncode}
+	contentEncLock  sync.RWMutex
+)
+
+// RegisterContentEncoding 注册压缩算法，如 zstd：
+//
+//	enc, _ := zstd.NewWriter(nil)
+//	mcpserver.RegisterContentEncoding("zstd", func(b []byte) ([]byte, error) {
+//		return enc.EncodeAll(b, nil), nil
+//	})
+//
+// 客户端需要注册同名的解压算法（mcpclient.RegisterContentDecoder）
+func RegisterContentEncoding(name string, enc ContentEncoder) {
+	contentEncLock.Lock()
+	defer contentEncLock.Unlock()
+	contentEncoders[name] = enc
+}
+
+func gzipEncode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WithCompression 设置内容块压缩阈值（字节），0 表示不压缩
+func WithCompression(threshold int) Option {
+	return func(s *McpServer) {
+		s.conf.CompressThreshold = threshold
+	}
+}
+
+// negotiateEncoding 按客户端声明的顺序选第一个服务端支持的算法
+func negotiateEncoding(meta RequestMeta) (string, ContentEncoder) {
+	var accept []string
+	if !meta.Fields.Get("acceptContentEncoding", &accept) {
+		return "", nil
+	}
+	contentEncLock.RLock()
+	defer contentEncLock.RUnlock()
+	for _, name := range accept {
+		if enc, ok := contentEncoders[name]; ok {
+			return name, enc
+		}
+	}
+	return "", nil
+}
+
+// compressResult 压缩结果中超过阈值的内容块，返回副本；不满足条件时原样返回
+func (s *McpServer) compressResult(res *CallToolResult, meta RequestMeta) *CallToolResult {
+	if res == nil || s.conf.CompressThreshold <= 0 {
+		return res
+	}
+	name, enc := negotiateEncoding(meta)
+	if enc == nil {
+		return res
+	}
+	var out *CallToolResult
+	for i, block := range res.Content {
+		compressed, ok := s.compressContent(block, name, enc)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			copied := *res
+			copied.Content = append([]Content(nil), res.Content...)
+			out = &copied
+		}
+		out.Content[i] = compressed
+	}
+	if out == nil {
+		return res
+	}
+	return out
+}
+
+// compressContent 压缩单个内容块，块太小、类型不支持或压缩后没有变小时返回 false
+func (s *McpServer) compressContent(block Content, name string, enc ContentEncoder) (Content, bool) {
+	var raw []byte
+	switch c := block.(type) {
+	case TextContent:
+		raw = []byte(c.Text)
+	case ImageContent:
+		raw, _ = base64.StdEncoding.DecodeString(c.Data)
+	case EmbeddedResource:
+		if c.Resource.Blob != "" {
+			raw, _ = base64.StdEncoding.DecodeString(c.Resource.Blob)
+		} else {
+			raw = []byte(c.Resource.Text)
+		}
+	default:
+		return nil, false
+	}
+	if len(raw) < s.conf.CompressThreshold {
+		return nil, false
+	}
+	packed, err := enc(raw)
+	if err != nil {
+		logf(LevelWarn, "compress content (%s): %v", name, err)
+		return nil, false
+	}
+	encoded := base64.StdEncoding.EncodeToString(packed)
+
+	switch c := block.(type) {
+	case TextContent:
+		// 文本经 base64 后会膨胀，仍要比原文小才值得
+		if len(encoded) >= len(raw) {
+			return nil, false
+		}
+		return encodedContent{Type: "text", Text: encoded, ContentEncoding: name}, true
+	case ImageContent:
+		if len(packed) >= len(raw) {
+			return nil, false
+		}
+		return encodedContent{Type: "image", Data: encoded, MimeType: c.MimeType, ContentEncoding: name}, true
+	case EmbeddedResource:
+		r := c.Resource
+		if r.Blob != "" {
+			if len(packed) >= len(raw) {
+				return nil, false
+			}
+			r.Blob = encoded
+		} else {
+			if len(encoded) >= len(raw) {
+				return nil, false
+			}
+			r.Text = encoded
+		}
+		return encodedContent{Type: "resource", Resource: &r, ContentEncoding: name}, true
+	}
+	return nil, false
+}
+
+// encodedContent 压缩后的内容块
+type encodedContent struct {
+	Type            string            `json:"type"`
+	Text            string            `json:"text,omitempty"`
+	Data            string            `json:"data,omitempty"`
+	MimeType        string            `json:"mimeType,omitempty"`
+	Resource        *ResourceContents `json:"resource,omitempty"`
+	ContentEncoding string            `json:"contentEncoding"`
+}
+
+func (c encodedContent) contentType() string { return c.Type }
+
+func (c encodedContent) MarshalJSON() ([]byte, error) {
+	type plain encodedContent
+	return json.Marshal(plain(c))
+}
@@ -0,0 +1,74 @@
+package mcpserver
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ---------------------- 工具重名 ----------------------
+// 插件、manifest 和代码可能注册同名工具，后注册的默认直接替换前一个。
+// 注册表可以改用其他策略（McpConf.ToolConflict 或 SetConflictPolicy）：
+//   - replace：替换已有工具（默认）；
+//   - error：拒绝注册，Register 返回 ErrToolExists；
+//   - version-suffix：改名为 name_v2、name_v3…… 注册，tool.Name 随之更新。
+
+// 重名策略
+const (
+	ConflictReplace       = "replace"
+	ConflictError         = "error"
+	ConflictVersionSuffix = "version-suffix"
+)
+
+// 注册结果
+const (
+	RegisterAdded    = "added"
+	RegisterReplaced = "replaced"
+	RegisterRenamed  = "renamed"
+	RegisterRejected = "rejected"
+)
+
+// RegisterResult 一次注册的结果
+type RegisterResult struct {
+	// Requested 注册时的工具名，Name 实际注册的名字（改名时两者不同，被拒绝时为空）
+	Requested string `json:"requested"`
+	Name      string `json:"name,omitempty"`
+	Action    string `json:"action"`
+}
+
+// SetConflictPolicy 设置重名策略，空字符串恢复默认（replace）；未知策略返回错误
+func (r *ToolRegistry) SetConflictPolicy(policy string) error {
+	switch policy {
+	case "", ConflictReplace, ConflictError, ConflictVersionSuffix:
+	default:
+		return fmt.Errorf("unknown tool conflict policy %q", policy)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conflict = policy
+	return nil
+}
+
+// resolveConflict 按策略决定工具的注册名，调用时持有写锁
+func (r *ToolRegistry) resolveConflict(tool *Tool) (RegisterResult, error) {
+	res := RegisterResult{Requested: tool.Name, Name: tool.Name, Action: RegisterAdded}
+	if _, exists := r.tools[tool.Name]; !exists {
+		return res, nil
+	}
+	switch r.conflict {
+	case ConflictError:
+		res.Name, res.Action = "", RegisterRejected
+		return res, fmt.Errorf("%w: %s", ErrToolExists, tool.Name)
+	case ConflictVersionSuffix:
+		for n := 2; ; n++ {
+			name := tool.Name + "_v" + strconv.Itoa(n)
+			if _, exists := r.tools[name]; !exists {
+				tool.Name = name
+				res.Name, res.Action = name, RegisterRenamed
+				return res, nil
+			}
+		}
+	default:
+		res.Action = RegisterReplaced
+		return res, nil
+	}
+}
@@ -0,0 +1,102 @@
+package mcpserver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+)
+
+// ---------------------- 内容块 ----------------------
+// 工具结果中的内容块，对应 MCP 规范的 TextContent / ImageContent / EmbeddedResource。
+// 工具可以直接返回 Content、[]Content 或用 NewToolResult 构造的 *CallToolResult
+
+// Content 一个内容块，序列化时自动带上 type 字段
+type Content interface {
+	contentType() string
+}
+
+// TextContent 文本块
+type TextContent struct {
+	Text string `json:"text"`
+}
+
+// ImageContent 图片块，Data 为 base64 编码
+type ImageContent struct {
+	Data     string `json:"data"`
+	MimeType string `json:"mimeType"`
+}
+
+// ResourceContents 嵌入资源的内容，Text 和 Blob（base64）二选一
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// EmbeddedResource 嵌入资源块
+type EmbeddedResource struct {
+	Resource ResourceContents `json:"resource"`
+}
+
+func (TextContent) contentType() string      { return "text" }
+func (ImageContent) contentType() string     { return "image" }
+func (EmbeddedResource) contentType() string { return "resource" }
+
+func (c TextContent) MarshalJSON() ([]byte, error) {
+	type plain TextContent
+	return marshalContent(c.contentType(), plain(c))
+}
+
+func (c ImageContent) MarshalJSON() ([]byte, error) {
+	type plain ImageContent
+	return marshalContent(c.contentType(), plain(c))
+}
+
+func (c EmbeddedResource) MarshalJSON() ([]byte, error) {
+	type plain EmbeddedResource
+	return marshalContent(c.contentType(), plain(c))
+}
+
+// marshalContent 在内容块的字段前加上 "type"
+func marshalContent(typ string, v interface{}) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	head := `{"type":` + strconv.Quote(typ)
+	if len(body) > 2 {
+		head += ","
+	}
+	return append([]byte(head), body[1:]...), nil
+}
+
+// NewTextContent 文本块
+func NewTextContent(text string) TextContent {
+	return TextContent{Text: text}
+}
+
+// NewImageContent 图片块，data 为原始字节
+func NewImageContent(data []byte, mimeType string) ImageContent {
+	return ImageContent{Data: base64.StdEncoding.EncodeToString(data), MimeType: mimeType}
+}
+
+// NewTextResource 文本形式的嵌入资源
+func NewTextResource(uri, mimeType, text string) EmbeddedResource {
+	return EmbeddedResource{Resource: ResourceContents{URI: uri, MimeType: mimeType, Text: text}}
+}
+
+// NewBlobResource 二进制形式的嵌入资源，blob 为原始字节
+func NewBlobResource(uri, mimeType string, blob []byte) EmbeddedResource {
+	return EmbeddedResource{Resource: ResourceContents{URI: uri, MimeType: mimeType, Blob: base64.StdEncoding.EncodeToString(blob)}}
+}
+
+// NewToolResult 用内容块构造工具结果
+func NewToolResult(blocks ...Content) *CallToolResult {
+	return &CallToolResult{Content: blocks}
+}
+
+// NewToolError 工具自身的业务错误：作为 isError 结果返回给模型，而不是 JSON-RPC 错误
+func NewToolError(message string) *CallToolResult {
+	return &CallToolResult{Content: []Content{NewTextContent(message)}, IsError: true}
+}
@@ -0,0 +1,172 @@
+package mcpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"mcptool/mcpctx"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------- 成本核算 ----------------------
+
+// CostKey 成本归集维度：认证主体 / 租户 / API Key / 会话，取自 mcpctx.Caller（见 callerFromRequest）
+type CostKey struct {
+	Principal string `json:"principal,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+	APIKey    string `json:"api_key,omitempty"` // 只保存 key 的指纹，不保存明文
+	Session   string `json:"session,omitempty"`
+}
+
+// CostEntry 某个维度下累计的调用次数和成本
+type CostEntry struct {
+	CostKey
+	Calls     int64              `json:"calls"`
+	CostUnits float64            `json:"cost_units"`
+	ByTool    map[string]float64 `json:"by_tool"`
+
+	lastSeen time.Time // 最近一次调用，过期清理用
+}
+
+var (
+	costLedger = make(map[CostKey]*CostEntry)
+	costLock   sync.Mutex
+)
+
+// RecordCost 累计一次工具调用的成本
+func RecordCost(key CostKey, tool string, units float64) {
+	costLock.Lock()
+	defer costLock.Unlock()
+	e, ok := costLedger[key]
+	if !ok {
+		e = &CostEntry{CostKey: key, ByTool: map[string]float64{}}
+		costLedger[key] = e
+	}
+	e.Calls++
+	e.CostUnits += units
+	e.ByTool[tool] += units
+	e.lastSeen = time.Now()
+}
+
+// CostReport 返回当前累计的成本，按成本从高到低排序
+func CostReport() []CostEntry {
+	costLock.Lock()
+	defer costLock.Unlock()
+	return costEntries(costLedger)
+}
+
+// snapshotAndReset 在同一次加锁中取出并清空成本账本，两者之间入账的调用不会丢失
+func snapshotAndReset() []CostEntry {
+	costLock.Lock()
+	ledger := costLedger
+	costLedger = make(map[CostKey]*CostEntry)
+	costLock.Unlock()
+	return costEntries(ledger)
+}
+
+// costEntries 复制账本条目，按成本从高到低排序；ledger 仍可能被修改时调用方需持有 costLock
+func costEntries(ledger map[CostKey]*CostEntry) []CostEntry {
+	list := []CostEntry{}
+	for _, e := range ledger {
+		byTool := make(map[string]float64, len(e.ByTool))
+		for k, v := range e.ByTool {
+			byTool[k] = v
+		}
+		entry := *e
+		entry.ByTool = byTool
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CostUnits > list[j].CostUnits })
+	return list
+}
+
+// ResetCosts 清空成本账本
+func ResetCosts() {
+	costLock.Lock()
+	defer costLock.Unlock()
+	costLedger = make(map[CostKey]*CostEntry)
+}
+
+// restoreCosts 用备份中的条目替换成本账本，条目按恢复时刻重新计算过期
+func restoreCosts(entries []CostEntry) {
+	now := time.Now()
+	ledger := make(map[CostKey]*CostEntry, len(entries))
+	for i := range entries {
+		e := entries[i]
+		if e.ByTool == nil {
+			e.ByTool = map[string]float64{}
+		}
+		e.lastSeen = now
+		ledger[e.CostKey] = &e
+	}
+	costLock.Lock()
+	defer costLock.Unlock()
+	costLedger = ledger
+}
+
+// apiKeyFingerprint 返回 key 的短指纹，用于账本展示
+func apiKeyFingerprint(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// costKeyOf 调用方和会话对应的成本维度
+func costKeyOf(caller mcpctx.Caller, session string) CostKey {
+	return CostKey{Principal: caller.Principal, Tenant: caller.Tenant, APIKey: caller.APIKey, Session: session}
+}
+
+// costKeyFromRequest 请求的成本维度：调用方与 ACL 看到的相同，开启认证时来自认证结果，
+// 使用 Bearer / JWT 认证的调用方按主体归集
+func (s *McpServer) costKeyFromRequest(r *http.Request, session string) CostKey {
+	if session == "" {
+		session = r.Header.Get("Mcp-Session-Id")
+	}
+	return costKeyOf(s.callerFromRequest(r, ""), session)
+}
+
+// recordToolCost 计算一次调用的成本（注解中的成本 + Tool.Cost 回调）并入账，
+// 返回带最终成本的注解，供响应 meta 使用
+func recordToolCost(reg *ToolRegistry, key CostKey, name string, args json.RawMessage, result interface{}, ann *AnnotatedResult) *AnnotatedResult {
+	var units float64
+	if ann != nil {
+		units = ann.CostUnits
+	}
+	if tool, ok := reg.Get(name); ok && tool.Cost != nil {
+		units += tool.Cost(args, result)
+	}
+	RecordCost(key, name, units)
+	if units == 0 {
+		return ann
+	}
+	if ann == nil {
+		ann = &AnnotatedResult{Value: result}
+	}
+	ann.CostUnits = units
+	return ann
+}
+
+// adminCosts admin.costs 方法：查询成本账本，reset=true 时读取后清空
+func adminCosts(raw json.RawMessage) (interface{}, *RPCError) {
+	var params struct {
+		Reset bool `json:"reset"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+	}
+	var report []CostEntry
+	if params.Reset {
+		report = snapshotAndReset()
+	} else {
+		report = CostReport()
+	}
+	return map[string]interface{}{"costs": report}, nil
+}
@@ -0,0 +1,55 @@
+package mcpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCostKeyFromAuthenticatedCaller(t *testing.T) {
+	s := NewMcpServerWithTools(McpConf{APIKeys: map[string]string{"k1": "ci-bot"}}, NewToolRegistry(),
+		WithPrincipalTenants(map[string]string{"ci-bot": "acme"}))
+
+	var got CostKey
+	h := s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		got = s.costKeyFromRequest(r, "")
+	})
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	r.Header.Set("Authorization", "Bearer k1")
+	r.Header.Set("X-Tenant-ID", "globex")
+	r.Header.Set("Mcp-Session-Id", "sess-1")
+	h(httptest.NewRecorder(), r)
+
+	want := CostKey{Principal: "ci-bot", Tenant: "acme", APIKey: apiKeyFingerprint("k1"), Session: "sess-1"}
+	if got != want {
+		t.Errorf("cost key = %+v, want %+v", got, want)
+	}
+}
+
+func TestSnapshotAndResetKeepsConcurrentCalls(t *testing.T) {
+	ResetCosts()
+	defer ResetCosts()
+	key := CostKey{Principal: "ci-bot"}
+	const calls = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < calls; i++ {
+			RecordCost(key, "weather", 1)
+		}
+	}()
+	var total int64
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		for _, e := range snapshotAndReset() {
+			total += e.Calls
+		}
+	}
+	if total != calls {
+		t.Errorf("counted %d calls across resets, want %d", total, calls)
+	}
+}
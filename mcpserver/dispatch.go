@@ -0,0 +1,342 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"mcptool/mcpctx"
+	"mcptool/version"
+)
+
+// ---------------------- 方法分发 ----------------------
+// 所有传输（HTTP、WS、HTTP+SSE、stdio）把解码后的请求交给 dispatch，由它统一完成：
+// 规范方法名翻译、方法开关与功能开关、授权、调用方法处理函数、规范结果转换和响应 meta。
+// 传输只负责读写消息，并在 ctx 中放入调用方（mcpctx.WithCaller）和所属会话（mcpctx.WithSession）。
+// 自定义方法用 RegisterMethod 注册，与内置方法一样受方法开关和 ACL 控制
+
+// MethodHandler 自定义方法的处理函数，ctx 中带有调用方、会话等信息（见 mcpctx）
+type MethodHandler func(ctx context.Context, params json.RawMessage) (interface{}, *RPCError)
+
+// methodCall 一次方法调用，内置方法可以使用传输提供的会话、成本归集等信息
+type methodCall struct {
+	params  json.RawMessage
+	access  AccessRequest
+	session mcpctx.Session
+	target  notifyTarget // 进度、行流等通知的接收方，没有所属会话时为 nil
+	costKey CostKey
+	ann     *AnnotatedResult // tools.run 的结果注解，用于响应 meta
+	steps   *stepRecorder    // tools.run 中工具记录的子步骤，用于响应 meta
+}
+
+type methodFunc func(ctx context.Context, c *methodCall) (interface{}, *RPCError)
+
+// dispatcher 方法名 -> 处理函数
+type dispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string]methodFunc
+}
+
+func (d *dispatcher) register(name string, fn methodFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.handlers == nil {
+		d.handlers = make(map[string]methodFunc)
+	}
+	d.handlers[name] = fn
+}
+
+func (d *dispatcher) lookup(name string) (methodFunc, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	fn, ok := d.handlers[name]
+	return fn, ok
+}
+
+// RegisterMethod 注册自定义方法，同名时替换（包括内置方法）。新方法默认启用，
+// 可用 SetMethodEnabled 关闭；方法名建议带命名空间，如 "billing.quota"
+func (s *McpServer) RegisterMethod(name string, handler MethodHandler) {
+	s.methodsMu.Lock()
+	if _, ok := s.methods[name]; !ok {
+		s.methods[name] = true
+	}
+	s.methodsMu.Unlock()
+	s.dispatcher.register(name, func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return handler(ctx, c.params)
+	})
+}
+
+// Dispatch 处理一个请求并返回响应，通知返回 nil。供自行实现的传输使用：
+// ctx 中应放入调用方（mcpctx.WithCaller）和所属会话（mcpctx.WithSession，可选）
+func (s *McpServer) Dispatch(ctx context.Context, req RPCRequest) *RPCResponse {
+	caller, _ := mcpctx.CallerFromContext(ctx)
+	key := costKeyOf(caller, "")
+	if session, ok := mcpctx.SessionFromContext(ctx); ok {
+		key.Session = session.ID()
+	}
+	return s.dispatch(ctx, req, key)
+}
+
+// dispatch 分发请求，costKey 为工具调用成本的归集键
+func (s *McpServer) dispatch(ctx context.Context, req RPCRequest, costKey CostKey) *RPCResponse {
+	if isNotification(&req) {
+		// 通知没有响应（如 notifications/initialized）
+		if req.Method == "notifications/roots/list_changed" {
+			s.rootsListChanged(ctx)
+		}
+		return nil
+	}
+	start := time.Now()
+	resp := &RPCResponse{
+		JsonRPC: version.JSONRPC,
+		ID:      req.ID,
+	}
+	ctx = mcpctx.WithRequestID(ctx, requestIDString(req.ID))
+	method := s.translateMethod(req.Method)
+	caller, _ := mcpctx.CallerFromContext(ctx)
+	session, _ := mcpctx.SessionFromContext(ctx)
+	c := &methodCall{
+		params:  req.Params,
+		access:  accessRequest(ctx, caller, session),
+		session: session,
+		costKey: costKey,
+	}
+	c.target, _ = session.(notifyTarget)
+
+	fn, ok := s.dispatcher.lookup(method)
+	tool := requestTool(method, req.Params)
+	switch {
+	case !ok || s.methodGated(method, caller):
+		// 关闭的方法和被功能开关关闭的方法对该调用方不可见，按不存在处理
+		resp.Error = &RPCError{Code: -32601, Message: "Method not found"}
+	case tool != "" && !s.methodEnabled(method, tool):
+		// 按工具关闭（如 "tools.run[name=delete_*]"）与 admin.tools 禁用工具的表现一致
+		resp.Error = &RPCError{Code: -32602, Message: "Tool disabled"}
+	default:
+		// 被策略拒绝时返回 Forbidden
+		if resp.Error = s.authorize(ctx, c.access, method, req.Params); resp.Error == nil {
+			resp.Result, resp.Error = fn(ctx, c)
+		}
+	}
+
+	if resp.Error == nil {
+		resp.Result = protocolResult(protocolOf(ctx), method, resp.Result)
+		if isSpecMethod(req.Method) {
+			resp.Result = specResult(method, resp.Result)
+		}
+	}
+	s.attachMeta(resp, start, c.ann, c.steps)
+	return resp
+}
+
+// requestIDString 请求 id 的文本：字符串 id 去掉引号，数字 id 原样
+func requestIDString(id json.RawMessage) string {
+	var s string
+	if json.Unmarshal(id, &s) == nil {
+		return s
+	}
+	return string(id)
+}
+
+// ---------------------- 内置方法 ----------------------
+
+// registerBuiltinMethods 注册内置方法，开关见 Methods
+func (s *McpServer) registerBuiltinMethods() {
+	d := &s.dispatcher
+	d.register("initialize", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		result, protocol := s.initialize(c.params)
+		recordInitialize(ctx, c.session, c.params, protocol)
+		return result, nil
+	})
+	d.register("ping", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return map[string]interface{}{}, nil
+	})
+	d.register("tools.list", s.paginateList("tools", s.coalesceList("tools.list", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.listTools(ctx, c.access), nil
+	})))
+	d.register("tools.run", s.runTool)
+
+	// resources
+	d.register("resources.get", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		var params struct {
+			Name string `json:"name"`
+			URI  string `json:"uri"` // resources/read 使用 uri
+		}
+		if err := json.Unmarshal(c.params, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+		if params.Name == "" {
+			params.Name = params.URI
+		}
+		r, err := readResource(ctx, params.Name)
+		if err != nil {
+			return nil, s.lookupError(err)
+		}
+		return r, nil
+	})
+	d.register("resources/templates/list", s.listResourceTemplates)
+	d.register("resources/subscribe", s.subscribeResource(true))
+	d.register("resources/unsubscribe", s.subscribeResource(false))
+	d.register("events.subscribe", s.subscribeEvents(true))
+	d.register("events.unsubscribe", s.subscribeEvents(false))
+	d.register("resources.list", s.paginateList("resources", s.coalesceList("resources.list", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		list := listResources(ctx)
+		// 提供者的资源按提供者名称授权
+		names := make([]string, len(list))
+		for i, r := range list {
+			names[i] = resourceName(r["uri"])
+		}
+		visible := make(map[string]bool)
+		for _, name := range s.visibleNames(ctx, c.access, "resources.get", names) {
+			visible[name] = true
+		}
+		kept := list[:0]
+		for i, r := range list {
+			if visible[names[i]] {
+				kept = append(kept, r)
+			}
+		}
+		return map[string]interface{}{"resources": kept}, nil
+	})))
+
+	// prompts
+	d.register("prompts.get", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		var params struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := json.Unmarshal(c.params, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+		p, err := GetPrompt(params.Name)
+		if err != nil {
+			return nil, s.lookupError(err)
+		}
+		rendered, err := s.renderPrompt(ctx, c.access, p, params.Arguments)
+		if err != nil {
+			return nil, s.lookupError(err)
+		}
+		return rendered, nil
+	})
+	d.register("prompts.list", s.paginateList("prompts", s.coalesceList("prompts.list", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return map[string]interface{}{"prompts": s.visiblePrompts(ctx, c.access)}, nil
+	})))
+
+	d.register("completion/complete", s.complete)
+	d.register("logging/setLevel", s.setLogLevel)
+
+	d.register("server.info", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		info := map[string]interface{}{
+			"name":         s.conf.ServerName,
+			"version":      s.conf.ServerVersion,
+			"capabilities": s.capabilities(protocolOf(ctx)),
+			"tools":        s.tools.List(),
+			// 实际启用的传输，见 transports.go
+			"transports": s.transports(),
+		}
+		if s.conf.Instructions != "" {
+			info["instructions"] = s.conf.Instructions
+		}
+		if budget := s.budgetStatus(c.costKey.Session); budget != nil {
+			info["budget"] = budget
+		}
+		return info, nil
+	})
+	d.register("system.describe", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return map[string]interface{}{
+			"description": "This is a JSON-RPC server for MCP.",
+			"version":     version.Library,
+			"methods":     s.EnabledMethods(),
+		}, nil
+	})
+	d.register("system.listMethods", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.EnabledMethods(), nil
+	})
+	d.register("system.version", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		info := version.Current()
+		info.Capabilities = s.capabilities(protocolOf(ctx))
+		return info, nil
+	})
+	d.register("system.stats", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.systemStats(), nil
+	})
+
+	// 管理接口，默认关闭
+	d.register("admin.costs", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return adminCosts(c.params)
+	})
+	d.register("admin.wireStats", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.adminWireStats(c.params)
+	})
+	d.register("admin.tools", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.tools.adminTools(c.params)
+	})
+	d.register("admin.canaries", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.tools.adminCanaries(c.params)
+	})
+	d.register("admin.shadows", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return map[string]interface{}{"shadows": s.tools.ShadowStatuses()}, nil
+	})
+	d.register("admin.backup", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.adminBackup(c.params)
+	})
+	d.register("admin.restore", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.adminRestore(c.params)
+	})
+	d.register("admin.validateConfig", func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		return s.adminValidateConfig(ctx, c.params)
+	})
+}
+
+// runTool tools.run：执行工具，工具失败按 toolError 转换为 RPC 错误或 isError 结果
+func (s *McpServer) runTool(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+		Meta      RequestMeta     `json:"_meta"`
+	}
+	if err := json.Unmarshal(c.params, &params); err != nil {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+	}
+	if rpcErr := s.takeCallBudget(c.costKey.Session); rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	ctx, cancel := withMeta(ctx, params.Meta)
+	defer cancel()
+	ctx = s.withProgress(ctx, params.Meta.ProgressToken, c.target)
+	ctx = s.withClientLogger(ctx, c.target, c.session)
+	ctx = withRowStream(ctx, params.Meta, c.target)
+	ctx = withDistanceCache(ctx, s.distances)
+	ctx, c.steps = s.withSteps(ctx, params.Name)
+	var (
+		result interface{}
+		err    error
+		shared bool
+	)
+	if key, ok := s.toolFlightKey(params.Name, params.Arguments, params.Meta); ok {
+		result, err, shared = s.flights.coalesce(ctx, key, func() (interface{}, error) {
+			return s.callTool(ctx, params.Name, params.Arguments)
+		})
+	} else {
+		result, err = s.callTool(ctx, params.Name, params.Arguments)
+	}
+	if err != nil {
+		rpcErr, failure := s.toolError(err)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return echoMeta(failure, params.Meta), nil
+	}
+	value, ann := unwrapAnnotated(result)
+	if shared {
+		// 共享其他请求的结果：计入调用次数，成本已由执行的那次调用记录
+		RecordCost(c.costKey, params.Name, 0)
+		c.ann = &AnnotatedResult{Value: value, CacheHit: true}
+	} else {
+		c.ann = recordToolCost(s.tools, c.costKey, params.Name, params.Arguments, value, ann)
+	}
+	s.pushSuggestions(ctx, c, params.Name)
+	return s.compressResult(echoMeta(toolCallResult(s.tools, params.Name, value), params.Meta), params.Meta), nil
+}
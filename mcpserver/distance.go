@@ -0,0 +1,299 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------- 距离矩阵 ----------------------
+
+const (
+	// 矩阵尺寸限制
+	distanceMatrixMaxOrigins      = 25
+	distanceMatrixMaxDestinations = 25
+	distanceMatrixMaxCells        = 100
+	// 单次 provider 调用最多携带的起点数
+	distanceMatrixProviderBatch = 10
+	// 单元格缓存有效期和每个服务最多缓存的单元格数
+	distanceMatrixCacheTTL  = 10 * time.Minute
+	distanceMatrixCacheSize = 10000
+)
+
+type DistanceMatrixToolInput struct {
+	Origins      []string `json:"origins" jsonschema:"minItems=1,maxItems=25"`
+	Destinations []string `json:"destinations" jsonschema:"minItems=1,maxItems=25"`
+	Mode         string   `json:"mode,omitempty"`
+}
+
+// DistanceCell 矩阵中的一个单元格（某个起点到某个终点）
+type DistanceCell struct {
+	Origin          string `json:"origin"`
+	Destination     string `json:"destination"`
+	DistanceMeters  int    `json:"distance_meters"`
+	DurationSeconds int    `json:"duration_seconds"`
+	Error           string `json:"error,omitempty"`
+}
+
+// DistanceMatrixResult rows[i][j] 对应 origins[i] -> destinations[j]
+type DistanceMatrixResult struct {
+	Origins      []string         `json:"origins"`
+	Destinations []string         `json:"destinations"`
+	Mode         string           `json:"mode"`
+	Rows         [][]DistanceCell `json:"rows"`
+	CachedCells  int              `json:"cached_cells"`
+}
+
+// DistanceMatrixProvider 可选接口，GeoProvider 实现它即可支持距离矩阵
+type DistanceMatrixProvider interface {
+	DistanceMatrix(ctx context.Context, origins, destinations []string, mode string) ([][]DistanceCell, error)
+}
+
+func (mockGeoProvider) DistanceMatrix(_ context.Context, origins, destinations []string, mode string) ([][]DistanceCell, error) {
+	rows := make([][]DistanceCell, len(origins))
+	for i, o := range origins {
+		rows[i] = make([]DistanceCell, len(destinations))
+		for j, d := range destinations {
+			meters := 1000 * (len(o) + len(d) + 1)
+			rows[i][j] = DistanceCell{
+				Origin:          o,
+				Destination:     d,
+				DistanceMeters:  meters,
+				DurationSeconds: meters / 10,
+			}
+		}
+	}
+	return rows, nil
+}
+
+// ---------------------- 单元格缓存 ----------------------
+// 每个服务一份（McpServer.distances），工具通过 ctx 找到所属服务的缓存；不经过服务直接调用工具时不缓存。
+// 条目的有效期相同，写入顺序即过期顺序：超过上限时淘汰最早写入的条目，后台清理（janitor.go）从队首删除过期条目
+
+type distanceCacheEntry struct {
+	cell    DistanceCell
+	expires time.Time
+	seq     uint64 // 写入序号，区分同一个 key 的新旧写入
+}
+
+// distanceCacheItem 写入队列中的一项，对应条目已被覆盖或删除时跳过
+type distanceCacheItem struct {
+	key string
+	seq uint64
+}
+
+type distanceCache struct {
+	mu      sync.Mutex
+	max     int
+	seq     uint64
+	entries map[string]distanceCacheEntry
+	order   []distanceCacheItem
+}
+
+func newDistanceCache(max int) *distanceCache {
+	return &distanceCache{max: max, entries: make(map[string]distanceCacheEntry)}
+}
+
+type distanceCacheKey struct{}
+
+// withDistanceCache 在 ctx 中放入服务的距离缓存
+func withDistanceCache(ctx context.Context, c *distanceCache) context.Context {
+	return context.WithValue(ctx, distanceCacheKey{}, c)
+}
+
+// distanceCacheFrom ctx 中的距离缓存，没有时返回 nil（不缓存）
+func distanceCacheFrom(ctx context.Context) *distanceCache {
+	c, _ := ctx.Value(distanceCacheKey{}).(*distanceCache)
+	return c
+}
+
+func distanceKey(origin, destination, mode string) string {
+	return origin + "\x00" + destination + "\x00" + mode
+}
+
+func (c *distanceCache) get(origin, destination, mode string) (DistanceCell, bool) {
+	if c == nil {
+		return DistanceCell{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := distanceKey(origin, destination, mode)
+	e, ok := c.entries[key]
+	if !ok {
+		return DistanceCell{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return DistanceCell{}, false
+	}
+	return e.cell, true
+}
+
+func (c *distanceCache) put(cell DistanceCell, mode string) {
+	// 失败的单元格不缓存，下次重试
+	if c == nil || cell.Error != "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := distanceKey(cell.Origin, cell.Destination, mode)
+	c.seq++
+	c.entries[key] = distanceCacheEntry{cell: cell, expires: time.Now().Add(distanceMatrixCacheTTL), seq: c.seq}
+	c.order = append(c.order, distanceCacheItem{key: key, seq: c.seq})
+	for len(c.entries) > c.max {
+		c.popLocked()
+	}
+	// 反复覆盖同一批 key 时队列中的旧项会累积，超过上限的两倍时重建
+	if len(c.order) > 2*c.max {
+		kept := make([]distanceCacheItem, 0, len(c.entries))
+		for _, item := range c.order {
+			if e, ok := c.entries[item.key]; ok && e.seq == item.seq {
+				kept = append(kept, item)
+			}
+		}
+		c.order = kept
+	}
+}
+
+// popLocked 删除队首对应的条目（如果还是那次写入），返回是否删除了条目
+func (c *distanceCache) popLocked() bool {
+	item := c.order[0]
+	c.order = c.order[1:]
+	if e, ok := c.entries[item.key]; ok && e.seq == item.seq {
+		delete(c.entries, item.key)
+		return true
+	}
+	return false
+}
+
+// expire 从队首删除过期的条目，返回删除的条数
+func (c *distanceCache) expire(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for len(c.order) > 0 {
+		item := c.order[0]
+		if e, ok := c.entries[item.key]; ok && e.seq == item.seq && !now.After(e.expires) {
+			break
+		}
+		if c.popLocked() {
+			removed++
+		}
+	}
+	return removed
+}
+
+func (c *distanceCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// ---------------------- 工具逻辑 ----------------------
+
+// handleDistanceMatrix 返回的结果带注解：全部命中缓存时 CacheHit=true，CostUnits 为向 provider 请求的单元格数
+func handleDistanceMatrix(ctx context.Context, input DistanceMatrixToolInput) (*AnnotatedResult, error) {
+	provider, ok := geoProvider.(DistanceMatrixProvider)
+	if !ok {
+		return nil, fmt.Errorf("geo provider does not support distance matrix")
+	}
+	if len(input.Origins) == 0 || len(input.Destinations) == 0 {
+		return nil, fmt.Errorf("origins and destinations are required")
+	}
+	if len(input.Origins) > distanceMatrixMaxOrigins || len(input.Destinations) > distanceMatrixMaxDestinations {
+		return nil, fmt.Errorf("matrix too large: max %d origins and %d destinations", distanceMatrixMaxOrigins, distanceMatrixMaxDestinations)
+	}
+	if n := len(input.Origins) * len(input.Destinations); n > distanceMatrixMaxCells {
+		return nil, fmt.Errorf("matrix too large: %d cells (max %d)", n, distanceMatrixMaxCells)
+	}
+	if input.Mode == "" {
+		input.Mode = "driving"
+	}
+
+	result := &DistanceMatrixResult{
+		Origins:      input.Origins,
+		Destinations: input.Destinations,
+		Mode:         input.Mode,
+		Rows:         make([][]DistanceCell, len(input.Origins)),
+	}
+
+	// 先查缓存，按起点记录缺失的终点
+	cache := distanceCacheFrom(ctx)
+	var missingOrigins []string
+	missingDests := make(map[string][]string)
+	missingCells := make(map[string]bool)
+	for i, o := range input.Origins {
+		result.Rows[i] = make([]DistanceCell, len(input.Destinations))
+		for j, d := range input.Destinations {
+			if cell, ok := cache.get(o, d, input.Mode); ok {
+				result.Rows[i][j] = cell
+				result.CachedCells++
+				continue
+			}
+			key := distanceKey(o, d, input.Mode)
+			if missingCells[key] {
+				continue
+			}
+			missingCells[key] = true
+			if _, ok := missingDests[o]; !ok {
+				missingOrigins = append(missingOrigins, o)
+			}
+			missingDests[o] = append(missingDests[o], d)
+		}
+	}
+
+	// 缺失终点相同的起点合为一组，每组按起点分批调用 provider，只请求缺失的单元格
+	var groups [][]string
+	groupOf := make(map[string]int)
+	for _, o := range missingOrigins {
+		sig := strings.Join(missingDests[o], "\x00")
+		g, ok := groupOf[sig]
+		if !ok {
+			g = len(groups)
+			groupOf[sig] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], o)
+	}
+	fetched := make(map[string]DistanceCell)
+	for _, origins := range groups {
+		dests := missingDests[origins[0]]
+		for start := 0; start < len(origins); start += distanceMatrixProviderBatch {
+			end := start + distanceMatrixProviderBatch
+			if end > len(origins) {
+				end = len(origins)
+			}
+			rows, err := provider.DistanceMatrix(ctx, origins[start:end], dests, input.Mode)
+			if err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				for _, cell := range row {
+					fetched[distanceKey(cell.Origin, cell.Destination, input.Mode)] = cell
+					cache.put(cell, input.Mode)
+				}
+			}
+		}
+	}
+
+	for i, o := range input.Origins {
+		for j, d := range input.Destinations {
+			if result.Rows[i][j].Origin != "" {
+				continue
+			}
+			if cell, ok := fetched[distanceKey(o, d, input.Mode)]; ok {
+				result.Rows[i][j] = cell
+			} else {
+				result.Rows[i][j] = DistanceCell{Origin: o, Destination: d, Error: "no result from provider"}
+			}
+		}
+	}
+	// 成本按向 provider 请求的单元格计算
+	return &AnnotatedResult{
+		Value:     result,
+		CacheHit:  len(missingCells) == 0,
+		CostUnits: float64(len(missingCells)),
+	}, nil
+}
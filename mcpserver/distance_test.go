@@ -0,0 +1,104 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordingGeoProvider 记录 DistanceMatrix 的每次调用
+type recordingGeoProvider struct {
+	mockGeoProvider
+	mu    sync.Mutex
+	calls [][2][]string
+}
+
+func (p *recordingGeoProvider) DistanceMatrix(ctx context.Context, origins, destinations []string, mode string) ([][]DistanceCell, error) {
+	p.mu.Lock()
+	p.calls = append(p.calls, [2][]string{append([]string(nil), origins...), append([]string(nil), destinations...)})
+	p.mu.Unlock()
+	return p.mockGeoProvider.DistanceMatrix(ctx, origins, destinations, mode)
+}
+
+func TestDistanceMatrixFetchesOnlyMissingCells(t *testing.T) {
+	provider := &recordingGeoProvider{}
+	old := geoProvider
+	SetGeoProvider(provider)
+	defer SetGeoProvider(old)
+
+	cache := newDistanceCache(distanceMatrixCacheSize)
+	ctx := withDistanceCache(context.Background(), cache)
+	// 预先缓存 A->X、B->X、C->X、C->Y
+	for _, pair := range [][2]string{{"A", "X"}, {"B", "X"}, {"C", "X"}, {"C", "Y"}} {
+		cache.put(DistanceCell{Origin: pair[0], Destination: pair[1], DistanceMeters: 1}, "driving")
+	}
+
+	res, err := handleDistanceMatrix(ctx, DistanceMatrixToolInput{
+		Origins:      []string{"A", "B", "C", "D"},
+		Destinations: []string{"X", "Y", "Z"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2][]string{
+		{{"A", "B"}, {"Y", "Z"}},
+		{{"C"}, {"Z"}},
+		{{"D"}, {"X", "Y", "Z"}},
+	}
+	if !reflect.DeepEqual(provider.calls, want) {
+		t.Errorf("provider calls = %v, want %v", provider.calls, want)
+	}
+	// 12 个单元格中 4 个命中缓存
+	if res.CostUnits != 8 {
+		t.Errorf("CostUnits = %v, want 8", res.CostUnits)
+	}
+	matrix := res.Value.(*DistanceMatrixResult)
+	if matrix.CachedCells != 4 {
+		t.Errorf("CachedCells = %d, want 4", matrix.CachedCells)
+	}
+	for i, row := range matrix.Rows {
+		for j, cell := range row {
+			if cell.Origin != matrix.Origins[i] || cell.Destination != matrix.Destinations[j] || cell.Error != "" {
+				t.Errorf("cell [%d][%d] = %+v", i, j, cell)
+			}
+		}
+	}
+
+	// 再次请求全部命中缓存
+	provider.calls = nil
+	res, err = handleDistanceMatrix(ctx, DistanceMatrixToolInput{
+		Origins:      []string{"A", "B", "C", "D"},
+		Destinations: []string{"X", "Y", "Z"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.calls) != 0 || res.CostUnits != 0 || !res.CacheHit {
+		t.Errorf("second request: calls %v, cost %v, cache hit %v", provider.calls, res.CostUnits, res.CacheHit)
+	}
+}
+
+func TestDistanceMatrixBatchesOrigins(t *testing.T) {
+	provider := &recordingGeoProvider{}
+	old := geoProvider
+	SetGeoProvider(provider)
+	defer SetGeoProvider(old)
+
+	var origins []string
+	for i := 0; i < distanceMatrixProviderBatch+5; i++ {
+		origins = append(origins, fmt.Sprintf("O%d", i))
+	}
+	ctx := withDistanceCache(context.Background(), newDistanceCache(distanceMatrixCacheSize))
+	res, err := handleDistanceMatrix(ctx, DistanceMatrixToolInput{Origins: origins, Destinations: []string{"X", "Y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.calls) != 2 || len(provider.calls[0][0]) != distanceMatrixProviderBatch || len(provider.calls[1][0]) != 5 {
+		t.Errorf("provider calls = %v", provider.calls)
+	}
+	if res.CostUnits != float64(2*len(origins)) {
+		t.Errorf("CostUnits = %v, want %d", res.CostUnits, 2*len(origins))
+	}
+}
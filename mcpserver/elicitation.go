@@ -0,0 +1,119 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mcptool/jsonschema"
+	"mcptool/version"
+)
+
+// ---------------------- Elicitation ----------------------
+// 工具执行中途可以通过 elicitation/create 请用户补充信息：客户端按 schema 向用户展示表单，
+// 用户提交（accept）、拒绝（decline）或关闭（cancel）后工具继续执行：
+//
+//	res, err := mcpserver.Elicit(ctx, "Which branch should be deployed?", json.RawMessage(`{
+//		"type": "object",
+//		"properties": {"branch": {"type": "string"}, "force": {"type": "boolean"}},
+//		"required": ["branch"]
+//	}`))
+//	if err != nil {
+//		return nil, err
+//	}
+//	if !res.Accepted() {
+//		return mcpserver.NewToolError("deployment cancelled by user"), nil
+//	}
+//	var answer struct {
+//		Branch string `json:"branch"`
+//		Force  bool   `json:"force"`
+//	}
+//	res.Decode(&answer)
+//
+// 按规范 schema 为 type 为 object、属性为基本类型的扁平对象。用户提交的内容按 schema 校验，不符合时返回错误。
+// 与 sampling 一样只能在 WS、stdio 会话中使用，协商的协议版本早于 2025-06-18 时返回 ErrElicitationUnsupported
+
+// ErrElicitationUnsupported 当前会话不支持 elicitation
+var ErrElicitationUnsupported = errors.New("client does not support elicitation")
+
+// 用户对 elicitation 的处理
+const (
+	ElicitAccept  = "accept"
+	ElicitDecline = "decline"
+	ElicitCancel  = "cancel"
+)
+
+// ElicitResult 用户的回答
+type ElicitResult struct {
+	Action  string          `json:"action"`
+	Content json.RawMessage `json:"content,omitempty"` // Action 为 accept 时用户填写的对象
+}
+
+// Accepted 用户是否提交了表单
+func (r *ElicitResult) Accepted() bool {
+	return r.Action == ElicitAccept
+}
+
+// Decode 把用户填写的内容解码到 v
+func (r *ElicitResult) Decode(v interface{}) error {
+	if !r.Accepted() {
+		return fmt.Errorf("elicitation %s by user", r.Action)
+	}
+	return json.Unmarshal(r.Content, v)
+}
+
+// Elicit 向当前会话的客户端发送 elicitation/create，等待用户回答
+func Elicit(ctx context.Context, message string, schema json.RawMessage) (*ElicitResult, error) {
+	parsed, err := elicitSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	if !clientCapability(ctx, "elicitation") || !version.Has(protocolOf(ctx), version.FeatureElicitation) {
+		return nil, ErrElicitationUnsupported
+	}
+	params := map[string]interface{}{
+		"message":         message,
+		"requestedSchema": schema,
+	}
+	var res ElicitResult
+	if err := callClient(ctx, "elicitation/create", params, &res); err != nil {
+		if errors.Is(err, ErrClientRequestUnsupported) {
+			return nil, fmt.Errorf("%w: %v", ErrElicitationUnsupported, err)
+		}
+		return nil, err
+	}
+	switch res.Action {
+	case ElicitAccept:
+		if err := jsonschema.ValidateJSON(parsed, res.Content); err != nil {
+			// 不包装 ValidationError：这是客户端回答的问题，不是调用方参数的问题（-32602）
+			return nil, fmt.Errorf("elicitation: invalid answer: %v", err)
+		}
+	case ElicitDecline, ElicitCancel:
+		res.Content = nil
+	default:
+		return nil, fmt.Errorf("elicitation: invalid action %q", res.Action)
+	}
+	return &res, nil
+}
+
+// elicitSchema 检查 schema 是否为规范允许的扁平对象
+func elicitSchema(schema json.RawMessage) (jsonschema.Schema, error) {
+	parsed, err := jsonschema.Parse(schema)
+	if err != nil {
+		return nil, fmt.Errorf("elicitation: %w", err)
+	}
+	if parsed["type"] != "object" {
+		return nil, errors.New(`elicitation: schema type must be "object"`)
+	}
+	props, _ := parsed["properties"].(map[string]interface{})
+	for name, p := range props {
+		prop, _ := p.(map[string]interface{})
+		switch prop["type"] {
+		case "string", "number", "integer", "boolean":
+		default:
+			return nil, fmt.Errorf("elicitation: property %q must be a string, number, integer or boolean", name)
+		}
+	}
+	return parsed, nil
+}
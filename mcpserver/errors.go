@@ -0,0 +1,97 @@
+package mcpserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"mcptool/jsonschema"
+	"mcptool/version"
+)
+
+// ---------------------- 错误脱敏 ----------------------
+
+var (
+	ErrToolNotFound     = errors.New("tool not found")
+	ErrToolDisabled     = errors.New("tool disabled")
+	ErrToolExists       = errors.New("tool already registered")
+	ErrResourceNotFound = errors.New("resource not found")
+	ErrPromptNotFound   = errors.New("prompt not found")
+	ErrPromptArguments  = errors.New("invalid prompt arguments")
+	ErrForbidden        = errors.New("forbidden")
+)
+
+func newErrorID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sanitizeError 把内部错误转换为对外的 RPCError：
+// 完整错误连同错误 ID 写入日志，客户端只拿到分类信息和错误 ID（Data.error_id），
+// McpConf.ExposeErrors 为 true 时（开发环境）额外返回原始错误（Data.detail）
+func (s *McpServer) sanitizeError(code int, message string, err error) *RPCError {
+	id := newErrorID()
+	logf(LevelError, "[error %s] %s: %v", id, message, err)
+	data := map[string]interface{}{"error_id": id}
+	if s.conf.ExposeErrors {
+		data["detail"] = err.Error()
+	}
+	return &RPCError{Code: code, Message: message, Data: data}
+}
+
+// toolError 按 MCP 规范区分工具调用的两类错误：
+// 工具不存在、被禁用、参数不符合 schema 属于协议错误，返回 RPCError；
+// 工具已经执行但失败属于执行错误，返回 isError 结果，模型可以据此调整后重试。
+// 执行错误的详情同样按 ExposeErrors 脱敏，需要把具体原因告诉模型时工具应返回 NewToolError
+func (s *McpServer) toolError(err error) (*RPCError, *CallToolResult) {
+	if errors.Is(err, ErrToolNotFound) {
+		return s.sanitizeError(-32602, "Unknown tool", err), nil
+	}
+	// 禁用是运维操作，明确告诉调用方而不是当成内部错误
+	if errors.Is(err, ErrToolDisabled) {
+		return &RPCError{Code: -32602, Message: "Tool disabled"}, nil
+	}
+	// 参数校验错误是调用方自己的问题，直接把不符合 schema 的位置告诉客户端
+	var verr *jsonschema.ValidationError
+	if errors.As(err, &verr) {
+		return &RPCError{Code: -32602, Message: "Invalid params", Data: map[string]interface{}{"problems": verr.Problems}}, nil
+	}
+	rpcErr := s.sanitizeError(-32603, "Tool execution failed", err)
+	data := rpcErr.Data.(map[string]interface{})
+	text := fmt.Sprintf("Tool execution failed (error_id %s)", data["error_id"])
+	if detail, ok := data["detail"]; ok {
+		text += ": " + detail.(string)
+	}
+	return nil, NewToolError(text)
+}
+
+// lookupError 资源、提示词等查询类错误
+func (s *McpServer) lookupError(err error) *RPCError {
+	switch {
+	case errors.Is(err, ErrResourceNotFound):
+		return s.sanitizeError(-32601, "Resource not found", err)
+	case errors.Is(err, ErrPromptNotFound):
+		return s.sanitizeError(-32601, "Prompt not found", err)
+	case errors.Is(err, ErrPromptArguments):
+		// 参数问题是调用方自己的，直接告诉客户端缺少或多出了哪些参数
+		return &RPCError{Code: -32602, Message: "Invalid params", Data: map[string]interface{}{"problems": []string{err.Error()}}}
+	case errors.Is(err, ErrForbidden):
+		return &RPCError{Code: -32003, Message: "Forbidden"}
+	default:
+		return s.sanitizeError(-32603, "Internal error", err)
+	}
+}
+
+// writeParseError 请求体无法解析时返回 JSON-RPC Parse error，不回显请求内容
+func (s *McpServer) writeParseError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(RPCResponse{
+		JsonRPC: version.JSONRPC,
+		Error:   s.sanitizeError(-32700, "Parse error", err),
+	})
+}
@@ -0,0 +1,197 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ---------------------- 事件总线 ----------------------
+// 服务端主动推送的通知都经 EventBus 发出：发布方调用 server.Notify(method, params)，通知先进入优先级队列
+// （合并、优先级见 notify.go），再由总线以同一个事件序号发给本服务的所有连接：
+//   - SSEPath 的订阅者：事件名为 method；
+//   - WS、HTTP+SSE、Streamable HTTP、stdio 会话：JSON-RPC 通知；
+//   - 进程内订阅者（EventBus.Subscribe），用于自行实现的传输、审计等。
+// 只面向 SSEPath 订阅者（如浏览器看板）的应用事件用 server.Broadcast(event, data)，不作为 JSON-RPC 通知发给会话。
+// 通知的方法名即主题（topic）。连接默认接收全部主题，也可以只订阅部分：SSE 订阅者在 URL 中带
+// ?topics=notifications/tools/*,custom/*（旧名 ?events=），会话调用 events.subscribe（{"topics": ["notifications/tools/*"]}）
+// 增加订阅、events.unsubscribe 取消订阅，取消全部后恢复接收全部主题。
+// 主题按 glob（path.Match）匹配，不影响发给某个请求的进度等通知
+
+// EventBus 通知总线，见 McpServer.Events
+type EventBus struct {
+	server *McpServer // 通知只发给该服务的连接
+	mu     sync.RWMutex
+	subs   map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	filter eventFilter
+	fn     func(method string, params interface{})
+}
+
+func newEventBus(s *McpServer) *EventBus {
+	return &EventBus{server: s, subs: make(map[*eventSubscriber]struct{})}
+}
+
+// Subscribe 在进程内订阅通知，methods 为方法名的 glob，为空表示全部；返回取消订阅的函数。
+// fn 在发送 goroutine 中依次调用，不能阻塞
+func (b *EventBus) Subscribe(methods []string, fn func(method string, params interface{})) (func(), error) {
+	filter, err := parseEventFilter(methods)
+	if err != nil {
+		return nil, err
+	}
+	sub := &eventSubscriber{filter: filter, fn: fn}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		delete(b.subs, sub)
+		b.mu.Unlock()
+	}, nil
+}
+
+// deliver 通知队列的出口：本服务各传输的连接和进程内订阅者使用同一个事件序号。
+// 同一进程内的其他服务有各自的总线和连接，收不到这里的通知
+func (b *EventBus) deliver(method string, params interface{}) {
+	s := b.server
+	stamp := NextEventStamp()
+	s.sendSSE(stamp, method, params)
+	s.sendWS(stamp, method, params)
+	s.sendInspector(stamp, method, params)
+	s.sendStreamable(stamp, method, params)
+	s.sendStdio(stamp, method, params)
+
+	b.mu.RLock()
+	subs := make([]*eventSubscriber, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.mu.RUnlock()
+	for _, sub := range subs {
+		if sub.filter.match(method) {
+			sub.fn(method, params)
+		}
+	}
+}
+
+// Events 服务的通知总线
+func (s *McpServer) Events() *EventBus {
+	return s.bus
+}
+
+// Notify 向本服务的所有连接发布一条通知，优先级按 method 推断
+func (s *McpServer) Notify(method string, params interface{}) {
+	s.notifier.Push(&notification{
+		Method:   method,
+		Params:   params,
+		Priority: notificationPriority(method),
+	})
+}
+
+// Broadcast 向 SSEPath 的订阅者推送一个事件，事件名为 event，data 需可以 JSON 编码；
+// 与其他事件共用事件序号和补发缓冲，按订阅者的 ?topics= 过滤。要同时发给会话请用 Notify
+func (s *McpServer) Broadcast(event string, data interface{}) {
+	s.sendSSE(NextEventStamp(), event, data)
+}
+
+// eventFilter 连接订阅的方法名 glob，为空表示全部
+type eventFilter []string
+
+// parseEventFilter 检查 glob 语法，去掉空白项
+func parseEventFilter(patterns []string) (eventFilter, error) {
+	var f eventFilter
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid event pattern %q: %w", p, err)
+		}
+		f = append(f, p)
+	}
+	return f, nil
+}
+
+func (f eventFilter) match(method string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, p := range f {
+		if ok, _ := path.Match(p, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// with 加入 add 中尚未包含的规则
+func (f eventFilter) with(add eventFilter) eventFilter {
+	for _, p := range add {
+		if !f.has(p) {
+			f = append(f, p)
+		}
+	}
+	return f
+}
+
+// without 去掉 remove 中的规则，结果为空时返回 nil（接收全部）
+func (f eventFilter) without(remove eventFilter) eventFilter {
+	var out eventFilter
+	for _, p := range f {
+		if !remove.has(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (f eventFilter) has(pattern string) bool {
+	for _, p := range f {
+		if p == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribeEvents events.subscribe / events.unsubscribe：增加或取消会话订阅的主题，返回生效的主题列表
+// （空列表表示接收全部）。参数 topics 为主题的 glob，旧名 methods 仍可使用；取消订阅时不带 topics 表示全部取消
+func (s *McpServer) subscribeEvents(on bool) methodFunc {
+	return func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		var params struct {
+			Topics  []string `json:"topics"`
+			Methods []string `json:"methods"`
+		}
+		if len(c.params) > 0 {
+			if err := json.Unmarshal(c.params, &params); err != nil {
+				return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+			}
+		}
+		filter, err := parseEventFilter(append(params.Topics, params.Methods...))
+		if err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: err.Error()}
+		}
+		if on && len(filter) == 0 {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: "topics is required"}
+		}
+		st := stateOf(c.session)
+		if st == nil {
+			return nil, &RPCError{Code: -32602, Message: "Subscriptions require a session"}
+		}
+		if on {
+			filter = st.addEvents(filter)
+		} else {
+			filter = st.removeEvents(filter)
+		}
+		if filter == nil {
+			filter = eventFilter{}
+		}
+		return map[string]interface{}{"topics": filter}, nil
+	}
+}
@@ -0,0 +1,75 @@
+package mcpserver
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ---------------------- 事件序号 ----------------------
+// 服务端发出的每个事件（SSE 事件、WS / SSE 通知）都带一个全局单调递增的序号和时间戳，
+// 消费方可以跨传输方式对事件全排序，并根据序号是否连续发现丢失的事件。
+// 只发给单个 WS 会话的进度通知也占用序号，因此某个连接看到的序号跳跃不一定是丢失，
+// 需要严格检测丢失时应结合 WS 断线恢复（见 ResumeWindow）。
+// 审计日志、历史查询等以后的事件来源也应通过 NextEventStamp 取号。
+
+// EventStamp 事件的序号与时间
+type EventStamp struct {
+	// Epoch 序号所属的纪元，进程启动时生成；纪元变化说明服务重启过，序号重新从 1 开始
+	Epoch string `json:"epoch"`
+	// Seq 纪元内从 1 开始连续递增
+	Seq uint64 `json:"seq"`
+	// Time 墙上时间，同一纪元内不会回退（系统时钟回拨时沿用上一个事件的时间）
+	Time time.Time `json:"ts"`
+}
+
+// ID 形如 "<epoch>-<seq>"，用作 SSE 的 id 字段
+func (e EventStamp) ID() string {
+	return e.Epoch + "-" + strconv.FormatUint(e.Seq, 10)
+}
+
+type eventSequencer struct {
+	mu    sync.Mutex
+	epoch string
+	seq   uint64
+	last  time.Time
+}
+
+var events = &eventSequencer{epoch: strconv.FormatInt(time.Now().UnixNano(), 36)}
+
+// NextEventStamp 为一个新事件取号
+func NextEventStamp() EventStamp {
+	return events.next()
+}
+
+func (q *eventSequencer) next() EventStamp {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now().UTC()
+	if now.Before(q.last) {
+		now = q.last
+	}
+	q.last = now
+	q.seq++
+	return EventStamp{Epoch: q.epoch, Seq: q.seq, Time: now}
+}
+
+// stampPayload 把事件序号放进 JSON 对象的 _meta.event（MCP 通知的 params 允许携带 _meta），
+// 保留已有的 _meta 字段；payload 为空或 null 时生成只含 _meta 的对象，数组等其他值原样返回
+func stampPayload(payload []byte, stamp EventStamp) json.RawMessage {
+	obj := map[string]json.RawMessage{}
+	if len(payload) > 0 && string(payload) != "null" {
+		if err := json.Unmarshal(payload, &obj); err != nil || obj == nil {
+			return payload
+		}
+	}
+	meta := map[string]json.RawMessage{}
+	if raw, ok := obj["_meta"]; ok {
+		json.Unmarshal(raw, &meta)
+	}
+	meta["event"], _ = json.Marshal(stamp)
+	obj["_meta"], _ = json.Marshal(meta)
+	out, _ := json.Marshal(obj)
+	return out
+}
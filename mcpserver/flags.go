@@ -0,0 +1,156 @@
+package mcpserver
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 功能开关 ----------------------
+// FlagProvider 按调用方（租户 / API Key）求值功能开关。工具通过 mcpctx.FlagsFromContext 读取，
+// 服务端用 McpConf.MethodFlags 按开关隐藏实验性方法，切换行为不需要重新部署。
+// 每个服务使用自己的数据源（McpConf.Flags 或 WithFlagProvider），都没有配置时使用 SetFlagProvider 设置的默认数据源。
+// 租户和 API Key 只取自认证结果（见 callerFromRequest），未认证的请求按匿名调用方求值
+
+// FlagProvider 功能开关的数据源
+type FlagProvider interface {
+	Enabled(flag string, caller mcpctx.Caller) bool
+}
+
+var (
+	flagProvider     FlagProvider = StaticFlags(nil)
+	flagProviderLock sync.RWMutex
+)
+
+// SetFlagProvider 设置默认的功能开关数据源，用于没有配置自己的数据源的服务
+func SetFlagProvider(p FlagProvider) {
+	flagProviderLock.Lock()
+	defer flagProviderLock.Unlock()
+	flagProvider = p
+}
+
+// FlagEnabled 用默认数据源对指定调用方求值开关
+func FlagEnabled(flag string, caller mcpctx.Caller) bool {
+	flagProviderLock.RLock()
+	p := flagProvider
+	flagProviderLock.RUnlock()
+	return p != nil && p.Enabled(flag, caller)
+}
+
+// WithFlagProvider 设置本服务的功能开关数据源，优先于 McpConf.Flags
+func WithFlagProvider(p FlagProvider) Option {
+	return func(s *McpServer) {
+		s.flags = p
+	}
+}
+
+// flagEnabled 用本服务的数据源对调用方求值开关
+func (s *McpServer) flagEnabled(flag string, caller mcpctx.Caller) bool {
+	caller = flagCaller(caller)
+	if s.flags == nil {
+		return FlagEnabled(flag, caller)
+	}
+	return s.flags.Enabled(flag, caller)
+}
+
+// flagCaller 求值开关时使用的调用方。未认证的请求的租户和 API Key 来自请求头，可以随意声明，
+// 不用于名单和灰度分桶
+func flagCaller(caller mcpctx.Caller) mcpctx.Caller {
+	if caller.Principal == "" {
+		caller.Tenant, caller.APIKey = "", ""
+	}
+	return caller
+}
+
+// callerFlags 绑定了调用方的 mcpctx.Flags
+type callerFlags struct {
+	server *McpServer
+	caller mcpctx.Caller
+}
+
+func (f callerFlags) Enabled(flag string) bool {
+	return f.server.flagEnabled(flag, f.caller)
+}
+
+// methodGated 方法未启用（见 SetMethodEnabled），或配置了功能开关且对该调用方关闭时返回 true
+func (s *McpServer) methodGated(method string, caller mcpctx.Caller) bool {
+	if !s.IsMethodEnabled(method) {
+		return true
+	}
+	flag, ok := s.conf.MethodFlags[method]
+	return ok && !s.flagEnabled(flag, caller)
+}
+
+// ---------------------- 静态配置 ----------------------
+
+// FlagRule 一个开关的静态规则，依次判断：名单命中则开启，否则按百分比灰度，最后取 Default
+type FlagRule struct {
+	Default bool     `yaml:"default"`
+	Tenants []string `yaml:"tenants"`
+	// APIKeys API Key 指纹（与成本账本中展示的一致），不要写明文
+	APIKeys []string `yaml:"api_keys"`
+	// Percent 按租户 + API Key 哈希稳定分桶，0-100
+	Percent float64 `yaml:"percent"`
+}
+
+// StaticFlags 基于配置的开关，未配置的开关均为关闭
+type StaticFlags map[string]FlagRule
+
+func (f StaticFlags) Enabled(flag string, caller mcpctx.Caller) bool {
+	rule, ok := f[flag]
+	if !ok {
+		return false
+	}
+	if caller.Tenant != "" && containsString(rule.Tenants, caller.Tenant) {
+		return true
+	}
+	if caller.APIKey != "" && containsString(rule.APIKeys, caller.APIKey) {
+		return true
+	}
+	if rule.Percent > 0 && (caller.Tenant != "" || caller.APIKey != "") {
+		h := fnv.New32a()
+		h.Write([]byte(flag + "|" + caller.Tenant + "|" + caller.APIKey))
+		return float64(h.Sum32()%10000) < rule.Percent*100
+	}
+	return rule.Default
+}
+
+// ---------------------- LaunchDarkly ----------------------
+
+// LaunchDarklyFlags LaunchDarkly 适配器。为避免引入 SDK 依赖，由调用方传入 Variation，
+// 通常是对 ldclient.LDClient.BoolVariation 的一层包装：
+//
+//	flags := mcpserver.LaunchDarklyFlags{Variation: func(flag, key string, attrs map[string]string, def bool) (bool, error) {
+//		b := ldcontext.NewBuilder(key)
+//		for k, v := range attrs {
+//			b.SetString(k, v)
+//		}
+//		return client.BoolVariation(flag, b.Build(), def)
+//	}}
+type LaunchDarklyFlags struct {
+	// Variation 求值函数；key 为上下文 key（租户，缺省时为 API Key 指纹）
+	Variation func(flag, key string, attrs map[string]string, defaultValue bool) (bool, error)
+	// Default 求值失败时使用的值
+	Default bool
+}
+
+func (f LaunchDarklyFlags) Enabled(flag string, caller mcpctx.Caller) bool {
+	if f.Variation == nil {
+		return f.Default
+	}
+	key := caller.Tenant
+	if key == "" {
+		key = caller.APIKey
+	}
+	if key == "" {
+		key = "anonymous"
+	}
+	attrs := map[string]string{"tenant": caller.Tenant, "apiKey": caller.APIKey, "transport": caller.Transport}
+	v, err := f.Variation(flag, key, attrs, f.Default)
+	if err != nil {
+		logf(LevelWarn, "flag %s: launchdarkly evaluation failed: %v", flag, err)
+		return f.Default
+	}
+	return v
+}
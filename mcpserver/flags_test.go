@@ -0,0 +1,45 @@
+package mcpserver
+
+import (
+	"testing"
+
+	"mcptool/mcpctx"
+)
+
+func TestStaticFlagsAuthenticatedTenant(t *testing.T) {
+	flags := StaticFlags{"beta": {Tenants: []string{"acme"}, APIKeys: []string{"fp1"}}}
+	tests := []struct {
+		name   string
+		caller mcpctx.Caller
+		want   bool
+	}{
+		{"authenticated tenant", mcpctx.Caller{Principal: "ci-bot", Tenant: "acme"}, true},
+		{"authenticated key", mcpctx.Caller{Principal: "key:fp1", APIKey: "fp1"}, true},
+		{"other tenant", mcpctx.Caller{Principal: "ci-bot", Tenant: "globex"}, false},
+		{"header tenant", mcpctx.Caller{Tenant: "acme"}, false},
+		{"header key", mcpctx.Caller{APIKey: "fp1"}, false},
+	}
+	for _, tt := range tests {
+		if got := flags.Enabled("beta", flagCaller(tt.caller)); got != tt.want {
+			t.Errorf("%s: Enabled = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFlagProviderPerServer(t *testing.T) {
+	a := NewMcpServerWithTools(McpConf{Flags: map[string]FlagRule{"beta": {Default: true}}}, NewToolRegistry())
+	b := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	c := NewMcpServerWithTools(McpConf{Flags: map[string]FlagRule{"beta": {Default: true}}}, NewToolRegistry(),
+		WithFlagProvider(StaticFlags{}))
+
+	caller := mcpctx.Caller{Transport: "http"}
+	if !a.flagEnabled("beta", caller) {
+		t.Error("server a: beta disabled, want enabled")
+	}
+	if b.flagEnabled("beta", caller) {
+		t.Error("server b picked up server a's flags")
+	}
+	if c.flagEnabled("beta", caller) {
+		t.Error("server c: WithFlagProvider did not take precedence over McpConf.Flags")
+	}
+}
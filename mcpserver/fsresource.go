@@ -0,0 +1,215 @@
+//go:build !nofsnotify
+
+package mcpserver
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ---------------------- 目录资源 ----------------------
+// WatchResourceDir 把目录树下的每个文件注册为资源（URI 为前缀加相对路径，MIME 类型按扩展名），
+// 并用 fsnotify 监听变化：
+//   - 文件内容改变时重新读取，发送 notifications/resources/updated；
+//   - 新增、删除、改名时更新注册表，发送 notifications/resources/list_changed；
+//   - 新建的子目录自动加入监听。
+// 隐藏文件和编辑器的临时文件（以 . 开头或以 ~ 结尾）不作为资源。编辑器保存一次可能产生多个事件，
+// 配置 NotifyCoalesceWindow 可以合并。依赖 fsnotify，用 -tags nofsnotify 编译时不可用，见 fsresource_stub.go
+
+// ResourceDir 一个被监听的资源目录，见 WatchResourceDir
+type ResourceDir struct {
+	s         *McpServer
+	dir       string
+	uriPrefix string
+	watcher   *fsnotify.Watcher
+
+	mu   sync.Mutex
+	uris map[string]string // 文件路径 -> 已注册的 URI
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchResourceDir 注册 dir 下的文件并开始监听，uriPrefix 为空时使用 "file:///"。
+// 服务停止或调用 Close 时停止监听，已注册的资源保留
+func (s *McpServer) WatchResourceDir(dir, uriPrefix string) (*ResourceDir, error) {
+	if uriPrefix == "" {
+		uriPrefix = defaultResourceURIPrefix
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	d := &ResourceDir{
+		s:         s,
+		dir:       filepath.Clean(dir),
+		uriPrefix: uriPrefix,
+		watcher:   watcher,
+		uris:      make(map[string]string),
+		done:      make(chan struct{}),
+	}
+	if _, err := d.addTree(d.dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		d.run()
+	}()
+	return d, nil
+}
+
+// Close 停止监听
+func (d *ResourceDir) Close() error {
+	var err error
+	d.closeOnce.Do(func() {
+		close(d.done)
+		err = d.watcher.Close()
+	})
+	return err
+}
+
+// URIs 当前注册的资源 URI，按名称排序
+func (d *ResourceDir) URIs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	uris := make([]string, 0, len(d.uris))
+	for _, uri := range d.uris {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+func (d *ResourceDir) run() {
+	defer d.Close()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.s.stop:
+			return
+		case event, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			d.handle(event)
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+			logf(LevelWarn, "resource dir %s: %v", d.dir, err)
+		}
+	}
+}
+
+// handle 处理一个文件系统事件
+func (d *ResourceDir) handle(event fsnotify.Event) {
+	if ignoredResourceFile(event.Name) {
+		return
+	}
+	switch {
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return // 已被删除
+		}
+		var added bool
+		if info.IsDir() {
+			added, err = d.addTree(event.Name)
+		} else {
+			added, err = d.addFile(event.Name)
+		}
+		if err != nil {
+			logf(LevelWarn, "resource dir %s: %v", d.dir, err)
+		}
+		if added {
+			d.s.NotifyResourcesListChanged()
+		}
+
+	case event.Has(fsnotify.Write):
+		added, err := d.addFile(event.Name)
+		if err != nil {
+			logf(LevelWarn, "resource dir %s: %v", d.dir, err)
+			return
+		}
+		if added {
+			d.s.NotifyResourcesListChanged()
+			return
+		}
+		d.mu.Lock()
+		uri := d.uris[event.Name]
+		d.mu.Unlock()
+		d.s.NotifyResourceUpdated(uri)
+
+	case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+		// 改名时新名称另有 Create 事件；目录被删除时移除其下全部文件
+		if d.remove(event.Name) {
+			d.s.NotifyResourcesListChanged()
+		}
+	}
+}
+
+// addTree 监听目录树并注册其中的文件，返回是否新增了资源
+func (d *ResourceDir) addTree(root string) (bool, error) {
+	added := false
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && ignoredResourceFile(path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return d.watcher.Add(path)
+		}
+		isNew, err := d.addFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // 扫描期间被删除
+		}
+		added = added || isNew
+		return err
+	})
+	return added, err
+}
+
+// addFile 读取文件并注册（或替换）资源，返回是否为新资源
+func (d *ResourceDir) addFile(path string) (bool, error) {
+	r, err := loadFileResource(d.dir, d.uriPrefix, path)
+	if err != nil {
+		return false, err
+	}
+	RegisterResource(r)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, exists := d.uris[path]
+	d.uris[path] = r.URI
+	return !exists, nil
+}
+
+// remove 移除文件或目录下全部文件对应的资源，返回是否有资源被移除
+func (d *ResourceDir) remove(path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	removed := false
+	prefix := path + string(filepath.Separator)
+	for file, uri := range d.uris {
+		if file == path || strings.HasPrefix(file, prefix) {
+			UnregisterResource(uri)
+			delete(d.uris, file)
+			removed = true
+		}
+	}
+	return removed
+}
@@ -0,0 +1,39 @@
+//go:build nofsnotify
+
+package mcpserver
+
+import "errors"
+
+// ---------------------- 目录监听占位 ----------------------
+// 用 -tags nofsnotify 编译时不能监听资源目录和 prompt 目录，WatchResourceDir / WatchPromptDir 返回错误；
+// manifest 中的目录仍在加载时注册一次
+
+var errFSWatchUnavailable = errors.New("mcpserver: built without fsnotify support (nofsnotify)")
+
+// ResourceDir 占位类型，不会有实例
+type ResourceDir struct{}
+
+// WatchResourceDir 见 fsresource.go，此构建中不可用
+func (s *McpServer) WatchResourceDir(dir, uriPrefix string) (*ResourceDir, error) {
+	return nil, errFSWatchUnavailable
+}
+
+// Close 见 fsresource.go
+func (d *ResourceDir) Close() error { return nil }
+
+// URIs 见 fsresource.go
+func (d *ResourceDir) URIs() []string { return nil }
+
+// PromptDir 占位类型，不会有实例
+type PromptDir struct{}
+
+// WatchPromptDir 见 promptwatch.go，此构建中不可用；只需加载一次时用 LoadPromptsFromDir
+func (s *McpServer) WatchPromptDir(dir string) (*PromptDir, error) {
+	return nil, errFSWatchUnavailable
+}
+
+// Close 见 promptwatch.go
+func (d *PromptDir) Close() error { return nil }
+
+// Names 见 promptwatch.go
+func (d *PromptDir) Names() []string { return nil }
@@ -0,0 +1,269 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 地理服务 Provider ----------------------
+
+// GeoProvider 地理服务提供方，地理类工具都通过它访问真实的地图服务；
+// ctx 来自工具调用，实现应在请求被取消时尽快返回
+type GeoProvider interface {
+	Geocode(ctx context.Context, input GeocodeToolInput) (map[string]interface{}, error)
+	POISearch(ctx context.Context, input POISearchToolInput) ([]map[string]interface{}, error)
+	Route(ctx context.Context, input RouteToolInput) (map[string]interface{}, error)
+	ReverseGeocode(ctx context.Context, input ReverseGeocodeToolInput) (map[string]interface{}, error)
+	IPLocate(ctx context.Context, input IPLocateToolInput) (map[string]interface{}, error)
+	// RouteCapabilities 返回路线规划支持的出行方式和规避项，用于参数校验和工具描述
+	RouteCapabilities() RouteCapabilities
+}
+
+// RouteCapabilities provider 支持的路线选项
+type RouteCapabilities struct {
+	Modes        []string `json:"modes"`
+	Avoid        []string `json:"avoid"`
+	MaxWaypoints int      `json:"max_waypoints"`
+}
+
+// mockGeoProvider 默认的演示实现，返回固定数据
+type mockGeoProvider struct{}
+
+func (mockGeoProvider) Geocode(_ context.Context, input GeocodeToolInput) (map[string]interface{}, error) {
+	return handleGeocode(input), nil
+}
+
+func (mockGeoProvider) POISearch(_ context.Context, input POISearchToolInput) ([]map[string]interface{}, error) {
+	return handlePOISearch(input), nil
+}
+
+func (mockGeoProvider) Route(_ context.Context, input RouteToolInput) (map[string]interface{}, error) {
+	return handleRoute(input), nil
+}
+
+func (mockGeoProvider) ReverseGeocode(_ context.Context, input ReverseGeocodeToolInput) (map[string]interface{}, error) {
+	return handleReverseGeocode(input), nil
+}
+
+func (mockGeoProvider) IPLocate(_ context.Context, input IPLocateToolInput) (map[string]interface{}, error) {
+	return handleIPLocate(input), nil
+}
+
+func (mockGeoProvider) RouteCapabilities() RouteCapabilities {
+	return RouteCapabilities{
+		Modes:        []string{"driving", "walking", "transit", "cycling"},
+		Avoid:        []string{"tolls", "highways", "ferries"},
+		MaxWaypoints: 16,
+	}
+}
+
+var geoProvider GeoProvider = mockGeoProvider{}
+
+// SetGeoProvider 替换地理服务提供方，需在注册工具前调用
+func SetGeoProvider(p GeoProvider) {
+	geoProvider = p
+}
+
+// ---------------------- 路线规划 ----------------------
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRouteInput 按 provider 的能力校验路线参数，并补全默认出行方式
+func validateRouteInput(input *RouteToolInput, caps RouteCapabilities) error {
+	if input.Origin == "" || input.Destination == "" {
+		return fmt.Errorf("origin and destination are required")
+	}
+	if input.Mode == "" && len(caps.Modes) > 0 {
+		input.Mode = caps.Modes[0]
+	}
+	if !containsString(caps.Modes, input.Mode) {
+		return fmt.Errorf("unsupported mode %q (supported: %s)", input.Mode, strings.Join(caps.Modes, ", "))
+	}
+	for _, a := range input.Avoid {
+		if !containsString(caps.Avoid, a) {
+			return fmt.Errorf("unsupported avoid option %q (supported: %s)", a, strings.Join(caps.Avoid, ", "))
+		}
+	}
+	if caps.MaxWaypoints > 0 && len(input.Waypoints) > caps.MaxWaypoints {
+		return fmt.Errorf("too many waypoints: %d (max %d)", len(input.Waypoints), caps.MaxWaypoints)
+	}
+	if input.DepartureTime != "" {
+		if _, err := time.Parse(time.RFC3339, input.DepartureTime); err != nil {
+			return fmt.Errorf("invalid departure_time, expect RFC3339: %v", err)
+		}
+	}
+	return nil
+}
+
+func handleRouteTool(ctx context.Context, input RouteToolInput) (map[string]interface{}, error) {
+	if err := validateRouteInput(&input, geoProvider.RouteCapabilities()); err != nil {
+		return nil, err
+	}
+	return geoProvider.Route(ctx, input)
+}
+
+// routeToolDescription 根据 provider 能力生成 route 工具描述，使 tools.list 能反映可选参数
+func routeToolDescription() string {
+	caps := geoProvider.RouteCapabilities()
+	return fmt.Sprintf("Route planning between two addresses with optional waypoints and departure_time (modes: %s; avoid: %s; max waypoints: %d)",
+		strings.Join(caps.Modes, ", "), strings.Join(caps.Avoid, ", "), caps.MaxWaypoints)
+}
+
+// routeInputSchema 根据 provider 能力生成 route 工具的参数 schema
+func routeInputSchema() json.RawMessage {
+	caps := geoProvider.RouteCapabilities()
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"origin":      map[string]interface{}{"type": "string", "minLength": 1},
+			"destination": map[string]interface{}{"type": "string", "minLength": 1},
+			"mode":        map[string]interface{}{"type": "string", "enum": caps.Modes},
+			"waypoints": map[string]interface{}{
+				"type":     "array",
+				"items":    map[string]interface{}{"type": "string"},
+				"maxItems": caps.MaxWaypoints,
+			},
+			"avoid": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string", "enum": caps.Avoid},
+			},
+			"departure_time": map[string]interface{}{"type": "string", "description": "RFC3339"},
+		},
+		"required": []string{"origin", "destination"},
+	}
+	data, _ := json.Marshal(schema)
+	return data
+}
+
+// ---------------------- 逆地理编码 / IP 定位 ----------------------
+
+func handleReverseGeocodeTool(ctx context.Context, input ReverseGeocodeToolInput) (map[string]interface{}, error) {
+	if input.Lat < -90 || input.Lat > 90 || input.Lng < -180 || input.Lng > 180 {
+		return nil, fmt.Errorf("coordinates out of range: (%v, %v)", input.Lat, input.Lng)
+	}
+	return geoProvider.ReverseGeocode(ctx, input)
+}
+
+func handleIPLocateTool(ctx context.Context, input IPLocateToolInput) (map[string]interface{}, error) {
+	ip := net.ParseIP(input.IP)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip: %q", input.IP)
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() {
+		return nil, fmt.Errorf("ip %s is not publicly routable", input.IP)
+	}
+	return geoProvider.IPLocate(ctx, input)
+}
+
+// ---------------------- 批量地理工具 ----------------------
+
+const (
+	// 单次批量请求的最大条目数
+	geoBatchMaxItems = 100
+	// 批量请求对 provider 的最大并发数
+	geoBatchConcurrency = 4
+)
+
+type BatchGeocodeToolInput struct {
+	Addresses []string `json:"addresses" jsonschema:"minItems=1,maxItems=100"`
+	City      string   `json:"city,omitempty"`
+}
+
+type BatchPOISearchToolInput struct {
+	Keywords []string `json:"keywords" jsonschema:"minItems=1,maxItems=100"`
+	City     string   `json:"city,omitempty"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"minimum=0,maximum=50"`
+}
+
+// BatchItemResult 批量请求中单个条目的结果，失败时只填写 Error，不影响其他条目
+type BatchItemResult struct {
+	Index  int         `json:"index"`
+	Input  string      `json:"input"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func checkBatchSize(n int) error {
+	if n == 0 {
+		return fmt.Errorf("batch is empty")
+	}
+	if n > geoBatchMaxItems {
+		return fmt.Errorf("batch too large: %d items (max %d)", n, geoBatchMaxItems)
+	}
+	return nil
+}
+
+// runBatch 以有限并发逐条调用 fn，结果顺序与输入一致；ctx 取消后剩余条目不再调用，直接记为失败
+func runBatch(ctx context.Context, inputs []string, fn func(string) (interface{}, error)) []BatchItemResult {
+	results := make([]BatchItemResult, len(inputs))
+	sem := make(chan struct{}, geoBatchConcurrency)
+	var wg sync.WaitGroup
+
+	// 每完成一条上报一次进度
+	progress := mcpctx.ProgressFromContext(ctx)
+	var completed int64
+
+	for i, in := range inputs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i] = BatchItemResult{Index: i, Input: in, Error: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, in string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			item := BatchItemResult{Index: i, Input: in}
+			if out, err := fn(in); err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Result = out
+			}
+			results[i] = item
+			progress(float64(atomic.AddInt64(&completed, 1)), float64(len(inputs)), "")
+		}(i, in)
+	}
+	wg.Wait()
+	return results
+}
+
+func handleBatchGeocode(ctx context.Context, input BatchGeocodeToolInput) ([]BatchItemResult, error) {
+	if err := checkBatchSize(len(input.Addresses)); err != nil {
+		return nil, err
+	}
+	return runBatch(ctx, input.Addresses, func(addr string) (interface{}, error) {
+		if addr == "" {
+			return nil, fmt.Errorf("empty address")
+		}
+		return geoProvider.Geocode(ctx, GeocodeToolInput{Address: addr, City: input.City})
+	}), nil
+}
+
+func handleBatchPOISearch(ctx context.Context, input BatchPOISearchToolInput) ([]BatchItemResult, error) {
+	if err := checkBatchSize(len(input.Keywords)); err != nil {
+		return nil, err
+	}
+	return runBatch(ctx, input.Keywords, func(kw string) (interface{}, error) {
+		if kw == "" {
+			return nil, fmt.Errorf("empty keywords")
+		}
+		return geoProvider.POISearch(ctx, POISearchToolInput{Keywords: kw, City: input.City, Limit: input.Limit})
+	}), nil
+}
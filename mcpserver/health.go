@@ -0,0 +1,206 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------- 依赖健康检查 ----------------------
+// 工具依赖的外部服务（地图 API、数据库等）注册为健康检查，Start 时按 McpConf.StartupChecks 执行：
+//   - "fail"：任一检查失败时 Start 直接返回错误，不开始监听；
+//   - "degrade"：失败的检查所涉及的工具在 tools.list 中标记 degraded（仍可调用）；
+//   - 空：不执行。
+// 只使用 Handler() 嵌入已有服务时不会经过 Start，可以自行调用 CheckHealth
+
+// 健康检查模式
+const (
+	StartupChecksFail    = "fail"
+	StartupChecksDegrade = "degrade"
+)
+
+// defaultHealthCheckTimeout 单个检查的默认超时
+const defaultHealthCheckTimeout = 5 * time.Second
+
+// HealthCheck 一项依赖检查
+type HealthCheck struct {
+	// Name 依赖名，如 "amap-api"、"postgres"
+	Name string
+	// Check 返回 nil 表示依赖可用；ctx 带有超时
+	Check func(ctx context.Context) error
+	// Tools 依赖它的工具
+	Tools []string
+}
+
+// HealthResult 一项检查的结果
+type HealthResult struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Error    string        `json:"error,omitempty"`
+	Tools    []string      `json:"tools,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// HealthReport 一轮检查的结果
+type HealthReport struct {
+	Results []HealthResult `json:"results"`
+	// Degraded 受影响的工具 -> 失败的依赖
+	Degraded map[string][]string `json:"degraded,omitempty"`
+}
+
+// OK 所有检查都通过
+func (r *HealthReport) OK() bool {
+	return r.failed() == nil
+}
+
+func (r *HealthReport) failed() []string {
+	var names []string
+	for _, res := range r.Results {
+		if !res.OK {
+			names = append(names, fmt.Sprintf("%s: %s", res.Name, res.Error))
+		}
+	}
+	return names
+}
+
+// ErrUnhealthy StartupChecks 为 fail 且有检查失败时 Start 返回的错误
+var ErrUnhealthy = errors.New("startup health check failed")
+
+// RegisterHealthCheck 注册依赖检查，同名的会被替换
+func (r *ToolRegistry) RegisterHealthCheck(hc HealthCheck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.checks {
+		if existing.Name == hc.Name {
+			r.checks[i] = hc
+			return
+		}
+	}
+	r.checks = append(r.checks, hc)
+}
+
+// RegisterHealthCheck 向默认注册表注册依赖检查
+func RegisterHealthCheck(hc HealthCheck) {
+	DefaultToolRegistry.RegisterHealthCheck(hc)
+}
+
+// runHealthChecks 并发执行所有检查，并按结果更新工具的 degraded 标记
+func (r *ToolRegistry) runHealthChecks(ctx context.Context, timeout time.Duration) *HealthReport {
+	r.mu.RLock()
+	checks := append([]HealthCheck(nil), r.checks...)
+	r.mu.RUnlock()
+
+	report := &HealthReport{Results: make([]HealthResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, hc := range checks {
+		wg.Add(1)
+		go func(i int, hc HealthCheck) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			err := runCheck(cctx, hc.Check)
+			res := HealthResult{Name: hc.Name, OK: err == nil, Tools: hc.Tools, Duration: time.Since(start)}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			report.Results[i] = res
+		}(i, hc)
+	}
+	wg.Wait()
+
+	degraded := make(map[string][]string)
+	for _, res := range report.Results {
+		if res.OK {
+			continue
+		}
+		logf(LevelWarn, "health check %s failed: %s (tools: %s)", res.Name, res.Error, strings.Join(res.Tools, ", "))
+		for _, tool := range res.Tools {
+			degraded[tool] = append(degraded[tool], res.Name)
+		}
+	}
+	if len(degraded) > 0 {
+		report.Degraded = degraded
+	}
+
+	r.mu.Lock()
+	changed := len(degraded) != len(r.degraded)
+	for tool := range degraded {
+		if _, ok := r.degraded[tool]; !ok {
+			changed = true
+		}
+	}
+	r.degraded = degraded
+	r.mu.Unlock()
+	if changed {
+		r.changed()
+	}
+	return report
+}
+
+// runCheck 执行检查，检查函数不响应 ctx 时按超时处理
+func runCheck(ctx context.Context, check func(context.Context) error) error {
+	if check == nil {
+		return errors.New("no check function")
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// degradedBy 工具依赖的失败检查，按名称排序；没有时返回 nil
+func (r *ToolRegistry) degradedBy(name string) []string {
+	deps := r.degraded[name]
+	if len(deps) == 0 {
+		return nil
+	}
+	out := append([]string(nil), deps...)
+	sort.Strings(out)
+	return out
+}
+
+// CheckHealth 执行一轮依赖检查并更新 tools.list 中的 degraded 标记，可以定期调用
+func (s *McpServer) CheckHealth(ctx context.Context) *HealthReport {
+	timeout := s.conf.HealthCheckTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	return s.tools.runHealthChecks(ctx, timeout)
+}
+
+// startupChecks Start 时按 StartupChecks 执行检查
+func (s *McpServer) startupChecks(ctx context.Context) error {
+	switch s.conf.StartupChecks {
+	case "":
+		return nil
+	case StartupChecksFail, StartupChecksDegrade:
+	default:
+		return fmt.Errorf("unknown startup_checks %q", s.conf.StartupChecks)
+	}
+	report := s.CheckHealth(ctx)
+	failed := report.failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	if s.conf.StartupChecks == StartupChecksFail {
+		return fmt.Errorf("%w: %s", ErrUnhealthy, strings.Join(failed, "; "))
+	}
+	logf(LevelWarn, "starting with degraded tools: %v", report.Degraded)
+	return nil
+}
@@ -0,0 +1,207 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ---------------------- HTTP 资源 ----------------------
+// HTTPResourceProvider 把远端 URL 作为资源（URI 即 URL），读取时才下载并缓存：
+//
+//	p := mcpserver.NewHTTPResourceProvider(mcpserver.HTTPResourceConf{
+//		Resources: []mcpserver.HTTPResource{{URL: "https://docs.example.com/api.md", Name: "api"}},
+//		TTL:       10 * time.Minute,
+//	})
+//	mcpserver.RegisterResourceProvider("docs", "https://docs.example.com/", p)
+//
+// 缓存在 TTL 内直接使用；过期后带 If-None-Match / If-Modified-Since 重新验证，远端返回 304 时沿用缓存。
+// 远端出错时继续使用过期的缓存并记录日志；远端返回 404 / 410 时资源不存在。只能读取 Resources 中列出的 URL
+
+// HTTPResource 一个远端文档
+type HTTPResource struct {
+	URL         string
+	Name        string
+	Description string
+	// MimeType 为空时使用响应的 Content-Type
+	MimeType string
+}
+
+// HTTPResourceConf HTTP 资源的配置
+type HTTPResourceConf struct {
+	Resources []HTTPResource
+	// TTL 缓存有效期，默认 5 分钟；-1 表示每次读取都重新验证
+	TTL time.Duration
+	// Timeout 单次请求的超时，默认 30 秒
+	Timeout time.Duration
+	// MaxBytes 响应大小上限，默认 10MB
+	MaxBytes int64
+	// Headers 附加的请求头，如 Authorization
+	Headers map[string]string
+	// Client 为空时使用 http.DefaultClient
+	Client *http.Client
+}
+
+const (
+	defaultHTTPResourceTTL      = 5 * time.Minute
+	defaultHTTPResourceTimeout  = 30 * time.Second
+	defaultHTTPResourceMaxBytes = 10 << 20
+)
+
+// HTTPResourceProvider 远端 URL 的资源提供者，见 NewHTTPResourceProvider
+type HTTPResourceProvider struct {
+	conf      HTTPResourceConf
+	resources map[string]HTTPResource // URL -> 配置
+
+	mu    sync.Mutex
+	cache map[string]*httpResourceEntry
+}
+
+// httpResourceEntry 一个 URL 的缓存；mu 保证同一 URL 同时只有一个请求
+type httpResourceEntry struct {
+	mu           sync.Mutex
+	body         []byte
+	mimeType     string
+	etag         string
+	lastModified string
+	fetchedAt    time.Time
+}
+
+// NewHTTPResourceProvider 创建 HTTP 资源提供者，用 RegisterResourceProvider 注册
+func NewHTTPResourceProvider(conf HTTPResourceConf) *HTTPResourceProvider {
+	if conf.TTL == 0 {
+		conf.TTL = defaultHTTPResourceTTL
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultHTTPResourceTimeout
+	}
+	if conf.MaxBytes <= 0 {
+		conf.MaxBytes = defaultHTTPResourceMaxBytes
+	}
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+	p := &HTTPResourceProvider{
+		conf:      conf,
+		resources: make(map[string]HTTPResource, len(conf.Resources)),
+		cache:     make(map[string]*httpResourceEntry),
+	}
+	for _, r := range conf.Resources {
+		p.resources[r.URL] = r
+	}
+	return p
+}
+
+// List 列出配置的 URL，不发起请求
+func (p *HTTPResourceProvider) List(ctx context.Context) ([]*Resource, error) {
+	list := make([]*Resource, 0, len(p.conf.Resources))
+	for _, r := range p.conf.Resources {
+		list = append(list, &Resource{URI: r.URL, Name: r.Name, Description: r.Description, MimeType: r.MimeType})
+	}
+	return list, nil
+}
+
+// Read 读取 URL 的内容，缓存有效时不发起请求
+func (p *HTTPResourceProvider) Read(ctx context.Context, uri string) (*Resource, error) {
+	conf, ok := p.resources[uri]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+	}
+	p.mu.Lock()
+	entry, ok := p.cache[uri]
+	if !ok {
+		entry = &httpResourceEntry{}
+		p.cache[uri] = entry
+	}
+	p.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.body == nil || p.conf.TTL < 0 || time.Since(entry.fetchedAt) >= p.conf.TTL {
+		if err := p.fetch(ctx, uri, entry); err != nil {
+			if entry.body == nil || errors.Is(err, ErrResourceNotFound) {
+				entry.body = nil
+				return nil, err
+			}
+			logf(LevelWarn, "http resource %s: %v, serving stale copy", uri, err)
+		}
+	}
+
+	r := &Resource{URI: uri, Name: conf.Name, Description: conf.Description, MimeType: conf.MimeType}
+	if r.MimeType == "" {
+		r.MimeType = entry.mimeType
+	}
+	if utf8.Valid(entry.body) {
+		r.Text = string(entry.body)
+	} else {
+		r.Blob = entry.body
+	}
+	return r, nil
+}
+
+// Invalidate 丢弃 URL 的缓存，下次读取时重新下载
+func (p *HTTPResourceProvider) Invalidate(uri string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cache, uri)
+}
+
+// fetch 下载或重新验证 URL，更新缓存
+func (p *HTTPResourceProvider) fetch(ctx context.Context, uri string, entry *httpResourceEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, p.conf.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range p.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	if entry.body != nil {
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := p.conf.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry.body != nil:
+		entry.fetchedAt = time.Now()
+		return nil
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("upstream returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, p.conf.MaxBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > p.conf.MaxBytes {
+		return fmt.Errorf("response exceeds %d bytes", p.conf.MaxBytes)
+	}
+	typ, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		typ, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	entry.body = body
+	entry.mimeType = typ
+	entry.etag = resp.Header.Get("ETag")
+	entry.lastModified = resp.Header.Get("Last-Modified")
+	entry.fetchedAt = time.Now()
+	return nil
+}
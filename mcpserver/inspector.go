@@ -0,0 +1,250 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"mcptool/version"
+)
+
+// ---------------------- MCP Inspector 兼容 ----------------------
+// 官方 MCP Inspector 可以直接连接本服务调试：
+//   - Streamable HTTP：指向 HTTPPath，支持 initialize / ping 和 notifications/*
+//   - SSE：指向 InspectorPath + "/sse"。这是规范中的 HTTP+SSE 传输：连接后先收到 endpoint 事件，
+//     请求 POST 到其中的 messages 地址，响应和通知以 message 事件从 SSE 流返回。
+//     原有的 SSEPath 只广播事件，格式与规范不同，保持不变
+
+// initialize 协商协议版本并返回服务能力和协商的版本：客户端请求的版本受支持时原样返回，否则返回最新版本，见 protocol.go
+func (s *McpServer) initialize(raw json.RawMessage) (map[string]interface{}, string) {
+	var params struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(raw, &params)
+	protocol := version.Negotiate(params.ProtocolVersion)
+	result := map[string]interface{}{
+		"protocolVersion": protocol,
+		"capabilities":    s.capabilities(protocol),
+		"serverInfo":      s.serverInfo(),
+	}
+	if s.conf.Instructions != "" {
+		result["instructions"] = s.conf.Instructions
+	}
+	return result, protocol
+}
+
+// isNotification JSON-RPC 通知（没有 id），如 notifications/initialized，不需要响应
+func isNotification(req *RPCRequest) bool {
+	return len(req.ID) == 0 && strings.HasPrefix(req.Method, "notifications/")
+}
+
+// isSpecMethod 客户端使用的是规范的 slash 风格方法名
+func isSpecMethod(method string) bool {
+	_, ok := methodAliases[method]
+	return ok
+}
+
+// specResult 规范方法名对应的结果形状：内部方法的 prompts / resources 结果
+// 是早期的简化格式，官方客户端（包括 Inspector）会按规范校验
+func specResult(method string, result interface{}) interface{} {
+	switch method {
+	case "prompts.get":
+		p, ok := result.(*renderedPrompt)
+		if !ok {
+			return result
+		}
+		out := map[string]interface{}{"messages": p.messages}
+		if p.prompt.Description != "" {
+			out["description"] = p.prompt.Description
+		}
+		return out
+
+	case "resources.list":
+		items, _ := resultList(result, "resources").([]map[string]string)
+		list := make([]map[string]interface{}, 0, len(items))
+		for _, item := range items {
+			r := map[string]interface{}{
+				"uri":  item["uri"],
+				"name": item["name"],
+			}
+			if item["mimeType"] != "" {
+				r["mimeType"] = item["mimeType"]
+			}
+			if item["description"] != "" {
+				r["description"] = item["description"]
+			}
+			list = append(list, r)
+		}
+		return withNextCursor(result, map[string]interface{}{"resources": list})
+
+	case "resources.get":
+		r, ok := result.(*Resource)
+		if !ok {
+			return result
+		}
+		return map[string]interface{}{
+			"contents": []ResourceContents{r.Contents()},
+		}
+	}
+	return result
+}
+
+// withNextCursor 把分页结果的 nextCursor 带到改写后的结果中
+func withNextCursor(result interface{}, out map[string]interface{}) map[string]interface{} {
+	if next, ok := resultNextCursor(result); ok {
+		out["nextCursor"] = next
+	}
+	return out
+}
+
+// resultList 内部 list 方法结果 {"key": [...]} 中的列表，列表项已按调用方权限过滤
+func resultList(result interface{}, key string) interface{} {
+	m, _ := result.(map[string]interface{})
+	return m[key]
+}
+
+// ---------------------- HTTP+SSE 传输 ----------------------
+
+// inspectorSession 一个 HTTP+SSE 传输的会话，对应一条 SSE 连接
+type inspectorSession struct {
+	id        string
+	principal string     // 开启认证时建立会话的主体，只有同一主体可以向会话发请求
+	mu        sync.Mutex // 串行化对 SSE 流的写入
+	writer    http.ResponseWriter
+	flusher   http.Flusher
+	throttle  *connThrottle
+	st        sessionState
+	wire      *wireDump
+}
+
+var inspectorSessionSeq uint64
+
+// ID 实现 mcpctx.Session
+func (sess *inspectorSession) ID() string {
+	return sess.id
+}
+
+// send 写一个 message 事件
+func (sess *inspectorSession) send(data []byte) {
+	sess.wire.frame("sse", sess.id, "send", data)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	fmt.Fprintf(sess.throttle.writer(sess.writer), "event: message\ndata: %s\n\n", data)
+	sess.flusher.Flush()
+}
+
+func (sess *inspectorSession) notify(n *RPCNotification) {
+	data, _ := json.Marshal(n)
+	sess.send(data)
+}
+
+// sendInspector 向本服务的所有 HTTP+SSE 会话推送通知
+func (s *McpServer) sendInspector(stamp EventStamp, method string, params interface{}) {
+	s.inspectorMu.Lock()
+	sessions := make([]*inspectorSession, 0, len(s.inspectorSessions))
+	for _, sess := range s.inspectorSessions {
+		sessions = append(sessions, sess)
+	}
+	s.inspectorMu.Unlock()
+	if len(sessions) == 0 {
+		return
+	}
+	n := newRPCNotification(stamp, method, params)
+	for _, sess := range sessions {
+		if sess.st.wants(method, params) {
+			sess.notify(n)
+		}
+	}
+}
+
+// inspectorSSEHandler GET InspectorPath/sse：建立会话并告知客户端 messages 地址
+func (s *McpServer) inspectorSSEHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	sess := &inspectorSession{
+		id:       fmt.Sprintf("sse-%d", atomic.AddUint64(&inspectorSessionSeq, 1)),
+		writer:   w,
+		flusher:  flusher,
+		throttle: s.newThrottle(),
+		wire:     &s.wire,
+	}
+	sess.principal, _ = principalFromRequest(r)
+	sess.mu.Lock()
+	fmt.Fprintf(w, "event: endpoint\ndata: %s/messages?sessionId=%s\n\n", s.conf.InspectorPath, sess.id)
+	flusher.Flush()
+	sess.mu.Unlock()
+
+	s.inspectorMu.Lock()
+	s.inspectorSessions[sess.id] = sess
+	s.inspectorMu.Unlock()
+	defer func() {
+		s.inspectorMu.Lock()
+		delete(s.inspectorSessions, sess.id)
+		s.inspectorMu.Unlock()
+		s.forgetSession(sess.id)
+	}()
+
+	select {
+	case <-r.Context().Done():
+	case <-s.drained:
+	}
+}
+
+type inspectorSessionKey struct{}
+
+// inspectorSessionFrom 通过 messages 端点转发的请求所属的会话
+func inspectorSessionFrom(r *http.Request) (*inspectorSession, bool) {
+	sess, ok := r.Context().Value(inspectorSessionKey{}).(*inspectorSession)
+	return sess, ok
+}
+
+// inspectorMessagesHandler POST InspectorPath/messages?sessionId=：按 HTTP 端点处理请求，
+// 响应写到会话的 SSE 流，HTTP 本身只返回 202
+func (s *McpServer) inspectorMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.inspectorMu.Lock()
+	sess, ok := s.inspectorSessions[r.URL.Query().Get("sessionId")]
+	s.inspectorMu.Unlock()
+	if principal, _ := principalFromRequest(r); !ok || principal != sess.principal {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	rec := &bufferedResponse{header: http.Header{}}
+	ctx := context.WithValue(r.Context(), inspectorSessionKey{}, sess)
+	s.httpHandler(rec, r.WithContext(ctx))
+
+	w.WriteHeader(http.StatusAccepted)
+	io.WriteString(w, "Accepted")
+	if body := bytes.TrimSpace(rec.body.Bytes()); len(body) > 0 {
+		sess.send(body)
+	}
+}
+
+// bufferedResponse 收集 httpHandler 的输出，再转写到 SSE 流
+type bufferedResponse struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
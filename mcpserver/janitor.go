@@ -0,0 +1,212 @@
+package mcpserver
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------- 过期数据清理 ----------------------
+// 长时间运行的服务中，按会话累积的状态（成本账本的会话条目、调用预算、工具推荐的调用历史）
+// 和过期的缓存条目如果不清理会无限增长。后台每隔 GCInterval 运行一次各个清理函数：
+//   - sessions：空闲超过 SessionTTL 的会话，成本并入同一租户 / API Key 的汇总条目，
+//     预算桶和推荐历史直接删除；
+//   - distance_cache：已过期的 distance_matrix 单元格缓存（每个服务一份，另有条数上限，见 distance.go）。
+// WS 会话按 ResumeWindow 过期、HTTP+SSE 会话随连接结束，不需要在这里清理。
+// 以后新增的有状态存储（异步任务、上传、审计记录等）用 RegisterCollector 接入，统计见 system.stats
+
+const (
+	defaultGCInterval = time.Minute
+	defaultSessionTTL = 30 * time.Minute
+)
+
+// Collector 清理函数，删除 now 时已过期的数据，返回删除的条数
+type Collector func(now time.Time) int
+
+type namedCollector struct {
+	name string
+	fn   Collector
+}
+
+// GCStats 清理统计
+type GCStats struct {
+	Runs         int64            `json:"runs"`
+	LastRun      time.Time        `json:"last_run,omitempty"`
+	LastDuration time.Duration    `json:"last_duration_ns"`
+	LastRemoved  map[string]int   `json:"last_removed"`  // 最近一次各清理函数删除的条数
+	TotalRemoved map[string]int64 `json:"total_removed"` // 累计删除的条数
+}
+
+type janitor struct {
+	mu         sync.Mutex
+	collectors []namedCollector
+	stats      GCStats
+}
+
+// RegisterCollector 注册清理函数，随后台清理定期运行，同名时替换
+func (s *McpServer) RegisterCollector(name string, fn Collector) {
+	s.janitor.mu.Lock()
+	defer s.janitor.mu.Unlock()
+	for i := range s.janitor.collectors {
+		if s.janitor.collectors[i].name == name {
+			s.janitor.collectors[i].fn = fn
+			return
+		}
+	}
+	s.janitor.collectors = append(s.janitor.collectors, namedCollector{name: name, fn: fn})
+}
+
+// registerBuiltinCollectors 注册内置的清理函数
+func (s *McpServer) registerBuiltinCollectors() {
+	s.RegisterCollector("sessions", func(now time.Time) int {
+		cutoff := now.Add(-s.conf.SessionTTL)
+		removed := expireSessionCosts(cutoff)
+		if s.budget != nil {
+			removed += s.budget.expire(now)
+		}
+		if s.suggester != nil {
+			removed += s.suggester.expire(cutoff)
+		}
+		return removed
+	})
+	s.RegisterCollector("http_sessions", s.expireStreamableSessions)
+	s.RegisterCollector("distance_cache", s.distances.expire)
+}
+
+// collect 运行一次全部清理函数
+func (s *McpServer) collect() {
+	j := &s.janitor
+	j.mu.Lock()
+	collectors := append([]namedCollector(nil), j.collectors...)
+	j.mu.Unlock()
+
+	start := time.Now()
+	removed := make(map[string]int, len(collectors))
+	for _, c := range collectors {
+		removed[c.name] = c.fn(start)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stats.Runs++
+	j.stats.LastRun = start
+	j.stats.LastDuration = time.Since(start)
+	j.stats.LastRemoved = removed
+	if j.stats.TotalRemoved == nil {
+		j.stats.TotalRemoved = make(map[string]int64)
+	}
+	for name, n := range removed {
+		j.stats.TotalRemoved[name] += int64(n)
+	}
+}
+
+// runJanitor 后台清理循环
+func (s *McpServer) runJanitor() {
+	ticker := time.NewTicker(s.conf.GCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.collect()
+		}
+	}
+}
+
+// GCStats 返回清理统计
+func (s *McpServer) GCStats() GCStats {
+	j := &s.janitor
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	stats := j.stats
+	stats.LastRemoved = make(map[string]int, len(j.stats.LastRemoved))
+	for k, v := range j.stats.LastRemoved {
+		stats.LastRemoved[k] = v
+	}
+	stats.TotalRemoved = make(map[string]int64, len(j.stats.TotalRemoved))
+	for k, v := range j.stats.TotalRemoved {
+		stats.TotalRemoved[k] = v
+	}
+	return stats
+}
+
+// systemStats system.stats：清理统计和当前各类状态的数量
+func (s *McpServer) systemStats() map[string]interface{} {
+	s.mu.Lock()
+	wsConns := len(s.wsConns)
+	s.mu.Unlock()
+	s.wsSessionsMu.Lock()
+	wsSess := len(s.wsSessions)
+	s.wsSessionsMu.Unlock()
+	s.sseMu.Lock()
+	sse := len(s.sseClients)
+	s.sseMu.Unlock()
+	s.inspectorMu.Lock()
+	inspector := len(s.inspectorSessions)
+	s.inspectorMu.Unlock()
+	s.streamableMu.Lock()
+	streamable := len(s.streamableSessions)
+	s.streamableMu.Unlock()
+	costLock.Lock()
+	costs := len(costLedger)
+	costLock.Unlock()
+	cached := s.distances.len()
+
+	collectors := []string{}
+	s.janitor.mu.Lock()
+	for _, c := range s.janitor.collectors {
+		collectors = append(collectors, c.name)
+	}
+	s.janitor.mu.Unlock()
+	sort.Strings(collectors)
+
+	return map[string]interface{}{
+		"gc":         s.GCStats(),
+		"coalesced":  atomic.LoadInt64(&s.flights.shared),
+		"collectors": collectors,
+		"counts": map[string]int{
+			"ws_connections":     wsConns,
+			"ws_sessions":        wsSess,
+			"sse_clients":        sse,
+			"inspector_sessions": inspector,
+			"http_sessions":      streamable,
+			"cost_entries":       costs,
+			"distance_cache":     cached,
+		},
+		"inflight": atomic.LoadInt64(&s.inflight),
+	}
+}
+
+// ---------------------- 内置清理函数 ----------------------
+
+// expireSessionCosts 把 cutoff 之后没有调用的会话条目并入同一主体 / 租户 / API Key 的汇总条目
+func expireSessionCosts(cutoff time.Time) int {
+	costLock.Lock()
+	defer costLock.Unlock()
+	removed := 0
+	for key, e := range costLedger {
+		if key.Session == "" || !e.lastSeen.Before(cutoff) {
+			continue
+		}
+		rollup := key
+		rollup.Session = ""
+		total, ok := costLedger[rollup]
+		if !ok {
+			total = &CostEntry{CostKey: rollup, ByTool: map[string]float64{}}
+			costLedger[rollup] = total
+		}
+		total.Calls += e.Calls
+		total.CostUnits += e.CostUnits
+		for tool, units := range e.ByTool {
+			total.ByTool[tool] += units
+		}
+		if e.lastSeen.After(total.lastSeen) {
+			total.lastSeen = e.lastSeen
+		}
+		delete(costLedger, key)
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,462 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------- JWT 认证 ----------------------
+// 支持 HS256（共享密钥）和 RS256（公钥或 JWKS）。凭证是 JWT（三段式）时按 JWT 校验，
+// 主体取 sub（没有时取 client_id / azp），声明放进请求 context，供 Policy 和工具使用
+
+const (
+	defaultJWTLeeway = time.Minute
+	jwksRefreshMin   = 30 * time.Second // 遇到未知 kid 时两次拉取 JWKS 的最小间隔
+	jwksRefreshMax   = time.Hour        // JWKS 缓存的最长时间
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// Claims JWT 声明
+type Claims map[string]interface{}
+
+// String 字符串类型的声明，不存在或类型不符时返回空串
+func (c Claims) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// Scopes OAuth scope：scope（空格分隔的字符串）或 scp（字符串数组）
+func (c Claims) Scopes() []string {
+	if scope := c.String("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+	return c.strings("scp")
+}
+
+// strings 字符串或字符串数组类型的声明
+func (c Claims) strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+type claimsKey struct{}
+
+// ClaimsFromContext 请求使用 JWT 认证时的声明，工具可以据此做更细的授权
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
+
+// JWTConfig JWT 校验配置，Secret、PublicKey、JWKSURL 至少设置一个
+type JWTConfig struct {
+	// Secret HS256 共享密钥
+	Secret []byte
+	// PublicKey RS256 公钥
+	PublicKey *rsa.PublicKey
+	// JWKSURL 按 kid 从 JWKS 获取 RS256 公钥，如 https://issuer/.well-known/jwks.json
+	JWKSURL string
+	// Issuer / Audience 非空时要求 iss 相等、aud 包含该值
+	Issuer   string
+	Audience string
+	// Leeway 校验 exp / nbf 时允许的时钟偏差，默认 1 分钟
+	Leeway time.Duration
+	// AllowNoExpiry 接受没有 exp 的令牌。默认拒绝：这样的令牌泄露后永久有效
+	AllowNoExpiry bool
+}
+
+// WithJWT 开启 JWT 认证，可与 API Key、TokenValidator 同时使用
+func WithJWT(cfg JWTConfig) Option {
+	return func(s *McpServer) {
+		s.jwt = newJWTVerifier(cfg)
+	}
+}
+
+// jwtConfigFromConf 由 McpConf 中的 JWT 配置构造，未配置时返回 nil。
+// 公钥文件读取失败时只记录日志，此时 RS256 令牌一律校验失败
+func jwtConfigFromConf(conf McpConf) *JWTConfig {
+	if conf.JWTSecret == "" && conf.JWTPublicKeyFile == "" && conf.JWKSURL == "" {
+		return nil
+	}
+	cfg := &JWTConfig{
+		JWKSURL:       conf.JWKSURL,
+		Issuer:        conf.JWTIssuer,
+		Audience:      conf.JWTAudience,
+		AllowNoExpiry: conf.JWTAllowNoExpiry,
+	}
+	if conf.JWTSecret != "" {
+		cfg.Secret = []byte(conf.JWTSecret)
+	}
+	if conf.JWTPublicKeyFile != "" {
+		key, err := loadRSAPublicKey(conf.JWTPublicKeyFile)
+		if err != nil {
+			logf(LevelError, "load jwt public key: %v", err)
+		}
+		cfg.PublicKey = key
+	}
+	return cfg
+}
+
+// loadRSAPublicKey 读取 PEM 格式的 RSA 公钥（PKIX 或 PKCS#1）或证书
+func loadRSAPublicKey(file string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", file)
+	}
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an RSA public key", file)
+	}
+	return rsaKey, nil
+}
+
+// jwtVerifier 校验 JWT，并缓存 JWKS
+type jwtVerifier struct {
+	cfg JWTConfig
+
+	mu        sync.Mutex
+	jwks      map[string]*rsa.PublicKey // kid -> 公钥
+	fetchedAt time.Time                 // 最近一次拉取 JWKS（包括失败的）的时间
+	refreshes flightGroup               // 合并并发的 JWKS 拉取
+}
+
+func newJWTVerifier(cfg JWTConfig) *jwtVerifier {
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = defaultJWTLeeway
+	}
+	return &jwtVerifier{cfg: cfg}
+}
+
+// looksLikeJWT 三段式、以 base64url 编码的 JSON 开头
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// verify 校验签名与时间、签发方、受众，返回主体和声明
+func (v *jwtVerifier) verify(ctx context.Context, token string) (string, Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", nil, fmt.Errorf("%w: malformed jwt", ErrUnauthorized)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: malformed jwt signature", ErrUnauthorized)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch header.Alg {
+	case "HS256":
+		if len(v.cfg.Secret) == 0 {
+			return "", nil, fmt.Errorf("%w: HS256 is not configured", ErrUnauthorized)
+		}
+		mac := hmac.New(sha256.New, v.cfg.Secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return "", nil, fmt.Errorf("%w: bad jwt signature", ErrUnauthorized)
+		}
+	case "RS256":
+		key, err := v.publicKey(ctx, header.Kid)
+		if err != nil {
+			return "", nil, err
+		}
+		sum := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+			return "", nil, fmt.Errorf("%w: bad jwt signature", ErrUnauthorized)
+		}
+	default:
+		// 包括 none：不接受未签名或未配置算法的令牌
+		return "", nil, fmt.Errorf("%w: unsupported jwt alg %q", ErrUnauthorized, header.Alg)
+	}
+
+	var claims Claims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", nil, err
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return "", nil, err
+	}
+	principal := claims.String("sub")
+	if principal == "" {
+		principal = claims.String("client_id")
+	}
+	if principal == "" {
+		principal = claims.String("azp")
+	}
+	if principal == "" {
+		return "", nil, fmt.Errorf("%w: jwt has no subject", ErrUnauthorized)
+	}
+	return principal, claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: malformed jwt", ErrUnauthorized)
+	}
+	return nil
+}
+
+// checkClaims 校验 exp / nbf / iss / aud。没有 exp 时按 AllowNoExpiry 处理；
+// exp / nbf 存在但不是数字时拒绝，不跳过检查
+func (v *jwtVerifier) checkClaims(claims Claims, now time.Time) error {
+	leeway := v.cfg.Leeway.Seconds()
+	ts := float64(now.Unix())
+	exp, ok, err := numericClaim(claims, "exp")
+	switch {
+	case err != nil:
+		return err
+	case !ok && !v.cfg.AllowNoExpiry:
+		return fmt.Errorf("%w: jwt has no exp", ErrUnauthorized)
+	case ok && ts > exp+leeway:
+		return fmt.Errorf("%w: jwt expired", ErrUnauthorized)
+	}
+	nbf, ok, err := numericClaim(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && ts < nbf-leeway {
+		return fmt.Errorf("%w: jwt not yet valid", ErrUnauthorized)
+	}
+	if v.cfg.Issuer != "" && claims.String("iss") != v.cfg.Issuer {
+		return fmt.Errorf("%w: unexpected jwt issuer", ErrUnauthorized)
+	}
+	if v.cfg.Audience != "" && !containsString(claims.strings("aud"), v.cfg.Audience) {
+		return fmt.Errorf("%w: unexpected jwt audience", ErrUnauthorized)
+	}
+	return nil
+}
+
+// numericClaim 数字类型的时间声明（NumericDate），不存在时 ok 为 false，类型不符时返回错误
+func numericClaim(claims Claims, name string) (value float64, ok bool, err error) {
+	raw, present := claims[name]
+	if !present {
+		return 0, false, nil
+	}
+	value, ok = raw.(float64)
+	if !ok {
+		return 0, false, fmt.Errorf("%w: jwt %s is not a number", ErrUnauthorized, name)
+	}
+	return value, true, nil
+}
+
+// publicKey RS256 公钥：配置了 JWKS 时按 kid 查找，缓存中没有该 kid 时刷新 JWKS
+func (v *jwtVerifier) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if v.cfg.JWKSURL == "" {
+		if v.cfg.PublicKey == nil {
+			return nil, fmt.Errorf("%w: RS256 is not configured", ErrUnauthorized)
+		}
+		return v.cfg.PublicKey, nil
+	}
+
+	v.mu.Lock()
+	key, ok := v.jwks[kid]
+	age := time.Since(v.fetchedAt)
+	v.mu.Unlock()
+	if (ok && age < jwksRefreshMax) || (!ok && age < jwksRefreshMin) {
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown jwt kid %q", ErrUnauthorized, kid)
+		}
+		return key, nil
+	}
+	// 拉取时不持有锁，其他请求照常使用缓存；并发的刷新合并为一次
+	val, err, _ := v.refreshes.coalesce(ctx, "jwks", func() (interface{}, error) {
+		return v.refresh(ctx)
+	})
+	if err != nil {
+		// 拉取失败时继续使用缓存中的公钥
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("%w: jwks unavailable", ErrUnauthorized)
+	}
+	if key, ok = val.(map[string]*rsa.PublicKey)[kid]; !ok {
+		return nil, fmt.Errorf("%w: unknown jwt kid %q", ErrUnauthorized, kid)
+	}
+	return key, nil
+}
+
+// refresh 拉取 JWKS 并替换缓存。距上次拉取不足 jwksRefreshMin 时（如另一次刷新刚刚结束）不再拉取，
+// 直接返回缓存，未知 kid 因此不会触发频繁的拉取
+func (v *jwtVerifier) refresh(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	v.mu.Lock()
+	if time.Since(v.fetchedAt) < jwksRefreshMin {
+		keys := v.jwks
+		v.mu.Unlock()
+		if keys == nil {
+			return nil, errors.New("jwks unavailable")
+		}
+		return keys, nil
+	}
+	v.mu.Unlock()
+
+	keys, err := fetchJWKS(ctx, v.cfg.JWKSURL)
+	if err != nil && ctx.Err() != nil {
+		// 请求本身被取消，不计为一次拉取
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fetchedAt = time.Now()
+	if err != nil {
+		logf(LevelWarn, "fetch jwks %s: %v", v.cfg.JWKSURL, err)
+		return nil, err
+	}
+	v.jwks = keys
+	return keys, nil
+}
+
+// fetchJWKS 拉取 JWKS 中的 RSA 公钥
+func fetchJWKS(ctx context.Context, url string) (map[string]*rsa.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA keys")
+	}
+	return keys, nil
+}
+
+// ---------------------- 授权策略 ----------------------
+
+// Policy 授权策略，返回调用方能否调用方法。method 为内部方法名（如 "tools.list"、"tools.run"，
+// 规范方法名会先转换），tools.run 时 tool 为工具名，prompts.get / resources.get 时为 prompt 名 / 资源名。
+// prompts.list / resources.list 按 prompts.get / resources.get 过滤列表项。claims 为 JWT 声明，
+// 使用其他方式认证（API Key、TokenValidator）或未开启认证时为 nil。initialize / ping 不经过策略
+type Policy func(claims Claims, method, tool string) bool
+
+// WithPolicy 设置授权策略，覆盖 McpConf.Scopes
+func WithPolicy(p Policy) Option {
+	return func(s *McpServer) {
+		s.policy = p
+	}
+}
+
+// ScopePolicy 按 JWT 的 scope 授权：scope -> 允许的方法。方法支持 "*"、"resources.*" 形式的前缀通配，
+// "tools.run:weather" 表示只允许调用该工具，"prompts.get:public_*" 只允许读取名称匹配的 prompt
+// （名称部分的 * 和 ? 同 path.Match）。令牌的任一 scope 允许即可；没有 JWT 声明的请求不受限制，例如：
+//
+//	ScopePolicy(map[string][]string{
+//		"mcp:read":     {"tools.list", "resources.*", "prompts.list", "prompts.get:public_*", "server.info"},
+//		"mcp:tools":    {"tools.*"},
+//		"mcp:internal": {"prompts.get", "resources.get"},
+//	})
+func ScopePolicy(scopes map[string][]string) Policy {
+	return func(claims Claims, method, tool string) bool {
+		if claims == nil {
+			return true
+		}
+		for _, scope := range claims.Scopes() {
+			for _, pattern := range scopes[scope] {
+				if matchMethodPattern(pattern, method, tool) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// matchMethodPattern 匹配 "*"、"prefix.*"、"method" 或 "method:name"，name 可以是 path.Match 通配符
+func matchMethodPattern(pattern, method, tool string) bool {
+	if p, name, ok := strings.Cut(pattern, ":"); ok {
+		if p != method {
+			return false
+		}
+		matched, _ := path.Match(name, tool)
+		return matched || name == tool
+	}
+	if pattern == "*" || pattern == method {
+		return true
+	}
+	return strings.HasSuffix(pattern, ".*") && strings.HasPrefix(method, pattern[:len(pattern)-1])
+}
@@ -0,0 +1,250 @@
+package mcpserver
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func encodeJWTPart(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret []byte, claims Claims) string {
+	t.Helper()
+	signed := encodeJWTPart(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeJWTPart(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims Claims) string {
+	t.Helper()
+	signed := encodeJWTPart(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encodeJWTPart(t, claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTClaims(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Now().Unix()
+	tests := []struct {
+		name    string
+		cfg     JWTConfig
+		claims  Claims
+		wantErr bool
+	}{
+		{"valid", JWTConfig{}, Claims{"sub": "u1", "exp": now + 60}, false},
+		{"expired", JWTConfig{}, Claims{"sub": "u1", "exp": now - 3600}, true},
+		{"expired within leeway", JWTConfig{}, Claims{"sub": "u1", "exp": now - 30}, false},
+		{"not yet valid", JWTConfig{}, Claims{"sub": "u1", "exp": now + 7200, "nbf": now + 3600}, true},
+		{"nbf within leeway", JWTConfig{}, Claims{"sub": "u1", "exp": now + 7200, "nbf": now + 30}, false},
+		{"audience string", JWTConfig{Audience: "mcp"}, Claims{"sub": "u1", "exp": now + 60, "aud": "mcp"}, false},
+		{"audience list", JWTConfig{Audience: "mcp"}, Claims{"sub": "u1", "exp": now + 60, "aud": []string{"other", "mcp"}}, false},
+		{"wrong audience", JWTConfig{Audience: "mcp"}, Claims{"sub": "u1", "exp": now + 60, "aud": "other"}, true},
+		{"missing audience", JWTConfig{Audience: "mcp"}, Claims{"sub": "u1", "exp": now + 60}, true},
+		{"wrong issuer", JWTConfig{Issuer: "https://a/"}, Claims{"sub": "u1", "exp": now + 60, "iss": "https://b/"}, true},
+		{"no subject", JWTConfig{}, Claims{"exp": now + 60}, true},
+		{"no exp", JWTConfig{}, Claims{"sub": "u1"}, true},
+		{"no exp allowed", JWTConfig{AllowNoExpiry: true}, Claims{"sub": "u1"}, false},
+		{"string exp", JWTConfig{}, Claims{"sub": "u1", "exp": "9999999999"}, true},
+		{"string exp with AllowNoExpiry", JWTConfig{AllowNoExpiry: true}, Claims{"sub": "u1", "exp": "9999999999"}, true},
+		{"null exp", JWTConfig{}, Claims{"sub": "u1", "exp": nil}, true},
+		{"string nbf", JWTConfig{}, Claims{"sub": "u1", "exp": now + 60, "nbf": "0"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Secret = secret
+			v := newJWTVerifier(cfg)
+			_, _, err := v.verify(context.Background(), signHS256(t, secret, tt.claims))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("error %v does not wrap ErrUnauthorized", err)
+			}
+		})
+	}
+}
+
+func TestJWTRejectsBadSignature(t *testing.T) {
+	v := newJWTVerifier(JWTConfig{Secret: []byte("right")})
+	token := signHS256(t, []byte("wrong"), Claims{"sub": "u1", "exp": time.Now().Unix() + 60})
+	if _, _, err := v.verify(context.Background(), token); err == nil {
+		t.Error("token signed with another secret accepted")
+	}
+	none := encodeJWTPart(t, map[string]string{"alg": "none"}) + "." + encodeJWTPart(t, Claims{"sub": "u1"}) + "."
+	if _, _, err := v.verify(context.Background(), none); err == nil {
+		t.Error("alg none accepted")
+	}
+}
+
+// jwksServer 提供 JWKS 的测试服务，gate 非 nil 时每次请求等待 gate 关闭
+type jwksServer struct {
+	*httptest.Server
+	hits int32
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+	gate chan struct{}
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	js := &jwksServer{keys: map[string]*rsa.PublicKey{}}
+	js.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&js.hits, 1)
+		js.mu.Lock()
+		gate := js.gate
+		js.mu.Unlock()
+		if gate != nil {
+			<-gate
+		}
+		js.mu.Lock()
+		defer js.mu.Unlock()
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, k := range js.keys {
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(js.Close)
+	return js
+}
+
+func (js *jwksServer) setKey(kid string, key *rsa.PublicKey) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.keys[kid] = key
+}
+
+func (js *jwksServer) setGate(gate chan struct{}) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.gate = gate
+}
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	k1, k2 := generateRSAKey(t), generateRSAKey(t)
+	js := newJWKSServer(t)
+	js.setKey("k1", &k1.PublicKey)
+	v := newJWTVerifier(JWTConfig{JWKSURL: js.URL})
+	ctx := context.Background()
+	claims := Claims{"sub": "u1", "exp": time.Now().Unix() + 60}
+
+	if _, _, err := v.verify(ctx, signRS256(t, k1, "k1", claims)); err != nil {
+		t.Fatalf("k1: %v", err)
+	}
+	if _, _, err := v.verify(ctx, signRS256(t, k1, "k1", claims)); err != nil {
+		t.Fatalf("k1 cached: %v", err)
+	}
+	if hits := atomic.LoadInt32(&js.hits); hits != 1 {
+		t.Fatalf("jwks fetched %d times, want 1", hits)
+	}
+
+	// 刚拉取过时未知 kid 不触发拉取
+	js.setKey("k2", &k2.PublicKey)
+	if _, _, err := v.verify(ctx, signRS256(t, k2, "k2", claims)); err == nil {
+		t.Fatal("unknown kid accepted before the refresh interval")
+	}
+	if hits := atomic.LoadInt32(&js.hits); hits != 1 {
+		t.Fatalf("unknown kid refetched jwks within the refresh interval (%d fetches)", hits)
+	}
+
+	// 超过最小间隔后，未知 kid 触发一次拉取；拉取期间已缓存的 kid 照常校验，并发的拉取合并为一次
+	v.mu.Lock()
+	v.fetchedAt = v.fetchedAt.Add(-jwksRefreshMin)
+	v.mu.Unlock()
+	gate := make(chan struct{})
+	js.setGate(gate)
+	errs := make(chan error, 8)
+	k2Token := signRS256(t, k2, "k2", claims)
+	k1Token := signRS256(t, k1, "k1", claims)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, _, err := v.verify(ctx, k2Token)
+			errs <- err
+		}()
+	}
+	for atomic.LoadInt32(&js.hits) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := v.verify(ctx, k1Token)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("k1 during refresh: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("verification of a cached kid blocked on the jwks fetch")
+	}
+	close(gate)
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("k2 after refresh: %v", err)
+		}
+	}
+	if hits := atomic.LoadInt32(&js.hits); hits != 2 {
+		t.Errorf("concurrent refreshes fetched jwks %d times, want 2 in total", hits)
+	}
+}
+
+func TestJWKSUnavailableKeepsCachedKeys(t *testing.T) {
+	k1 := generateRSAKey(t)
+	js := newJWKSServer(t)
+	js.setKey("k1", &k1.PublicKey)
+	v := newJWTVerifier(JWTConfig{JWKSURL: js.URL})
+	ctx := context.Background()
+	token := signRS256(t, k1, "k1", Claims{"sub": "u1", "exp": time.Now().Unix() + 60})
+	if _, _, err := v.verify(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	js.Close()
+	v.mu.Lock()
+	v.fetchedAt = v.fetchedAt.Add(-jwksRefreshMax)
+	v.mu.Unlock()
+	if _, _, err := v.verify(ctx, token); err != nil {
+		t.Errorf("cached key rejected while jwks is unavailable: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package mcpserver
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 日志级别 ----------------------
+
+type LogLevel int32
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[string]LogLevel{
+	"debug": LevelDebug,
+	"info":  LevelInfo,
+	"warn":  LevelWarn,
+	"error": LevelError,
+}
+
+var logLevel int32 = int32(LevelInfo)
+
+// SetLogLevel 设置服务端日志级别：debug / info / warn / error
+func SetLogLevel(level string) error {
+	l, ok := levelNames[strings.ToLower(level)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	atomic.StoreInt32(&logLevel, int32(l))
+	return nil
+}
+
+var (
+	logOutput     mcpctx.Logger = log.Default()
+	logOutputLock sync.RWMutex
+)
+
+// SetLogger 设置服务端日志的输出，nil 表示恢复为标准库默认 logger
+func SetLogger(l mcpctx.Logger) {
+	if l == nil {
+		l = log.Default()
+	}
+	logOutputLock.Lock()
+	defer logOutputLock.Unlock()
+	logOutput = l
+}
+
+func logf(level LogLevel, format string, v ...interface{}) {
+	if int32(level) < atomic.LoadInt32(&logLevel) {
+		return
+	}
+	logOutputLock.RLock()
+	l := logOutput
+	logOutputLock.RUnlock()
+	l.Printf(format, v...)
+}
@@ -0,0 +1,470 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------- 声明式 Manifest ----------------------
+
+// Manifest 用一个 YAML 文件描述完整的 MCP 服务：
+//
+//	server:            # 即 McpConf
+//	  addr: 0.0.0.0
+//	  port: 8074
+//	  transports: [http, ws]
+//	builtins: [geo]    # 内置工具集
+//	tools:
+//	  - name: weather
+//	    description: Query weather by city
+//	    input_schema:
+//	      type: object
+//	      properties: {city: {type: string}}
+//	      required: [city]
+//	    http:
+//	      method: GET
+//	      url: "https://example.com/weather?city={{.city}}"
+//	  - name: disk_usage
+//	    command:
+//	      path: /usr/bin/du
+//	      args: ["-sh", "{{.path}}"]
+//	  - name: fahrenheit   # 需先注册脚本引擎，见 RegisterScriptEngine
+//	    script:
+//	      lang: starlark
+//	      file: scripts/fahrenheit.star
+//	prompts:
+//	  - file: prompts/summary.txt
+//	resources:
+//	  - dir: docs
+//
+// 相对路径都以 manifest 文件所在目录为基准
+type Manifest struct {
+	Server    McpConf            `yaml:"server"`
+	Builtins  []string           `yaml:"builtins"`
+	Tools     []ManifestTool     `yaml:"tools"`
+	Prompts   []ManifestPrompt   `yaml:"prompts"`
+	Resources []ManifestResource `yaml:"resources"`
+
+	// 以下配置段尚未支持，出现时报错而不是静默忽略
+	Auth       yaml.Node `yaml:"auth"`
+	RateLimits yaml.Node `yaml:"rate_limits"`
+}
+
+// ManifestTool 声明式工具，http、command、script 三选一
+type ManifestTool struct {
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description"`
+	InputSchema map[string]interface{} `yaml:"input_schema"`
+	// OutputSchema 结构化结果的 schema，工具输出需是 JSON 对象
+	OutputSchema map[string]interface{} `yaml:"output_schema"`
+	// ReadOnly 工具没有副作用，相同参数的并发调用合并为一次，见 Tool.ReadOnly
+	ReadOnly bool                 `yaml:"read_only"`
+	HTTP     *ManifestHTTPTool    `yaml:"http"`
+	Command  *ManifestCommandTool `yaml:"command"`
+	Script   *ManifestScriptTool  `yaml:"script"`
+}
+
+// ManifestHTTPTool 调用一个 HTTP 接口；url 和 headers 中可以使用 {{.参数名}}，
+// 非 GET 请求把参数作为 JSON body 发送
+type ManifestHTTPTool struct {
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+}
+
+// ManifestCommandTool 执行一个本地命令；args 中可以使用 {{.参数名}}，参数 JSON 同时写入 stdin
+type ManifestCommandTool struct {
+	Path    string        `yaml:"path"`
+	Args    []string      `yaml:"args"`
+	Env     []string      `yaml:"env"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ManifestPrompt 从文件加载提示词，name 为空时取文件名（不含扩展名）；
+// 设置 dir 时加载整个目录，见 LoadPromptsFromDir
+type ManifestPrompt struct {
+	Name string `yaml:"name"`
+	File string `yaml:"file"`
+	Dir  string `yaml:"dir"`
+	// Watch 与 dir 一起使用，目录中的文件变化后自动重新加载，见 McpServer.WatchPromptDir
+	Watch bool `yaml:"watch"`
+	// Arguments 声明的参数，文件内容按 text/template 以 {{.名称}} 引用
+	Arguments []ManifestPromptArgument `yaml:"arguments"`
+}
+
+// ManifestPromptArgument prompt 的一个参数
+type ManifestPromptArgument struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+}
+
+// ManifestResource 把目录下的所有文件注册为资源，资源名为相对路径，URI 为 uri_prefix 加相对路径
+type ManifestResource struct {
+	Dir string `yaml:"dir"`
+	// URIPrefix 资源 URI 的前缀，默认 "file:///"
+	URIPrefix string `yaml:"uri_prefix"`
+	// Watch 监听目录变化，文件增删改时更新资源并通知客户端，见 McpServer.WatchResourceDir。
+	// 由 Manifest.WatchResources 启动，重新加载 manifest 时不会变化
+	Watch bool `yaml:"watch"`
+}
+
+// 声明式工具的默认超时
+const manifestToolTimeout = 30 * time.Second
+
+// LoadManifest 读取并解析 manifest 文件
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// NewServerFromManifest 根据 manifest 构建服务：注册工具、提示词、资源，返回未启动的 McpServer
+func NewServerFromManifest(path string) (*McpServer, error) {
+	m, err := LoadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Apply(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return NewMcpServer(m.Server), nil
+}
+
+// ReloadManifest 重新加载 manifest 中的工具、提示词、资源，替换当前注册表的内容；
+// server 段（地址、端口等）的变化需要重启服务才能生效
+func ReloadManifest(path string) error {
+	m, err := LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	// 先把工具全部构建一遍，避免清空注册表后才发现配置错误
+	for _, t := range m.Tools {
+		if _, err := t.build(filepath.Dir(path)); err != nil {
+			return err
+		}
+	}
+	resetRegistries()
+	return m.Apply(filepath.Dir(path))
+}
+
+// resetRegistries 清空工具、提示词、资源注册表
+func resetRegistries() {
+	DefaultToolRegistry.Reset()
+
+	promptLock.Lock()
+	promptRegistry = make(map[string]*Prompt)
+	promptLock.Unlock()
+
+	resourceLock.Lock()
+	resourceRegistry = make(map[string]*Resource)
+	resourceLock.Unlock()
+}
+
+// Apply 把 manifest 中的工具、提示词、资源注册到全局注册表，baseDir 用于解析相对路径
+func (m *Manifest) Apply(baseDir string) error {
+	if !m.Auth.IsZero() {
+		return fmt.Errorf("manifest: auth section is not supported yet")
+	}
+	if !m.RateLimits.IsZero() {
+		return fmt.Errorf("manifest: rate_limits section is not supported yet")
+	}
+
+	// 重名策略要在注册之前设置
+	if m.Server.ToolConflict != "" {
+		if err := DefaultToolRegistry.SetConflictPolicy(m.Server.ToolConflict); err != nil {
+			return fmt.Errorf("manifest: %w", err)
+		}
+	}
+
+	for _, b := range m.Builtins {
+		switch b {
+		case "geo":
+			testTools()
+		default:
+			return fmt.Errorf("manifest: unknown builtin %q", b)
+		}
+	}
+
+	for _, t := range m.Tools {
+		tool, err := t.build(baseDir)
+		if err != nil {
+			return err
+		}
+		if _, err := RegisterTool(tool); err != nil {
+			return fmt.Errorf("manifest: %w", err)
+		}
+	}
+
+	for _, p := range m.Prompts {
+		if p.Dir != "" {
+			if _, err := LoadPromptsFromDir(resolvePath(baseDir, p.Dir)); err != nil {
+				return fmt.Errorf("manifest: prompts %s: %w", p.Dir, err)
+			}
+			continue
+		}
+		file := resolvePath(baseDir, p.File)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("manifest: prompt %s: %w", p.File, err)
+		}
+		name := p.Name
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		prompt := &Prompt{Name: name, Template: string(content)}
+		for _, arg := range p.Arguments {
+			prompt.Arguments = append(prompt.Arguments, PromptArgument{Name: arg.Name, Description: arg.Description, Required: arg.Required})
+		}
+		RegisterPrompt(prompt)
+	}
+
+	for _, r := range m.Resources {
+		if err := registerResourceDir(resolvePath(baseDir, r.Dir), r.URIPrefix); err != nil {
+			return fmt.Errorf("manifest: resources %s: %w", r.Dir, err)
+		}
+	}
+	return nil
+}
+
+// WatchResources 为 watch 为 true 的资源目录启动监听，在创建服务之后调用
+func (m *Manifest) WatchResources(s *McpServer, baseDir string) error {
+	for _, r := range m.Resources {
+		if !r.Watch {
+			continue
+		}
+		if _, err := s.WatchResourceDir(resolvePath(baseDir, r.Dir), r.URIPrefix); err != nil {
+			return fmt.Errorf("manifest: resources %s: %w", r.Dir, err)
+		}
+	}
+	return nil
+}
+
+// WatchPrompts 为 watch 为 true 的 prompt 目录启动监听，在创建服务之后调用
+func (m *Manifest) WatchPrompts(s *McpServer, baseDir string) error {
+	for _, p := range m.Prompts {
+		if !p.Watch || p.Dir == "" {
+			continue
+		}
+		if _, err := s.WatchPromptDir(resolvePath(baseDir, p.Dir)); err != nil {
+			return fmt.Errorf("manifest: prompts %s: %w", p.Dir, err)
+		}
+	}
+	return nil
+}
+
+func resolvePath(baseDir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(baseDir, p)
+}
+
+// defaultResourceURIPrefix manifest 目录资源的默认 URI 前缀
+const defaultResourceURIPrefix = "file:///"
+
+// registerResourceDir 把目录下每个文件注册为资源，MIME 类型按扩展名推断，非 UTF-8 的文件作为二进制内容
+func registerResourceDir(dir, uriPrefix string) error {
+	if uriPrefix == "" {
+		uriPrefix = defaultResourceURIPrefix
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		r, err := loadFileResource(dir, uriPrefix, path)
+		if err != nil {
+			return err
+		}
+		RegisterResource(r)
+		return nil
+	})
+}
+
+// ---------------------- 声明式工具实现 ----------------------
+
+func (t ManifestTool) build(baseDir string) (*Tool, error) {
+	if t.Name == "" {
+		return nil, fmt.Errorf("manifest: tool without name")
+	}
+	kinds := 0
+	for _, set := range []bool{t.HTTP != nil, t.Command != nil, t.Script != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("manifest: tool %s must define exactly one of http / command / script", t.Name)
+	}
+
+	tool := &Tool{Name: t.Name, Description: t.Description, ReadOnly: t.ReadOnly}
+	if t.InputSchema != nil {
+		schema, err := json.Marshal(t.InputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: tool %s: input_schema: %w", t.Name, err)
+		}
+		tool.InputSchema = schema
+	}
+	if t.OutputSchema != nil {
+		schema, err := json.Marshal(t.OutputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: tool %s: output_schema: %w", t.Name, err)
+		}
+		tool.OutputSchema = schema
+	}
+	if t.HTTP != nil {
+		h := *t.HTTP
+		if h.Method == "" {
+			h.Method = http.MethodGet
+		}
+		if _, err := template.New("url").Parse(h.URL); err != nil {
+			return nil, fmt.Errorf("manifest: tool %s: %w", t.Name, err)
+		}
+		tool.Handler = h.call
+	} else if t.Script != nil {
+		script, err := t.Script.compile(t.Name, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: tool %s: %w", t.Name, err)
+		}
+		tool.Handler = script.call
+	} else {
+		c := *t.Command
+		if c.Path == "" {
+			return nil, fmt.Errorf("manifest: tool %s: command path is required", t.Name)
+		}
+		tool.Handler = c.call
+	}
+	return tool, nil
+}
+
+// renderArg 用工具参数渲染模板字符串
+func renderArg(tmpl string, args map[string]interface{}) (string, error) {
+	t, err := template.New("arg").Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, args); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func decodeArgs(raw json.RawMessage) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return args, nil
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// decodeOutput 输出是 JSON 时按 JSON 返回，否则作为字符串返回
+func decodeOutput(out []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(out, &v); err == nil {
+		return v
+	}
+	return strings.TrimRight(string(out), "\n")
+}
+
+func timeoutOrDefault(d time.Duration) time.Duration {
+	if d <= 0 {
+		return manifestToolTimeout
+	}
+	return d
+}
+
+func (h ManifestHTTPTool) call(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	args, err := decodeArgs(raw)
+	if err != nil {
+		return nil, err
+	}
+	url, err := renderArg(h.URL, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if h.Method != http.MethodGet && len(raw) > 0 {
+		body = bytes.NewReader(raw)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutOrDefault(h.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, h.Method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range h.Headers {
+		if v, err = renderArg(v, args); err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	return decodeOutput(out), nil
+}
+
+func (c ManifestCommandTool) call(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	args, err := decodeArgs(raw)
+	if err != nil {
+		return nil, err
+	}
+	argv := make([]string, 0, len(c.Args))
+	for _, a := range c.Args {
+		v, err := renderArg(a, args)
+		if err != nil {
+			return nil, err
+		}
+		argv = append(argv, v)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutOrDefault(c.Timeout))
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.Path, argv...)
+	cmd.Env = append(os.Environ(), c.Env...)
+	cmd.Stdin = bytes.NewReader(raw)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("command %s failed: %v: %s", c.Path, err, strings.TrimSpace(stderr.String()))
+	}
+	return decodeOutput(out), nil
+}
@@ -1,13 +1,21 @@
 package mcpserver
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"mcptool/mcpctx"
+	"mcptool/version"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 // MCP 里常用的 method 示例
@@ -18,83 +26,88 @@ import (
 // "system.describe"	可选方法，一些 JSON-RPC 服务提供的自描述接口
 // "system.listMethods"	列出服务端支持的所有方法
 // "system.version"	获取服务端 JSON-RPC 版本
+// 另外兼容 MCP 规范的 slash 风格方法名（tools/call、resources/read 等），见 methodAliases
 
-// Methods 全局方法开关表
-// key: 方法名，如 "tools.run"
-// value: 是否启用（true=启用，false=禁用）
-var Methods = map[string]bool{
-	"tools.run":          true,
-	"tools.list":         true,
-	"resources.get":      true,
-	"resources.list":     true,
-	"prompts.get":        true,
-	"prompts.list":       true,
-	"server.info":        true,
-	"system.describe":    true,
-	"system.listMethods": true,
-	"system.version":     true,
-}
-
-// 检查方法是否启用
-func IsMethodEnabled(method string) bool {
-	enabled, ok := Methods[method]
-	return ok && enabled
-}
-
-// 设置方法开关
-func SetMethodEnabled(method string, enabled bool) {
-	if _, ok := Methods[method]; ok {
-		Methods[method] = enabled
-	}
-}
-
-// 获取当前启用的 Method 列表
-func ListEnabledMethods() []string {
-	enabled := []string{}
-	for method, ok := range Methods {
-		if ok {
-			enabled = append(enabled, method)
-		}
+// methodAliases MCP 规范中的 slash 风格方法名 -> 服务端内部的方法名，
+// 官方 MCP 客户端发送的都是左侧的名字
+var methodAliases = map[string]string{
+	"tools/list":     "tools.list",
+	"tools/call":     "tools.run",
+	"resources/list": "resources.list",
+	"resources/read": "resources.get",
+	"prompts/list":   "prompts.list",
+	"prompts/get":    "prompts.get",
+}
+
+// CanonicalMethod 把规范方法名转换为内部方法名，非别名原样返回
+func CanonicalMethod(method string) string {
+	if m, ok := methodAliases[method]; ok {
+		return m
 	}
-	return enabled
+	return method
 }
 
 // ---------------------- JSON-RPC 基础结构 ----------------------
+// RPCRequest.ID / RPCResponse.ID 按规范可以是数字或字符串，服务端不解析，原样回传
 type RPCRequest struct {
 	JsonRPC string          `json:"jsonrpc"`
-	ID      uint64          `json:"id"`
+	ID      json.RawMessage `json:"id"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params"`
 }
 
 type RPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 type RPCResponse struct {
-	JsonRPC string      `json:"jsonrpc"`
-	ID      uint64      `json:"id"`
-	Result  interface{} `json:"result,omitempty"`
-	Error   *RPCError   `json:"error,omitempty"`
+	JsonRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	Meta    *ResponseMeta   `json:"meta,omitempty"`
+}
+
+// ResponseMeta 响应附带的元信息，仅在 McpConf.ResponseMeta 开启时返回
+type ResponseMeta struct {
+	ProcessingMs float64 `json:"processing_ms"`
+	ServerID     string  `json:"server_id,omitempty"`
+	CacheHit     bool    `json:"cache_hit,omitempty"`
+	CostUnits    float64 `json:"cost_units,omitempty"`
+	// Steps 工具记录的子步骤，见 steps.go
+	Steps []StepRecord `json:"steps,omitempty"`
 }
 
 // ---------------------- 工具参数结构 ----------------------
 type GeocodeToolInput struct {
-	Address string `json:"address"`
+	Address string `json:"address" jsonschema:"minLength=1"`
 	City    string `json:"city,omitempty"`
 }
 
 type POISearchToolInput struct {
-	Keywords string `json:"keywords"`
+	Keywords string `json:"keywords" jsonschema:"minLength=1"`
 	City     string `json:"city,omitempty"`
-	Limit    int    `json:"limit,omitempty"`
+	Limit    int    `json:"limit,omitempty" jsonschema:"minimum=0,maximum=50"`
 }
 
 type RouteToolInput struct {
-	Origin      string `json:"origin"`
-	Destination string `json:"destination"`
-	Mode        string `json:"mode,omitempty"`
+	Origin        string   `json:"origin"`
+	Destination   string   `json:"destination"`
+	Mode          string   `json:"mode,omitempty"`           // driving / walking / transit / cycling
+	Waypoints     []string `json:"waypoints,omitempty"`      // 途经点，按顺序经过
+	Avoid         []string `json:"avoid,omitempty"`          // 规避项：tolls / highways / ferries
+	DepartureTime string   `json:"departure_time,omitempty"` // RFC3339 出发时间，为空表示现在
+}
+
+type ReverseGeocodeToolInput struct {
+	Lat float64 `json:"lat" jsonschema:"minimum=-90,maximum=90"`
+	Lng float64 `json:"lng" jsonschema:"minimum=-180,maximum=180"`
+}
+
+type IPLocateToolInput struct {
+	IP string `json:"ip"`
 }
 
 // ---------------------- 工具逻辑 ----------------------
@@ -126,309 +139,902 @@ func handlePOISearch(input POISearchToolInput) []map[string]interface{} {
 }
 
 func handleRoute(input RouteToolInput) map[string]interface{} {
+	// 演示数据：每个途经点增加 5km，不同出行方式速度不同
+	distance := 10 + 5*len(input.Waypoints)
+	minutesPerKm := map[string]int{"driving": 2, "transit": 3, "cycling": 4, "walking": 12}[input.Mode]
+	if minutesPerKm == 0 {
+		minutesPerKm = 2
+	}
 	return map[string]interface{}{
-		"origin":      input.Origin,
-		"destination": input.Destination,
-		"mode":        input.Mode,
-		"distance":    "10km",
-		"duration":    "20min",
+		"origin":         input.Origin,
+		"destination":    input.Destination,
+		"mode":           input.Mode,
+		"waypoints":      input.Waypoints,
+		"avoid":          input.Avoid,
+		"departure_time": input.DepartureTime,
+		"distance":       fmt.Sprintf("%dkm", distance),
+		"duration":       fmt.Sprintf("%dmin", distance*minutesPerKm),
+	}
+}
+
+func handleReverseGeocode(input ReverseGeocodeToolInput) map[string]interface{} {
+	return map[string]interface{}{
+		"address": fmt.Sprintf("Mock Address near (%.4f, %.4f)", input.Lat, input.Lng),
+		"lat":     input.Lat,
+		"lng":     input.Lng,
+		"city":    "北京",
+	}
+}
+
+func handleIPLocate(input IPLocateToolInput) map[string]interface{} {
+	return map[string]interface{}{
+		"ip":      input.IP,
+		"country": "中国",
+		"city":    "北京",
+		"lat":     39.9042,
+		"lng":     116.4074,
 	}
 }
 
 // ---------------------- 工具列表 ----------------------
-func listTools() interface{} {
-	return map[string]interface{}{"tools": ListTools()}
+func (s *McpServer) listTools(ctx context.Context, access AccessRequest) interface{} {
+	return map[string]interface{}{"tools": s.visibleTools(ctx, access)}
 }
 
 // ---------------------- HTTP MCP Handler ----------------------
-func httpHandler(w http.ResponseWriter, r *http.Request) {
+func (s *McpServer) httpHandler(w http.ResponseWriter, r *http.Request) {
+	// GET / DELETE 见 streamable.go
+	switch r.Method {
+	case http.MethodGet:
+		s.streamableGet(w, r)
+		return
+	case http.MethodDelete:
+		s.streamableDelete(w, r)
+		return
+	}
+	if s.wire.enabled() {
+		body, _ := io.ReadAll(r.Body)
+		s.wire.frame("http", r.RemoteAddr, "recv", body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
 	var req RPCRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), 400)
+		s.writeParseError(w, err)
+		return
+	}
+	if !checkProtocolHeader(w, r) {
 		return
 	}
 
-	resp := RPCResponse{
-		JsonRPC: "2.0",
-		ID:      req.ID,
+	// 通过 HTTP+SSE 传输转发的请求属于对应的会话；Streamable HTTP 的会话由 initialize 创建，
+	// 之后按 Mcp-Session-Id 查找
+	var (
+		session    mcpctx.Session
+		streamable *streamableSession
+	)
+	costKey := s.costKeyFromRequest(r, "")
+	inspector := false
+	if sess, ok := inspectorSessionFrom(r); ok {
+		session, costKey.Session, inspector = sess, sess.id, true
+	} else if id := r.Header.Get("Mcp-Session-Id"); id != "" {
+		if streamable, ok = s.lookupStreamableSession(r, id); !ok {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+	} else if req.Method == "initialize" {
+		streamable = s.newStreamableSession(r)
+		w.Header().Set("Mcp-Session-Id", streamable.id)
+	}
+	if streamable != nil {
+		session, costKey.Session = streamable, streamable.id
 	}
 
-	switch req.Method {
+	if !inspector && len(req.ID) > 0 && CanonicalMethod(req.Method) == "tools.run" && acceptsEventStream(r) {
+		if s.streamRequest(w, r, req, streamable, costKey) {
+			return
+		}
+	}
+	resp := s.dispatch(s.requestContext(r, "http", session), req, costKey)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if !inspector {
+		// HTTP+SSE 会话的响应经 SSE 流发出，在那里转储
+		s.wire.json("http", r.RemoteAddr, resp)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
 
-	case "tools.list":
-		resp.Result = listTools()
+// ---------------------- SSE Handler（Optional） ----------------------
+// 每个 SSE 连接有一个带缓冲的发送队列，由连接自己的 goroutine 写出；广播只入队不阻塞。
+// 队列满说明客户端读得太慢，断开该连接，避免拖慢其他连接或无限占用内存
 
-	case "tools.run":
-		var params struct {
-			Name      string          `json:"name"`
-			Arguments json.RawMessage `json:"arguments"`
-		}
-		if err := json.Unmarshal(req.Params, &params); err != nil {
-			resp.Error = &RPCError{Code: -32602, Message: "Invalid params"}
-			break
-		}
+// defaultSSEBufferSize 每个 SSE 连接默认可以积压的消息数
+const defaultSSEBufferSize = 64
 
-		if result, err := CallToolByName(params.Name, params.Arguments); err != nil {
-			resp.Error = &RPCError{Code: -32601, Message: err.Error()}
-		} else {
-			resp.Result = result
-		}
-		// resources
-	case "resources.get":
-		var params struct {
-			Name string `json:"name"`
+type SSEClient struct {
+	remote    string
+	writer    http.ResponseWriter
+	flusher   http.Flusher
+	throttle  *connThrottle
+	send      chan []byte
+	evicted   chan struct{} // 因积压被断开时关闭
+	evictOnce sync.Once
+	filter    eventFilter // ?events= 订阅的事件，为空表示全部
+	wire      *wireDump
+}
+
+// write 按连接的速率写出并 flush，只在连接自己的 goroutine 中调用
+func (c *SSEClient) write(msg []byte) {
+	c.wire.frame("sse", c.remote, "send", msg)
+	c.throttle.writer(c.writer).Write(msg)
+	c.flusher.Flush()
+}
+
+// enqueue 把消息放入发送队列，队列已满时断开连接
+func (c *SSEClient) enqueue(msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		c.evictOnce.Do(func() {
+			logf(LevelWarn, "sse client %s is too slow (%d messages pending), disconnecting", c.remote, len(c.send))
+			close(c.evicted)
+		})
+	}
+}
+
+// startSSE 写出 SSE 响应头并创建连接的发送队列，ResponseWriter 不支持流式输出时返回 500
+func (s *McpServer) startSSE(w http.ResponseWriter, r *http.Request) (*SSEClient, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// 关闭 NGINX 的响应缓冲，事件才能及时到达客户端
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &SSEClient{
+		remote:   r.RemoteAddr,
+		writer:   w,
+		flusher:  flusher,
+		throttle: s.newThrottle(),
+		send:     make(chan []byte, s.conf.SSEBufferSize),
+		evicted:  make(chan struct{}),
+		wire:     &s.wire,
+	}, true
+}
+
+// pumpSSE 把发送队列写到连接，直到客户端断开、因积压被断开或服务停止
+func (s *McpServer) pumpSSE(r *http.Request, client *SSEClient) {
+	for {
+		select {
+		case msg := <-client.send:
+			client.write(msg)
+		case <-r.Context().Done():
+			return
+		case <-client.evicted:
+			return
+		case <-s.drained:
+			// 服务停止时把队列中剩余的通知发完再结束响应
+			for {
+				select {
+				case msg := <-client.send:
+					client.write(msg)
+				default:
+					return
+				}
+			}
 		}
-		if err := json.Unmarshal(req.Params, &params); err != nil {
-			resp.Error = &RPCError{Code: -32602, Message: "Invalid params"}
-			break
+	}
+}
+
+func (s *McpServer) sseHandler(w http.ResponseWriter, r *http.Request) {
+	// ?topics= 订阅的主题，?events= 为旧名
+	var patterns []string
+	for _, key := range []string{"topics", "events"} {
+		if q := r.URL.Query().Get(key); q != "" {
+			patterns = append(patterns, strings.Split(q, ",")...)
 		}
-		if r, err := GetResource(params.Name); err != nil {
-			resp.Error = &RPCError{Code: -32601, Message: err.Error()}
-		} else {
-			resp.Result = r
+	}
+	filter, err := parseEventFilter(patterns)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client, ok := s.startSSE(w, r)
+	if !ok {
+		return
+	}
+	client.filter = filter
+	// 重连时补发错过的事件，见 ssereplay.go
+	lastID := r.Header.Get("Last-Event-ID")
+	s.sseMu.Lock()
+	var replay []sseEvent
+	gap := false
+	if lastID != "" {
+		replay, gap = s.sseReplay.since(lastID)
+	}
+	s.sseClients[client] = struct{}{}
+	s.sseMu.Unlock()
+	defer func() {
+		s.sseMu.Lock()
+		delete(s.sseClients, client)
+		s.sseMu.Unlock()
+	}()
+	if s.conf.SSERetry > 0 {
+		client.write([]byte(fmt.Sprintf("retry: %d\n\n", s.conf.SSERetry.Milliseconds())))
+	}
+	if gap {
+		logf(LevelInfo, "sse client %s resumed from %s, some events are no longer buffered", client.remote, lastID)
+		client.write(sseGapEvent(lastID, replay))
+	}
+	for _, e := range replay {
+		if filter.match(e.event) {
+			client.write(e.msg)
 		}
-	case "resources.list":
-		resp.Result = map[string]interface{}{"resources": ListResources()}
+	}
+	s.pumpSSE(r, client)
+}
+
+// snapshotSSEClients 本服务当前的 SSE 连接，发送时不持有锁
+func (s *McpServer) snapshotSSEClients() []*SSEClient {
+	s.sseMu.Lock()
+	defer s.sseMu.Unlock()
+	return s.sseClientListLocked()
+}
 
-	// prompts
-	case "prompts.get":
-		var params struct {
-			Name string `json:"name"`
+func (s *McpServer) sseClientListLocked() []*SSEClient {
+	clients := make([]*SSEClient, 0, len(s.sseClients))
+	for client := range s.sseClients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// sendSSE 广播已取号的事件，id 字段为事件序号，data 中的对象附带 _meta.event；
+// 发给本服务订阅了该事件的连接，补发缓冲中保存全部事件
+func (s *McpServer) sendSSE(stamp EventStamp, event string, data interface{}) {
+	payload, _ := json.Marshal(data)
+	msg := []byte(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", stamp.ID(), event, stampPayload(payload, stamp)))
+	s.sseMu.Lock()
+	s.sseReplay.add(stamp, event, msg)
+	clients := s.sseClientListLocked()
+	s.sseMu.Unlock()
+	for _, client := range clients {
+		if client.filter.match(event) {
+			client.enqueue(msg)
 		}
-		if err := json.Unmarshal(req.Params, &params); err != nil {
-			resp.Error = &RPCError{Code: -32602, Message: "Invalid params"}
-			break
+	}
+}
+
+// broadcastSSEComment 发送 SSE 注释行，客户端会忽略，仅用于保活
+func (s *McpServer) broadcastSSEComment(text string) {
+	msg := []byte(": " + text + "\n\n")
+	for _, client := range s.snapshotSSEClients() {
+		client.enqueue(msg)
+	}
+}
+
+type McpConf struct {
+	Addr string `yaml:"addr" default:"localhost"`
+	Port int    `yaml:"port" default:"8074"`
+	// ServerName / ServerVersion initialize 和 server.info 返回的服务名称、版本，默认 "MCP Server" 和库版本
+	ServerName    string `yaml:"server_name"`
+	ServerVersion string `yaml:"server_version"`
+	// Instructions 非空时随 initialize 返回，告诉模型如何使用本服务
+	Instructions string `yaml:"instructions"`
+	// Capabilities 声明的能力，为空时声明全部，见 serverinfo.go
+	Capabilities *ServerCapabilities `yaml:"capabilities"`
+	// Transports 启用的传输方式（http / ws / sse），为空表示全部启用
+	Transports []string `yaml:"transports"`
+	HTTPPath   string   `yaml:"http_path" default:"/mcp"`
+	WSPath     string   `yaml:"ws_path" default:"/ws"`
+	SSEPath    string   `yaml:"sse_path" default:"/sse"`
+	// ResponseMeta 为 true 时每个响应都带 meta（耗时、实例 ID、缓存命中、成本）
+	ResponseMeta bool `yaml:"response_meta"`
+	// ServerID 实例标识，为空时使用 hostname-pid
+	ServerID string `yaml:"server_id"`
+	// NotifyCoalesceWindow 同一资源的更新通知在该窗口内合并，0 表示不合并
+	NotifyCoalesceWindow time.Duration `yaml:"notify_coalesce_window"`
+	// ResumeWindow WS 断线后会话保留的时长，期间客户端可凭 resume token 恢复，0 表示不保留
+	ResumeWindow time.Duration `yaml:"resume_window"`
+	// ExposeErrors 为 true 时错误响应带上内部错误详情，仅建议在开发环境开启
+	ExposeErrors bool `yaml:"expose_errors"`
+	// ReadOnlyPath 非空时额外开放一个只允许 list/get/info 方法的 HTTP 端点，如 "/mcp-ro"
+	ReadOnlyPath string `yaml:"read_only_path"`
+	// InspectorPath 非空时开放规范的 HTTP+SSE 传输（如 "/inspector"，Inspector 连接 /inspector/sse），
+	// 供官方 MCP Inspector 等只支持该传输的工具调试使用
+	InspectorPath string `yaml:"inspector_path"`
+	// ToolConflict 工具重名时的处理：replace（默认）/ error / version-suffix，作用于服务的工具注册表
+	ToolConflict string `yaml:"tool_conflict"`
+	// DisabledTools 启动时禁用的工具，运行中可通过 admin.tools 重新启用
+	DisabledTools []string `yaml:"disabled_tools"`
+	// WireDump 非空时把本服务收发的原始报文脱敏后转储到该文件（"-" 为标准错误），用于排查互通问题，见 SetWireDump
+	WireDump string `yaml:"wire_dump"`
+	// Flags 静态功能开关，非空时作为本服务的 FlagProvider；也可用 WithFlagProvider 接入 LaunchDarkly 等
+	Flags map[string]FlagRule `yaml:"flags"`
+	// Methods 覆盖默认的方法开关，如 {"admin.tools": true}，见 Methods
+	Methods map[string]bool `yaml:"methods"`
+	// MethodFlags 内部方法名（如 "admin.costs"）-> 开关名，开关对调用方关闭时该方法返回 Method not found
+	MethodFlags map[string]string `yaml:"method_flags"`
+	// APIKeys 非空时所有端点要求认证：API Key -> 主体名（为空时使用 key 的指纹），见 WithTokenValidator
+	APIKeys map[string]string `yaml:"api_keys"`
+	// JWTSecret / JWTPublicKeyFile / JWKSURL 任一非空时接受 JWT（HS256 / RS256），见 WithJWT
+	JWTSecret        string `yaml:"jwt_secret"`
+	JWTPublicKeyFile string `yaml:"jwt_public_key_file"`
+	JWKSURL          string `yaml:"jwks_url"`
+	// JWTIssuer / JWTAudience 非空时校验 iss / aud
+	JWTIssuer   string `yaml:"jwt_issuer"`
+	JWTAudience string `yaml:"jwt_audience"`
+	// JWTAllowNoExpiry 接受没有 exp 的 JWT，默认拒绝
+	JWTAllowNoExpiry bool `yaml:"jwt_allow_no_expiry"`
+	// JWTTenantClaim JWT 中表示租户的声明，默认 tenant
+	JWTTenantClaim string `yaml:"jwt_tenant_claim"`
+	// PrincipalTenants 已认证主体（API Key 的主体名、JWT 的 sub、mTLS 证书的 CN 等）所属的租户，
+	// JWT 带租户声明时以声明为准。开启认证后租户只来自这两处，X-Tenant-ID 请求头被忽略
+	PrincipalTenants map[string]string `yaml:"principal_tenants"`
+	// Scopes 非空时按 JWT 的 scope 授权：scope -> 允许的方法，见 ScopePolicy
+	Scopes map[string][]string `yaml:"scopes"`
+	// ACL 按调用方允许 / 拒绝方法和工具，见 ACLConfig
+	ACL *ACLConfig `yaml:"acl"`
+	// CompressThreshold 大于 0 时，超过该字节数的结果内容块对声明支持的客户端压缩，见 RegisterContentEncoding
+	CompressThreshold int `yaml:"compress_threshold"`
+	// StartupChecks Start 时执行依赖健康检查：fail 任一失败即启动失败，degrade 在 tools.list 中标记受影响的工具，
+	// 为空不执行，见 RegisterHealthCheck
+	StartupChecks string `yaml:"startup_checks"`
+	// HealthCheckTimeout 单个健康检查的超时，默认 5s
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout"`
+	// SessionCallRate 每个会话每分钟最多调用 tools.run 的次数，0 表示不限；SessionCallBurst 可攒的次数，默认等于 SessionCallRate
+	SessionCallRate  int `yaml:"session_call_rate"`
+	SessionCallBurst int `yaml:"session_call_burst"`
+	// ConnByteRate 每条 WS / SSE 连接的出站字节速率上限（字节/秒），0 表示不限
+	ConnByteRate int64 `yaml:"conn_byte_rate"`
+	// TotalByteRate 所有 WS / SSE 连接合计的出站速率上限，由正在发送的连接平分，0 表示不限
+	TotalByteRate int64 `yaml:"total_byte_rate"`
+
+	// 以下保活参数需小于前置代理的空闲超时（Envoy/NGINX 常见为 60s），为 0 时使用默认值
+	// WSPingInterval WS 服务端 ping 间隔，默认 25s
+	WSPingInterval time.Duration `yaml:"ws_ping_interval"`
+	// WSPongTimeout 超过 ping 间隔加该时长仍未收到任何消息（含 pong）即断开；
+	// 0 表示不检测，只有持续读取连接的客户端才会及时回 pong，开启前确认客户端行为
+	WSPongTimeout time.Duration `yaml:"ws_pong_timeout"`
+	// WSIdleTimeout 客户端持续该时长没有发来请求（pong 不算）时关闭 WS 连接，0 表示不限；处理请求期间不计时
+	WSIdleTimeout time.Duration `yaml:"ws_idle_timeout"`
+	// WSMaxMessageSize 单条 WS 消息的上限（字节），超出时以 1009 关闭连接，默认 16MB，-1 表示不限
+	WSMaxMessageSize int64 `yaml:"ws_max_message_size"`
+	// WSAllowedOrigins 允许发起 WS 握手的浏览器 Origin，支持通配符（如 "https://*.example.com"），"*" 允许全部；
+	// 为空时只允许与 Host 同源的请求。不带 Origin 的非浏览器客户端总是允许
+	WSAllowedOrigins []string `yaml:"ws_allowed_origins"`
+	// WSReadBufferSize / WSWriteBufferSize WS 连接的读写缓冲（字节），0 使用 4KB
+	WSReadBufferSize  int `yaml:"ws_read_buffer_size"`
+	WSWriteBufferSize int `yaml:"ws_write_buffer_size"`
+	// WSCompression 与客户端协商 permessage-deflate 压缩
+	WSCompression bool `yaml:"ws_compression"`
+	// ClientPingInterval 大于 0 时 WS、stdio 会话按该间隔向客户端发送 JSON-RPC ping，
+	// 一个间隔内没有响应即断开，0 表示不发送，见 ping.go
+	ClientPingInterval time.Duration `yaml:"client_ping_interval"`
+	// GCInterval 过期数据清理的间隔，默认 1m；SessionTTL 会话状态（会话成本条目、调用预算、推荐历史）
+	// 空闲多久后清理，默认 30m，见 janitor.go
+	GCInterval time.Duration `yaml:"gc_interval"`
+	SessionTTL time.Duration `yaml:"session_ttl"`
+	// BackupDir 非空时 admin.backup 可把备份写入该目录；BackupInterval 大于 0 时按该间隔定期备份，
+	// 保留最近 BackupKeep 份（默认 7），见 backup.go
+	BackupDir      string        `yaml:"backup_dir"`
+	BackupInterval time.Duration `yaml:"backup_interval"`
+	BackupKeep     int           `yaml:"backup_keep"`
+	// ListPageSize tools.list / resources.list / prompts.list 每页最多返回的条目数，0 表示不分页，见 pagination.go
+	ListPageSize int `yaml:"list_page_size"`
+	// SSEHeartbeat SSE 注释心跳间隔，默认 15s，-1 表示不发送（前面没有会断开空闲连接的代理时）
+	SSEHeartbeat time.Duration `yaml:"sse_heartbeat"`
+	// SSEBufferSize 每个 SSE 连接可积压的消息数，超出时断开该连接，默认 64
+	SSEBufferSize int `yaml:"sse_buffer_size"`
+	// SSERetry 连接建立时发给客户端的 retry: 重连间隔，默认 3s，-1 表示不发送
+	SSERetry time.Duration `yaml:"sse_retry"`
+	// SSEReplaySize 保留的最近事件数，客户端带 Last-Event-ID 重连时补发，默认 256，-1 表示不保留
+	SSEReplaySize int `yaml:"sse_replay_size"`
+	// IdleTimeout HTTP keep-alive 连接的空闲超时，默认 120s
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// ReadTimeout / WriteTimeout HTTP 服务的读写超时，0 表示不限制；WriteTimeout 会截断 SSE 长连接
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+
+	// TLSCertFile / TLSKeyFile 非空时以 HTTPS / WSS 提供服务
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+	// TLSClientCAFile 非空时开启 mTLS，用其中的 CA 校验客户端证书
+	TLSClientCAFile string `yaml:"tls_client_ca_file"`
+	// TLSClientAuth 客户端证书校验方式：require（默认）/ optional
+	TLSClientAuth string `yaml:"tls_client_auth"`
+}
+
+// 保活参数默认值，均低于常见代理 60s 的空闲超时
+const (
+	defaultWSPingInterval = 25 * time.Second
+	wsPingWriteTimeout    = 10 * time.Second
+	defaultSSEHeartbeat   = 15 * time.Second
+	defaultIdleTimeout    = 120 * time.Second
+
+	defaultWSMaxMessageSize = 16 << 20
+)
+
+type McpServer struct {
+	conf      McpConf
+	tools     *ToolRegistry
+	logger    mcpctx.Logger
+	tlsConfig *tls.Config
+	// wsCheckOrigin 非空时代替 WSAllowedOrigins 校验 WS 握手的 Origin
+	wsCheckOrigin func(r *http.Request) bool
+	validator     TokenValidator
+	jwt           *jwtVerifier
+	policy        Policy
+
+	methodsMu  sync.RWMutex
+	methods    map[string]bool // 已知方法及默认开关
+	toggles    []*methodToggle // 设置过的开关规则，见 SetMethodEnabled
+	dispatcher dispatcher      // 方法处理函数，见 RegisterMethod
+
+	authorizers []Authorizer
+	aclMu       sync.Mutex
+	sessionACLs map[string]*ACLRule // 连接级规则，见 SetSessionACL
+	notifier    *notificationQueue
+	bus         *EventBus
+	bandwidth   *bandwidth     // 出站限速，nil 表示不限
+	budget      *callBudget    // 会话调用预算，nil 表示不限
+	suggester   *toolSuggester // 工具推荐，nil 表示关闭，见 WithToolSuggestions
+	stepTracer  StepTracer     // 工具子步骤的追踪，见 WithStepTracer
+	janitor     janitor        // 过期数据清理，见 RegisterCollector
+	flights     flightGroup    // 并发相同请求的合并，见 coalesce.go
+	backups     backups        // 备份的各部分，见 RegisterBackupPart
+	distances   *distanceCache // distance_matrix 的单元格缓存，见 distance.go
+	flags       FlagProvider   // 本服务的功能开关，nil 时使用 SetFlagProvider 设置的默认数据源
+	wire        wireDump       // 本服务的报文转储，见 wiredump.go
+	wireStats   wireStats      // 各请求形状的使用统计，见 compat.go
+	// rootsChanged 客户端的 roots 变化时的回调，见 WithRootsChangedHandler
+	rootsChanged []func(ctx context.Context)
+
+	// 各传输的连接和会话只属于本服务：通知只发给这里的连接（见 EventBus.deliver），
+	// WS 会话也只能在创建它的服务上恢复
+	sseMu              sync.Mutex
+	sseClients         map[*SSEClient]struct{}
+	sseReplay          *sseRing // 由 sseMu 保护，见 ssereplay.go
+	wsSessionsMu       sync.Mutex
+	wsSessions         map[string]*wsSession // key: resume token
+	inspectorMu        sync.Mutex
+	inspectorSessions  map[string]*inspectorSession
+	streamableMu       sync.Mutex
+	streamableSessions map[string]*streamableSession
+	stdioMu            sync.Mutex
+	stdioSessions      map[*stdioSession]struct{}
+
+	handlerOnce sync.Once
+	handler     http.Handler
+
+	// 以下用于优雅停止，见 Stop
+	mu           sync.Mutex
+	httpServer   *http.Server
+	wsConns      map[*wsConn]struct{}
+	stopping     int32         // 开始停止后为 1，WS 连接不再处理新请求
+	inflight     int64         // 进行中的工具调用数
+	stdioServing int32         // 运行中的 ServeStdio 数，见 transports
+	stop         chan struct{} // 关闭后后台任务退出，通知队列发完剩余通知
+	drained      chan struct{} // 剩余通知发完后关闭，SSE 响应随之结束
+	background   sync.WaitGroup
+	stopOnce     sync.Once
+}
+
+// NewMcpServer 创建服务，默认使用 DefaultToolRegistry 中的工具，opts 见 Option
+func NewMcpServer(conf McpConf, opts ...Option) *McpServer {
+	return NewMcpServerWithTools(conf, DefaultToolRegistry, opts...)
+}
+
+// NewMcpServerWithTools 使用指定的工具注册表创建服务，同一进程内的多个服务可以提供不同的工具
+func NewMcpServerWithTools(conf McpConf, tools *ToolRegistry, opts ...Option) *McpServer {
+	s := &McpServer{
+		conf:               conf,
+		tools:              tools,
+		wsConns:            make(map[*wsConn]struct{}),
+		sseClients:         make(map[*SSEClient]struct{}),
+		wsSessions:         make(map[string]*wsSession),
+		inspectorSessions:  make(map[string]*inspectorSession),
+		streamableSessions: make(map[string]*streamableSession),
+		stdioSessions:      make(map[*stdioSession]struct{}),
+		distances:          newDistanceCache(distanceMatrixCacheSize),
+		stop:               make(chan struct{}),
+		drained:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	conf, tools = s.conf, s.tools
+
+	if conf.ServerName == "" {
+		conf.ServerName = defaultServerName
+	}
+	if conf.ServerVersion == "" {
+		conf.ServerVersion = version.Library
+	}
+	if conf.HTTPPath == "" {
+		conf.HTTPPath = "/mcp"
+	}
+	if conf.WSPath == "" {
+		conf.WSPath = "/ws"
+	}
+	if conf.JWTTenantClaim == "" {
+		conf.JWTTenantClaim = "tenant"
+	}
+	if conf.SSEPath == "" {
+		conf.SSEPath = "/sse"
+	}
+	if conf.WSPingInterval <= 0 {
+		conf.WSPingInterval = defaultWSPingInterval
+	}
+	if conf.WSMaxMessageSize == 0 {
+		conf.WSMaxMessageSize = defaultWSMaxMessageSize
+	}
+	if conf.SSEHeartbeat == 0 {
+		conf.SSEHeartbeat = defaultSSEHeartbeat
+	}
+	if conf.SSEBufferSize <= 0 {
+		conf.SSEBufferSize = defaultSSEBufferSize
+	}
+	if conf.SSERetry == 0 {
+		conf.SSERetry = defaultSSERetry
+	}
+	if conf.SSEReplaySize == 0 {
+		conf.SSEReplaySize = defaultSSEReplaySize
+	}
+	s.sseReplay = newSSERing(conf.SSEReplaySize)
+	if conf.IdleTimeout <= 0 {
+		conf.IdleTimeout = defaultIdleTimeout
+	}
+	if conf.GCInterval <= 0 {
+		conf.GCInterval = defaultGCInterval
+	}
+	if conf.SessionTTL <= 0 {
+		conf.SessionTTL = defaultSessionTTL
+	}
+	if conf.BackupKeep <= 0 {
+		conf.BackupKeep = defaultBackupKeep
+	}
+	if conf.ToolConflict != "" {
+		if err := tools.SetConflictPolicy(conf.ToolConflict); err != nil {
+			logf(LevelError, "%v, keeping current policy", err)
 		}
-		if p, err := GetPrompt(params.Name); err != nil {
-			resp.Error = &RPCError{Code: -32601, Message: err.Error()}
+	}
+	for _, name := range conf.DisabledTools {
+		tools.setEnabled(name, false)
+	}
+	if s.flags == nil && conf.Flags != nil {
+		s.flags = StaticFlags(conf.Flags)
+	}
+	if conf.WireDump != "" {
+		if w, err := openWireDump(conf.WireDump); err != nil {
+			logf(LevelError, "wire dump: %v", err)
 		} else {
-			resp.Result = p
+			s.SetWireDump(w)
 		}
-	case "prompts.list":
-		resp.Result = map[string]interface{}{"prompts": ListPrompts()}
-	case "server.info":
-		resp.Result = map[string]interface{}{
-			"name":    "MCP Server",
-			"version": "1.0.0",
-			"tools":   ListTools(),
-		}
-
-	case "system.describe":
-		resp.Result = map[string]interface{}{
-			"description": "This is a JSON-RPC server for MCP.",
-			"version":     "1.0.0",
-			"methods":     ListEnabledMethods(),
+	}
+	if conf.ServerID == "" {
+		host, _ := os.Hostname()
+		conf.ServerID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if s.jwt == nil {
+		if cfg := jwtConfigFromConf(conf); cfg != nil {
+			s.jwt = newJWTVerifier(*cfg)
 		}
+	}
+	if s.policy == nil && len(conf.Scopes) > 0 {
+		s.policy = ScopePolicy(conf.Scopes)
+	}
+	if conf.ACL != nil {
+		s.authorizers = append(s.authorizers, conf.ACL)
+	}
+	s.conf = conf
+	s.initMethods(conf.Methods)
+	s.registerBuiltinMethods()
+	s.registerBuiltinCollectors()
+	s.registerBuiltinBackupParts()
+	s.bandwidth = newBandwidth(conf.ConnByteRate, conf.TotalByteRate, s.stop)
+	s.budget = newCallBudget(conf.SessionCallRate, conf.SessionCallBurst)
+	s.bus = newEventBus(s)
+	s.notifier = newNotificationQueue(conf.NotifyCoalesceWindow, s.bus.deliver)
+	// 运行中注册、移除、启停工具后通知客户端刷新工具缓存
+	tools.watch(s.NotifyToolsListChanged)
+	return s
+}
 
-	case "system.listMethods":
-		resp.Result = ListEnabledMethods()
-
-	case "system.version":
-		resp.Result = "2.0"
-
-	default:
-		resp.Error = &RPCError{Code: -32601, Message: "Method not found"}
+// callerFromRequest 从请求中提取调用方身份。认证中间件得到的身份优先，其次是 mTLS 校验通过的客户端证书，
+// 这两种情况下租户、API Key 只取自认证结果。都没有时无法核实身份，租户和 API Key 指纹取自
+// X-Tenant-ID / X-API-Key 请求头，只适合由网关设置这些头的部署
+func (s *McpServer) callerFromRequest(r *http.Request, transport string) mcpctx.Caller {
+	caller := mcpctx.Caller{Transport: transport, RemoteAddr: r.RemoteAddr}
+	if id, ok := identityFromRequest(r); ok {
+		caller.Principal, caller.Tenant, caller.APIKey = id.principal, id.tenant, id.apiKey
+		return caller
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		caller.Principal = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		caller.Tenant = s.tenantOf(caller.Principal, nil)
+		return caller
 	}
+	caller.Tenant = r.Header.Get("X-Tenant-ID")
+	caller.APIKey = apiKeyFingerprint(r.Header.Get("X-API-Key"))
+	return caller
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+// requestContext 构造工具调用的 context：继承请求的取消信号，并放入 mcpctx 中的调用方、会话、功能开关
+func (s *McpServer) requestContext(r *http.Request, transport string, session mcpctx.Session) context.Context {
+	caller := s.callerFromRequest(r, transport)
+	ctx := mcpctx.WithCaller(r.Context(), caller)
+	ctx = mcpctx.WithFlags(ctx, callerFlags{server: s, caller: caller})
+	if s.logger != nil {
+		ctx = mcpctx.WithLogger(ctx, s.logger)
+	}
+	if session != nil {
+		ctx = mcpctx.WithSession(ctx, session)
+	}
+	return withRequestProtocol(ctx, r)
 }
 
-// ---------------------- WebSocket MCP Handler ----------------------
-var upgrader = websocket.Upgrader{}
+// transportEnabled 判断某种传输方式是否启用
+func (s *McpServer) transportEnabled(name string) bool {
+	return len(s.conf.Transports) == 0 || containsString(s.conf.Transports, name)
+}
 
-func wsHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("WS upgrade error:", err)
+// attachMeta 按配置给响应附加 meta
+func (s *McpServer) attachMeta(resp *RPCResponse, start time.Time, ann *AnnotatedResult, steps *stepRecorder) {
+	if !s.conf.ResponseMeta {
 		return
 	}
-	defer conn.Close()
+	meta := &ResponseMeta{
+		ProcessingMs: float64(time.Since(start).Microseconds()) / 1000,
+		ServerID:     s.conf.ServerID,
+	}
+	if ann != nil {
+		meta.CacheHit = ann.CacheHit
+		meta.CostUnits = ann.CostUnits
+	}
+	meta.Steps = steps.records()
+	resp.Meta = meta
+}
 
-	done := make(chan struct{}) // 用于通知 goroutine 停止
-	// 启动心跳 goroutine
-	go func() {
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				if err := conn.WriteMessage(websocket.PingMessage, []byte("ping")); err != nil {
-					log.Println("Ping error, closing:", err)
-					conn.Close()
-					return
-				}
-			}
+// Handler 返回挂载了所有 MCP 端点的 http.Handler，可以嵌入已有的 mux / router：
+//
+//	mux.Handle("/mcp", srv.Handler())
+//	mux.Handle("/ws", srv.Handler())
+//
+// 首次调用时启动通知发送和 SSE 心跳等后台任务，Shutdown 时停止
+func (s *McpServer) Handler() http.Handler {
+	s.handlerOnce.Do(func() {
+		mux := http.NewServeMux()
+		if s.transportEnabled("http") {
+			mux.HandleFunc(s.conf.HTTPPath, s.requireAuth(s.httpHandler))
 		}
-	}()
-	for {
-		var req RPCRequest
-		if err := conn.ReadJSON(&req); err != nil {
-			// 非主动关闭连接
-			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-				log.Println("WS read error:", err)
+		if s.transportEnabled("ws") {
+			if !wsSupported && len(s.conf.Transports) > 0 {
+				logf(LevelWarn, "ws transport is enabled but the binary was built with -tags nowebsocket")
 			}
-
-			break
+			mux.HandleFunc(s.conf.WSPath, s.requireAuth(s.wsHandler))
 		}
-
-		resp := RPCResponse{
-			JsonRPC: "2.0",
-			ID:      req.ID,
+		if s.transportEnabled("sse") {
+			mux.HandleFunc(s.conf.SSEPath, s.requireAuth(s.sseHandler))
 		}
+		if s.conf.ReadOnlyPath != "" {
+			mux.HandleFunc(s.conf.ReadOnlyPath, s.requireAuth(s.readOnlyHandler))
+		}
+		if s.conf.InspectorPath != "" {
+			mux.HandleFunc(s.conf.InspectorPath+"/sse", s.requireAuth(s.inspectorSSEHandler))
+			mux.HandleFunc(s.conf.InspectorPath+"/messages", s.requireAuth(s.inspectorMessagesHandler))
+		}
+		s.handler = mux
+		s.startBackground()
+	})
+	return s.handler
+}
 
-		switch req.Method {
-
-		case "tools.list":
-			resp.Result = listTools()
+// startBackground 启动与监听方式无关的后台任务
+func (s *McpServer) startBackground() {
+	s.background.Add(2)
+	go func() {
+		defer s.background.Done()
+		s.notifier.run(s.stop)
+	}()
+	go func() {
+		defer s.background.Done()
+		s.runJanitor()
+	}()
 
-		case "tools.run":
-			var params struct {
-				Name      string          `json:"name"`
-				Arguments json.RawMessage `json:"arguments"`
-			}
-			json.Unmarshal(req.Params, &params)
+	// SSE 注释心跳，避免代理把长时间无数据的流判定为空闲
+	if s.conf.SSEHeartbeat > 0 {
+		s.background.Add(1)
+		go func() {
+			defer s.background.Done()
+			s.runSSEHeartbeat()
+		}()
+	}
+	if s.conf.BackupDir != "" && s.conf.BackupInterval > 0 {
+		s.background.Add(1)
+		go func() {
+			defer s.background.Done()
+			s.runBackups()
+		}()
+	}
+}
 
-			if result, err := CallToolByName(params.Name, params.Arguments); err != nil {
-				resp.Error = &RPCError{Code: -32601, Message: err.Error()}
-			} else {
-				resp.Result = result
-			}
-			// resources
-		case "resources.get":
-			var params struct {
-				Name string `json:"name"`
-			}
-			if err := json.Unmarshal(req.Params, &params); err != nil {
-				resp.Error = &RPCError{Code: -32602, Message: "Invalid params"}
-				break
-			}
-			if r, err := GetResource(params.Name); err != nil {
-				resp.Error = &RPCError{Code: -32601, Message: err.Error()}
-			} else {
-				resp.Result = r
-			}
-		case "resources.list":
-			resp.Result = map[string]interface{}{"resources": ListResources()}
+// runSSEHeartbeat 定期向 SSE 连接发送注释行
+func (s *McpServer) runSSEHeartbeat() {
+	ticker := time.NewTicker(s.conf.SSEHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.broadcastSSEComment("heartbeat")
+		}
+	}
+}
 
-		// prompts
-		case "prompts.get":
-			var params struct {
-				Name string `json:"name"`
-			}
-			if err := json.Unmarshal(req.Params, &params); err != nil {
-				resp.Error = &RPCError{Code: -32602, Message: "Invalid params"}
-				break
-			}
-			if p, err := GetPrompt(params.Name); err != nil {
-				resp.Error = &RPCError{Code: -32601, Message: err.Error()}
-			} else {
-				resp.Result = p
-			}
-		case "prompts.list":
-			resp.Result = map[string]interface{}{"prompts": ListPrompts()}
-
-		case "server.info":
-			resp.Result = map[string]interface{}{
-				"name":    "MCP Server",
-				"version": "1.0.0",
-				"tools":   ListTools(),
-			}
+// tlsEnabled 配置了证书文件或 tls.Config
+func (s *McpServer) tlsEnabled() bool {
+	return s.tlsConfig != nil || s.conf.TLSCertFile != ""
+}
 
-		case "system.describe":
-			resp.Result = map[string]interface{}{
-				"description": "This is a JSON-RPC server for MCP.",
-				"version":     "1.0.0",
-				"methods":     ListEnabledMethods(),
-			}
+// defaultStopTimeout Start 的 ctx 结束后等待优雅停止的时长
+const defaultStopTimeout = 10 * time.Second
 
-		case "system.listMethods":
-			resp.Result = ListEnabledMethods()
+// Start 监听并提供服务，直到 ctx 结束或调用 Stop。
+// ctx 结束时按 Stop 的流程优雅停止（最多等待 10s）；监听失败时返回错误
+func (s *McpServer) Start(ctx context.Context) error {
+	tlsConfig, err := s.serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	if err := s.startupChecks(ctx); err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", s.conf.Addr, s.conf.Port),
+		Handler:      s.Handler(),
+		ReadTimeout:  s.conf.ReadTimeout,
+		WriteTimeout: s.conf.WriteTimeout,
+		IdleTimeout:  s.conf.IdleTimeout,
+		TLSConfig:    tlsConfig,
+	}
+	s.mu.Lock()
+	s.httpServer = srv
+	s.mu.Unlock()
 
-		case "system.version":
-			resp.Result = "2.0"
-		default:
-			resp.Error = &RPCError{Code: -32601, Message: "Method not found"}
+	errc := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			fmt.Printf("✅ MCP Server running at: https://%s:%d\n", s.conf.Addr, s.conf.Port)
+			errc <- srv.ListenAndServeTLS(s.conf.TLSCertFile, s.conf.TLSKeyFile)
+		} else {
+			fmt.Printf("✅ MCP Server running at: http://%s:%d\n", s.conf.Addr, s.conf.Port)
+			errc <- srv.ListenAndServe()
 		}
+	}()
 
-		if err := conn.WriteJSON(resp); err != nil {
-			log.Println("WS write error:", err)
-			return
+	select {
+	case err := <-errc:
+		if err == http.ErrServerClosed {
+			return nil
 		}
+		return err
+	case <-ctx.Done():
+		stopCtx, cancel := context.WithTimeout(context.Background(), defaultStopTimeout)
+		defer cancel()
+		return s.Stop(stopCtx)
 	}
-	close(done)
 }
 
-// ---------------------- SSE Handler（Optional） ----------------------
-type SSEClient struct {
-	writer  http.ResponseWriter
-	flusher http.Flusher
+// Stop 优雅停止：不再接受新连接和新的 WS 请求，等待进行中的 HTTP 请求和工具调用结束，
+// 发出剩余通知后结束 SSE 响应，并以 going-away 关闭 WS 连接。ctx 结束时不再等待，返回 ctx 的错误
+func (s *McpServer) Stop(ctx context.Context) error {
+	var err error
+	s.stopOnce.Do(func() {
+		err = s.stopGracefully(ctx)
+	})
+	return err
 }
 
-var sseClients = make(map[*SSEClient]struct{})
+func (s *McpServer) stopGracefully(ctx context.Context) error {
+	atomic.StoreInt32(&s.stopping, 1)
 
-func sseHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
+	s.mu.Lock()
+	srv := s.httpServer
+	s.mu.Unlock()
+	shutdown := make(chan error, 1)
+	if srv != nil {
+		// 关闭监听并等待 HTTP 请求结束；SSE 响应在 drained 关闭后才结束
+		go func() { shutdown <- srv.Shutdown(ctx) }()
+	} else {
+		shutdown <- nil
+	}
 
-	flusher := w.(http.Flusher)
-	client := &SSEClient{writer: w, flusher: flusher}
+	err := s.waitInflight(ctx)
 
-	sseClients[client] = struct{}{}
-	defer delete(sseClients, client)
+	close(s.stop)
+	s.background.Wait()
+	close(s.drained)
+	s.closeWSConns()
 
-	notify := w.(http.CloseNotifier).CloseNotify()
-	<-notify
+	if shutdownErr := <-shutdown; err == nil {
+		err = shutdownErr
+	}
+	return err
 }
 
-func broadcastSSE(event string, data interface{}) {
-	payload, _ := json.Marshal(data)
-	msg := fmt.Sprintf("event: %s\ndata: %s\n\n", event, payload)
-	for client := range sseClients {
-		client.writer.Write([]byte(msg))
-		client.flusher.Flush()
+// waitInflight 等待进行中的工具调用结束
+func (s *McpServer) waitInflight(ctx context.Context) error {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&s.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
 	}
+	return nil
 }
 
-type McpConf struct {
-	Addr string `yaml:"addr" default:"localhost"`
-	Port int    `yaml:"port" default:"8074"`
+// callTool 执行工具并计入进行中的调用，Stop 会等待这些调用结束；工具返回 *Table 时在这里写出数据
+func (s *McpServer) callTool(ctx context.Context, name string, args json.RawMessage) (interface{}, error) {
+	atomic.AddInt64(&s.inflight, 1)
+	defer atomic.AddInt64(&s.inflight, -1)
+	result, err := s.tools.Call(ctx, name, args)
+	if t, ok := result.(*Table); ok && err == nil {
+		return runTable(ctx, name, t)
+	}
+	return result, err
 }
 
-type McpServer struct {
-	conf McpConf
+// stopped 服务已开始停止
+func (s *McpServer) stopped() bool {
+	return atomic.LoadInt32(&s.stopping) == 1
 }
 
-func NewMcpServer(conf McpConf) *McpServer {
-	return &McpServer{conf: conf}
+func (s *McpServer) trackWSConn(conn *wsConn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		s.wsConns[conn] = struct{}{}
+	} else {
+		delete(s.wsConns, conn)
+	}
 }
 
-func (s *McpServer) Start() {
-	http.HandleFunc("/mcp", httpHandler)
-	http.HandleFunc("/ws", wsHandler)
-	http.HandleFunc("/sse", sseHandler)
+// Shutdown 见 Stop。
+//
+// Deprecated: 使用 Stop
+func (s *McpServer) Shutdown(ctx context.Context) error {
+	return s.Stop(ctx)
+}
 
-	// 定时 SSE 事件
-	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
-		count := 0
-		for range ticker.C {
-			count++
-			broadcastSSE("update", map[string]interface{}{
-				"message": fmt.Sprintf("Event #%d", count),
-			})
-		}
-	}()
-	fmt.Printf("✅ MCP Server running at: http://%s:%d\n", s.conf.Addr, s.conf.Port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", s.conf.Addr, s.conf.Port), nil))
+// Tools 返回服务使用的工具注册表
+func (s *McpServer) Tools() *ToolRegistry {
+	return s.tools
+}
 
+// Conf 返回服务配置
+func (s *McpServer) Conf() McpConf {
+	return s.conf
 }
 
 // ---------------------- 启动 Server ----------------------
+
+// StartMcpServer 启动内置的 geo 演示服务。
+//
+// Deprecated: 使用 cmd/gomcp-server 或 NewServerFromManifest
 func StartMcpServer() {
 
 	// 注册工具
@@ -439,5 +1045,7 @@ func StartMcpServer() {
 		Addr: "localhost",
 		Port: 8074,
 	})
-	mcp.Start()
+	if err := mcp.Start(context.Background()); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,306 @@
+package mcpserver
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ---------------------- 方法开关 ----------------------
+// 每个服务持有自己的方法开关表，创建时从 Methods 复制默认值，再应用 McpConf.Methods。
+// 分发时在翻译方法名之后统一检查开关，关闭的方法按不存在处理（-32601）；开关也可以按模式设置，见下文
+
+// Methods 新建服务的默认方法开关
+// key: 方法名，如 "tools.run"
+// value: 是否启用（true=启用，false=禁用）
+// 只在创建服务时读取，运行中请用 (*McpServer).SetMethodEnabled
+var Methods = map[string]bool{
+	"tools.run":                true,
+	"tools.list":               true,
+	"resources.get":            true,
+	"resources.list":           true,
+	"resources/subscribe":      true,
+	"resources/templates/list": true,
+	"resources/unsubscribe":    true,
+	"events.subscribe":         true,
+	"events.unsubscribe":       true,
+	"prompts.get":              true,
+	"prompts.list":             true,
+	"completion/complete":      true,
+	"logging/setLevel":         true,
+	"server.info":              true,
+	"system.describe":          true,
+	"system.listMethods":       true,
+	"system.version":           true,
+	"system.stats":             true,
+	"initialize":               true,
+	"ping":                     true,
+	"admin.costs":              false, // 管理接口，默认关闭
+	"admin.wireStats":          false,
+	"admin.tools":              false,
+	"admin.canaries":           false,
+	"admin.shadows":            false,
+	"admin.validateConfig":     false,
+	"admin.backup":             false,
+	"admin.restore":            false,
+}
+
+var methodsLock sync.RWMutex
+
+// 检查方法的默认开关
+func IsMethodEnabled(method string) bool {
+	methodsLock.RLock()
+	defer methodsLock.RUnlock()
+	enabled, ok := Methods[method]
+	return ok && enabled
+}
+
+// 设置方法的默认开关，只影响之后创建的服务
+func SetMethodEnabled(method string, enabled bool) {
+	methodsLock.Lock()
+	defer methodsLock.Unlock()
+	if _, ok := Methods[method]; ok {
+		Methods[method] = enabled
+	}
+}
+
+// 获取默认启用的 Method 列表
+func ListEnabledMethods() []string {
+	methodsLock.RLock()
+	defer methodsLock.RUnlock()
+	return enabledMethods(Methods)
+}
+
+func enabledMethods(methods map[string]bool) []string {
+	enabled := []string{}
+	for method, ok := range methods {
+		if ok {
+			enabled = append(enabled, method)
+		}
+	}
+	sort.Strings(enabled)
+	return enabled
+}
+
+// ---------------------- 开关模式 ----------------------
+// SetMethodEnabled / McpConf.Methods 的 key 除了方法名，还可以是：
+//   - 通配符："system.*"、"admin.*"，* 和 ? 的含义同 path.Match；
+//   - 正则：用 / 包围，如 "/^admin\.(costs|tools)$/"；
+//   - 按工具：方法后跟 [name=模式]，如 "tools.run[name=delete_*]"，只对该方法调用的工具生效，模式同上。
+// 一次调用按以下顺序取第一个有结论的：按工具的规则（多条匹配时任一关闭即关闭）、方法名本身的开关、
+// 最长的通配符 / 正则规则（同样长时关闭优先）、默认值（Methods）
+
+// methodToggle 一条开关规则
+type methodToggle struct {
+	pattern string
+	exact   bool // 不含通配符和工具条件的方法名
+	method  func(string) bool
+	tool    func(string) bool // 为 nil 表示不限工具
+	enabled bool
+}
+
+// parseMethodToggle 解析开关规则
+func parseMethodToggle(pattern string) (*methodToggle, error) {
+	t := &methodToggle{pattern: pattern}
+	methodPart := pattern
+	if strings.HasSuffix(pattern, "]") {
+		if i := strings.Index(pattern, "[name="); i > 0 {
+			matcher, err := compileNameMatcher(pattern[i+len("[name=") : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("method toggle %q: %w", pattern, err)
+			}
+			methodPart, t.tool = pattern[:i], matcher
+		}
+	}
+	matcher, err := compileNameMatcher(methodPart)
+	if err != nil {
+		return nil, fmt.Errorf("method toggle %q: %w", pattern, err)
+	}
+	t.method = matcher
+	t.exact = t.tool == nil && !isNamePattern(methodPart)
+	return t, nil
+}
+
+// isNamePattern 是否为通配符或正则
+func isNamePattern(expr string) bool {
+	return isRegexPattern(expr) || strings.ContainsAny(expr, "*?[")
+}
+
+func isRegexPattern(expr string) bool {
+	return len(expr) >= 2 && strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/")
+}
+
+// compileNameMatcher 把名称、通配符或 /正则/ 编译为匹配函数
+func compileNameMatcher(expr string) (func(string) bool, error) {
+	switch {
+	case expr == "":
+		return nil, fmt.Errorf("empty pattern")
+	case isRegexPattern(expr):
+		re, err := regexp.Compile(expr[1 : len(expr)-1])
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	case isNamePattern(expr):
+		if _, err := path.Match(expr, ""); err != nil {
+			return nil, err
+		}
+		return func(name string) bool {
+			ok, _ := path.Match(expr, name)
+			return ok
+		}, nil
+	default:
+		return func(name string) bool { return name == expr }, nil
+	}
+}
+
+// initMethods 复制默认开关并应用配置，配置中的未知方法和无效模式忽略
+func (s *McpServer) initMethods(overrides map[string]bool) {
+	methodsLock.RLock()
+	s.methods = make(map[string]bool, len(Methods))
+	for method, enabled := range Methods {
+		s.methods[method] = enabled
+	}
+	methodsLock.RUnlock()
+	for pattern, enabled := range overrides {
+		if err := s.setToggle(pattern, enabled); err != nil {
+			logf(LevelWarn, "methods: %v, ignored", err)
+		}
+	}
+}
+
+// setToggle 添加或替换一条规则，调用方不能持有 methodsMu
+func (s *McpServer) setToggle(pattern string, enabled bool) error {
+	t, err := parseMethodToggle(pattern)
+	if err != nil {
+		return err
+	}
+	t.enabled = enabled
+	s.methodsMu.Lock()
+	defer s.methodsMu.Unlock()
+	if _, ok := s.methods[pattern]; t.exact && !ok {
+		return fmt.Errorf("unknown method %q", pattern)
+	}
+	for i, existing := range s.toggles {
+		if existing.pattern == pattern {
+			s.toggles[i] = t
+			return nil
+		}
+	}
+	s.toggles = append(s.toggles, t)
+	return nil
+}
+
+// MethodToggle 一条设置过的开关规则，用于备份
+type MethodToggle struct {
+	Pattern string `json:"pattern"`
+	Enabled bool   `json:"enabled"`
+}
+
+// methodToggles 设置过的规则，按设置顺序
+func (s *McpServer) methodToggles() []MethodToggle {
+	s.methodsMu.RLock()
+	defer s.methodsMu.RUnlock()
+	list := make([]MethodToggle, len(s.toggles))
+	for i, t := range s.toggles {
+		list[i] = MethodToggle{Pattern: t.pattern, Enabled: t.enabled}
+	}
+	return list
+}
+
+// restoreMethodToggles 用 toggles 替换全部规则；先全部解析，有无效规则时不做修改，
+// 本服务不认识的方法名（如来自启用了其他方法的部署）忽略
+func (s *McpServer) restoreMethodToggles(toggles []MethodToggle) error {
+	parsed := make([]*methodToggle, 0, len(toggles))
+	for _, mt := range toggles {
+		t, err := parseMethodToggle(mt.Pattern)
+		if err != nil {
+			return err
+		}
+		t.enabled = mt.Enabled
+		parsed = append(parsed, t)
+	}
+	s.methodsMu.Lock()
+	defer s.methodsMu.Unlock()
+	kept := parsed[:0]
+	for _, t := range parsed {
+		if _, ok := s.methods[t.pattern]; t.exact && !ok {
+			logf(LevelWarn, "methods: unknown method %q in backup, ignored", t.pattern)
+			continue
+		}
+		kept = append(kept, t)
+	}
+	s.toggles = kept
+	return nil
+}
+
+// methodEnabled 方法（及 tools.run 调用的工具）是否启用，tool 为空时只按方法判断
+func (s *McpServer) methodEnabled(method, tool string) bool {
+	s.methodsMu.RLock()
+	defer s.methodsMu.RUnlock()
+	enabled, known := s.methods[method]
+	if !known {
+		return false
+	}
+	if tool != "" {
+		decided, allowed := false, true
+		for _, t := range s.toggles {
+			if t.tool != nil && t.method(method) && t.tool(tool) {
+				decided, allowed = true, allowed && t.enabled
+			}
+		}
+		if decided {
+			return allowed
+		}
+	}
+	var best *methodToggle
+	for _, t := range s.toggles {
+		if t.tool != nil || !t.method(method) {
+			continue
+		}
+		if t.exact {
+			return t.enabled
+		}
+		if best == nil || len(t.pattern) > len(best.pattern) ||
+			len(t.pattern) == len(best.pattern) && !t.enabled {
+			best = t
+		}
+	}
+	if best != nil {
+		return best.enabled
+	}
+	return enabled
+}
+
+// IsMethodEnabled 方法在该服务上是否启用（不考虑按工具的规则）
+func (s *McpServer) IsMethodEnabled(method string) bool {
+	return s.methodEnabled(method, "")
+}
+
+// SetMethodEnabled 运行中开启或关闭方法，对之后的请求立即生效。method 可以是方法名、
+// 通配符、正则或按工具的规则（见上文）；未知方法名或无效模式返回 false
+func (s *McpServer) SetMethodEnabled(method string, enabled bool) bool {
+	if err := s.setToggle(method, enabled); err != nil {
+		logf(LevelWarn, "methods: %v", err)
+		return false
+	}
+	return true
+}
+
+// EnabledMethods 该服务启用的方法，按名称排序
+func (s *McpServer) EnabledMethods() []string {
+	s.methodsMu.RLock()
+	methods := make([]string, 0, len(s.methods))
+	for method := range s.methods {
+		methods = append(methods, method)
+	}
+	s.methodsMu.RUnlock()
+	enabled := map[string]bool{}
+	for _, method := range methods {
+		enabled[method] = s.methodEnabled(method, "")
+	}
+	return enabledMethods(enabled)
+}
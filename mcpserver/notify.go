@@ -0,0 +1,214 @@
+package mcpserver
+
+import (
+	"sync"
+	"time"
+)
+
+// ---------------------- 通知优先级队列 ----------------------
+
+// NotificationPriority 通知优先级，高优先级先发送
+type NotificationPriority int
+
+const (
+	PriorityLow    NotificationPriority = iota // 批量更新，如 resources/updated、list_changed
+	PriorityNormal                             // 普通通知
+	PriorityHigh                               // 取消、进度等交互类消息
+)
+
+// notificationPriority 按 method 推断优先级
+func notificationPriority(method string) NotificationPriority {
+	switch method {
+	case "notifications/cancelled", "notifications/progress":
+		return PriorityHigh
+	case "notifications/resources/updated", "notifications/resources/list_changed",
+		"notifications/tools/list_changed", "notifications/prompts/list_changed",
+		"notifications/tools/suggested":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+type notification struct {
+	Method      string
+	Params      interface{}
+	Priority    NotificationPriority
+	CoalesceKey string // 非空时，同一 key 在合并窗口内只发送最后一条
+	// Deliver 非空时只通过它发送（例如只发给某个会话），否则广播
+	Deliver func(method string, params interface{})
+}
+
+type notificationQueue struct {
+	mu      sync.Mutex
+	queues  [PriorityHigh + 1][]*notification
+	pending map[string]*notification // 处于合并窗口中的通知
+	window  time.Duration
+	wake    chan struct{}
+	send    func(method string, params interface{})
+}
+
+func newNotificationQueue(window time.Duration, send func(method string, params interface{})) *notificationQueue {
+	return &notificationQueue{
+		pending: make(map[string]*notification),
+		window:  window,
+		wake:    make(chan struct{}, 1),
+		send:    send,
+	}
+}
+
+// Push 入队；带 CoalesceKey 的通知先在窗口内合并，窗口结束后才进入发送队列
+func (q *notificationQueue) Push(n *notification) {
+	q.mu.Lock()
+	if n.CoalesceKey != "" && q.window > 0 {
+		if p, ok := q.pending[n.CoalesceKey]; ok {
+			p.Params = n.Params
+			q.mu.Unlock()
+			return
+		}
+		q.pending[n.CoalesceKey] = n
+		q.mu.Unlock()
+		time.AfterFunc(q.window, func() { q.release(n.CoalesceKey) })
+		return
+	}
+	q.queues[n.Priority] = append(q.queues[n.Priority], n)
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *notificationQueue) release(key string) {
+	q.mu.Lock()
+	n, ok := q.pending[key]
+	if ok {
+		delete(q.pending, key)
+		q.queues[n.Priority] = append(q.queues[n.Priority], n)
+	}
+	q.mu.Unlock()
+	if ok {
+		q.signal()
+	}
+}
+
+func (q *notificationQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop 取出优先级最高的一条通知，队列为空时返回 nil
+func (q *notificationQueue) pop() *notification {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		if len(q.queues[p]) > 0 {
+			n := q.queues[p][0]
+			q.queues[p] = q.queues[p][1:]
+			return n
+		}
+	}
+	return nil
+}
+
+// run 发送循环，stop 关闭时把剩余通知（包括合并窗口中的）全部发出后退出
+func (q *notificationQueue) run(stop <-chan struct{}) {
+	for {
+		n := q.pop()
+		if n == nil {
+			select {
+			case <-q.wake:
+			case <-stop:
+				q.flush()
+				return
+			}
+			continue
+		}
+		q.deliver(n)
+	}
+}
+
+// flush 立即发送所有待发通知，不再等待合并窗口
+func (q *notificationQueue) flush() {
+	q.mu.Lock()
+	for key, n := range q.pending {
+		delete(q.pending, key)
+		q.queues[n.Priority] = append(q.queues[n.Priority], n)
+	}
+	q.mu.Unlock()
+	for n := q.pop(); n != nil; n = q.pop() {
+		q.deliver(n)
+	}
+}
+
+func (q *notificationQueue) deliver(n *notification) {
+	if n.Deliver != nil {
+		n.Deliver(n.Method, n.Params)
+	} else {
+		q.send(n.Method, n.Params)
+	}
+}
+
+// barrier 等待此前入队的通知都已发出（仍在合并窗口中的除外），超过 timeout 或发送循环已退出时放弃
+func (q *notificationQueue) barrier(timeout time.Duration) {
+	done := make(chan struct{})
+	q.Push(&notification{
+		Priority: PriorityLow, // 同优先级先进先出，更高优先级的总是先发
+		Deliver:  func(string, interface{}) { close(done) },
+	})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+}
+
+// ---------------------- McpServer 通知 API ----------------------
+
+// PushNotification 发送一条通知，优先级按 method 推断
+//
+// Deprecated: 使用 Notify
+func (s *McpServer) PushNotification(method string, params interface{}) {
+	s.Notify(method, params)
+}
+
+// NotifyResourceUpdated 通知资源变化，同一 URI 在合并窗口内只发送一次
+func (s *McpServer) NotifyResourceUpdated(uri string) {
+	method := "notifications/resources/updated"
+	s.notifier.Push(&notification{
+		Method:      method,
+		Params:      map[string]interface{}{"uri": uri},
+		Priority:    notificationPriority(method),
+		CoalesceKey: method + ":" + uri,
+	})
+}
+
+// NotifyResourcesListChanged 通知客户端资源列表已变化，合并窗口内多次变化只发送一次
+func (s *McpServer) NotifyResourcesListChanged() {
+	method := "notifications/resources/list_changed"
+	s.notifier.Push(&notification{
+		Method:      method,
+		Priority:    notificationPriority(method),
+		CoalesceKey: method,
+	})
+}
+
+// NotifyPromptsListChanged 通知客户端 prompt 列表已变化，合并窗口内多次变化只发送一次
+func (s *McpServer) NotifyPromptsListChanged() {
+	method := "notifications/prompts/list_changed"
+	s.notifier.Push(&notification{
+		Method:      method,
+		Priority:    notificationPriority(method),
+		CoalesceKey: method,
+	})
+}
+
+// NotifyToolsListChanged 通知客户端工具列表已变化，合并窗口内多次变化只发送一次
+func (s *McpServer) NotifyToolsListChanged() {
+	method := "notifications/tools/list_changed"
+	s.notifier.Push(&notification{
+		Method:      method,
+		Priority:    notificationPriority(method),
+		CoalesceKey: method,
+	})
+}
@@ -0,0 +1,154 @@
+package mcpserver
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 服务选项 ----------------------
+// NewMcpServer 的函数式选项，在 McpConf 之后应用，同名配置以选项为准
+
+// Option 服务选项
+type Option func(*McpServer)
+
+// WithPaths 设置 HTTP / WS / SSE 端点路径，传空字符串的保持不变
+func WithPaths(httpPath, wsPath, ssePath string) Option {
+	return func(s *McpServer) {
+		if httpPath != "" {
+			s.conf.HTTPPath = httpPath
+		}
+		if wsPath != "" {
+			s.conf.WSPath = wsPath
+		}
+		if ssePath != "" {
+			s.conf.SSEPath = ssePath
+		}
+	}
+}
+
+// WithLogger 设置日志：工具通过 mcpctx.LoggerFromContext 拿到它，服务端自身的日志也改为输出到它。
+// 服务端日志是包级的（见 SetLogger），同一进程内多个服务以最后设置的为准
+func WithLogger(l mcpctx.Logger) Option {
+	return func(s *McpServer) {
+		s.logger = l
+		SetLogger(l)
+	}
+}
+
+// WithTimeouts 设置 HTTP 服务的读、写、空闲超时，为 0 的保持不变。
+// 写超时同样作用于 SSE 长连接，开启 SSE 时不要设置或设置得足够长；WS 连接不受影响
+func WithTimeouts(read, write, idle time.Duration) Option {
+	return func(s *McpServer) {
+		if read > 0 {
+			s.conf.ReadTimeout = read
+		}
+		if write > 0 {
+			s.conf.WriteTimeout = write
+		}
+		if idle > 0 {
+			s.conf.IdleTimeout = idle
+		}
+	}
+}
+
+// WithWSTimeouts 设置 WS 连接的 ping 间隔、pong 超时（见 McpConf.WSPongTimeout）和空闲超时，为 0 的保持不变
+func WithWSTimeouts(pingInterval, pongTimeout, idleTimeout time.Duration) Option {
+	return func(s *McpServer) {
+		if pingInterval > 0 {
+			s.conf.WSPingInterval = pingInterval
+		}
+		if pongTimeout > 0 {
+			s.conf.WSPongTimeout = pongTimeout
+		}
+		if idleTimeout > 0 {
+			s.conf.WSIdleTimeout = idleTimeout
+		}
+	}
+}
+
+// WithSSEHeartbeat 设置 SSE 注释心跳的间隔，-1 表示不发送
+func WithSSEHeartbeat(interval time.Duration) Option {
+	return func(s *McpServer) {
+		s.conf.SSEHeartbeat = interval
+	}
+}
+
+// WithWSMaxMessageSize 设置单条 WS 消息的上限（字节），-1 表示不限
+func WithWSMaxMessageSize(n int64) Option {
+	return func(s *McpServer) {
+		s.conf.WSMaxMessageSize = n
+	}
+}
+
+// WithWSCheckOrigin 自定义 WS 握手的 Origin 校验，代替 McpConf.WSAllowedOrigins
+func WithWSCheckOrigin(fn func(r *http.Request) bool) Option {
+	return func(s *McpServer) {
+		s.wsCheckOrigin = fn
+	}
+}
+
+// WithWSBuffers 设置 WS 连接的读写缓冲大小（字节），为 0 的保持不变
+func WithWSBuffers(read, write int) Option {
+	return func(s *McpServer) {
+		if read > 0 {
+			s.conf.WSReadBufferSize = read
+		}
+		if write > 0 {
+			s.conf.WSWriteBufferSize = write
+		}
+	}
+}
+
+// WithWSCompression 开启或关闭 WS permessage-deflate 压缩协商
+func WithWSCompression(enable bool) Option {
+	return func(s *McpServer) {
+		s.conf.WSCompression = enable
+	}
+}
+
+// WithTLS 使用证书和私钥文件提供 HTTPS / WSS
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *McpServer) {
+		s.conf.TLSCertFile = certFile
+		s.conf.TLSKeyFile = keyFile
+	}
+}
+
+// WithTLSConfig 使用自定义的 tls.Config（如从证书管理服务动态获取证书），
+// 证书已在 Certificates / GetCertificate 中提供时不需要再设置 WithTLS；mTLS 见 WithClientCA
+func WithTLSConfig(c *tls.Config) Option {
+	return func(s *McpServer) {
+		s.tlsConfig = c
+	}
+}
+
+// WithToolRegistry 使用指定的工具注册表，代替 DefaultToolRegistry
+func WithToolRegistry(r *ToolRegistry) Option {
+	return func(s *McpServer) {
+		s.tools = r
+	}
+}
+
+// WithByteRate 设置出站限速（字节/秒）：perConn 为每条 WS / SSE 连接的上限，
+// total 为所有连接合计的上限，由正在发送的连接平分；为 0 的保持不变
+func WithByteRate(perConn, total int64) Option {
+	return func(s *McpServer) {
+		if perConn > 0 {
+			s.conf.ConnByteRate = perConn
+		}
+		if total > 0 {
+			s.conf.TotalByteRate = total
+		}
+	}
+}
+
+// WithToolSuggestions 开启工具推荐（见 suggest.go）：每次工具调用后向所属会话推送
+// notifications/tools/suggested。r 为 nil 时按各会话的调用顺序统计"调用 A 之后常调用 B"来推荐
+func WithToolSuggestions(r Recommender) Option {
+	return func(s *McpServer) {
+		s.suggester = newToolSuggester(r)
+	}
+}
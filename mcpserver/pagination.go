@@ -0,0 +1,109 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+)
+
+// ---------------------- 列表分页 ----------------------
+// tools.list、resources.list、prompts.list（及规范方法名）按 MCP 的游标分页：配置 ListPageSize 后每页最多返回
+// 这么多项，还有后续时结果带 nextCursor，客户端把它作为下一次请求的 {"cursor": ...}。
+// 游标是该页最后一项的键（工具名、资源 URI、prompt 名）的编码，列表按键排序，翻页期间增删条目不会重复或跳过其他条目。
+// 未配置 ListPageSize 时一次返回全部，带 cursor 的请求仍从游标之后开始返回
+
+// pageParams 列表方法的分页参数
+type pageParams struct {
+	Cursor string `json:"cursor"`
+}
+
+// encodeCursor / decodeCursor 游标与列表键的转换，对客户端不透明
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (string, bool) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	return string(key), err == nil && len(key) > 0
+}
+
+// paginateList 对列表方法 fn 的结果 {field: [...]} 分页。fn 的结果可能被合并的调用共享，这里只复制不修改
+func (s *McpServer) paginateList(field string, fn methodFunc) methodFunc {
+	return func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		var params pageParams
+		if len(c.params) > 0 {
+			json.Unmarshal(c.params, &params)
+		}
+		after := ""
+		if params.Cursor != "" {
+			key, ok := decodeCursor(params.Cursor)
+			if !ok {
+				return nil, &RPCError{Code: -32602, Message: "Invalid cursor"}
+			}
+			after = key
+		}
+		result, rpcErr := fn(ctx, c)
+		if rpcErr != nil || (after == "" && s.conf.ListPageSize <= 0) {
+			return result, rpcErr
+		}
+		m, ok := result.(map[string]interface{})
+		if !ok {
+			return result, nil
+		}
+
+		var page interface{}
+		var next string
+		switch list := m[field].(type) {
+		case []ToolSummary:
+			start, end := s.pageBounds(len(list), after, func(i int) string { return list[i].Name })
+			page, next = list[start:end], pageCursor(end, len(list), func() string { return list[end-1].Name })
+		case []map[string]string:
+			start, end := s.pageBounds(len(list), after, func(i int) string { return list[i]["uri"] })
+			page, next = list[start:end], pageCursor(end, len(list), func() string { return list[end-1]["uri"] })
+		case []PromptSummary:
+			start, end := s.pageBounds(len(list), after, func(i int) string { return list[i].Name })
+			page, next = list[start:end], pageCursor(end, len(list), func() string { return list[end-1].Name })
+		default:
+			return result, nil
+		}
+
+		out := make(map[string]interface{}, len(m)+1)
+		for k, v := range m {
+			out[k] = v
+		}
+		out[field] = page
+		if next != "" {
+			out["nextCursor"] = next
+		}
+		return out, nil
+	}
+}
+
+// pageBounds 按键排序的列表中游标之后的一页 [start, end)
+func (s *McpServer) pageBounds(n int, after string, key func(i int) string) (int, int) {
+	start := 0
+	if after != "" {
+		start = sort.Search(n, func(i int) bool { return key(i) > after })
+	}
+	end := n
+	if size := s.conf.ListPageSize; size > 0 && n-start > size {
+		end = start + size
+	}
+	return start, end
+}
+
+// pageCursor 还有后续时返回下一页的游标
+func pageCursor(end, n int, last func() string) string {
+	if end >= n || end == 0 {
+		return ""
+	}
+	return encodeCursor(last())
+}
+
+// resultNextCursor 分页结果中的 nextCursor，specResult 改写结果形状时保留
+func resultNextCursor(result interface{}) (string, bool) {
+	m, _ := result.(map[string]interface{})
+	next, ok := m["nextCursor"].(string)
+	return next, ok
+}
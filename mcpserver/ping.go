@@ -0,0 +1,49 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ---------------------- 客户端保活 ----------------------
+// 客户端随时可以发送 ping，服务端回复空结果。反方向上，PingClient 向当前请求所在会话的客户端发送 ping；
+// 配置 ClientPingInterval 后 WS、stdio 会话按该间隔 ping 客户端，一个间隔内没有响应时认为连接已半开：
+// WS 连接被关闭，ServeStdio 返回错误。与 WS 的 ping/pong 控制帧不同，它确认的是客户端的读循环仍在处理消息。
+// 回复错误（如不认识 ping 的客户端回复 -32601）也说明客户端在线。开启前确认客户端会响应服务端的请求
+
+// ErrClientUnresponsive 客户端没有在 ClientPingInterval 内响应 ping
+var ErrClientUnresponsive = errors.New("client did not answer ping")
+
+// PingClient 向当前请求所在会话的客户端发送 ping，返回往返时间。只能在 WS、stdio 会话中使用
+func PingClient(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if err := callClient(ctx, "ping", nil, nil); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// keepAlive 按 ClientPingInterval 向客户端发送 ping，直到 ctx 结束；客户端没有响应时调用 dead 后返回
+func (s *McpServer) keepAlive(ctx context.Context, caller clientCaller, dead func(err error)) {
+	interval := s.conf.ClientPingInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := caller.callClient(pingCtx, "ping", nil, nil)
+		cancel()
+		var clientErr *ClientError
+		if err == nil || errors.As(err, &clientErr) || ctx.Err() != nil {
+			continue
+		}
+		dead(fmt.Errorf("%w: %v", ErrClientUnresponsive, err))
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 进度通知 ----------------------
+
+// RequestMeta 请求参数中的 _meta 字段
+type RequestMeta struct {
+	// ProgressToken 客户端要求进度通知时携带，数字或字符串，原样回传
+	ProgressToken json.RawMessage `json:"progressToken,omitempty"`
+	// Fields _meta 的全部字段（包括 progressToken），原样放入工具 context 并回传到结果的 _meta
+	Fields mcpctx.Meta `json:"-"`
+}
+
+func (m *RequestMeta) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.Fields); err != nil {
+		return err
+	}
+	m.ProgressToken = m.Fields["progressToken"]
+	return nil
+}
+
+// withMeta 把请求的 _meta 放入工具 context；_meta 带 timeoutMs（客户端剩余超时）时
+// 给 context 设置相同的截止时间，客户端放弃等待后工具不再继续做无用功。调用方负责调用 cancel
+func withMeta(ctx context.Context, meta RequestMeta) (context.Context, context.CancelFunc) {
+	if len(meta.Fields) == 0 {
+		return ctx, func() {}
+	}
+	ctx = mcpctx.WithMeta(ctx, meta.Fields)
+	var ms int64
+	if meta.Fields.Get("timeoutMs", &ms) && ms > 0 {
+		return context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+	}
+	return ctx, func() {}
+}
+
+// notifyTarget 可以单独接收通知的会话（WS 会话、HTTP+SSE 会话）
+type notifyTarget interface {
+	notify(n *RPCNotification)
+}
+
+// withProgress 请求带 progressToken 时，在 ctx 中放入进度上报函数：进度只发给请求所属的会话
+// （WS、HTTP+SSE、stdio）或请求自己的 SSE 响应流。普通 HTTP 请求没有可以接收进度的地方，不上报，
+// 进度中的 token 和消息不能广播给其他订阅者
+func (s *McpServer) withProgress(ctx context.Context, token json.RawMessage, sess notifyTarget) context.Context {
+	if len(token) == 0 || string(token) == "null" || sess == nil {
+		return ctx
+	}
+
+	deliver := func(method string, params interface{}) {
+		sess.notify(newRPCNotification(NextEventStamp(), method, params))
+	}
+
+	method := "notifications/progress"
+	return mcpctx.WithProgress(ctx, func(progress, total float64, message string) {
+		params := map[string]interface{}{
+			"progressToken": token,
+			"progress":      progress,
+		}
+		if total > 0 {
+			params["total"] = total
+		}
+		if message != "" {
+			params["message"] = message
+		}
+		s.notifier.Push(&notification{
+			Method:   method,
+			Params:   params,
+			Priority: notificationPriority(method),
+			Deliver:  deliver,
+		})
+	})
+}
@@ -1,14 +1,48 @@
 package mcpserver
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"text/template"
 )
 
 // -------------------- Prompt --------------------
+// Template 为 text/template 模板，声明的参数以 {{.名称}} 引用：
+//
+//	mcpserver.RegisterPrompt(&mcpserver.Prompt{
+//		Name:      "review",
+//		Template:  "Review this {{.language}} code:\n{{.code}}",
+//		Arguments: []mcpserver.PromptArgument{{Name: "language"}, {Name: "code", Required: true}},
+//	})
+//
+// prompts.get 带 {"name", "arguments": {...}}，缺少必填参数或带未声明的参数时返回 -32602；未提供的可选参数为空字符串。
+// 没有声明参数的 prompt 原样返回模板。需要多条消息、图片或嵌入资源时设置 Messages：
+//
+//	Messages: []mcpserver.PromptMessage{
+//		{Role: "user", Content: mcpserver.NewTextContent("Summarize the log of {{.date}}:")},
+//		{Role: "user", Content: mcpserver.NewResourceRef("file:///logs/{{.date}}.log")},
+//		{Role: "user", Content: mcpserver.NewImageContent(chart, "image/png")},
+//	}
 type Prompt struct {
-	Name     string
-	Template string
+	Name        string
+	Description string
+	Template    string
+	Arguments   []PromptArgument
+	// Messages 多条消息（含图片、嵌入资源）的 prompt，文本内容同样按参数渲染；为空时 Template 作为一条 user 消息
+	Messages []PromptMessage
+}
+
+// PromptArgument prompt 的一个参数
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	// Complete 可选，completion/complete 时给出参数值的补全建议，见 CompletionFunc
+	Complete CompletionFunc `json:"-"`
 }
 
 var (
@@ -22,21 +56,182 @@ func RegisterPrompt(p *Prompt) {
 	promptRegistry[p.Name] = p
 }
 
+// UnregisterPrompt 移除 prompt，返回是否存在
+func UnregisterPrompt(name string) bool {
+	promptLock.Lock()
+	defer promptLock.Unlock()
+	_, ok := promptRegistry[name]
+	delete(promptRegistry, name)
+	return ok
+}
+
 func GetPrompt(name string) (*Prompt, error) {
 	promptLock.RLock()
 	defer promptLock.RUnlock()
 	if p, ok := promptRegistry[name]; ok {
 		return p, nil
 	}
-	return nil, fmt.Errorf("prompt not found: %s", name)
+	return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
 }
 
-func ListPrompts() []string {
+// PromptSummary prompts.list 中的一项
+type PromptSummary struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// ListPrompts 已注册的 prompt 摘要，按名称排序
+func ListPrompts() []PromptSummary {
 	promptLock.RLock()
 	defer promptLock.RUnlock()
-	names := []string{}
-	for n := range promptRegistry {
-		names = append(names, n)
+	list := []PromptSummary{}
+	for _, p := range promptRegistry {
+		list = append(list, PromptSummary{Name: p.Name, Description: p.Description, Arguments: p.Arguments})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Render 用参数渲染模板，参数不符合声明时返回 ErrPromptArguments
+func (p *Prompt) Render(args map[string]string) (string, error) {
+	data, err := p.arguments(args)
+	if err != nil {
+		return "", err
 	}
-	return names
+	return p.render(p.Template, data)
+}
+
+// arguments 按声明检查参数，返回模板数据：未提供的可选参数为空字符串
+func (p *Prompt) arguments(args map[string]string) (map[string]string, error) {
+	declared := make(map[string]bool, len(p.Arguments))
+	data := make(map[string]string, len(p.Arguments))
+	var missing []string
+	for _, a := range p.Arguments {
+		declared[a.Name] = true
+		v, ok := args[a.Name]
+		if a.Required && (!ok || v == "") {
+			missing = append(missing, a.Name)
+		}
+		data[a.Name] = v
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing required %s", ErrPromptArguments, strings.Join(missing, ", "))
+	}
+	for name := range args {
+		if !declared[name] {
+			return nil, fmt.Errorf("%w: unknown argument %q", ErrPromptArguments, name)
+		}
+	}
+	return data, nil
+}
+
+// render 渲染一段模板文本，没有声明参数时原样返回
+func (p *Prompt) render(text string, data map[string]string) (string, error) {
+	if len(p.Arguments) == 0 {
+		return text, nil
+	}
+	tmpl, err := template.New(p.Name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return b.String(), nil
+}
+
+// PromptMessage prompt 中的一条消息。Role 为 "user" 或 "assistant"（为空时为 "user"），
+// Content 为 NewTextContent、NewImageContent、NewTextResource 等内容块，或用 NewResourceRef 引用已注册的资源
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// resourceRef prompt 消息中对资源的引用，prompts.get 时读取为嵌入资源
+type resourceRef struct {
+	URI string
+}
+
+func (resourceRef) contentType() string { return "resource" }
+
+// NewResourceRef 引用资源的内容块：prompts.get 时按 URI 读取资源（静态资源、提供者或模板），
+// 作为嵌入资源放入消息。URI 中可以引用 prompt 参数，如 "file:///logs/{{.date}}.log"
+func NewResourceRef(uri string) Content {
+	return resourceRef{URI: uri}
+}
+
+// renderedPrompt prompts.get 的结果：规范的 messages，另带旧版的 Name、Template（渲染后的文本）
+type renderedPrompt struct {
+	prompt   *Prompt
+	text     string
+	messages []PromptMessage
+}
+
+// renderPrompt 渲染 prompt：Messages 中的文本和资源 URI 按参数渲染，引用的资源按调用方权限读取；
+// 没有 Messages 时 Template 作为一条 user 消息
+func (s *McpServer) renderPrompt(ctx context.Context, access AccessRequest, p *Prompt, args map[string]string) (*renderedPrompt, error) {
+	data, err := p.arguments(args)
+	if err != nil {
+		return nil, err
+	}
+	text, err := p.render(p.Template, data)
+	if err != nil {
+		return nil, err
+	}
+	r := &renderedPrompt{prompt: p, text: text}
+	if len(p.Messages) == 0 {
+		r.messages = []PromptMessage{{Role: "user", Content: NewTextContent(text)}}
+		return r, nil
+	}
+	for _, m := range p.Messages {
+		msg := PromptMessage{Role: m.Role, Content: m.Content}
+		switch msg.Role {
+		case "":
+			msg.Role = "user"
+		case "user", "assistant":
+		default:
+			return nil, fmt.Errorf("prompt %s: invalid role %q", p.Name, m.Role)
+		}
+		switch c := m.Content.(type) {
+		case TextContent:
+			if c.Text, err = p.render(c.Text, data); err != nil {
+				return nil, err
+			}
+			msg.Content = c
+		case resourceRef:
+			if msg.Content, err = s.embedResource(ctx, access, p, c.URI, data); err != nil {
+				return nil, err
+			}
+		}
+		r.messages = append(r.messages, msg)
+	}
+	return r, nil
+}
+
+// embedResource 读取 prompt 引用的资源；调用方无权读取该资源时拒绝，避免借 prompt 绕过资源授权
+func (s *McpServer) embedResource(ctx context.Context, access AccessRequest, p *Prompt, uri string, data map[string]string) (Content, error) {
+	uri, err := p.render(uri, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.visibleNames(ctx, access, "resources.get", []string{resourceName(uri)})) == 0 {
+		return nil, fmt.Errorf("%w: prompt %s embeds %s", ErrForbidden, p.Name, uri)
+	}
+	res, err := readResource(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	return EmbeddedResource{Resource: res.Contents()}, nil
+}
+
+func (r *renderedPrompt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"Name":        r.prompt.Name,
+		"description": r.prompt.Description,
+		"Template":    r.text,
+		"Arguments":   r.prompt.Arguments,
+		"messages":    r.messages,
+	})
 }
@@ -0,0 +1,157 @@
+package mcpserver
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------- Prompt 目录 ----------------------
+// LoadPromptsFromDir 把目录树下的每个 .tmpl / .md 文件注册为 prompt，文件开头可以有 YAML front-matter：
+//
+//	---
+//	name: review
+//	description: Review a piece of code
+//	arguments:
+//	  - name: language
+//	  - name: code
+//	    required: true
+//	---
+//	Review this {{.language}} code:
+//	{{.code}}
+//
+// name 为空时取相对路径去掉扩展名（如 "git/commit"）。front-matter 之后的内容为模板，见 Prompt。
+// 隐藏文件和编辑器的临时文件忽略。需要修改后自动生效时用 McpServer.WatchPromptDir
+
+// promptFrontMatter prompt 文件的 front-matter
+type promptFrontMatter struct {
+	Name        string                   `yaml:"name"`
+	Description string                   `yaml:"description"`
+	Arguments   []ManifestPromptArgument `yaml:"arguments"`
+}
+
+// LoadPromptsFromDir 注册目录下的 prompt 文件，返回注册的名称。任一文件解析失败时不注册任何 prompt
+func LoadPromptsFromDir(dir string) ([]string, error) {
+	prompts, err := readPromptDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(prompts))
+	for name, p := range prompts {
+		RegisterPrompt(p)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readPromptDir 解析目录下的全部 prompt 文件，名称重复时报错
+func readPromptDir(dir string) (map[string]*Prompt, error) {
+	prompts := make(map[string]*Prompt)
+	files := make(map[string]string) // 名称 -> 文件，用于报告重名
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && ignoredResourceFile(path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !isPromptFile(path) {
+			return nil
+		}
+		p, err := loadPromptFile(dir, path)
+		if err != nil {
+			return err
+		}
+		if other, ok := files[p.Name]; ok {
+			return fmt.Errorf("prompt %q defined by both %s and %s", p.Name, other, path)
+		}
+		files[p.Name] = path
+		prompts[p.Name] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prompts, nil
+}
+
+// isPromptFile prompt 目录中作为 prompt 加载的文件
+func isPromptFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".tmpl", ".md":
+		return true
+	}
+	return false
+}
+
+// loadPromptFile 解析一个 prompt 文件
+func loadPromptFile(dir, path string) (*Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var meta promptFrontMatter
+	body := data
+	if head, rest, ok := splitFrontMatter(data); ok {
+		if err := yaml.Unmarshal(head, &meta); err != nil {
+			return nil, fmt.Errorf("prompt %s: front-matter: %w", path, err)
+		}
+		body = rest
+	}
+	if meta.Name == "" {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		meta.Name = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+	}
+	p := &Prompt{Name: meta.Name, Description: meta.Description, Template: string(body)}
+	for _, arg := range meta.Arguments {
+		if arg.Name == "" {
+			return nil, fmt.Errorf("prompt %s: argument without name", path)
+		}
+		p.Arguments = append(p.Arguments, PromptArgument{Name: arg.Name, Description: arg.Description, Required: arg.Required})
+	}
+	// 模板语法错误在加载时报告，而不是等到 prompts/get
+	if len(p.Arguments) > 0 {
+		if _, err := template.New(p.Name).Parse(p.Template); err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", path, err)
+		}
+	}
+	return p, nil
+}
+
+// splitFrontMatter 拆分以 "---" 行开头、以 "---" 行结束的 front-matter
+func splitFrontMatter(data []byte) (head, body []byte, ok bool) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	first, rest, found := bytes.Cut(data, []byte("\n"))
+	if !found || string(bytes.TrimRight(first, "\r")) != "---" {
+		return nil, data, false
+	}
+	for off := 0; off < len(rest); {
+		line, _, more := bytes.Cut(rest[off:], []byte("\n"))
+		if string(bytes.TrimRight(line, "\r")) == "---" {
+			end := off + len(line)
+			if end < len(rest) {
+				end++ // 结束行的换行
+			}
+			return rest[:off], rest[end:], true
+		}
+		if !more {
+			break
+		}
+		off += len(line) + 1
+	}
+	return nil, data, false
+}
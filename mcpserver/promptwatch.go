@@ -0,0 +1,164 @@
+//go:build !nofsnotify
+
+package mcpserver
+
+import (
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ---------------------- Prompt 目录热加载 ----------------------
+// WatchPromptDir 加载 prompt 目录（见 LoadPromptsFromDir）并监听变化：文件增删改后稍等片刻整体重新加载，
+// 注册新增和修改的 prompt、移除已删除的 prompt，有变化时发送 notifications/prompts/list_changed。
+// 重新加载时有文件解析失败则记录日志并保留当前的 prompt，改正后自动生效。
+// 依赖 fsnotify，用 -tags nofsnotify 编译时不可用
+
+// promptReloadDelay 最后一个文件事件之后多久重新加载，编辑器保存一次往往产生多个事件
+const promptReloadDelay = 200 * time.Millisecond
+
+// PromptDir 一个被监听的 prompt 目录，见 WatchPromptDir
+type PromptDir struct {
+	s       *McpServer
+	dir     string
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex
+	prompts map[string]*Prompt // 由该目录注册的 prompt
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchPromptDir 注册 dir 下的 prompt 并开始监听，服务停止或调用 Close 时停止监听，已注册的 prompt 保留
+func (s *McpServer) WatchPromptDir(dir string) (*PromptDir, error) {
+	prompts, err := readPromptDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	d := &PromptDir{
+		s:       s,
+		dir:     filepath.Clean(dir),
+		watcher: watcher,
+		prompts: prompts,
+		done:    make(chan struct{}),
+	}
+	if err := d.watchTree(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	for _, p := range prompts {
+		RegisterPrompt(p)
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		d.run()
+	}()
+	return d, nil
+}
+
+// Close 停止监听
+func (d *PromptDir) Close() error {
+	var err error
+	d.closeOnce.Do(func() {
+		close(d.done)
+		err = d.watcher.Close()
+	})
+	return err
+}
+
+// Names 当前由该目录注册的 prompt 名称
+func (d *PromptDir) Names() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, 0, len(d.prompts))
+	for name := range d.prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *PromptDir) run() {
+	defer d.Close()
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.s.stop:
+			return
+		case event, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			if ignoredResourceFile(event.Name) {
+				continue
+			}
+			reload = time.After(promptReloadDelay)
+		case <-reload:
+			reload = nil
+			d.reload()
+		case err, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+			logf(LevelWarn, "prompt dir %s: %v", d.dir, err)
+		}
+	}
+}
+
+// reload 重新加载整个目录，与当前注册的 prompt 对比后更新注册表
+func (d *PromptDir) reload() {
+	// 新建的子目录加入监听
+	if err := d.watchTree(); err != nil {
+		logf(LevelWarn, "prompt dir %s: %v", d.dir, err)
+	}
+	prompts, err := readPromptDir(d.dir)
+	if err != nil {
+		logf(LevelWarn, "prompt dir %s: reload: %v, keeping current prompts", d.dir, err)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changed := false
+	for name := range d.prompts {
+		if _, ok := prompts[name]; !ok {
+			UnregisterPrompt(name)
+			changed = true
+		}
+	}
+	for name, p := range prompts {
+		if old, ok := d.prompts[name]; !ok || !reflect.DeepEqual(old, p) {
+			RegisterPrompt(p)
+			changed = true
+		}
+	}
+	d.prompts = prompts
+	if changed {
+		d.s.NotifyPromptsListChanged()
+	}
+}
+
+// watchTree 监听目录树中的每个目录
+func (d *PromptDir) watchTree() error {
+	return filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		if path != d.dir && ignoredResourceFile(path) {
+			return filepath.SkipDir
+		}
+		return d.watcher.Add(path)
+	})
+}
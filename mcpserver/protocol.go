@@ -0,0 +1,93 @@
+package mcpserver
+
+import (
+	"context"
+	"net/http"
+
+	"mcptool/mcpctx"
+	"mcptool/version"
+)
+
+// ---------------------- 协议版本协商 ----------------------
+// 服务端支持 version.ProtocolVersions 中的多个修订版本。initialize 时客户端请求的版本受支持就使用它，
+// 否则返回最新版本，由客户端决定降级还是断开（见 version.Negotiate）。协商结果记在会话上，
+// 之后该会话的响应按协商的版本调整形状，旧版客户端不会收到它不认识的字段：
+//   - 2024-11-05：不声明 completions，experimental.transports 不列出 Streamable HTTP（该版本使用 HTTP+SSE 传输）
+//   - 2025-03-26 及之前：tools/list 不带 outputSchema，工具结果不带 structuredContent（内容仍在 text 块中），
+//     不发起 elicitation/create
+//
+// 没有会话的 HTTP 请求按 MCP-Protocol-Version 头的版本处理，头中的版本不受支持时返回 400；
+// 没有会话也没有该头（包括尚未 initialize 的 WS、stdio 会话）时按最新版本处理
+
+// protocolHeader 客户端在 initialize 之后的 HTTP 请求中带上协商的版本
+const protocolHeader = "MCP-Protocol-Version"
+
+type protocolKey struct{}
+
+// withRequestProtocol 把 HTTP 请求头中的协议版本放进 ctx
+func withRequestProtocol(ctx context.Context, r *http.Request) context.Context {
+	if v := r.Header.Get(protocolHeader); v != "" {
+		return context.WithValue(ctx, protocolKey{}, v)
+	}
+	return ctx
+}
+
+// checkProtocolHeader 请求头中的协议版本不受支持时返回 400，返回 false
+func checkProtocolHeader(w http.ResponseWriter, r *http.Request) bool {
+	if v := r.Header.Get(protocolHeader); v != "" && !version.Supports(v) {
+		http.Error(w, "unsupported "+protocolHeader+": "+v, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// protocolOf 请求适用的协议版本：会话协商的版本、请求头中的版本，都没有时为空（按最新版本处理）
+func protocolOf(ctx context.Context) string {
+	if st, ok := mcpctx.SessionStateFromContext(ctx); ok && st.Initialized() {
+		if v := st.Client().ProtocolVersion; v != "" {
+			return v
+		}
+	}
+	v, _ := ctx.Value(protocolKey{}).(string)
+	return v
+}
+
+// protocolResult 按协议版本调整结果形状，method 为内部方法名。
+// 结果可能被合并的请求共享（见 coalesce.go），只能返回副本，不能修改原结果
+func protocolResult(protocol, method string, result interface{}) interface{} {
+	if version.Has(protocol, version.FeatureStructuredContent) {
+		return result
+	}
+	switch method {
+	case "tools.list":
+		m, ok := result.(map[string]interface{})
+		if !ok {
+			return result
+		}
+		tools, ok := m["tools"].([]ToolSummary)
+		if !ok {
+			return result
+		}
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[k] = v
+		}
+		list := make([]ToolSummary, len(tools))
+		for i, t := range tools {
+			t.OutputSchema = nil
+			list[i] = t
+		}
+		out["tools"] = list
+		return out
+
+	case "tools.run":
+		res, ok := result.(*CallToolResult)
+		if !ok || res == nil || res.StructuredContent == nil {
+			return result
+		}
+		out := *res
+		out.StructuredContent = nil
+		return &out
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package mcpserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"mcptool/version"
+)
+
+// ---------------------- 只读镜像端点 ----------------------
+
+// readOnlyMethods 只读端点允许的方法：只查询，不执行任何工具
+var readOnlyMethods = map[string]bool{
+	"tools.list":               true,
+	"resources.get":            true,
+	"resources.list":           true,
+	"resources/templates/list": true,
+	"prompts.get":              true,
+	"prompts.list":             true,
+	"completion/complete":      true,
+	"server.info":              true,
+	"system.describe":          true,
+	"system.listMethods":       true,
+	"system.version":           true,
+	"initialize":               true,
+	"ping":                     true,
+}
+
+// IsReadOnlyMethod 判断方法是否可以在只读端点上调用
+func IsReadOnlyMethod(method string) bool {
+	return readOnlyMethods[method]
+}
+
+// readOnlyHandler 只读镜像端点，供看板、目录、监控系统使用，永远不会触发工具执行
+func (s *McpServer) readOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeParseError(w, err)
+		return
+	}
+
+	var req RPCRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.writeParseError(w, err)
+		return
+	}
+	if !IsReadOnlyMethod(CanonicalMethod(req.Method)) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(RPCResponse{
+			JsonRPC: version.JSONRPC,
+			ID:      req.ID,
+			Error:   &RPCError{Code: -32601, Message: "Method not allowed on read-only endpoint"},
+		})
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	s.httpHandler(w, r)
+}
@@ -1,48 +1,216 @@
 package mcpserver
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 // -------------------- Resource --------------------
+// 资源按 URI 寻址（MCP 规范的资源模型），内容为文本（Text）或二进制（Blob，传输时 base64），带 MIME 类型：
+//
+//	mcpserver.RegisterResource(&mcpserver.Resource{
+//		URI:      "file:///docs/readme.md",
+//		Name:     "readme",
+//		MimeType: "text/markdown",
+//		Text:     readme,
+//	})
+//
+// resources/read 按 URI 读取，返回 {"contents": [{"uri", "mimeType", "text" | "blob"}]}。
+// 兼容旧的按名称注册：只设置 Name、Type、Data 的资源以 Name 作为 URI，Data 为字符串时是文本，
+// 其他值按 JSON 编码；resources.get 仍可按 name 读取，返回旧的 {Name, Type, Data} 格式。
+// 按资源授权（ACL 中的 resources.get:名称）始终按 Name 判断，用 URI 读取时也一样
+
+// Resource 一个资源
 type Resource struct {
-	Name string
+	// URI 资源地址，如 "file:///docs/readme.md"、"config://app"；为空时使用 Name
+	URI string
+	// Name 资源名，为空时使用 URI
+	Name        string
+	Description string
+	// MimeType 内容的 MIME 类型，为空时按内容推断（文本为 text/plain，Blob 为 application/octet-stream）
+	MimeType string
+	// Text / Blob 资源内容，二选一
+	Text string
+	Blob []byte
+
+	// Type 旧版的类型说明，含 "/" 时视为 MIME 类型。Deprecated: 使用 MimeType
 	Type string
+	// Data 旧版的资源内容，Text、Blob 都为空时使用。Deprecated: 使用 Text / Blob
 	Data interface{}
 }
 
 var (
-	resourceRegistry = make(map[string]*Resource)
+	resourceRegistry = make(map[string]*Resource) // URI -> 资源
 	resourceLock     sync.RWMutex
 )
 
+// RegisterResource 注册资源，同一 URI 已有资源时替换
 func RegisterResource(r *Resource) {
+	if r.URI == "" {
+		r.URI = r.Name
+	}
+	if r.Name == "" {
+		r.Name = r.URI
+	}
 	resourceLock.Lock()
 	defer resourceLock.Unlock()
-	resourceRegistry[r.Name] = r
+	resourceRegistry[r.URI] = r
 }
 
-func GetResource(name string) (*Resource, error) {
+// UnregisterResource 移除资源，返回是否存在
+func UnregisterResource(uri string) bool {
+	resourceLock.Lock()
+	defer resourceLock.Unlock()
+	_, ok := resourceRegistry[uri]
+	delete(resourceRegistry, uri)
+	return ok
+}
+
+// GetResource 按 URI 查找资源，找不到时再按名称查找（兼容旧的按名称读取）
+func GetResource(uriOrName string) (*Resource, error) {
 	resourceLock.RLock()
 	defer resourceLock.RUnlock()
-	if r, ok := resourceRegistry[name]; ok {
+	if r, ok := resourceRegistry[uriOrName]; ok {
 		return r, nil
 	}
-	return nil, fmt.Errorf("resource not found: %s", name)
+	for _, r := range resourceRegistry {
+		if r.Name == uriOrName {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, uriOrName)
 }
 
+// ListResources 已注册的资源和资源提供者列出的资源，按 URI 排序。每项包含 uri、name、mimeType、
+// description（非空时），以及旧版的 type
 func ListResources() []map[string]string {
-	resourceLock.RLock()
-	defer resourceLock.RUnlock()
-	list := []map[string]string{}
-	for _, r := range resourceRegistry {
-		list = append(list, map[string]string{
-			"name": r.Name,
-			"type": r.Type,
-		})
+	return listResources(context.Background())
+}
+
+// listItem resources/list 中的一项
+func (r *Resource) listItem() map[string]string {
+	item := map[string]string{
+		"uri":      r.URI,
+		"name":     r.Name,
+		"mimeType": r.mimeType(),
+		"type":     r.Type,
+	}
+	if item["type"] == "" {
+		item["type"] = item["mimeType"]
+	}
+	if r.Description != "" {
+		item["description"] = r.Description
+	}
+	return item
+}
+
+// mimeType 资源的 MIME 类型：MimeType，其次是旧版 Type 中的 MIME 类型，最后按内容推断
+func (r *Resource) mimeType() string {
+	switch {
+	case r.MimeType != "":
+		return r.MimeType
+	case strings.Contains(r.Type, "/"):
+		return r.Type
+	case r.Blob != nil:
+		return "application/octet-stream"
+	case r.Text != "":
+		return "text/plain"
+	}
+	switch r.Data.(type) {
+	case string, nil:
+		return "text/plain"
+	case []byte:
+		return "application/octet-stream"
+	default:
+		return "application/json"
+	}
+}
+
+// Contents 资源内容：二进制内容以 base64 放在 blob 中，其他放在 text 中
+func (r *Resource) Contents() ResourceContents {
+	c := ResourceContents{URI: r.URI, MimeType: r.mimeType()}
+	switch {
+	case r.Blob != nil:
+		c.Blob = base64.StdEncoding.EncodeToString(r.Blob)
+	case r.Text != "":
+		c.Text = r.Text
+	default:
+		switch v := r.Data.(type) {
+		case nil:
+		case string:
+			c.Text = v
+		case []byte:
+			c.Blob = base64.StdEncoding.EncodeToString(v)
+		default:
+			data, _ := json.Marshal(v)
+			c.Text = string(data)
+		}
+	}
+	return c
+}
+
+// MarshalJSON resources.get 的旧版结果 {Name, Type, Data}，另带 uri 和 mimeType
+func (r *Resource) MarshalJSON() ([]byte, error) {
+	data := r.Data
+	if data == nil {
+		c := r.Contents()
+		if c.Blob != "" {
+			data = c.Blob
+		} else {
+			data = c.Text
+		}
 	}
-	return list
+	typ := r.Type
+	if typ == "" {
+		typ = r.mimeType()
+	}
+	return json.Marshal(map[string]interface{}{
+		"Name":     r.Name,
+		"Type":     typ,
+		"Data":     data,
+		"uri":      r.URI,
+		"mimeType": r.mimeType(),
+	})
+}
+
+// loadFileResource 读取 dir 下的文件 path 作为资源：URI 为 uriPrefix 加相对路径（/ 分隔），名称为相对路径，
+// MIME 类型按扩展名推断，推断不出时按内容判断；UTF-8 文本放在 Text，其他放在 Blob
+func loadFileResource(dir, uriPrefix, path string) (*Resource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+	typ := mime.TypeByExtension(filepath.Ext(path))
+	if typ == "" {
+		typ = http.DetectContentType(data)
+	}
+	r := &Resource{URI: uriPrefix + rel, Name: rel, MimeType: typ}
+	if utf8.Valid(data) {
+		r.Text = string(data)
+	} else {
+		r.Blob = data
+	}
+	return r, nil
+}
+
+// ignoredResourceFile 隐藏文件和编辑器的临时文件，目录资源和 prompt 目录都不加载
+func ignoredResourceFile(path string) bool {
+	base := filepath.Base(path)
+	return strings.HasPrefix(base, ".") || strings.HasSuffix(base, "~")
 }
 
 // ---------------------- resource ----------
@@ -0,0 +1,146 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ---------------------- 资源提供者 ----------------------
+// 内容保存在数据库、对象存储或远端 HTTP 上的资源不必预先读入内存，实现 ResourceProvider 后按 URI 前缀注册，
+// resources/list 时调用 List 列出，resources/read 时才调用 Read 读取内容：
+//
+//	mcpserver.RegisterResourceProvider("reports", "s3://reports/", &s3Provider{bucket: "reports"})
+//
+// 读取顺序：已注册的静态资源、URI 前缀匹配的提供者（最长前缀优先）、资源模板。
+// 按资源授权时，提供者的资源以注册时的名称作为资源名（与模板相同），ACL 中写 resources.get:reports
+
+// ResourceProvider 按需提供资源
+type ResourceProvider interface {
+	// List 列出资源，只需填写 URI、Name、Description、MimeType，内容由 Read 读取
+	List(ctx context.Context) ([]*Resource, error)
+	// Read 读取资源内容；资源不存在时返回 ErrResourceNotFound（可以包装）
+	Read(ctx context.Context, uri string) (*Resource, error)
+}
+
+type resourceProviderEntry struct {
+	name      string
+	uriPrefix string
+	provider  ResourceProvider
+}
+
+var (
+	resourceProviders     []*resourceProviderEntry
+	resourceProvidersLock sync.RWMutex
+)
+
+// RegisterResourceProvider 注册资源提供者，uriPrefix 下的 URI 由它读取；同名的提供者已存在时替换
+func RegisterResourceProvider(name, uriPrefix string, p ResourceProvider) error {
+	if name == "" || uriPrefix == "" || p == nil {
+		return fmt.Errorf("resource provider %q: name, uri prefix and provider are required", name)
+	}
+	entry := &resourceProviderEntry{name: name, uriPrefix: uriPrefix, provider: p}
+	resourceProvidersLock.Lock()
+	defer resourceProvidersLock.Unlock()
+	for i, existing := range resourceProviders {
+		if existing.name == name {
+			resourceProviders[i] = entry
+			return nil
+		}
+	}
+	resourceProviders = append(resourceProviders, entry)
+	return nil
+}
+
+// UnregisterResourceProvider 移除资源提供者，返回是否存在
+func UnregisterResourceProvider(name string) bool {
+	resourceProvidersLock.Lock()
+	defer resourceProvidersLock.Unlock()
+	for i, entry := range resourceProviders {
+		if entry.name == name {
+			resourceProviders = append(resourceProviders[:i], resourceProviders[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// matchResourceProvider URI 前缀匹配的提供者，多个匹配时取最长的前缀
+func matchResourceProvider(uri string) (*resourceProviderEntry, bool) {
+	resourceProvidersLock.RLock()
+	defer resourceProvidersLock.RUnlock()
+	var best *resourceProviderEntry
+	for _, entry := range resourceProviders {
+		if strings.HasPrefix(uri, entry.uriPrefix) && (best == nil || len(entry.uriPrefix) > len(best.uriPrefix)) {
+			best = entry
+		}
+	}
+	return best, best != nil
+}
+
+// read 由提供者读取资源，补全 URI 和 Name
+func (e *resourceProviderEntry) read(ctx context.Context, uri string) (*Resource, error) {
+	r, err := e.provider.Read(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+	}
+	read := *r
+	if read.URI == "" {
+		read.URI = uri
+	}
+	if read.Name == "" {
+		read.Name = read.URI
+	}
+	return &read, nil
+}
+
+// listResources 静态资源和各提供者列出的资源，按 URI 排序；URI 与静态资源重复时以静态资源为准。
+// 某个提供者出错时记录日志并跳过，不影响其他资源
+func listResources(ctx context.Context) []map[string]string {
+	resourceLock.RLock()
+	list := make([]map[string]string, 0, len(resourceRegistry))
+	seen := make(map[string]bool, len(resourceRegistry))
+	for _, r := range resourceRegistry {
+		list = append(list, r.listItem())
+		seen[r.URI] = true
+	}
+	resourceLock.RUnlock()
+
+	resourceProvidersLock.RLock()
+	providers := append([]*resourceProviderEntry(nil), resourceProviders...)
+	resourceProvidersLock.RUnlock()
+	for _, entry := range providers {
+		resources, err := entry.provider.List(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logf(LevelWarn, "resource provider %s: list: %v", entry.name, err)
+			}
+			continue
+		}
+		for _, r := range resources {
+			if r == nil || r.URI == "" || seen[r.URI] {
+				continue
+			}
+			item := *r
+			if item.Name == "" {
+				item.Name = item.URI
+			}
+			listed := item.listItem()
+			if item.MimeType == "" && !strings.Contains(item.Type, "/") {
+				// 没有内容，不能推断类型
+				delete(listed, "mimeType")
+				listed["type"] = item.Type
+			}
+			list = append(list, listed)
+			seen[r.URI] = true
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i]["uri"] < list[j]["uri"] })
+	return list
+}
@@ -0,0 +1,267 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ---------------------- 资源模板 ----------------------
+// 参数化的资源用 RFC 6570 URI 模板注册，resources/templates/list 列出模板，resources/read 读取的 URI
+// 不是已注册的资源时按模板匹配，由 Resolve 生成内容：
+//
+//	mcpserver.RegisterResourceTemplate(&mcpserver.ResourceTemplate{
+//		URITemplate: "file:///logs/{date}.log",
+//		Name:        "logs",
+//		MimeType:    "text/plain",
+//		Resolve: func(ctx context.Context, uri string, vars map[string]string) (*mcpserver.Resource, error) {
+//			data, err := os.ReadFile(filepath.Join(logDir, vars["date"]+".log"))
+//			if errors.Is(err, fs.ErrNotExist) {
+//				return nil, mcpserver.ErrResourceNotFound
+//			}
+//			...
+//		},
+//	})
+//
+// 支持 RFC 6570 的 {var}（不含 /、?、# 等保留字符）和 {+var}（可以包含 /，用于路径），变量值已做百分号解码。
+// 多个模板都能匹配时，按注册顺序取第一个。按资源授权时以模板的 Name 作为资源名
+
+// ResourceTemplate 一个资源模板
+type ResourceTemplate struct {
+	// URITemplate RFC 6570 URI 模板，如 "file:///logs/{date}.log"
+	URITemplate string
+	Name        string
+	Description string
+	// MimeType 生成的资源的默认 MIME 类型
+	MimeType string
+	// Resolve 按匹配到的变量生成资源；资源不存在时返回 ErrResourceNotFound（可以包装）。
+	// 返回的资源 URI 为空时使用请求的 URI，MimeType 为空时使用模板的 MimeType
+	Resolve func(ctx context.Context, uri string, vars map[string]string) (*Resource, error)
+	// Complete 可选，按变量名给出 completion/complete 的补全建议，见 CompletionFunc
+	Complete map[string]CompletionFunc
+
+	pattern *regexp.Regexp
+	vars    []string
+}
+
+var (
+	resourceTemplates     []*ResourceTemplate
+	resourceTemplatesLock sync.RWMutex
+)
+
+// uriTemplateExpr 模板中的表达式
+var uriTemplateExpr = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// uriTemplateVar 变量名：字母、数字、_ 和百分号编码
+var uriTemplateVar = regexp.MustCompile(`^(?:[A-Za-z0-9_]|%[0-9A-Fa-f]{2})+$`)
+
+// compile 把模板编译为匹配完整 URI 的正则
+func (t *ResourceTemplate) compile() error {
+	var b strings.Builder
+	b.WriteString("^")
+	t.vars = nil
+	last := 0
+	for _, m := range uriTemplateExpr.FindAllStringSubmatchIndex(t.URITemplate, -1) {
+		literal := t.URITemplate[last:m[0]]
+		if strings.ContainsAny(literal, "{}") {
+			return fmt.Errorf("uri template %q: unbalanced braces", t.URITemplate)
+		}
+		b.WriteString(regexp.QuoteMeta(literal))
+		expr := t.URITemplate[m[2]:m[3]]
+		value := `([^/?#]+)`
+		if strings.HasPrefix(expr, "+") {
+			expr, value = expr[1:], `(.+?)`
+		}
+		if !uriTemplateVar.MatchString(expr) {
+			return fmt.Errorf("uri template %q: unsupported expression {%s}, only {var} and {+var} are supported",
+				t.URITemplate, t.URITemplate[m[2]:m[3]])
+		}
+		t.vars = append(t.vars, expr)
+		b.WriteString(value)
+		last = m[1]
+	}
+	tail := t.URITemplate[last:]
+	if strings.ContainsAny(tail, "{}") {
+		return fmt.Errorf("uri template %q: unbalanced braces", t.URITemplate)
+	}
+	b.WriteString(regexp.QuoteMeta(tail))
+	b.WriteString("$")
+	pattern, err := regexp.Compile(b.String())
+	if err != nil {
+		return fmt.Errorf("uri template %q: %w", t.URITemplate, err)
+	}
+	t.pattern = pattern
+	return nil
+}
+
+// match 匹配 URI，返回解码后的变量
+func (t *ResourceTemplate) match(uri string) (map[string]string, bool) {
+	m := t.pattern.FindStringSubmatch(uri)
+	if m == nil {
+		return nil, false
+	}
+	vars := make(map[string]string, len(t.vars))
+	for i, name := range t.vars {
+		value, err := url.PathUnescape(m[i+1])
+		if err != nil {
+			return nil, false
+		}
+		vars[name] = value
+	}
+	return vars, true
+}
+
+// RegisterResourceTemplate 注册资源模板，同一 URITemplate 已有模板时替换；模板语法不支持时返回错误
+func RegisterResourceTemplate(t *ResourceTemplate) error {
+	if t.Resolve == nil {
+		return fmt.Errorf("uri template %q: Resolve is required", t.URITemplate)
+	}
+	if err := t.compile(); err != nil {
+		return err
+	}
+	if t.Name == "" {
+		t.Name = t.URITemplate
+	}
+	resourceTemplatesLock.Lock()
+	defer resourceTemplatesLock.Unlock()
+	for i, existing := range resourceTemplates {
+		if existing.URITemplate == t.URITemplate {
+			resourceTemplates[i] = t
+			return nil
+		}
+	}
+	resourceTemplates = append(resourceTemplates, t)
+	return nil
+}
+
+// UnregisterResourceTemplate 移除资源模板，返回是否存在
+func UnregisterResourceTemplate(uriTemplate string) bool {
+	resourceTemplatesLock.Lock()
+	defer resourceTemplatesLock.Unlock()
+	for i, t := range resourceTemplates {
+		if t.URITemplate == uriTemplate {
+			resourceTemplates = append(resourceTemplates[:i], resourceTemplates[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ListResourceTemplates 已注册的模板，按 URI 模板排序。每项包含 uriTemplate、name、description、mimeType（非空时）
+func ListResourceTemplates() []map[string]string {
+	resourceTemplatesLock.RLock()
+	defer resourceTemplatesLock.RUnlock()
+	list := []map[string]string{}
+	for _, t := range resourceTemplates {
+		item := map[string]string{"uriTemplate": t.URITemplate, "name": t.Name}
+		if t.Description != "" {
+			item["description"] = t.Description
+		}
+		if t.MimeType != "" {
+			item["mimeType"] = t.MimeType
+		}
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i]["uriTemplate"] < list[j]["uriTemplate"] })
+	return list
+}
+
+// findResourceTemplate 按 URI 模板查找已注册的模板
+func findResourceTemplate(uriTemplate string) (*ResourceTemplate, bool) {
+	resourceTemplatesLock.RLock()
+	defer resourceTemplatesLock.RUnlock()
+	for _, t := range resourceTemplates {
+		if t.URITemplate == uriTemplate {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// matchResourceTemplate 第一个能匹配 uri 的模板
+func matchResourceTemplate(uri string) (*ResourceTemplate, map[string]string, bool) {
+	resourceTemplatesLock.RLock()
+	defer resourceTemplatesLock.RUnlock()
+	for _, t := range resourceTemplates {
+		if vars, ok := t.match(uri); ok {
+			return t, vars, true
+		}
+	}
+	return nil, nil, false
+}
+
+// resourceName 授权用的资源名：已注册的资源取其名称，提供者的资源取提供者名称，匹配模板的 URI 取模板名称
+func resourceName(uriOrName string) string {
+	if r, err := GetResource(uriOrName); err == nil {
+		return r.Name
+	}
+	if p, ok := matchResourceProvider(uriOrName); ok {
+		return p.name
+	}
+	if t, _, ok := matchResourceTemplate(uriOrName); ok {
+		return t.Name
+	}
+	return uriOrName
+}
+
+// readResource 读取资源：先查已注册的资源，再由提供者读取，最后按模板生成
+func readResource(ctx context.Context, uriOrName string) (*Resource, error) {
+	r, err := GetResource(uriOrName)
+	if err == nil {
+		return r, nil
+	}
+	if p, ok := matchResourceProvider(uriOrName); ok {
+		r, perr := p.read(ctx, uriOrName)
+		if !errors.Is(perr, ErrResourceNotFound) {
+			return r, perr
+		}
+		err = perr
+	}
+	t, vars, ok := matchResourceTemplate(uriOrName)
+	if !ok {
+		return nil, err
+	}
+	r, err = t.Resolve(ctx, uriOrName, vars)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("%w: %s", ErrResourceNotFound, uriOrName)
+	}
+	resolved := *r
+	if resolved.URI == "" {
+		resolved.URI = uriOrName
+	}
+	if resolved.Name == "" {
+		resolved.Name = t.Name
+	}
+	if resolved.MimeType == "" && resolved.Type == "" {
+		resolved.MimeType = t.MimeType
+	}
+	return &resolved, nil
+}
+
+// listResourceTemplates resources/templates/list：只列出调用方可以读取的模板
+func (s *McpServer) listResourceTemplates(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+	list := ListResourceTemplates()
+	names := make([]string, len(list))
+	for i, t := range list {
+		names[i] = t["name"]
+	}
+	visible := make(map[string]bool)
+	for _, name := range s.visibleNames(ctx, c.access, "resources.get", names) {
+		visible[name] = true
+	}
+	kept := make([]map[string]string, 0, len(list))
+	for _, t := range list {
+		if visible[t["name"]] {
+			kept = append(kept, t)
+		}
+	}
+	return map[string]interface{}{"resourceTemplates": kept}, nil
+}
@@ -0,0 +1,83 @@
+package mcpserver
+
+import (
+	"encoding/json"
+
+	"mcptool/jsonschema"
+	"mcptool/mcpctx"
+)
+
+// ---------------------- CallToolResult ----------------------
+
+// CallToolResult MCP 规范的 tools/call 结果：
+// Content 给只能展示文本的 host，StructuredContent 是可按 outputSchema 校验的结构化结果
+type CallToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+	// Meta 结果的 _meta：请求的 _meta 原样回传，工具设置的同名字段优先
+	Meta mcpctx.Meta `json:"_meta,omitempty"`
+}
+
+// toolCallResult 把工具返回的普通值包装为 CallToolResult：
+// 结果的 JSON 放进一个 text 块；结果是 JSON 对象时同时作为 structuredContent。
+// 工具直接返回 *CallToolResult、Content 或 []Content 时按内容块处理
+func toolCallResult(reg *ToolRegistry, name string, result interface{}) *CallToolResult {
+	switch r := result.(type) {
+	case *CallToolResult:
+		if r != nil {
+			if r.Content == nil {
+				r.Content = []Content{}
+			}
+			return r
+		}
+	case CallToolResult:
+		return toolCallResult(reg, name, &r)
+	case []Content:
+		return NewToolResult(r...)
+	case Content:
+		return NewToolResult(r)
+	case string:
+		return NewToolResult(NewTextContent(r))
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		logf(LevelError, "tool %s: marshal result: %v", name, err)
+		return NewToolError("result is not JSON serializable")
+	}
+	out := NewToolResult(NewTextContent(string(data)))
+	if len(data) > 0 && data[0] == '{' {
+		out.StructuredContent = json.RawMessage(data)
+		checkOutputSchema(reg, name, data)
+	}
+	return out
+}
+
+// echoMeta 把请求的 _meta 合并进结果，返回副本，不修改工具可能复用的结果对象
+func echoMeta(res *CallToolResult, meta RequestMeta) *CallToolResult {
+	if res == nil || len(meta.Fields) == 0 {
+		return res
+	}
+	out := *res
+	out.Meta = make(mcpctx.Meta, len(meta.Fields)+len(res.Meta))
+	for k, v := range meta.Fields {
+		out.Meta[k] = v
+	}
+	for k, v := range res.Meta {
+		out.Meta[k] = v
+	}
+	return &out
+}
+
+// checkOutputSchema 结构化结果与工具声明的 OutputSchema 不符时记录警告，
+// 结果照常返回，由 host 决定如何处理
+func checkOutputSchema(reg *ToolRegistry, name string, data []byte) {
+	tool, ok := reg.Get(name)
+	if !ok || tool.outputSchema == nil {
+		return
+	}
+	if err := jsonschema.ValidateJSON(tool.outputSchema, data); err != nil {
+		logf(LevelWarn, "tool %s: result does not match output schema: %v", name, err)
+	}
+}
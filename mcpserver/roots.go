@@ -0,0 +1,92 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- Roots ----------------------
+// 客户端可以向服务端公开一组根（通常是用户打开的项目目录），工具用 ListRoots 获取，
+// 据此限定读写文件的范围：
+//
+//	roots, err := mcpserver.ListRoots(ctx)
+//	for _, r := range roots {
+//		// r.URI 如 "file:///home/user/project"
+//	}
+//
+// 结果按会话缓存；客户端的根变化时发送 notifications/roots/list_changed，服务端清除缓存，
+// 并调用 WithRootsChangedHandler 设置的回调。与 sampling 一样只能在 WS、stdio 会话中使用
+
+// ErrRootsUnsupported 当前会话不支持 roots
+var ErrRootsUnsupported = errors.New("client does not support roots")
+
+// Root 客户端公开的一个根
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// ListRoots 当前会话的客户端公开的根（roots/list）
+func ListRoots(ctx context.Context) ([]Root, error) {
+	if !clientCapability(ctx, "roots") {
+		return nil, ErrRootsUnsupported
+	}
+	session, _ := mcpctx.SessionFromContext(ctx)
+	st := stateOf(session)
+	var gen uint64
+	if st != nil {
+		st.mu.Lock()
+		roots, cached := st.roots, st.rootsCached
+		gen = st.rootsGen
+		st.mu.Unlock()
+		if cached {
+			return append([]Root(nil), roots...), nil
+		}
+	}
+
+	var result struct {
+		Roots []Root `json:"roots"`
+	}
+	if err := callClient(ctx, "roots/list", nil, &result); err != nil {
+		if errors.Is(err, ErrClientRequestUnsupported) {
+			return nil, fmt.Errorf("%w: %v", ErrRootsUnsupported, err)
+		}
+		return nil, err
+	}
+	if result.Roots == nil {
+		result.Roots = []Root{}
+	}
+	if st != nil {
+		st.mu.Lock()
+		if st.rootsGen == gen {
+			st.roots, st.rootsCached = result.Roots, true
+		}
+		st.mu.Unlock()
+	}
+	return append([]Root(nil), result.Roots...), nil
+}
+
+// WithRootsChangedHandler 客户端发送 notifications/roots/list_changed 时调用 fn，
+// ctx 带有该会话，可以在其中用 ListRoots 重新获取。fn 在收到通知的 goroutine 中执行，不要长时间阻塞
+func WithRootsChangedHandler(fn func(ctx context.Context)) Option {
+	return func(s *McpServer) {
+		s.rootsChanged = append(s.rootsChanged, fn)
+	}
+}
+
+// rootsListChanged 处理客户端的 notifications/roots/list_changed
+func (s *McpServer) rootsListChanged(ctx context.Context) {
+	session, _ := mcpctx.SessionFromContext(ctx)
+	if st := stateOf(session); st != nil {
+		st.mu.Lock()
+		st.roots, st.rootsCached = nil, false
+		st.rootsGen++
+		st.mu.Unlock()
+	}
+	for _, fn := range s.rootsChanged {
+		fn(ctx)
+	}
+}
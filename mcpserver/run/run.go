@@ -1,7 +0,0 @@
-package main
-
-import "mcptool/mcpserver"
-
-func main() {
-	mcpserver.StartMcpServer()
-}
@@ -0,0 +1,108 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ---------------------- Sampling ----------------------
+// 工具可以通过连接的客户端调用 LLM：在 WS、stdio 会话中 SamplerFromContext 返回的 Sampler
+// 向客户端发送 sampling/createMessage 请求并等待结果：
+//
+//	sampler, ok := mcpserver.SamplerFromContext(ctx)
+//	if !ok {
+//		return nil, errors.New("client does not support sampling")
+//	}
+//	res, err := sampler.CreateMessage(ctx, &mcpserver.CreateMessageRequest{
+//		Messages:  []mcpserver.SamplingMessage{{Role: "user", Content: mcpserver.NewTextContent("Summarize: " + text)}},
+//		MaxTokens: 200,
+//	})
+//
+// 普通 HTTP 请求无法向客户端发起请求，已 initialize 但没有声明 sampling 能力的客户端也不支持，这两种情况 ok 为 false
+
+// ErrSamplingUnsupported 当前会话不支持 sampling
+var ErrSamplingUnsupported = errors.New("client does not support sampling")
+
+// CreateMessageRequest sampling/createMessage 的参数
+type CreateMessageRequest struct {
+	Messages         []SamplingMessage      `json:"messages"`
+	ModelPreferences *ModelPreferences      `json:"modelPreferences,omitempty"`
+	SystemPrompt     string                 `json:"systemPrompt,omitempty"`
+	IncludeContext   string                 `json:"includeContext,omitempty"` // "none" / "thisServer" / "allServers"
+	Temperature      *float64               `json:"temperature,omitempty"`
+	MaxTokens        int                    `json:"maxTokens"`
+	StopSequences    []string               `json:"stopSequences,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// SamplingMessage 发给 LLM 的一条消息，Content 为 TextContent 或 ImageContent
+type SamplingMessage struct {
+	Role    string  `json:"role"` // "user" / "assistant"
+	Content Content `json:"content"`
+}
+
+// ModelPreferences 对模型的偏好，由客户端决定最终使用的模型；优先级取 0~1
+type ModelPreferences struct {
+	Hints                []ModelHint `json:"hints,omitempty"`
+	CostPriority         float64     `json:"costPriority,omitempty"`
+	SpeedPriority        float64     `json:"speedPriority,omitempty"`
+	IntelligencePriority float64     `json:"intelligencePriority,omitempty"`
+}
+
+// ModelHint 模型名称提示，如 "claude-3-sonnet"，客户端可按子串匹配
+type ModelHint struct {
+	Name string `json:"name"`
+}
+
+// CreateMessageResult 客户端返回的补全
+type CreateMessageResult struct {
+	Role       string          `json:"role"`
+	Content    SamplingContent `json:"content"`
+	Model      string          `json:"model"`
+	StopReason string          `json:"stopReason,omitempty"` // "endTurn" / "stopSequence" / "maxTokens" 等
+}
+
+// SamplingContent 补全的内容：Type 为 "text" 时是 Text，为 "image" / "audio" 时是 base64 的 Data
+type SamplingContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// Sampler 向客户端请求 LLM 补全
+type Sampler interface {
+	CreateMessage(ctx context.Context, req *CreateMessageRequest) (*CreateMessageResult, error)
+}
+
+// SamplerFromContext 当前会话的 Sampler，会话不支持 sampling 时返回 false
+func SamplerFromContext(ctx context.Context) (Sampler, bool) {
+	if !canCallClient(ctx) || !clientCapability(ctx, "sampling") {
+		return nil, false
+	}
+	return clientSampler{}, true
+}
+
+// clientSampler 通过当前请求的会话发送 sampling/createMessage
+type clientSampler struct{}
+
+func (clientSampler) CreateMessage(ctx context.Context, req *CreateMessageRequest) (*CreateMessageResult, error) {
+	if req == nil || len(req.Messages) == 0 {
+		return nil, errors.New("sampling: no messages")
+	}
+	if req.MaxTokens <= 0 {
+		return nil, errors.New("sampling: maxTokens must be positive")
+	}
+	if !clientCapability(ctx, "sampling") {
+		return nil, ErrSamplingUnsupported
+	}
+	var res CreateMessageResult
+	if err := callClient(ctx, "sampling/createMessage", req, &res); err != nil {
+		if errors.Is(err, ErrClientRequestUnsupported) {
+			return nil, fmt.Errorf("%w: %v", ErrSamplingUnsupported, err)
+		}
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,174 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------- 脚本工具 ----------------------
+// manifest 中的工具可以是一段 Starlark / Lua 等脚本，简单的胶水逻辑不必重新编译服务：
+//
+//	tools:
+//	  - name: fahrenheit
+//	    script:
+//	      lang: starlark
+//	      source: |
+//	        def main(args):
+//	            return {"f": args["c"] * 9 / 5 + 32}
+//	      timeout: 2s
+//	      max_steps: 100000
+//
+// 引擎需要在加载 manifest 之前用 RegisterScriptEngine 注册。内置 Starlark 引擎（见 starlark.go，-tags nostarlark 时去掉）：
+//
+//	mcpserver.RegisterScriptEngine("starlark", mcpserver.StarlarkEngine{})
+//
+// 其他语言由嵌入方实现 ScriptEngine。引擎负责沙箱：只提供纯计算的标准库（不能读写文件、访问网络、执行命令），
+// 并执行 ScriptLimits 中的限制。
+
+// 脚本工具的默认限制
+const (
+	scriptDefaultTimeout   = 5 * time.Second
+	scriptDefaultMaxSteps  = 1000000
+	scriptDefaultMaxMemory = 64 << 20
+)
+
+// ScriptLimits 一次脚本执行的限制，由引擎执行
+type ScriptLimits struct {
+	// Timeout 执行时间上限，Run 的 ctx 在超时时结束
+	Timeout time.Duration
+	// MaxSteps 解释器执行步数上限（Starlark 的 execution steps、Lua 的指令数），限制 CPU
+	MaxSteps uint64
+	// MaxMemory 脚本可分配内存的上限（字节），引擎不支持精确统计时可按分配的对象数估算
+	MaxMemory int64
+}
+
+// ScriptEngine 一种脚本语言的运行时
+type ScriptEngine interface {
+	// Compile 编译脚本，manifest 加载时调用，语法错误在加载阶段就报出；name 为工具名
+	Compile(name, source string) (Script, error)
+}
+
+// Script 编译后的脚本，同一个 Script 会被并发执行
+type Script interface {
+	// Run 执行脚本：args 为 JSON 解码后的工具参数，返回值需可以 JSON 编码。
+	// 引擎应在 ctx 结束或超出 limits 时中止脚本并返回错误
+	Run(ctx context.Context, args map[string]interface{}, limits ScriptLimits) (interface{}, error)
+}
+
+var (
+	scriptEngines     = make(map[string]ScriptEngine)
+	scriptEnginesLock sync.RWMutex
+)
+
+// RegisterScriptEngine 注册脚本语言 lang（manifest 中 script.lang 的值）的引擎，重复注册时替换
+func RegisterScriptEngine(lang string, engine ScriptEngine) {
+	scriptEnginesLock.Lock()
+	scriptEngines[lang] = engine
+	scriptEnginesLock.Unlock()
+}
+
+// ScriptEngines 已注册的脚本语言，按名称排序
+func ScriptEngines() []string {
+	scriptEnginesLock.RLock()
+	defer scriptEnginesLock.RUnlock()
+	langs := make([]string, 0, len(scriptEngines))
+	for lang := range scriptEngines {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+func lookupScriptEngine(lang string) (ScriptEngine, bool) {
+	scriptEnginesLock.RLock()
+	defer scriptEnginesLock.RUnlock()
+	engine, ok := scriptEngines[lang]
+	return engine, ok
+}
+
+// ManifestScriptTool 执行一段脚本；source 与 file 二选一，file 为相对 manifest 的路径
+type ManifestScriptTool struct {
+	Lang      string        `yaml:"lang"`
+	Source    string        `yaml:"source"`
+	File      string        `yaml:"file"`
+	Timeout   time.Duration `yaml:"timeout"`    // 默认 5s
+	MaxSteps  uint64        `yaml:"max_steps"`  // 默认 1000000
+	MaxMemory int64         `yaml:"max_memory"` // 字节，默认 64MB
+}
+
+// scriptTool 编译好的脚本工具
+type scriptTool struct {
+	script Script
+	limits ScriptLimits
+}
+
+// compile 读取并编译脚本，填充默认限制
+func (c ManifestScriptTool) compile(name, baseDir string) (*scriptTool, error) {
+	engine, ok := lookupScriptEngine(c.Lang)
+	if !ok {
+		return nil, fmt.Errorf("no script engine registered for lang %q (registered: %v)", c.Lang, ScriptEngines())
+	}
+	if (c.Source == "") == (c.File == "") {
+		return nil, fmt.Errorf("script must define exactly one of source / file")
+	}
+	source := c.Source
+	if c.File != "" {
+		data, err := os.ReadFile(resolvePath(baseDir, c.File))
+		if err != nil {
+			return nil, err
+		}
+		source = string(data)
+	}
+	script, err := engine.Compile(name, source)
+	if err != nil {
+		return nil, fmt.Errorf("compile script: %w", err)
+	}
+
+	limits := ScriptLimits{Timeout: c.Timeout, MaxSteps: c.MaxSteps, MaxMemory: c.MaxMemory}
+	if limits.Timeout <= 0 {
+		limits.Timeout = scriptDefaultTimeout
+	}
+	if limits.MaxSteps == 0 {
+		limits.MaxSteps = scriptDefaultMaxSteps
+	}
+	if limits.MaxMemory <= 0 {
+		limits.MaxMemory = scriptDefaultMaxMemory
+	}
+	return &scriptTool{script: script, limits: limits}, nil
+}
+
+// call 在超时内执行脚本；引擎没有及时响应 ctx 时不再等待，请求按超时返回
+func (t *scriptTool) call(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	args, err := decodeArgs(raw)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.limits.Timeout)
+	defer cancel()
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- outcome{err: fmt.Errorf("script panic: %v", p)}
+			}
+		}()
+		result, err := t.script.Run(ctx, args, t.limits)
+		done <- outcome{result, err}
+	}()
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("script: %w", ctx.Err())
+	}
+}
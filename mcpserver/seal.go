@@ -0,0 +1,134 @@
+package mcpserver
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ---------------------- 静态加密 ----------------------
+// 落盘数据的 AES-GCM 加密。密文带上密钥 ID，轮换后旧数据仍可用旧密钥解密，新数据一律使用当前密钥。
+// 目前唯一的使用方是备份（WithBackupSealer）。服务端还没有审计记录，会话也只保存在内存中，
+// 审计记录和会话数据因此都没有静态加密；以后加入持久化的审计 / 会话存储时应通过 Sealer 读写数据。
+
+// ErrUnknownKey 密文使用的密钥不在密钥环中（已被移除或来自其他部署）
+var ErrUnknownKey = errors.New("sealed data uses an unknown key")
+
+// Sealer 加密 / 解密落盘数据
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(sealed []byte) ([]byte, error)
+}
+
+// Keyring 基于 AES-GCM 的 Sealer，支持多把密钥和轮换。
+// 密文格式：4 字节密钥 ID（大端）+ nonce + GCM 密文
+type Keyring struct {
+	mu      sync.RWMutex
+	current uint32
+	keys    map[uint32]cipher.AEAD
+	// OnRotate 轮换后回调，可用于通知外部 KMS、触发旧数据重新加密等
+	OnRotate func(oldID, newID uint32)
+}
+
+// NewKeyring 用一把密钥创建密钥环，key 长度需为 16 / 24 / 32 字节
+func NewKeyring(id uint32, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[uint32]cipher.AEAD)}
+	if err := k.AddKey(id, key); err != nil {
+		return nil, err
+	}
+	k.current = id
+	return k, nil
+}
+
+// AddKey 加入一把只用于解密的密钥（如从 KMS 取回的历史密钥）
+func (k *Keyring) AddKey(id uint32, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("keyring: key %d: %w", id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("keyring: key %d: %w", id, err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = aead
+	return nil
+}
+
+// Rotate 加入新密钥并设为当前密钥，旧密钥保留用于解密
+func (k *Keyring) Rotate(id uint32, key []byte) error {
+	if err := k.AddKey(id, key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	old := k.current
+	k.current = id
+	hook := k.OnRotate
+	k.mu.Unlock()
+	if hook != nil {
+		hook(old, id)
+	}
+	return nil
+}
+
+// RemoveKey 移除不再需要的旧密钥，不能移除当前密钥
+func (k *Keyring) RemoveKey(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.current {
+		return fmt.Errorf("keyring: cannot remove current key %d", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// CurrentKeyID 当前用于加密的密钥 ID
+func (k *Keyring) CurrentKeyID() uint32 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	k.mu.RLock()
+	id := k.current
+	aead := k.keys[id]
+	k.mu.RUnlock()
+
+	out := make([]byte, 4+aead.NonceSize(), 4+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint32(out, id)
+	nonce := out[4:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// 密钥 ID 作为附加数据参与认证，防止被篡改成其他密钥
+	return aead.Seal(out, nonce, plaintext, out[:4]), nil
+}
+
+func (k *Keyring) Open(sealed []byte) ([]byte, error) {
+	if len(sealed) < 4 {
+		return nil, errors.New("keyring: sealed data too short")
+	}
+	id := binary.BigEndian.Uint32(sealed)
+	k.mu.RLock()
+	aead, ok := k.keys[id]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKey, id)
+	}
+	if len(sealed) < 4+aead.NonceSize() {
+		return nil, errors.New("keyring: sealed data too short")
+	}
+	nonce := sealed[4 : 4+aead.NonceSize()]
+	return aead.Open(nil, nonce, sealed[4+aead.NonceSize():], sealed[:4])
+}
+
+// NeedsReseal 数据不是用当前密钥加密的，轮换后可据此逐步重新加密旧数据
+func (k *Keyring) NeedsReseal(sealed []byte) bool {
+	return len(sealed) < 4 || binary.BigEndian.Uint32(sealed) != k.CurrentKeyID()
+}
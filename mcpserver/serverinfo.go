@@ -0,0 +1,141 @@
+package mcpserver
+
+import "mcptool/version"
+
+// ---------------------- 服务信息与能力声明 ----------------------
+// initialize 的 serverInfo / capabilities / instructions 与 server.info 共用这里的配置：
+//
+//	server_name: geo-tools
+//	server_version: 2.3.0
+//	instructions: |
+//	  先用 geocode 把地址转换为坐标，再调用 route / poi_search。
+//	capabilities:
+//	  tools: true
+//	  resources: true
+//	  subscribe: false
+//	  prompts: false
+//	  logging: true
+//	  completions: false
+//
+// 没有配置 capabilities 时声明全部能力。对应方法被关闭（见 Methods）的能力不会声明，
+// 例如关闭 logging/setLevel 后不再声明 logging，运行中修改方法开关对之后的 initialize 生效
+
+// defaultServerName 没有配置 ServerName 时的 serverInfo.name
+const defaultServerName = "MCP Server"
+
+// ServerCapabilities 服务声明的能力，Subscribe 为 resources 下的 subscribe
+type ServerCapabilities struct {
+	Tools       bool `yaml:"tools"`
+	Resources   bool `yaml:"resources"`
+	Subscribe   bool `yaml:"subscribe"`
+	Prompts     bool `yaml:"prompts"`
+	Logging     bool `yaml:"logging"`
+	Completions bool `yaml:"completions"`
+	// Experimental 附加的实验能力，与内置的 transports / suggest 合并
+	Experimental map[string]interface{} `yaml:"experimental"`
+}
+
+// AllCapabilities 声明全部能力，没有配置时的默认值
+func AllCapabilities() ServerCapabilities {
+	return ServerCapabilities{
+		Tools:       true,
+		Resources:   true,
+		Subscribe:   true,
+		Prompts:     true,
+		Logging:     true,
+		Completions: true,
+	}
+}
+
+// WithServerInfo 设置 serverInfo 的名称和版本，传空字符串的保持不变
+func WithServerInfo(name, ver string) Option {
+	return func(s *McpServer) {
+		if name != "" {
+			s.conf.ServerName = name
+		}
+		if ver != "" {
+			s.conf.ServerVersion = ver
+		}
+	}
+}
+
+// WithInstructions 设置 initialize 返回的 instructions：告诉模型如何使用本服务的工具，宿主通常放进系统提示词
+func WithInstructions(instructions string) Option {
+	return func(s *McpServer) {
+		s.conf.Instructions = instructions
+	}
+}
+
+// WithCapabilities 设置声明的能力，代替 McpConf.Capabilities
+func WithCapabilities(c ServerCapabilities) Option {
+	return func(s *McpServer) {
+		s.conf.Capabilities = &c
+	}
+}
+
+// serverInfo initialize 的 serverInfo
+func (s *McpServer) serverInfo() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    s.conf.ServerName,
+		"version": s.conf.ServerVersion,
+	}
+}
+
+// capabilities 服务能力，initialize、server.info 和 system.version 共用；protocol 为协商的协议版本，
+// 该版本没有的能力不声明
+func (s *McpServer) capabilities(protocol string) map[string]interface{} {
+	declared := AllCapabilities()
+	if s.conf.Capabilities != nil {
+		declared = *s.conf.Capabilities
+	}
+	capabilities := map[string]interface{}{}
+	if declared.Tools && s.anyMethodEnabled("tools.list", "tools.run") {
+		capabilities["tools"] = map[string]interface{}{"listChanged": true}
+	}
+	if declared.Resources && s.anyMethodEnabled("resources.list", "resources.get") {
+		resources := map[string]interface{}{"listChanged": true}
+		if declared.Subscribe && s.IsMethodEnabled("resources/subscribe") {
+			resources["subscribe"] = true
+		}
+		capabilities["resources"] = resources
+	}
+	if declared.Prompts && s.anyMethodEnabled("prompts.list", "prompts.get") {
+		capabilities["prompts"] = map[string]interface{}{"listChanged": true}
+	}
+	if declared.Completions && s.IsMethodEnabled("completion/complete") && version.Has(protocol, version.FeatureCompletions) {
+		capabilities["completions"] = map[string]interface{}{}
+	}
+	if declared.Logging && s.IsMethodEnabled("logging/setLevel") {
+		capabilities["logging"] = map[string]interface{}{}
+	}
+	experimental := map[string]interface{}{}
+	for name, v := range declared.Experimental {
+		experimental[name] = v
+	}
+	transports := s.transports()
+	if !version.Has(protocol, version.FeatureStreamableHTTP) {
+		kept := transports[:0]
+		for _, t := range transports {
+			if t.Name != "streamable-http" {
+				kept = append(kept, t)
+			}
+		}
+		transports = kept
+	}
+	experimental["transports"] = transports
+	if s.suggester != nil {
+		experimental["suggest"] = map[string]interface{}{}
+	}
+	capabilities["experimental"] = experimental
+	return capabilities
+}
+
+// anyMethodEnabled 任一方法启用
+func (s *McpServer) anyMethodEnabled(methods ...string) bool {
+	for _, m := range methods {
+		if s.IsMethodEnabled(m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 会话状态 ----------------------
+// WS、HTTP+SSE、Streamable HTTP、stdio 会话都带有一份 sessionState：
+//   - initialize 时记录客户端信息（clientInfo、协商的协议版本、capabilities）和认证主体；
+//   - resources/subscribe / resources/unsubscribe 维护订阅。完成 initialize 的会话只收到已订阅资源的
+//     notifications/resources/updated，未 initialize 的旧版客户端仍收到全部资源更新；
+//   - 工具通过 mcpctx.SessionStateFromContext 读取以上信息，并可用 SetValue 保存会话级的值。
+// 状态随会话结束（WS 断开超过恢复窗口、SSE 断开、Streamable HTTP 会话过期或 DELETE）丢弃
+
+// sessionState 实现 mcpctx.SessionState，嵌入各传输的会话类型
+type sessionState struct {
+	mu            sync.Mutex
+	initialized   bool
+	client        mcpctx.ClientInfo
+	principal     string
+	subscriptions map[string]bool
+	events        eventFilter // events.subscribe 订阅的主题
+	values        map[string]interface{}
+	roots         []Root // 缓存的客户端 roots，见 ListRoots
+	rootsCached   bool
+	rootsGen      uint64 // 收到 notifications/roots/list_changed 时递增，丢弃变化前发出的请求的结果
+	logLevel      string // logging/setLevel 设置的级别，见 clientlog.go
+}
+
+var _ mcpctx.SessionState = (*sessionState)(nil)
+
+// Initialized 实现 mcpctx.SessionState
+func (st *sessionState) Initialized() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.initialized
+}
+
+// Client 实现 mcpctx.SessionState
+func (st *sessionState) Client() mcpctx.ClientInfo {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.client
+}
+
+// Principal 实现 mcpctx.SessionState
+func (st *sessionState) Principal() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.principal
+}
+
+// Subscriptions 实现 mcpctx.SessionState
+func (st *sessionState) Subscriptions() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	uris := make([]string, 0, len(st.subscriptions))
+	for uri := range st.subscriptions {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// Value 实现 mcpctx.SessionState
+func (st *sessionState) Value(key string) (interface{}, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	v, ok := st.values[key]
+	return v, ok
+}
+
+// SetValue 实现 mcpctx.SessionState
+func (st *sessionState) SetValue(key string, v interface{}) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.values == nil {
+		st.values = make(map[string]interface{})
+	}
+	st.values[key] = v
+}
+
+// initialize 记录 initialize 的结果，重复 initialize 时覆盖
+func (st *sessionState) initialize(client mcpctx.ClientInfo, principal string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.initialized = true
+	st.client = client
+	st.principal = principal
+}
+
+func (st *sessionState) subscribe(uri string, on bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !on {
+		delete(st.subscriptions, uri)
+		return
+	}
+	if st.subscriptions == nil {
+		st.subscriptions = make(map[string]bool)
+	}
+	st.subscriptions[uri] = true
+}
+
+// addEvents 增加订阅的主题，返回生效的过滤规则
+func (st *sessionState) addEvents(f eventFilter) eventFilter {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.events = st.events.with(f)
+	return st.events
+}
+
+// removeEvents 取消订阅的主题，f 为空时取消全部（恢复接收全部通知），返回生效的过滤规则
+func (st *sessionState) removeEvents(f eventFilter) eventFilter {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(f) == 0 {
+		st.events = nil
+	} else {
+		st.events = st.events.without(f)
+	}
+	return st.events
+}
+
+// wants 广播通知是否发给该会话：先按 events.subscribe 的过滤，已 initialize 的会话只接收订阅了的资源更新
+func (st *sessionState) wants(method string, params interface{}) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.events.match(method) {
+		return false
+	}
+	if method != "notifications/resources/updated" {
+		return true
+	}
+	uri, _ := params.(map[string]interface{})["uri"].(string)
+	return !st.initialized || st.subscriptions[uri]
+}
+
+// stateOf 请求所属会话的状态，没有会话时返回 nil
+func stateOf(session mcpctx.Session) *sessionState {
+	if holder, ok := session.(interface{ state() *sessionState }); ok {
+		return holder.state()
+	}
+	return nil
+}
+
+// recordInitialize initialize 时把客户端信息记入会话状态，protocol 为协商后的版本
+func recordInitialize(ctx context.Context, session mcpctx.Session, raw json.RawMessage, protocol string) {
+	st := stateOf(session)
+	if st == nil {
+		return
+	}
+	var params struct {
+		ClientInfo struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"clientInfo"`
+		Capabilities json.RawMessage `json:"capabilities"`
+	}
+	json.Unmarshal(raw, &params)
+	caller, _ := mcpctx.CallerFromContext(ctx)
+	st.initialize(mcpctx.ClientInfo{
+		Name:            params.ClientInfo.Name,
+		Version:         params.ClientInfo.Version,
+		ProtocolVersion: protocol,
+		Capabilities:    params.Capabilities,
+	}, caller.Principal)
+}
+
+// subscribeResource resources/subscribe 与 resources/unsubscribe：订阅需要会话，且资源对调用方可见
+func (s *McpServer) subscribeResource(on bool) methodFunc {
+	return func(ctx context.Context, c *methodCall) (interface{}, *RPCError) {
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(c.params, &params); err != nil || params.URI == "" {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+		st := stateOf(c.session)
+		if st == nil {
+			return nil, &RPCError{Code: -32602, Message: "Subscriptions require a session"}
+		}
+		if on {
+			// 提供者和模板的资源按 URI 订阅，不需要先读取
+			_, provided := matchResourceProvider(params.URI)
+			if _, _, ok := matchResourceTemplate(params.URI); !ok && !provided {
+				if _, err := GetResource(params.URI); err != nil {
+					return nil, s.lookupError(err)
+				}
+			}
+			if len(s.visibleNames(ctx, c.access, "resources.get", []string{resourceName(params.URI)})) == 0 {
+				return nil, &RPCError{Code: -32003, Message: "Forbidden"}
+			}
+		}
+		st.subscribe(params.URI, on)
+		return map[string]interface{}{}, nil
+	}
+}
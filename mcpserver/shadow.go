@@ -0,0 +1,212 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 影子执行 ----------------------
+// 工具的每次调用都异步再执行一遍新实现（如把假的 geocoder 换成真实 provider），
+// 比较两边结果并记录差异，影子结果永远不会返回给调用方，用于切换前积累信心
+
+// Shadow 工具的影子实现
+type Shadow struct {
+	Handler func(ctx context.Context, args json.RawMessage) (interface{}, error)
+	// Compare 可选，判断两边结果是否一致；默认比较两者的 JSON 是否等价
+	Compare func(primary, shadow interface{}) bool
+	// Timeout 影子调用的超时，默认 30s
+	Timeout time.Duration
+}
+
+// ShadowStatus 影子执行的统计
+type ShadowStatus struct {
+	Tool       string `json:"tool"`
+	Calls      int64  `json:"calls"`
+	Matches    int64  `json:"matches"`
+	Mismatches int64  `json:"mismatches"`
+	Errors     int64  `json:"errors"`  // 影子实现返回错误的次数
+	Skipped    int64  `json:"skipped"` // 并发影子调用过多时跳过
+}
+
+type shadowState struct {
+	conf   Shadow
+	status ShadowStatus
+}
+
+// shadowSlots 限制同时进行的影子调用，避免拖慢主流程
+var shadowSlots = make(chan struct{}, 16)
+
+// SetShadow 为已注册的工具设置影子实现，重复设置会清空统计
+func (r *ToolRegistry) SetShadow(tool string, sh Shadow) error {
+	if _, ok := r.Get(tool); !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, tool)
+	}
+	if sh.Handler == nil {
+		return fmt.Errorf("shadow for %s: handler is required", tool)
+	}
+	if sh.Timeout <= 0 {
+		sh.Timeout = 30 * time.Second
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shadows[tool] = &shadowState{conf: sh, status: ShadowStatus{Tool: tool}}
+	return nil
+}
+
+// SetShadowTool 用另一个已注册的工具（如 geocode-next）作为影子实现。
+// 影子工具通常再用 SetToolEnabled 禁用，不对外暴露；影子调用不受开关影响
+func (r *ToolRegistry) SetShadowTool(tool, shadowTool string) error {
+	t, ok := r.Get(shadowTool)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, shadowTool)
+	}
+	return r.SetShadow(tool, Shadow{Handler: t.Handler})
+}
+
+// RemoveShadow 停止影子执行
+func (r *ToolRegistry) RemoveShadow(tool string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.shadows, tool)
+}
+
+// ShadowStatuses 返回所有影子执行的统计，按工具名排序
+func (r *ToolRegistry) ShadowStatuses() []ShadowStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]ShadowStatus, 0, len(r.shadows))
+	for _, st := range r.shadows {
+		list = append(list, st.status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Tool < list[j].Tool })
+	return list
+}
+
+// startShadow 主调用完成后异步执行影子实现并比较结果
+func (r *ToolRegistry) startShadow(ctx context.Context, tool string, args json.RawMessage, result interface{}, callErr error) {
+	r.mu.RLock()
+	st, ok := r.shadows[tool]
+	var conf Shadow
+	if ok {
+		conf = st.conf
+	}
+	r.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case shadowSlots <- struct{}{}:
+	default:
+		r.updateShadowStatus(tool, func(s *ShadowStatus) { s.Skipped++ })
+		return
+	}
+
+	// 保留调用方、会话等信息，但不随请求取消，也不向客户端上报进度
+	sctx := mcpctx.WithProgress(detachedContext{ctx}, nil)
+	go func() {
+		defer func() { <-shadowSlots }()
+		sctx, cancel := context.WithTimeout(sctx, conf.Timeout)
+		defer cancel()
+
+		shadowResult, shadowErr := conf.Handler(sctx, args)
+		match := sameResult(conf.Compare, result, callErr, shadowResult, shadowErr)
+		r.updateShadowStatus(tool, func(s *ShadowStatus) {
+			s.Calls++
+			if shadowErr != nil {
+				s.Errors++
+			}
+			if match {
+				s.Matches++
+			} else {
+				s.Mismatches++
+			}
+		})
+		if !match {
+			logf(LevelWarn, "shadow mismatch for tool %s: args=%s primary=%s shadow=%s",
+				tool, truncate(string(args), 512), describeResult(result, callErr), describeResult(shadowResult, shadowErr))
+		}
+	}()
+}
+
+func (r *ToolRegistry) updateShadowStatus(tool string, fn func(*ShadowStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if st, ok := r.shadows[tool]; ok {
+		fn(&st.status)
+	}
+}
+
+// SetShadow 见 ToolRegistry.SetShadow
+func SetShadow(tool string, sh Shadow) error {
+	return DefaultToolRegistry.SetShadow(tool, sh)
+}
+
+// SetShadowTool 见 ToolRegistry.SetShadowTool
+func SetShadowTool(tool, shadowTool string) error {
+	return DefaultToolRegistry.SetShadowTool(tool, shadowTool)
+}
+
+// RemoveShadow 见 ToolRegistry.RemoveShadow
+func RemoveShadow(tool string) {
+	DefaultToolRegistry.RemoveShadow(tool)
+}
+
+// ShadowStatuses 见 ToolRegistry.ShadowStatuses
+func ShadowStatuses() []ShadowStatus {
+	return DefaultToolRegistry.ShadowStatuses()
+}
+
+// sameResult 两边都出错视为一致；都成功时用 Compare 或 JSON 等价比较
+func sameResult(compare func(a, b interface{}) bool, a interface{}, aErr error, b interface{}, bErr error) bool {
+	if aErr != nil || bErr != nil {
+		return aErr != nil && bErr != nil
+	}
+	if compare != nil {
+		return compare(a, b)
+	}
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+// normalizeJSON 转成 encoding/json 的通用表示，消除结构体与 map 等类型差异
+func normalizeJSON(v interface{}) interface{} {
+	if ann, ok := v.(*AnnotatedResult); ok && ann != nil {
+		v = ann.Value
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func describeResult(v interface{}, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	data, _ := json.Marshal(normalizeJSON(v))
+	return truncate(string(data), 512)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// detachedContext 保留父 context 的值，但不继承取消和截止时间
+type detachedContext struct{ parent context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
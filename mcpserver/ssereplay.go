@@ -0,0 +1,104 @@
+package mcpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------- SSE 断线重放 ----------------------
+// 每个 SSE 事件的 id 是全局事件序号（见 EventStamp.ID），连接建立时先发 retry: 提示浏览器的重连间隔。
+// 最近广播的事件保存在环形缓冲中，客户端（EventSource 会自动带上）以 Last-Event-ID 重连时，
+// 先补发该 id 之后的事件再接收新事件。要补发的事件已被覆盖时，先发一个 gap 事件：
+//
+//	event: gap
+//	data: {"lastEventId":"<客户端的 id>","firstAvailable":"<缓冲中最早的 id>"}
+//
+// 服务重启后纪元变化，旧纪元的 id 按全部丢失处理。只有广播事件进入缓冲，心跳注释不进入
+
+const (
+	defaultSSERetry      = 3 * time.Second
+	defaultSSEReplaySize = 256
+)
+
+// sseEvent 缓冲中的一个已格式化事件
+type sseEvent struct {
+	stamp EventStamp
+	event string
+	msg   []byte
+}
+
+// sseRing 最近事件的环形缓冲，每个服务一个，由 McpServer.sseMu 保护：入缓冲与向连接分发在同一把锁下，
+// 新连接注册时取到的补发事件与之后收到的实时事件既不重复也不遗漏
+type sseRing struct {
+	events []sseEvent
+	next   int // 下一个写入位置
+	full   bool
+}
+
+// newSSERing 容量为 n 的缓冲（McpConf.SSEReplaySize），n < 0 表示不保留
+func newSSERing(n int) *sseRing {
+	if n < 0 {
+		n = 0
+	}
+	return &sseRing{events: make([]sseEvent, n)}
+}
+
+func (r *sseRing) add(stamp EventStamp, event string, msg []byte) {
+	if len(r.events) == 0 {
+		return
+	}
+	r.events[r.next] = sseEvent{stamp: stamp, event: event, msg: msg}
+	r.next++
+	if r.next == len(r.events) {
+		r.next, r.full = 0, true
+	}
+}
+
+// ordered 缓冲中的事件，最早的在前
+func (r *sseRing) ordered() []sseEvent {
+	if !r.full {
+		return append([]sseEvent(nil), r.events[:r.next]...)
+	}
+	out := make([]sseEvent, 0, len(r.events))
+	out = append(out, r.events[r.next:]...)
+	return append(out, r.events[:r.next]...)
+}
+
+// since lastID 之后的事件；gap 为 true 表示中间有事件已被覆盖或来自旧纪元
+func (r *sseRing) since(lastID string) (replay []sseEvent, gap bool) {
+	all := r.ordered()
+	epoch, seq, ok := parseEventID(lastID)
+	if !ok || epoch != events.epoch {
+		return all, true
+	}
+	for i, e := range all {
+		if e.stamp.Seq > seq {
+			// 缓冲中最早的事件也在 lastID 之后，且不紧接着它，说明中间的已被覆盖
+			return all[i:], i == 0 && e.stamp.Seq > seq+1
+		}
+	}
+	return nil, false
+}
+
+// parseEventID 解析 "<epoch>-<seq>"
+func parseEventID(id string) (epoch string, seq uint64, ok bool) {
+	i := strings.LastIndexByte(id, '-')
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+	return id[:i], seq, err == nil
+}
+
+// sseGapEvent 补发不完整时发给客户端的 gap 事件，没有 id，不改变客户端记录的 Last-Event-ID
+func sseGapEvent(lastID string, replay []sseEvent) []byte {
+	data := map[string]string{"lastEventId": lastID}
+	if len(replay) > 0 {
+		data["firstAvailable"] = replay[0].stamp.ID()
+	}
+	payload, _ := json.Marshal(data)
+	return []byte(fmt.Sprintf("event: gap\ndata: %s\n\n", payload))
+}
@@ -0,0 +1,324 @@
+//go:build !nostarlark
+
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- Starlark 引擎 ----------------------
+// 内置的 Starlark 脚本引擎（go.starlark.net），用 -tags nostarlark 编译时去掉。需要显式注册：
+//
+//	mcpserver.RegisterScriptEngine("starlark", mcpserver.StarlarkEngine{})
+//
+// 脚本定义 main(args)，args 为工具参数（整数值的数字转换为 int），返回值需为 None / bool / 数字 / 字符串 /
+// list / tuple / 键为字符串的 dict。除内置函数外只预置 json 和 math 模块，不支持 load，print 的内容作为
+// info 日志发给客户端。ScriptLimits 的执行方式：
+//   - MaxSteps：Starlark 的 execution steps，超出时中止；
+//   - MaxMemory：每执行 starlarkMemoryCheckSteps 步，估算全局变量和调用栈上各函数局部变量引用的值的大小，
+//     超出时中止，返回值也按同样的方式检查。估算不含表达式的中间值（单次分配由解释器限制在 1GB 以内），
+//     被多处引用的字符串和 tuple 重复计算，所以偏保守；
+//   - Timeout：ctx 结束时取消解释器。
+
+// starlarkMemoryCheckSteps 两次内存估算之间的执行步数
+const starlarkMemoryCheckSteps = 10000
+
+// starlarkPredeclared 脚本可以使用的模块，都只做纯计算
+var starlarkPredeclared = starlark.StringDict{
+	"json": json.Module,
+	"math": starlarkmath.Module,
+}
+
+// starlarkFileOptions 在默认选项上允许 set()；不允许 while、递归和顶层的 if / for
+var starlarkFileOptions = &syntax.FileOptions{Set: true}
+
+// StarlarkEngine go.starlark.net 实现的脚本引擎
+type StarlarkEngine struct{}
+
+// Compile 编译脚本，没有定义 main 函数时报错
+func (StarlarkEngine) Compile(name, source string) (Script, error) {
+	f, prog, err := starlark.SourceProgramOptions(starlarkFileOptions, name+".star", source, starlarkPredeclared.Has)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range f.Stmts {
+		if def, ok := stmt.(*syntax.DefStmt); ok && def.Name.Name == "main" {
+			return &starlarkScript{name: name, prog: prog}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: script does not define main(args)", name)
+}
+
+type starlarkScript struct {
+	name string
+	prog *starlark.Program
+}
+
+// Run 每次执行重新初始化全局变量，同一个脚本的并发执行互不影响
+func (s *starlarkScript) Run(ctx context.Context, args map[string]interface{}, limits ScriptLimits) (interface{}, error) {
+	input, err := toStarlark(args)
+	if err != nil {
+		return nil, err
+	}
+	clientLog := mcpctx.ClientLoggerFromContext(ctx)
+	thread := &starlark.Thread{
+		Name: s.name,
+		Print: func(_ *starlark.Thread, msg string) {
+			clientLog.Log("info", "script:"+s.name, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(nextStarlarkCheck(0, limits.MaxSteps))
+	thread.OnMaxSteps = func(thread *starlark.Thread) {
+		if thread.Steps >= limits.MaxSteps {
+			thread.Cancel(fmt.Sprintf("too many steps (max %d)", limits.MaxSteps))
+			return
+		}
+		if starlarkStackSize(thread, limits.MaxMemory) > limits.MaxMemory {
+			thread.Cancel(fmt.Sprintf("memory limit exceeded (max %d bytes)", limits.MaxMemory))
+			return
+		}
+		thread.SetMaxExecutionSteps(nextStarlarkCheck(thread.Steps, limits.MaxSteps))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	globals, err := s.prog.Init(thread, starlarkPredeclared)
+	if err != nil {
+		return nil, err
+	}
+	main, ok := globals["main"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("main is not a function")
+	}
+	result, err := starlark.Call(thread, main, starlark.Tuple{input}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if starlarkSize(result, limits.MaxMemory, map[starlark.Value]bool{}) > limits.MaxMemory {
+		return nil, fmt.Errorf("result exceeds the memory limit (max %d bytes)", limits.MaxMemory)
+	}
+	return fromStarlark(result)
+}
+
+// nextStarlarkCheck 下一次检查的步数：下一个估算点和步数上限中较小的
+func nextStarlarkCheck(steps, max uint64) uint64 {
+	if next := steps + starlarkMemoryCheckSteps; next < max {
+		return next
+	}
+	return max
+}
+
+// starlarkStackSize 估算全局变量和调用栈上局部变量引用的值的大小，超过 limit 后不再继续统计
+func starlarkStackSize(thread *starlark.Thread, limit int64) int64 {
+	seen := map[starlark.Value]bool{}
+	var total int64
+	globalsCounted := false
+	for depth := 0; depth < thread.CallStackDepth() && total <= limit; depth++ {
+		frame := thread.DebugFrame(depth)
+		fn, ok := frame.Callable().(*starlark.Function)
+		if !ok {
+			continue
+		}
+		if !globalsCounted {
+			globalsCounted = true
+			for _, v := range fn.Globals() {
+				total += starlarkSize(v, limit-total, seen)
+			}
+		}
+		for i := 0; i < frame.NumLocals() && total <= limit; i++ {
+			if _, v := frame.Local(i); v != nil {
+				total += starlarkSize(v, limit-total, seen)
+			}
+		}
+	}
+	return total
+}
+
+// starlarkSize 估算 v 及其元素占用的字节数；list / dict / set 按引用去重，超过 limit 后提前返回
+func starlarkSize(v starlark.Value, limit int64, seen map[starlark.Value]bool) int64 {
+	const header = 16
+	switch v := v.(type) {
+	case starlark.String:
+		return header + int64(len(v))
+	case starlark.Bytes:
+		return header + int64(len(v))
+	case starlark.Int:
+		if _, ok := v.Int64(); ok {
+			return header
+		}
+		return header + int64(v.BigInt().BitLen()/8)
+	case starlark.Tuple:
+		total := header * int64(1+len(v))
+		for _, e := range v {
+			if total > limit {
+				break
+			}
+			total += starlarkSize(e, limit-total, seen)
+		}
+		return total
+	case *starlark.List:
+		if seen[v] {
+			return 0
+		}
+		seen[v] = true
+		total := header * int64(1+v.Len())
+		for i := 0; i < v.Len() && total <= limit; i++ {
+			total += starlarkSize(v.Index(i), limit-total, seen)
+		}
+		return total
+	case *starlark.Dict:
+		if seen[v] {
+			return 0
+		}
+		seen[v] = true
+		// 每一项按键、值和哈希表中的槽位计算
+		total := header * int64(1+3*v.Len())
+		for _, item := range v.Items() {
+			if total > limit {
+				break
+			}
+			total += starlarkSize(item[0], limit-total, seen) + starlarkSize(item[1], limit-total, seen)
+		}
+		return total
+	case *starlark.Set:
+		if seen[v] {
+			return 0
+		}
+		seen[v] = true
+		total := header * int64(1+2*v.Len())
+		iter := v.Iterate()
+		defer iter.Done()
+		var x starlark.Value
+		for total <= limit && iter.Next(&x) {
+			total += starlarkSize(x, limit-total, seen)
+		}
+		return total
+	}
+	return header
+}
+
+// toStarlark JSON 解码得到的 Go 值转换为 Starlark 值，整数值的数字转换为 int
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v)), nil
+		}
+		return starlark.Float(v), nil
+	case string:
+		return starlark.String(v), nil
+	case []interface{}:
+		elems := make([]starlark.Value, len(v))
+		for i, e := range v {
+			x, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = x
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		d := starlark.NewDict(len(v))
+		for k, e := range v {
+			x, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.SetKey(starlark.String(k), x); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("unsupported argument type %T", v)
+}
+
+// fromStarlark 脚本的返回值转换为可以 JSON 编码的 Go 值
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		return v.BigInt(), nil
+	case starlark.Float:
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("result contains %v, which is not valid JSON", f)
+		}
+		return f, nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Bytes:
+		return string(v), nil
+	case starlark.Tuple:
+		return fromStarlarkElems(v)
+	case *starlark.List:
+		elems := make([]starlark.Value, v.Len())
+		for i := range elems {
+			elems[i] = v.Index(i)
+		}
+		return fromStarlarkElems(elems)
+	case *starlark.Dict:
+		out := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("result dict key %s is not a string", item[0])
+			}
+			x, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			out[string(key)] = x
+		}
+		return out, nil
+	case *starlark.Set:
+		var elems []starlark.Value
+		iter := v.Iterate()
+		defer iter.Done()
+		var x starlark.Value
+		for iter.Next(&x) {
+			elems = append(elems, x)
+		}
+		return fromStarlarkElems(elems)
+	}
+	return nil, fmt.Errorf("unsupported result type %s", v.Type())
+}
+
+func fromStarlarkElems(elems []starlark.Value) ([]interface{}, error) {
+	out := make([]interface{}, len(elems))
+	for i, e := range elems {
+		x, err := fromStarlark(e)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = x
+	}
+	return out, nil
+}
@@ -0,0 +1,19 @@
+//go:build nostarlark
+
+package mcpserver
+
+import "errors"
+
+// ---------------------- Starlark 引擎占位 ----------------------
+// 用 -tags nostarlark 编译时没有内置的 Starlark 引擎：注册后 manifest 中 lang: starlark 的脚本在加载时报错，
+// 嵌入方仍可以用 RegisterScriptEngine 注册自己的引擎
+
+var errStarlarkUnavailable = errors.New("mcpserver: built without starlark support (nostarlark)")
+
+// StarlarkEngine 见 starlark.go，此构建中不可用
+type StarlarkEngine struct{}
+
+// Compile 见 starlark.go
+func (StarlarkEngine) Compile(name, source string) (Script, error) {
+	return nil, errStarlarkUnavailable
+}
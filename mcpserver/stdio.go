@@ -0,0 +1,172 @@
+package mcpserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"mcptool/mcpctx"
+	"mcptool/version"
+)
+
+// ---------------------- stdio 传输 ----------------------
+// 作为子进程运行时（如被 mcpclient.StdioClient 或桌面客户端启动），按行收发 JSON-RPC 消息。
+// 请求并发处理，响应按完成顺序写回；进度等通知以及服务端发往客户端的请求写到同一输出
+
+// 单行消息的上限
+const maxStdioLine = 16 << 20
+
+var stdioSessionSeq uint64
+
+// stdioSession 一个 stdio 连接，串行化对输出的写入
+type stdioSession struct {
+	id    string
+	mu    sync.Mutex
+	out   io.Writer
+	st    sessionState
+	calls clientCalls // 服务端发往客户端、等待响应的请求
+	wire  *wireDump
+}
+
+// ID 实现 mcpctx.Session
+func (sess *stdioSession) ID() string {
+	return sess.id
+}
+
+// State 会话状态，见 mcpctx.SessionStateFromContext
+func (sess *stdioSession) State() mcpctx.SessionState {
+	return &sess.st
+}
+
+func (sess *stdioSession) state() *sessionState {
+	return &sess.st
+}
+
+func (sess *stdioSession) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sess.wire.frame("stdio", sess.id, "send", data)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	_, err = sess.out.Write(append(data, '\n'))
+	return err
+}
+
+func (sess *stdioSession) notify(n *RPCNotification) {
+	if err := sess.write(n); err != nil {
+		logf(LevelWarn, "stdio write error: %v", err)
+	}
+}
+
+// sendStdio 向本服务的所有 stdio 会话推送广播通知
+func (s *McpServer) sendStdio(stamp EventStamp, method string, params interface{}) {
+	s.stdioMu.Lock()
+	sessions := make([]*stdioSession, 0, len(s.stdioSessions))
+	for sess := range s.stdioSessions {
+		sessions = append(sessions, sess)
+	}
+	s.stdioMu.Unlock()
+	if len(sessions) == 0 {
+		return
+	}
+	n := newRPCNotification(stamp, method, params)
+	for _, sess := range sessions {
+		if sess.st.wants(method, params) {
+			sess.notify(n)
+		}
+	}
+}
+
+// ServeStdio 从 in 逐行读取请求并把响应写到 out，直到 in 结束或 ctx 取消；
+// 返回前等待进行中的请求完成。日志不要写到 out
+func (s *McpServer) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	sess := &stdioSession{
+		id:   fmt.Sprintf("stdio-%d", atomic.AddUint64(&stdioSessionSeq, 1)),
+		out:  out,
+		wire: &s.wire,
+	}
+	defer s.forgetSession(sess.id)
+	atomic.AddInt32(&s.stdioServing, 1)
+	defer atomic.AddInt32(&s.stdioServing, -1)
+	s.stdioMu.Lock()
+	s.stdioSessions[sess] = struct{}{}
+	s.stdioMu.Unlock()
+	defer func() {
+		s.stdioMu.Lock()
+		delete(s.stdioSessions, sess)
+		s.stdioMu.Unlock()
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	caller := mcpctx.Caller{Transport: "stdio"}
+	ctx = mcpctx.WithCaller(ctx, caller)
+	ctx = mcpctx.WithFlags(ctx, callerFlags{server: s, caller: caller})
+	if s.logger != nil {
+		ctx = mcpctx.WithLogger(ctx, s.logger)
+	}
+	ctx = mcpctx.WithSession(ctx, sess)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 64*1024), maxStdioLine)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	unresponsive := make(chan error, 1)
+	if s.conf.ClientPingInterval > 0 {
+		go s.keepAlive(ctx, sess, func(err error) { unresponsive <- err })
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case err := <-unresponsive:
+			return err
+		case line := <-lines:
+			if len(line) == 0 {
+				continue
+			}
+			s.wire.frame("stdio", sess.id, "recv", line)
+			var req RPCRequest
+			if err := json.Unmarshal(line, &req); err != nil {
+				sess.write(RPCResponse{JsonRPC: version.JSONRPC, Error: s.sanitizeError(-32700, "Parse error", err)})
+				continue
+			}
+			if isClientResponse(&req) {
+				// 客户端对 sampling/createMessage 等服务端请求的响应
+				sess.calls.resolve(line)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp := s.Dispatch(ctx, req); resp != nil {
+					if err := sess.write(resp); err != nil {
+						logf(LevelWarn, "stdio write error: %v", err)
+					}
+				}
+			}()
+		}
+	}
+}
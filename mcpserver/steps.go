@@ -0,0 +1,125 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- 工具子步骤 ----------------------
+// 工具通过 mcpctx.StepsFromContext(ctx).Step(name) 记录内部的子步骤，记录的步骤：
+//   - 请求带 progressToken 时，每个步骤开始和结束各发一条进度通知，progress / total 沿用工具
+//     最近一次上报的值，message 为 "fetch started"、"fetch done in 12ms"、"fetch failed: ..."；
+//   - 开启 response_meta 时出现在响应 meta 的 steps 中；
+//   - 以 debug 级别写入日志；
+//   - 交给 WithStepTracer 设置的 StepTracer，用于接入 OpenTelemetry 等追踪系统。
+
+// StepRecord 一个已结束的子步骤
+type StepRecord struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// StepTracer 把子步骤转发到追踪系统：StartStep 在步骤开始时调用，返回的函数在步骤结束时调用。
+// ctx 为工具调用的 context，tool 为工具名。接入 OpenTelemetry 时可以这样实现：
+//
+//	func (t otelSteps) StartStep(ctx context.Context, tool, name string) func(err error) {
+//		_, span := t.tracer.Start(ctx, tool+"/"+name)
+//		return func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			span.End()
+//		}
+//	}
+type StepTracer interface {
+	StartStep(ctx context.Context, tool, name string) func(err error)
+}
+
+// WithStepTracer 设置子步骤的追踪
+func WithStepTracer(t StepTracer) Option {
+	return func(s *McpServer) {
+		s.stepTracer = t
+	}
+}
+
+// stepRecorder 一次工具调用的子步骤记录，实现 mcpctx.StepRecorder
+type stepRecorder struct {
+	ctx    context.Context
+	tool   string
+	tracer StepTracer
+
+	mu       sync.Mutex
+	steps    []StepRecord
+	progress mcpctx.ProgressReporter
+	last     float64 // 工具最近一次上报的进度
+	total    float64
+}
+
+// withSteps 在 ctx 中放入子步骤记录器，并接管进度上报以便步骤通知沿用工具的进度值；
+// 需要在 withProgress 之后调用
+func (s *McpServer) withSteps(ctx context.Context, tool string) (context.Context, *stepRecorder) {
+	rec := &stepRecorder{tool: tool, tracer: s.stepTracer, progress: mcpctx.ProgressFromContext(ctx)}
+	ctx = mcpctx.WithProgress(ctx, func(progress, total float64, message string) {
+		rec.mu.Lock()
+		rec.last, rec.total = progress, total
+		rec.mu.Unlock()
+		rec.progress(progress, total, message)
+	})
+	ctx = mcpctx.WithSteps(ctx, rec)
+	rec.ctx = ctx
+	return ctx, rec
+}
+
+// Step 实现 mcpctx.StepRecorder
+func (r *stepRecorder) Step(name string) func(err error) {
+	start := time.Now()
+	var traced func(error)
+	if r.tracer != nil {
+		traced = r.tracer.StartStep(r.ctx, r.tool, name)
+	}
+	r.report(name + " started")
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			rec := StepRecord{Name: name, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+			msg := fmt.Sprintf("%s done in %s", name, time.Since(start).Round(time.Millisecond))
+			if err != nil {
+				rec.Error = err.Error()
+				msg = fmt.Sprintf("%s failed: %v", name, err)
+			}
+			r.mu.Lock()
+			r.steps = append(r.steps, rec)
+			r.mu.Unlock()
+			logf(LevelDebug, "step: tool=%s id=%s %s", r.tool, mcpctx.RequestIDFromContext(r.ctx), msg)
+			r.report(msg)
+			if traced != nil {
+				traced(err)
+			}
+		})
+	}
+}
+
+// report 以工具最近的进度值发送步骤通知，请求没有 progressToken 时是空操作
+func (r *stepRecorder) report(message string) {
+	r.mu.Lock()
+	progress, total := r.last, r.total
+	r.mu.Unlock()
+	r.progress(progress, total, message)
+}
+
+// records 已结束的步骤，按结束顺序；没有步骤时返回 nil
+func (r *stepRecorder) records() []StepRecord {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]StepRecord(nil), r.steps...)
+}
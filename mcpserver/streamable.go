@@ -0,0 +1,301 @@
+package mcpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mcptool/mcpctx"
+)
+
+// ---------------------- Streamable HTTP ----------------------
+// MCP 2025-03-26 的 Streamable HTTP 传输，与原有的 HTTP 端点共用 HTTPPath：
+//   - POST initialize 时创建会话，响应头 Mcp-Session-Id 带会话 ID，之后的请求都要带上它；
+//     未知或已结束的会话返回 404，客户端应重新 initialize。不带该头的请求按原来的无会话 HTTP 处理；
+//   - POST tools/call 且 Accept 包含 text/event-stream 时，响应是只属于该请求的 SSE 流：
+//     先发调用中产生的进度、行流等通知，最后发响应，然后结束。其他请求仍返回 JSON；
+//   - GET（Accept: text/event-stream，带 Mcp-Session-Id）打开会话的通知流，接收广播通知和
+//     不属于某个请求流的会话通知；同一会话再次 GET 时替换之前的流；
+//   - DELETE 结束会话。
+// 会话空闲超过 SessionTTL（且没有打开的通知流）时由后台清理
+
+// streamWaitNotifications 请求流写响应前等待通知队列发出该请求通知的最长时间
+const streamWaitNotifications = time.Second
+
+// streamableSession 一个 Streamable HTTP 会话
+type streamableSession struct {
+	id        string
+	principal string // 开启认证时创建会话的主体，只有同一主体可以使用会话
+	lastSeen  int64  // UnixNano，原子访问
+
+	mu     sync.Mutex
+	stream *SSEClient // GET 打开的通知流，nil 表示没有
+
+	st sessionState
+}
+
+// ID 实现 mcpctx.Session
+func (sess *streamableSession) ID() string {
+	return sess.id
+}
+
+// State 会话状态，见 mcpctx.SessionStateFromContext
+func (sess *streamableSession) State() mcpctx.SessionState {
+	return &sess.st
+}
+
+func (sess *streamableSession) state() *sessionState {
+	return &sess.st
+}
+
+// notify 写到会话的通知流，没有打开通知流时丢弃
+func (sess *streamableSession) notify(n *RPCNotification) {
+	sess.mu.Lock()
+	stream := sess.stream
+	sess.mu.Unlock()
+	if stream == nil {
+		return
+	}
+	data, _ := json.Marshal(n)
+	stream.enqueue(sseMessage(data))
+}
+
+func (sess *streamableSession) touch() {
+	atomic.StoreInt64(&sess.lastSeen, time.Now().UnixNano())
+}
+
+// sseMessage 格式化为 message 事件
+func sseMessage(data []byte) []byte {
+	return []byte(fmt.Sprintf("event: message\ndata: %s\n\n", data))
+}
+
+// sendStreamable 向本服务所有打开了通知流的 Streamable HTTP 会话推送通知
+func (s *McpServer) sendStreamable(stamp EventStamp, method string, params interface{}) {
+	s.streamableMu.Lock()
+	sessions := make([]*streamableSession, 0, len(s.streamableSessions))
+	for _, sess := range s.streamableSessions {
+		sessions = append(sessions, sess)
+	}
+	s.streamableMu.Unlock()
+	if len(sessions) == 0 {
+		return
+	}
+	n := newRPCNotification(stamp, method, params)
+	for _, sess := range sessions {
+		if sess.st.wants(method, params) {
+			sess.notify(n)
+		}
+	}
+}
+
+// newStreamableSession initialize 时创建会话
+func (s *McpServer) newStreamableSession(r *http.Request) *streamableSession {
+	sess := &streamableSession{id: newResumeToken()}
+	sess.principal, _ = principalFromRequest(r)
+	sess.touch()
+	s.streamableMu.Lock()
+	s.streamableSessions[sess.id] = sess
+	s.streamableMu.Unlock()
+	return sess
+}
+
+// lookupStreamableSession 按 Mcp-Session-Id 查找会话，不存在或主体不同时返回 false
+func (s *McpServer) lookupStreamableSession(r *http.Request, id string) (*streamableSession, bool) {
+	s.streamableMu.Lock()
+	sess, ok := s.streamableSessions[id]
+	s.streamableMu.Unlock()
+	if principal, _ := principalFromRequest(r); !ok || principal != sess.principal {
+		return nil, false
+	}
+	sess.touch()
+	return sess, true
+}
+
+// endStreamableSession 结束会话：关闭通知流，清除连接级规则和调用预算
+func (s *McpServer) endStreamableSession(sess *streamableSession) {
+	s.streamableMu.Lock()
+	delete(s.streamableSessions, sess.id)
+	s.streamableMu.Unlock()
+	sess.mu.Lock()
+	if sess.stream != nil {
+		sess.stream.evictOnce.Do(func() { close(sess.stream.evicted) })
+	}
+	sess.mu.Unlock()
+	s.forgetSession(sess.id)
+}
+
+// expireStreamableSessions 清理空闲的会话，打开着通知流的会话不清理
+func (s *McpServer) expireStreamableSessions(now time.Time) int {
+	cutoff := now.Add(-s.conf.SessionTTL).UnixNano()
+	s.streamableMu.Lock()
+	var idle []*streamableSession
+	for _, sess := range s.streamableSessions {
+		sess.mu.Lock()
+		open := sess.stream != nil
+		sess.mu.Unlock()
+		if !open && atomic.LoadInt64(&sess.lastSeen) < cutoff {
+			idle = append(idle, sess)
+		}
+	}
+	s.streamableMu.Unlock()
+	for _, sess := range idle {
+		s.endStreamableSession(sess)
+	}
+	return len(idle)
+}
+
+// acceptsEventStream 请求的 Accept 头是否包含 text/event-stream
+func acceptsEventStream(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mt == "text/event-stream" {
+			return true
+		}
+	}
+	return false
+}
+
+// streamableGet GET HTTPPath：打开会话的通知流
+func (s *McpServer) streamableGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		w.Header().Set("Allow", "POST, GET, DELETE")
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.Header.Get("Mcp-Session-Id")
+	if id == "" {
+		http.Error(w, "Mcp-Session-Id required", http.StatusBadRequest)
+		return
+	}
+	sess, ok := s.lookupStreamableSession(r, id)
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Mcp-Session-Id", sess.id)
+	client, ok := s.startSSE(w, r)
+	if !ok {
+		return
+	}
+	sess.mu.Lock()
+	prev := sess.stream
+	sess.stream = client
+	sess.mu.Unlock()
+	if prev != nil {
+		prev.evictOnce.Do(func() { close(prev.evicted) })
+	}
+	defer func() {
+		sess.mu.Lock()
+		if sess.stream == client {
+			sess.stream = nil
+		}
+		sess.mu.Unlock()
+		sess.touch()
+	}()
+	s.pumpSSE(r, client)
+}
+
+// streamableDelete DELETE HTTPPath：结束会话
+func (s *McpServer) streamableDelete(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.lookupStreamableSession(r, r.Header.Get("Mcp-Session-Id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	s.endStreamableSession(sess)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------------------- 请求流 ----------------------
+
+// requestStream 一个 POST 请求的 SSE 响应流，调用中发给会话的通知写到这里
+type requestStream struct {
+	session  *streamableSession // 所属的 Streamable HTTP 会话，没有时为 nil
+	remote   string
+	mu       sync.Mutex
+	writer   http.ResponseWriter
+	flusher  http.Flusher
+	throttle *connThrottle
+	closed   bool
+	wire     *wireDump
+}
+
+// ID 实现 mcpctx.Session：与所属会话相同，成本、预算和连接级规则按会话归集
+func (st *requestStream) ID() string {
+	if st.session == nil {
+		return ""
+	}
+	return st.session.id
+}
+
+// State 所属会话的状态，没有会话时为 nil
+func (st *requestStream) State() mcpctx.SessionState {
+	if st.session == nil {
+		return nil
+	}
+	return &st.session.st
+}
+
+func (st *requestStream) state() *sessionState {
+	if st.session == nil {
+		return nil
+	}
+	return &st.session.st
+}
+
+func (st *requestStream) notify(n *RPCNotification) {
+	data, _ := json.Marshal(n)
+	st.send(data)
+}
+
+// send 写一个 message 事件，响应写出后到达的通知被丢弃
+func (st *requestStream) send(data []byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closed {
+		return
+	}
+	st.wire.frame("http", st.remote, "send", data)
+	st.throttle.writer(st.writer).Write(sseMessage(data))
+	st.flusher.Flush()
+}
+
+// streamRequest 以 SSE 流响应一个 POST 请求，ResponseWriter 不支持流式输出时返回 false，由调用方按 JSON 响应
+func (s *McpServer) streamRequest(w http.ResponseWriter, r *http.Request, req RPCRequest, session *streamableSession, costKey CostKey) bool {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return false
+	}
+	st := &requestStream{
+		session:  session,
+		remote:   r.RemoteAddr,
+		writer:   w,
+		flusher:  flusher,
+		throttle: s.newThrottle(),
+		wire:     &s.wire,
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	resp := s.dispatch(s.requestContext(r, "http", st), req, costKey)
+	// 进度等通知经通知队列异步发送，等它们写完再写响应，客户端收到响应后不会再有该请求的通知
+	s.notifier.barrier(streamWaitNotifications)
+	st.finish(resp)
+	return true
+}
+
+// finish 写出响应并结束流
+func (st *requestStream) finish(resp *RPCResponse) {
+	data, _ := json.Marshal(resp)
+	st.send(data)
+	st.mu.Lock()
+	st.closed = true
+	st.mu.Unlock()
+}
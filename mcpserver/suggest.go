@@ -0,0 +1,190 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ---------------------- 工具推荐 ----------------------
+// 开启后（WithToolSuggestions），initialize 在 capabilities.experimental.suggest 中声明该能力，
+// 每次工具调用成功后向所属会话（WS、HTTP+SSE、Streamable HTTP）推送 notifications/tools/suggested：
+//
+//	{"after": "geocode", "suggestions": [{"name": "distance_matrix", "score": 0.8, "reason": "..."}]}
+//
+// 宿主可以据此在工具很多时优先展示相关工具。Streamable HTTP 会话的推送写到调用的请求流，
+// 请求不是流式响应时写到会话的通知流。
+// 推荐只包含该会话可见的工具，同一会话在合并窗口内的多次推送只发送最后一次
+
+// ToolSuggestion 一个推荐的工具
+type ToolSuggestion struct {
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"` // 0~1，越大越相关
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Recommender 根据会话最近调用的工具（最早的在前）推荐下一步可能用到的工具，返回 nil 表示不推送
+type Recommender func(ctx context.Context, recent []string) []ToolSuggestion
+
+const (
+	suggestHistorySize = 8                // 每个会话保留的最近调用数
+	suggestMaxSessions = 1024             // 超出时清理空闲的会话
+	suggestSessionIdle = 30 * time.Minute // 会话空闲多久后清理
+	suggestLimit       = 5                // 每次最多推荐的工具数
+)
+
+// toolSuggester 记录各会话最近的调用和全局的调用转移次数（调用 A 之后下一次调用 B）
+type toolSuggester struct {
+	recommender Recommender // nil 时使用转移次数推荐
+
+	mu          sync.Mutex
+	sessions    map[string]*suggestHistory
+	transitions map[string]map[string]int
+}
+
+type suggestHistory struct {
+	recent   []string
+	lastSeen time.Time
+}
+
+func newToolSuggester(r Recommender) *toolSuggester {
+	return &toolSuggester{
+		recommender: r,
+		sessions:    make(map[string]*suggestHistory),
+		transitions: make(map[string]map[string]int),
+	}
+}
+
+// record 记录一次调用，返回该会话最近的调用；session 为空时没有历史，只返回本次调用
+func (g *toolSuggester) record(session, tool string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if session == "" {
+		return []string{tool}
+	}
+	h, ok := g.sessions[session]
+	if !ok {
+		if len(g.sessions) >= suggestMaxSessions {
+			g.pruneLocked()
+		}
+		h = &suggestHistory{}
+		g.sessions[session] = h
+	}
+	if n := len(h.recent); n > 0 {
+		prev := h.recent[n-1]
+		if g.transitions[prev] == nil {
+			g.transitions[prev] = make(map[string]int)
+		}
+		g.transitions[prev][tool]++
+	}
+	h.recent = append(h.recent, tool)
+	if len(h.recent) > suggestHistorySize {
+		h.recent = h.recent[len(h.recent)-suggestHistorySize:]
+	}
+	h.lastSeen = time.Now()
+	return append([]string(nil), h.recent...)
+}
+
+// pruneLocked 清理空闲的会话，仍然过多时清空
+func (g *toolSuggester) pruneLocked() {
+	cutoff := time.Now().Add(-suggestSessionIdle)
+	for id, h := range g.sessions {
+		if h.lastSeen.Before(cutoff) {
+			delete(g.sessions, id)
+		}
+	}
+	if len(g.sessions) >= suggestMaxSessions {
+		g.sessions = make(map[string]*suggestHistory)
+	}
+}
+
+// expire 删除 cutoff 之后没有调用的会话历史，见 janitor.go
+func (g *toolSuggester) expire(cutoff time.Time) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	removed := 0
+	for id, h := range g.sessions {
+		if h.lastSeen.Before(cutoff) {
+			delete(g.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// byTransitions 默认的推荐：按最后一次调用之后各工具被调用的比例排序
+func (g *toolSuggester) byTransitions(recent []string) []ToolSuggestion {
+	last := recent[len(recent)-1]
+	g.mu.Lock()
+	next := g.transitions[last]
+	total := 0
+	list := make([]ToolSuggestion, 0, len(next))
+	for name, n := range next {
+		total += n
+		list = append(list, ToolSuggestion{Name: name, Score: float64(n)})
+	}
+	g.mu.Unlock()
+	for i := range list {
+		list[i].Reason = fmt.Sprintf("called after %s in %.0f of %d calls", last, list[i].Score, total)
+		list[i].Score /= float64(total)
+	}
+	return list
+}
+
+// suggest 计算推荐：过滤掉会话不可见的工具，按 Score 从高到低取前 suggestLimit 个
+func (g *toolSuggester) suggest(ctx context.Context, recent []string, visible map[string]bool) []ToolSuggestion {
+	var list []ToolSuggestion
+	if g.recommender != nil {
+		list = g.recommender(ctx, recent)
+	} else {
+		list = g.byTransitions(recent)
+	}
+	kept := list[:0:0]
+	for _, sg := range list {
+		if visible[sg.Name] {
+			kept = append(kept, sg)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].Score != kept[j].Score {
+			return kept[i].Score > kept[j].Score
+		}
+		return kept[i].Name < kept[j].Name
+	})
+	if len(kept) > suggestLimit {
+		kept = kept[:suggestLimit]
+	}
+	return kept
+}
+
+// pushSuggestions 工具调用成功后调用：记录调用，有所属会话时推送推荐
+func (s *McpServer) pushSuggestions(ctx context.Context, c *methodCall, tool string) {
+	if s.suggester == nil {
+		return
+	}
+	recent := s.suggester.record(c.costKey.Session, tool)
+	if c.target == nil {
+		return
+	}
+	visible := make(map[string]bool)
+	for _, t := range s.visibleTools(ctx, c.access) {
+		visible[t.Name] = true
+	}
+	list := s.suggester.suggest(ctx, recent, visible)
+	if len(list) == 0 {
+		return
+	}
+	method := "notifications/tools/suggested"
+	target := c.target
+	s.notifier.Push(&notification{
+		Method:      method,
+		Params:      map[string]interface{}{"after": tool, "suggestions": list},
+		Priority:    notificationPriority(method),
+		CoalesceKey: method + ":" + c.costKey.Session,
+		Deliver: func(method string, params interface{}) {
+			target.notify(newRPCNotification(NextEventStamp(), method, params))
+		},
+	})
+}
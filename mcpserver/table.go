@@ -0,0 +1,175 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+)
+
+// ---------------------- 表格结果 ----------------------
+// 数据查询类工具可能返回成千上万行，不适合拼成一个 JSON。工具返回 *Table，
+// 由 Rows 逐行写入，服务端按约 64KB 分块：
+//   - 请求 _meta 带 rowsToken 且请求属于某个会话（WS、HTTP+SSE）时，每块作为 notifications/rows
+//     通知先于最终结果发给该会话，最终结果只包含汇总；
+//   - 其他情况下每块作为一个嵌入资源放进结果。
+// mcpclient.CallToolRows 对两种方式都提供逐行迭代。
+// 每行在 ndjson 格式下是一个与 Columns 对齐的 JSON 数组，在 csv 格式下是一条记录；两种格式都不含表头
+
+// 表格格式
+const (
+	TableNDJSON = "ndjson"
+	TableCSV    = "csv"
+)
+
+// tableChunkSize 每块的目标字节数
+const tableChunkSize = 64 << 10
+
+// Table 表格结果
+type Table struct {
+	Columns []string
+	// Format TableNDJSON（默认）或 TableCSV
+	Format string
+	// Rows 逐行写入数据，返回错误时按工具执行失败处理；ctx 取消后 Write 返回错误
+	Rows func(ctx context.Context, w *RowWriter) error
+}
+
+// NewTable 创建 NDJSON 格式的表格结果
+func NewTable(columns []string, rows func(ctx context.Context, w *RowWriter) error) *Table {
+	return &Table{Columns: columns, Format: TableNDJSON, Rows: rows}
+}
+
+// TableSummary 表格结果的 structuredContent
+type TableSummary struct {
+	Columns  []string `json:"columns"`
+	Format   string   `json:"format"`
+	Rows     int64    `json:"rows"`
+	Chunks   int      `json:"chunks"`
+	Streamed bool     `json:"streamed"` // true 表示数据已通过 notifications/rows 发送
+}
+
+// RowWriter 按行写入表格数据
+type RowWriter struct {
+	ctx    context.Context
+	format string
+	buf    bytes.Buffer
+	csv    *csv.Writer
+	rows   int64
+	emit   func(chunk []byte) error
+}
+
+// Write 写入一行，值的顺序与 Columns 一致
+func (w *RowWriter) Write(values ...interface{}) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if w.format == TableCSV {
+		record := make([]string, len(values))
+		for i, v := range values {
+			if v != nil {
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := w.csv.Write(record); err != nil {
+			return err
+		}
+		w.csv.Flush()
+	} else {
+		line, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		w.buf.Write(line)
+		w.buf.WriteByte('\n')
+	}
+	w.rows++
+	if w.buf.Len() >= tableChunkSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *RowWriter) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	chunk := append([]byte(nil), w.buf.Bytes()...)
+	w.buf.Reset()
+	return w.emit(chunk)
+}
+
+// tableMimeType 分块的 MIME 类型
+func tableMimeType(format string) string {
+	if format == TableCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+type rowStreamKey struct{}
+
+// rowStream 按 rowsToken 把分块发给会话
+type rowStream struct {
+	token  json.RawMessage
+	target notifyTarget
+}
+
+// withRowStream 请求带 rowsToken 且有所属会话时，表格数据以通知的方式流式发送
+func withRowStream(ctx context.Context, meta RequestMeta, target notifyTarget) context.Context {
+	token := meta.Fields["rowsToken"]
+	if len(token) == 0 || string(token) == "null" || target == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rowStreamKey{}, &rowStream{token: token, target: target})
+}
+
+// runTable 执行表格的 Rows 并构造结果
+func runTable(ctx context.Context, name string, t *Table) (*CallToolResult, error) {
+	if t.Rows == nil {
+		return nil, fmt.Errorf("tool %s: table has no Rows function", name)
+	}
+	format := t.Format
+	if format == "" {
+		format = TableNDJSON
+	}
+	if format != TableNDJSON && format != TableCSV {
+		return nil, fmt.Errorf("tool %s: unknown table format %q", name, format)
+	}
+	stream, _ := ctx.Value(rowStreamKey{}).(*rowStream)
+
+	var blocks []Content
+	chunks := 0
+	w := &RowWriter{ctx: ctx, format: format}
+	w.csv = csv.NewWriter(&w.buf)
+	w.emit = func(chunk []byte) error {
+		if stream != nil {
+			params := map[string]interface{}{
+				"rowsToken": stream.token,
+				"seq":       chunks,
+				"format":    format,
+				"data":      string(chunk),
+			}
+			if chunks == 0 {
+				params["columns"] = t.Columns
+			}
+			// 直接写给会话而不经过通知队列，保证分块先于最终结果到达
+			stream.target.notify(newRPCNotification(NextEventStamp(), "notifications/rows", params))
+		} else {
+			uri := fmt.Sprintf("table://%s/%d", name, chunks)
+			blocks = append(blocks, NewTextResource(uri, tableMimeType(format), string(chunk)))
+		}
+		chunks++
+		return nil
+	}
+	if err := t.Rows(ctx, w); err != nil {
+		return nil, err
+	}
+	if err := w.flush(); err != nil {
+		return nil, err
+	}
+
+	summary := TableSummary{Columns: t.Columns, Format: format, Rows: w.rows, Chunks: chunks, Streamed: stream != nil}
+	content := append([]Content{NewTextContent(fmt.Sprintf("%d rows (%s)", w.rows, format))}, blocks...)
+	return &CallToolResult{Content: content, StructuredContent: summary}, nil
+}
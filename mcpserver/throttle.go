@@ -0,0 +1,148 @@
+package mcpserver
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ---------------------- 出站限速 ----------------------
+// 每条 WS / SSE / HTTP+SSE 连接一个令牌桶，限制写给客户端的字节速率：
+//   - ConnByteRate 单连接上限；
+//   - TotalByteRate 所有连接的总上限，由当前正在发送的连接平分，
+//     一个客户端拉取大资源时只能占用自己的份额，交互式会话的小消息仍能及时发出。
+// 每个桶允许约 1 秒的突发，空闲连接的小消息不需要等待。大消息按 16KB 分段计量，
+// 份额随发送中的连接数变化及时调整。服务开始停止后不再限速，剩余通知尽快发完
+
+// throttleChunk 分段计量的字节数
+const throttleChunk = 16 << 10
+
+// bandwidth 服务级的带宽配置与发送中的连接数
+type bandwidth struct {
+	perConn float64 // 字节/秒，0 表示不限
+	total   float64 // 字节/秒，0 表示不限
+	stop    <-chan struct{}
+
+	mu      sync.Mutex
+	sending int
+}
+
+// newBandwidth 两个速率都为 0 时返回 nil，表示不限速
+func newBandwidth(perConn, total int64, stop <-chan struct{}) *bandwidth {
+	if perConn <= 0 && total <= 0 {
+		return nil
+	}
+	return &bandwidth{perConn: float64(perConn), total: float64(total), stop: stop}
+}
+
+// acquire 登记一个发送中的连接，返回它当前可用的速率
+func (bw *bandwidth) acquire() float64 {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.sending++
+	rate := bw.perConn
+	if bw.total > 0 {
+		if share := bw.total / float64(bw.sending); rate <= 0 || share < rate {
+			rate = share
+		}
+	}
+	return rate
+}
+
+func (bw *bandwidth) release() {
+	bw.mu.Lock()
+	bw.sending--
+	bw.mu.Unlock()
+}
+
+// connThrottle 单个连接的令牌桶，nil 表示不限速
+type connThrottle struct {
+	bw     *bandwidth
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newThrottle 为新连接创建令牌桶，未配置限速时返回 nil
+func (s *McpServer) newThrottle() *connThrottle {
+	if s.bandwidth == nil {
+		return nil
+	}
+	return &connThrottle{bw: s.bandwidth}
+}
+
+// wait 发送 n 字节前调用，按需等待；令牌可以透支，透支部分由下一次发送偿还
+func (t *connThrottle) wait(n int) {
+	if t == nil {
+		return
+	}
+	select {
+	case <-t.bw.stop:
+		return
+	default:
+	}
+	rate := t.bw.acquire()
+	defer t.bw.release()
+
+	burst := rate
+	if burst < throttleChunk {
+		burst = throttleChunk
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.last.IsZero() {
+		t.tokens = burst
+	} else {
+		t.tokens += now.Sub(t.last).Seconds() * rate
+		if t.tokens > burst {
+			t.tokens = burst
+		}
+	}
+	t.last = now
+	t.tokens -= float64(n)
+	var delay time.Duration
+	if t.tokens < 0 {
+		delay = time.Duration(-t.tokens / rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-t.bw.stop:
+		}
+	}
+}
+
+// writer 返回按速率写入 w 的 Writer，t 为 nil 时原样返回 w
+func (t *connThrottle) writer(w io.Writer) io.Writer {
+	if t == nil {
+		return w
+	}
+	return &throttledWriter{w: w, t: t}
+}
+
+type throttledWriter struct {
+	w io.Writer
+	t *connThrottle
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > throttleChunk {
+			n = throttleChunk
+		}
+		tw.t.wait(n)
+		m, err := tw.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
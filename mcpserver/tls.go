@@ -0,0 +1,65 @@
+package mcpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// ---------------------- TLS / mTLS ----------------------
+// 配置证书后 HTTP、WS、SSE 端点都以 HTTPS / WSS 提供；配置客户端 CA 后要求并校验客户端证书，
+// 证书的 Subject CommonName 作为调用方主体（mcpctx.Caller.Principal）
+
+// 客户端证书校验方式，对应 McpConf.TLSClientAuth
+const (
+	ClientAuthRequire  = "require"  // 必须提供由 CA 签发的证书（默认）
+	ClientAuthOptional = "optional" // 可以不提供，提供了则必须通过校验
+)
+
+// WithClientCA 开启 mTLS：用 caFile 中的 CA 校验客户端证书，required 为 false 时允许不带证书的客户端
+func WithClientCA(caFile string, required bool) Option {
+	return func(s *McpServer) {
+		s.conf.TLSClientCAFile = caFile
+		s.conf.TLSClientAuth = ClientAuthRequire
+		if !required {
+			s.conf.TLSClientAuth = ClientAuthOptional
+		}
+	}
+}
+
+// serverTLSConfig 合并 WithTLSConfig 与 McpConf 中的 TLS 配置，未开启 TLS 时返回 nil
+func (s *McpServer) serverTLSConfig() (*tls.Config, error) {
+	if !s.tlsEnabled() {
+		if s.conf.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("tls_client_ca_file requires tls_cert_file and tls_key_file")
+		}
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.tlsConfig != nil {
+		cfg = s.tlsConfig.Clone()
+	}
+	if s.conf.TLSClientCAFile == "" {
+		return cfg, nil
+	}
+
+	pem, err := os.ReadFile(s.conf.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client CA %s: no certificates found", s.conf.TLSClientCAFile)
+	}
+	cfg.ClientCAs = pool
+	switch s.conf.TLSClientAuth {
+	case "", ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	case ClientAuthOptional:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return nil, fmt.Errorf("unknown tls_client_auth %q", s.conf.TLSClientAuth)
+	}
+	return cfg, nil
+}
@@ -1,81 +1,362 @@
 package mcpserver
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
+
+	"mcptool/jsonschema"
 )
 
 // ---------------------- Tool 定义 ----------------------
 type Tool struct {
 	Name        string
 	Description string
-	Handler     func(args json.RawMessage) (interface{}, error)
+	// InputSchema 参数的 JSON Schema，为空时视为 {"type":"object"}；调用前会先按它校验参数
+	InputSchema json.RawMessage
+	// OutputSchema 可选，结构化结果（structuredContent）的 JSON Schema，必须是 object 类型
+	OutputSchema json.RawMessage
+	// Handler 执行工具；ctx 随请求取消，并携带 mcpctx 中的会话、调用方等信息
+	Handler func(ctx context.Context, args json.RawMessage) (interface{}, error)
+	// Cost 可选，返回本次调用消耗的成本单位（如付费 API 的调用次数）
+	Cost func(args json.RawMessage, result interface{}) float64
+	// ReadOnly 工具没有副作用、结果只取决于参数，相同参数的并发调用会合并为一次，见 coalesce.go
+	ReadOnly bool
+
+	inputSchema  jsonschema.Schema // 解析后的 InputSchema
+	outputSchema jsonschema.Schema // 解析后的 OutputSchema
 }
 type ToolSummary struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	InputSchema  json.RawMessage `json:"inputSchema"`
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+	// Degraded 工具依赖的健康检查失败时列出失败的依赖，工具仍可调用，见 HealthCheck
+	Degraded []string `json:"degraded,omitempty"`
+}
+
+// defaultInputSchema 没有声明 InputSchema 的工具对外展示的 schema
+var defaultInputSchema = json.RawMessage(`{"type":"object"}`)
+
+// AnnotatedResult 工具可以用它包装返回值，附带缓存命中、成本等信息，
+// 服务端会把 Value 作为结果返回，其余字段进入响应 meta
+type AnnotatedResult struct {
+	Value     interface{}
+	CacheHit  bool
+	CostUnits float64
+}
+
+// unwrapAnnotated 拆出真正的结果和注解（没有注解时返回 nil）
+func unwrapAnnotated(result interface{}) (interface{}, *AnnotatedResult) {
+	if ann, ok := result.(*AnnotatedResult); ok && ann != nil {
+		return ann.Value, ann
+	}
+	return result, nil
 }
 
 // ---------------------- Tool Registry ----------------------
-var toolRegistry = make(map[string]*Tool)
 
-func RegisterTool(tool *Tool) {
-	toolRegistry[tool.Name] = tool
+// ToolRegistry 并发安全的工具注册表。每个 McpServer 持有一个，
+// 同一进程内的多个服务可以使用不同的工具集；包级的 RegisterTool 等函数操作 DefaultToolRegistry
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*Tool
+	// disabled 被禁用的工具名，按名称记录，重新注册或 Reset 后依然生效
+	disabled map[string]bool
+	canaries map[string]*canaryState
+	shadows  map[string]*shadowState
+	// conflict 重名时的处理方式，见 SetConflictPolicy
+	conflict string
+	// checks 依赖健康检查；degraded 最近一轮检查失败的工具 -> 失败的依赖，与 disabled 一样按名称记录
+	checks   []HealthCheck
+	degraded map[string][]string
+	// watchers 对外可见的工具集变化时调用，McpServer 用它广播 list_changed
+	watchers []func()
 }
 
-func ListTools() []ToolSummary {
+// NewToolRegistry 创建空的工具注册表
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools:    make(map[string]*Tool),
+		disabled: make(map[string]bool),
+		canaries: make(map[string]*canaryState),
+		shadows:  make(map[string]*shadowState),
+	}
+}
+
+// DefaultToolRegistry 包级函数和 NewMcpServer 使用的默认注册表
+var DefaultToolRegistry = NewToolRegistry()
+
+// Register 注册工具，重名时按 SetConflictPolicy 处理（默认替换），返回实际的处理结果；
+// 策略为 error 时返回 ErrToolExists，工具不会注册
+func (r *ToolRegistry) Register(tool *Tool) (RegisterResult, error) {
+	if len(tool.InputSchema) > 0 {
+		schema, err := jsonschema.Parse(tool.InputSchema)
+		if err != nil {
+			logf(LevelError, "tool %s: %v, arguments will not be validated", tool.Name, err)
+		}
+		tool.inputSchema = schema
+	}
+	if len(tool.OutputSchema) > 0 {
+		schema, err := jsonschema.Parse(tool.OutputSchema)
+		if err != nil {
+			logf(LevelError, "tool %s: %v, output will not be checked", tool.Name, err)
+		}
+		tool.outputSchema = schema
+	}
+	r.mu.Lock()
+	res, err := r.resolveConflict(tool)
+	if err == nil {
+		r.tools[tool.Name] = tool
+	}
+	r.mu.Unlock()
+	if err != nil {
+		logf(LevelWarn, "register tool %s: %v", res.Requested, err)
+		return res, err
+	}
+	if res.Action == RegisterRenamed {
+		logf(LevelWarn, "tool %s already registered, registered as %s", res.Requested, res.Name)
+	}
+	r.changed()
+	return res, nil
+}
+
+// Unregister 移除工具，返回工具是否存在
+func (r *ToolRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	_, ok := r.tools[name]
+	delete(r.tools, name)
+	r.mu.Unlock()
+	if ok {
+		r.changed()
+	}
+	return ok
+}
+
+// watch 注册工具集变化的回调
+func (r *ToolRegistry) watch(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watchers = append(r.watchers, fn)
+}
+
+// changed 通知所有 watcher，调用时不能持有锁
+func (r *ToolRegistry) changed() {
+	r.mu.RLock()
+	watchers := r.watchers
+	r.mu.RUnlock()
+	for _, fn := range watchers {
+		fn()
+	}
+}
+
+// Get 按名称查找工具（含禁用的）
+func (r *ToolRegistry) Get(name string) (*Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Reset 清空所有工具，开关、金丝雀、影子配置保留
+func (r *ToolRegistry) Reset() {
+	r.mu.Lock()
+	r.tools = make(map[string]*Tool)
+	r.mu.Unlock()
+	r.changed()
+}
+
+// List 返回启用的工具，按名称排序
+func (r *ToolRegistry) List() []ToolSummary {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	list := []ToolSummary{}
-	for _, t := range toolRegistry {
+	for _, t := range r.tools {
+		if r.disabled[t.Name] {
+			continue
+		}
+		schema := t.InputSchema
+		if len(schema) == 0 {
+			schema = defaultInputSchema
+		}
 		list = append(list, ToolSummary{
-			Name:        t.Name,
-			Description: t.Description,
+			Name:         t.Name,
+			Description:  t.Description,
+			InputSchema:  schema,
+			OutputSchema: t.OutputSchema,
+			Degraded:     r.degradedBy(t.Name),
 		})
 	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
 	return list
 }
 
-func CallToolByName(name string, args json.RawMessage) (interface{}, error) {
-	if tool, ok := toolRegistry[name]; ok {
-		return tool.Handler(args)
+// Call 校验参数后执行工具
+func (r *ToolRegistry) Call(ctx context.Context, name string, args json.RawMessage) (interface{}, error) {
+	tool, ok := r.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	if !r.IsEnabled(name) {
+		return nil, fmt.Errorf("%w: %s", ErrToolDisabled, name)
+	}
+	// arguments 可省略，按空对象处理
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage(`{}`)
 	}
-	return nil, fmt.Errorf("tool not found: %s", name)
+	if tool.inputSchema != nil {
+		if err := jsonschema.ValidateJSON(tool.inputSchema, args); err != nil {
+			return nil, fmt.Errorf("invalid arguments for tool %s: %w", name, err)
+		}
+	}
+	return r.invoke(ctx, tool, args)
+}
+
+// 包级函数，操作 DefaultToolRegistry
+
+// RegisterTool 向默认注册表注册工具，见 (*ToolRegistry).Register
+func RegisterTool(tool *Tool) (RegisterResult, error) {
+	return DefaultToolRegistry.Register(tool)
+}
+
+// UnregisterTool 从默认注册表移除工具，服务运行中也可调用
+func UnregisterTool(name string) bool {
+	return DefaultToolRegistry.Unregister(name)
+}
+
+func ListTools() []ToolSummary {
+	return DefaultToolRegistry.List()
+}
+
+func CallToolByName(ctx context.Context, name string, args json.RawMessage) (interface{}, error) {
+	return DefaultToolRegistry.Call(ctx, name, args)
+}
+
+// ---------------------- 工具开关 ----------------------
+// 被禁用的工具仍留在注册表中，只是不出现在 tools.list 里，调用时返回 ErrToolDisabled
+
+// IsEnabled 工具是否可用（未注册的工具也返回 true，由调用方判断是否存在）
+func (r *ToolRegistry) IsEnabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !r.disabled[name]
+}
+
+// SetEnabled 启用/禁用已注册的工具
+func (r *ToolRegistry) SetEnabled(name string, enabled bool) error {
+	if _, ok := r.Get(name); !ok {
+		return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	r.setEnabled(name, enabled)
+	r.changed()
+	return nil
+}
+
+// setEnabled 不检查工具是否存在，用于启动配置（工具可能稍后才注册）
+func (r *ToolRegistry) setEnabled(name string, enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if enabled {
+		delete(r.disabled, name)
+	} else {
+		r.disabled[name] = true
+	}
+}
+
+// ToolState 工具及其开关状态
+type ToolState struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// States 返回所有已注册工具（含禁用的）的状态，按名称排序
+func (r *ToolRegistry) States() []ToolState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]ToolState, 0, len(r.tools))
+	for name := range r.tools {
+		list = append(list, ToolState{Name: name, Enabled: !r.disabled[name]})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// IsToolEnabled 见 ToolRegistry.IsEnabled
+func IsToolEnabled(name string) bool {
+	return DefaultToolRegistry.IsEnabled(name)
+}
+
+// SetToolEnabled 见 ToolRegistry.SetEnabled
+func SetToolEnabled(name string, enabled bool) error {
+	return DefaultToolRegistry.SetEnabled(name, enabled)
+}
+
+// ToolStates 见 ToolRegistry.States
+func ToolStates() []ToolState {
+	return DefaultToolRegistry.States()
+}
+
+// adminTools admin.tools 方法：带 name 和 enabled 时切换该工具的开关，始终返回全部工具状态
+func (r *ToolRegistry) adminTools(raw json.RawMessage) (interface{}, *RPCError) {
+	var params struct {
+		Name    string `json:"name"`
+		Enabled *bool  `json:"enabled"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+		}
+	}
+	if params.Name != "" && params.Enabled != nil {
+		if err := r.SetEnabled(params.Name, *params.Enabled); err != nil {
+			return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: map[string]interface{}{"problems": []string{err.Error()}}}
+		}
+		logf(LevelWarn, "tool %s enabled=%v via admin.tools", params.Name, *params.Enabled)
+	}
+	return map[string]interface{}{"tools": r.States()}, nil
 }
 
 // ---------------------- 测试工具 ----------------------
 func testTools() {
-	RegisterTool(&Tool{
-		Name:        "geocode",
-		Description: "Convert address to coordinates",
-		Handler: func(args json.RawMessage) (interface{}, error) {
-			var input GeocodeToolInput
-			if err := json.Unmarshal(args, &input); err != nil {
-				return nil, err
-			}
-			return handleGeocode(input), nil
-		},
-	})
+	geocode := NewTypedTool("geocode", "Convert address to coordinates",
+		func(ctx context.Context, in GeocodeToolInput) (map[string]interface{}, error) {
+			return geoProvider.Geocode(ctx, in)
+		})
+	geocode.Cost = func(json.RawMessage, interface{}) float64 { return 1 }
+	geocode.ReadOnly = true
+	RegisterTool(geocode)
 
-	RegisterTool(&Tool{
-		Name:        "poi_search",
-		Description: "Search POI by keyword",
-		Handler: func(args json.RawMessage) (interface{}, error) {
-			var input POISearchToolInput
-			if err := json.Unmarshal(args, &input); err != nil {
-				return nil, err
-			}
-			return handlePOISearch(input), nil
-		},
-	})
+	RegisterTypedTool("poi_search", "Search POI by keyword",
+		func(ctx context.Context, in POISearchToolInput) ([]map[string]interface{}, error) {
+			return geoProvider.POISearch(ctx, in)
+		})
 
+	// route 的 schema 取决于 provider 支持的出行方式，单独构造
 	RegisterTool(&Tool{
 		Name:        "route",
-		Description: "Route planning between two addresses",
-		Handler: func(args json.RawMessage) (interface{}, error) {
+		Description: routeToolDescription(),
+		InputSchema: routeInputSchema(),
+		Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
 			var input RouteToolInput
 			if err := json.Unmarshal(args, &input); err != nil {
 				return nil, err
 			}
-			return handleRoute(input), nil
+			return handleRouteTool(ctx, input)
 		},
 	})
+
+	RegisterTypedTool("reverse_geocode", "Convert coordinates (lat/lng) to an address", handleReverseGeocodeTool)
+	RegisterTypedTool("ip_locate", "Locate a public IP address to country/city and coordinates", handleIPLocateTool)
+	RegisterTypedTool("distance_matrix", "Distance and duration for every origin x destination pair (max 25x25, 100 cells)", handleDistanceMatrix)
+
+	batchGeocode := NewTypedTool("batch_geocode", "Convert a list of addresses to coordinates, reporting errors per item", handleBatchGeocode)
+	batchGeocode.Cost = func(_ json.RawMessage, result interface{}) float64 {
+		items, _ := result.([]BatchItemResult)
+		return float64(len(items))
+	}
+	RegisterTool(batchGeocode)
+
+	RegisterTypedTool("batch_poi_search", "Search POI for a list of keywords, reporting errors per item", handleBatchPOISearch)
 }
@@ -0,0 +1,36 @@
+package mcpserver
+
+import "sync/atomic"
+
+// ---------------------- 传输声明 ----------------------
+// server.info 的 transports 和 initialize 的 capabilities.experimental.transports 列出实例实际启用的传输，
+// 按推荐顺序排列，客户端（如 mcpclient.NewUnifiedClientAuto）据此直接选择通道，不必逐个试连。
+// Path 是配置中的路径；把 Handler 挂在带前缀的路由下时，客户端需要自行加上前缀
+
+// TransportInfo 实例启用的一种传输
+type TransportInfo struct {
+	// Name "ws" / "streamable-http" / "http+sse"（规范的 HTTP+SSE，InspectorPath）/ "sse"（只推送事件）/ "stdio"
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+}
+
+// transports 启用的传输：WS 需要编译时支持，stdio 在 ServeStdio 运行期间列出
+func (s *McpServer) transports() []TransportInfo {
+	list := []TransportInfo{}
+	if s.transportEnabled("ws") && wsSupported {
+		list = append(list, TransportInfo{Name: "ws", Path: s.conf.WSPath})
+	}
+	if s.transportEnabled("http") {
+		list = append(list, TransportInfo{Name: "streamable-http", Path: s.conf.HTTPPath})
+	}
+	if s.conf.InspectorPath != "" {
+		list = append(list, TransportInfo{Name: "http+sse", Path: s.conf.InspectorPath + "/sse"})
+	}
+	if s.transportEnabled("sse") {
+		list = append(list, TransportInfo{Name: "sse", Path: s.conf.SSEPath})
+	}
+	if atomic.LoadInt32(&s.stdioServing) > 0 {
+		list = append(list, TransportInfo{Name: "stdio"})
+	}
+	return list
+}
@@ -0,0 +1,50 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"mcptool/jsonschema"
+)
+
+// ---------------------- 类型化工具 ----------------------
+
+// NewTypedTool 用强类型函数构造工具：InputSchema 由 In 的结构推导（规则见 jsonschema.FromType），
+// Out 是结构体（或其指针）时同样推导出 OutputSchema。
+// 参数解码到 In 后调用 fn，返回的 Out 按普通结果编码。需要设置 Cost 等字段、或注册到其他 ToolRegistry 时，
+// 用它构造后再 Register
+func NewTypedTool[In, Out any](name, desc string, fn func(ctx context.Context, in In) (Out, error)) *Tool {
+	schema, err := json.Marshal(jsonschema.FromType(reflect.TypeOf((*In)(nil)).Elem()))
+	if err != nil {
+		logf(LevelError, "tool %s: infer input schema: %v", name, err)
+	}
+	var outputSchema json.RawMessage
+	outType := reflect.TypeOf((*Out)(nil)).Elem()
+	if outType.Kind() == reflect.Ptr {
+		outType = outType.Elem()
+	}
+	if outType.Kind() == reflect.Struct && outType != reflect.TypeOf(AnnotatedResult{}) && outType != reflect.TypeOf(CallToolResult{}) {
+		outputSchema, _ = json.Marshal(jsonschema.FromType(outType))
+	}
+	return &Tool{
+		Name:         name,
+		Description:  desc,
+		InputSchema:  schema,
+		OutputSchema: outputSchema,
+		Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+			var in In
+			if len(args) > 0 {
+				if err := json.Unmarshal(args, &in); err != nil {
+					return nil, err
+				}
+			}
+			return fn(ctx, in)
+		},
+	}
+}
+
+// RegisterTypedTool 注册强类型工具，省去每个 Handler 里的 json.Unmarshal
+func RegisterTypedTool[In, Out any](name, desc string, fn func(ctx context.Context, in In) (Out, error)) (RegisterResult, error) {
+	return RegisterTool(NewTypedTool(name, desc, fn))
+}
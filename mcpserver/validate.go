@@ -0,0 +1,326 @@
+package mcpserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcptool/jsonschema"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ---------------------- 配置预检 ----------------------
+// admin.validateConfig 在不影响运行中服务的前提下检查一份候选 manifest：
+// 解析与未知字段、server 段（路径、传输、TLS 文件、端口占用）、工具定义（重名、schema、命令是否存在）、
+// 提示词与资源文件，以及 HTTP 工具的上游是否可连接。只读取文件和探测端口，不注册任何东西
+
+// 上游探测的超时
+const upstreamProbeTimeout = 3 * time.Second
+
+// ConfigReport 配置预检报告
+type ConfigReport struct {
+	// Valid 没有 error 级别的问题
+	Valid    bool            `json:"valid"`
+	Problems []ConfigProblem `json:"problems"`
+	// Tools manifest 会注册的工具
+	Tools []string `json:"tools"`
+	// RestartRequired 与运行中服务不同、需要重启才能生效的 server 字段
+	RestartRequired []string `json:"restartRequired,omitempty"`
+}
+
+// ConfigProblem 一个问题，Path 指向 manifest 中的位置，如 "tools[2].http.url"
+type ConfigProblem struct {
+	Severity string `json:"severity"` // error / warning
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+func (r *ConfigReport) errorf(path, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, ConfigProblem{Severity: "error", Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *ConfigReport) warnf(path, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, ConfigProblem{Severity: "warning", Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateManifest 检查 manifest 内容，baseDir 用于解析相对路径；probe 为 true 时探测上游连通性。
+// 不与运行中的服务比较，适合在部署前离线使用
+func ValidateManifest(ctx context.Context, data []byte, baseDir string, probe bool) *ConfigReport {
+	report, _ := validateManifest(ctx, data, baseDir, probe)
+	return report
+}
+
+// ValidateConfig 与 ValidateManifest 相同，另外对照运行中的服务检查端口冲突并列出需要重启的字段
+func (s *McpServer) ValidateConfig(ctx context.Context, data []byte, baseDir string, probe bool) *ConfigReport {
+	report, m := validateManifest(ctx, data, baseDir, probe)
+	if m != nil {
+		s.compareServerConf(report, m.Server)
+		report.Valid = !report.hasErrors()
+	}
+	return report
+}
+
+func (r *ConfigReport) hasErrors() bool {
+	for _, p := range r.Problems {
+		if p.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
+
+func validateManifest(ctx context.Context, data []byte, baseDir string, probe bool) (*ConfigReport, *Manifest) {
+	report := &ConfigReport{Problems: []ConfigProblem{}, Tools: []string{}}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		report.errorf("", "parse manifest: %v", err)
+		return report, nil
+	}
+	// 严格解析只用于发现拼错的字段
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&Manifest{}); err != nil {
+		report.warnf("", "%v", err)
+	}
+
+	if !m.Auth.IsZero() {
+		report.errorf("auth", "auth section is not supported yet")
+	}
+	if !m.RateLimits.IsZero() {
+		report.errorf("rate_limits", "rate_limits section is not supported yet")
+	}
+	for i, b := range m.Builtins {
+		if b != "geo" {
+			report.errorf(fmt.Sprintf("builtins[%d]", i), "unknown builtin %q", b)
+		}
+	}
+	validateServerConf(report, m.Server)
+	validateManifestTools(ctx, report, m.Tools, baseDir, probe)
+
+	for i, p := range m.Prompts {
+		path := fmt.Sprintf("prompts[%d].file", i)
+		if p.File == "" {
+			report.errorf(path, "file is required")
+		} else if _, err := os.Stat(resolvePath(baseDir, p.File)); err != nil {
+			report.errorf(path, "%v", err)
+		}
+	}
+	for i, r := range m.Resources {
+		path := fmt.Sprintf("resources[%d].dir", i)
+		if info, err := os.Stat(resolvePath(baseDir, r.Dir)); err != nil {
+			report.errorf(path, "%v", err)
+		} else if !info.IsDir() {
+			report.errorf(path, "%s is not a directory", r.Dir)
+		}
+	}
+	report.Valid = !report.hasErrors()
+	return report, &m
+}
+
+// validateServerConf 检查 server 段中不依赖运行状态的部分
+func validateServerConf(report *ConfigReport, conf McpConf) {
+	for i, t := range conf.Transports {
+		if t != "http" && t != "ws" && t != "sse" {
+			report.errorf(fmt.Sprintf("server.transports[%d]", i), "unknown transport %q", t)
+		}
+	}
+	if conf.Port < 0 || conf.Port > 65535 {
+		report.errorf("server.port", "invalid port %d", conf.Port)
+	}
+
+	// 未设置的路径按默认值参与冲突检查
+	orDefault := func(path, def string) string {
+		if path == "" {
+			return def
+		}
+		return path
+	}
+	paths := map[string]string{}
+	for _, p := range []struct{ field, path string }{
+		{"http_path", orDefault(conf.HTTPPath, "/mcp")},
+		{"ws_path", orDefault(conf.WSPath, "/ws")},
+		{"sse_path", orDefault(conf.SSEPath, "/sse")},
+		{"read_only_path", conf.ReadOnlyPath},
+		{"inspector_path", conf.InspectorPath},
+	} {
+		if p.path == "" {
+			continue
+		}
+		if !strings.HasPrefix(p.path, "/") {
+			report.errorf("server."+p.field, "path %q must start with /", p.path)
+		}
+		if other, ok := paths[p.path]; ok {
+			report.errorf("server."+p.field, "path %q is also used by %s", p.path, other)
+		}
+		paths[p.path] = p.field
+	}
+
+	if (conf.TLSCertFile == "") != (conf.TLSKeyFile == "") {
+		report.errorf("server.tls_cert_file", "tls_cert_file and tls_key_file must be set together")
+	} else if conf.TLSCertFile != "" {
+		if _, err := tls.LoadX509KeyPair(conf.TLSCertFile, conf.TLSKeyFile); err != nil {
+			report.errorf("server.tls_cert_file", "%v", err)
+		}
+	}
+	// TLS 客户端证书配置沿用启动时的检查
+	if _, err := (&McpServer{conf: conf}).serverTLSConfig(); err != nil {
+		report.errorf("server.tls_client_ca_file", "%v", err)
+	}
+	if conf.ACL != nil && conf.ACL.Default != "" && conf.ACL.Default != ACLAllow && conf.ACL.Default != ACLDeny {
+		report.errorf("server.acl.default", "acl default must be %q or %q", ACLAllow, ACLDeny)
+	}
+	if err := NewToolRegistry().SetConflictPolicy(conf.ToolConflict); err != nil {
+		report.errorf("server.tool_conflict", "%v", err)
+	}
+	for pattern := range conf.Methods {
+		t, err := parseMethodToggle(pattern)
+		if err != nil {
+			report.errorf("server.methods."+pattern, "%v", err)
+		} else if _, ok := Methods[pattern]; t.exact && !ok {
+			report.warnf("server.methods."+pattern, "unknown method %q is ignored", pattern)
+		}
+	}
+	if c := conf.StartupChecks; c != "" && c != StartupChecksFail && c != StartupChecksDegrade {
+		report.errorf("server.startup_checks", "startup_checks must be %q or %q", StartupChecksFail, StartupChecksDegrade)
+	}
+	if conf.JWTPublicKeyFile != "" {
+		if _, err := loadRSAPublicKey(conf.JWTPublicKeyFile); err != nil {
+			report.errorf("server.jwt_public_key_file", "%v", err)
+		}
+	}
+}
+
+// validateManifestTools 检查工具定义
+func validateManifestTools(ctx context.Context, report *ConfigReport, tools []ManifestTool, baseDir string, probe bool) {
+	seen := map[string]int{}
+	for i, t := range tools {
+		path := fmt.Sprintf("tools[%d]", i)
+		if first, ok := seen[t.Name]; ok && t.Name != "" {
+			report.errorf(path+".name", "duplicate tool name %q (also tools[%d])", t.Name, first)
+			continue
+		}
+		seen[t.Name] = i
+
+		tool, err := t.build(baseDir)
+		if err != nil {
+			report.errorf(path, "%v", strings.TrimPrefix(err.Error(), "manifest: "))
+			continue
+		}
+		report.Tools = append(report.Tools, tool.Name)
+		// 注册时 schema 错误只记日志，这里作为错误报告
+		if len(tool.InputSchema) > 0 {
+			if _, err := jsonschema.Parse(tool.InputSchema); err != nil {
+				report.errorf(path+".input_schema", "%v", err)
+			}
+		}
+		if len(tool.OutputSchema) > 0 {
+			if _, err := jsonschema.Parse(tool.OutputSchema); err != nil {
+				report.errorf(path+".output_schema", "%v", err)
+			}
+		}
+
+		if t.Command != nil {
+			if _, err := exec.LookPath(t.Command.Path); err != nil {
+				report.errorf(path+".command.path", "%v", err)
+			}
+		}
+		if t.HTTP != nil && probe {
+			if err := probeUpstream(ctx, t.HTTP.URL); err != nil {
+				// 上游可能只是暂时不可用，不阻止部署
+				report.warnf(path+".http.url", "upstream unreachable: %v", err)
+			}
+		}
+	}
+}
+
+// probeUpstream 用空参数渲染 URL，尝试与上游建立 TCP 连接
+func probeUpstream(ctx context.Context, rawURL string) error {
+	rendered, err := renderArg(rawURL, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(rendered)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url %q has no host", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, upstreamProbeTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// compareServerConf 对照运行中的服务：监听地址变化时检查新端口是否被占用，并列出需要重启的字段
+func (s *McpServer) compareServerConf(report *ConfigReport, conf McpConf) {
+	running := s.conf
+	if conf.Addr == "" {
+		conf.Addr = running.Addr
+	}
+	if conf.Addr != running.Addr || conf.Port != running.Port {
+		addr := net.JoinHostPort(conf.Addr, strconv.Itoa(conf.Port))
+		if ln, err := net.Listen("tcp", addr); err != nil {
+			report.errorf("server.port", "cannot listen on %s: %v", addr, err)
+		} else {
+			ln.Close()
+		}
+	}
+
+	// 比较 yaml 字段；零值表示使用默认值，不算变化
+	rv, cv := reflect.ValueOf(running), reflect.ValueOf(conf)
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" || cv.Field(i).IsZero() {
+			continue
+		}
+		if !reflect.DeepEqual(rv.Field(i).Interface(), cv.Field(i).Interface()) {
+			report.RestartRequired = append(report.RestartRequired, name)
+		}
+	}
+}
+
+// adminValidateConfig admin.validateConfig 方法，参数：
+//
+//	{"manifest": "<YAML 文本>", "baseDir": "相对路径的基准目录，默认为工作目录", "probe": true}
+func (s *McpServer) adminValidateConfig(ctx context.Context, raw json.RawMessage) (interface{}, *RPCError) {
+	var params struct {
+		Manifest string `json:"manifest"`
+		BaseDir  string `json:"baseDir"`
+		Probe    *bool  `json:"probe"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil || params.Manifest == "" {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params", Data: map[string]interface{}{"problems": []string{"manifest is required"}}}
+	}
+	baseDir := params.BaseDir
+	if baseDir == "" {
+		baseDir, _ = os.Getwd()
+	}
+	baseDir = filepath.Clean(baseDir)
+	probe := params.Probe == nil || *params.Probe
+	return s.ValidateConfig(ctx, []byte(params.Manifest), baseDir, probe), nil
+}
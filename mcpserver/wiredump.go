@@ -0,0 +1,61 @@
+package mcpserver
+
+import (
+	"io"
+	"os"
+
+	"mcptool/internal/wiredump"
+)
+
+// ---------------------- 报文转储 ----------------------
+// 排查与第三方 MCP 宿主的互通问题时，把收发的原始报文（HTTP 请求 / 响应体、WS 帧、SSE 行）
+// 逐条写到一个 writer，不必抓包。格式和脱敏规则见 internal/wiredump，与 mcpclient.SetWireDump 相同
+
+// wire 默认的转储目标，用于没有设置自己的转储目标的服务
+var wire wiredump.Dumper
+
+// SetWireDump 开始把报文转储到 w，w 为 nil 时关闭；redactKeys 追加需要脱敏的字段名。
+// 对没有设置自己的转储目标（McpConf.WireDump 或 McpServer.SetWireDump）的服务生效
+func SetWireDump(w io.Writer, redactKeys ...string) {
+	wire.Set(w, redactKeys...)
+}
+
+// SetWireDump 开始把本服务的报文转储到 w，w 为 nil 时关闭，回到包级 SetWireDump 设置的目标
+func (s *McpServer) SetWireDump(w io.Writer, redactKeys ...string) {
+	s.wire.own.Set(w, redactKeys...)
+}
+
+// openWireDump 按 McpConf.WireDump 打开转储目标："-" 或 "stderr" 为标准错误，其他为追加写入的文件
+func openWireDump(target string) (io.Writer, error) {
+	if target == "-" || target == "stderr" {
+		return os.Stderr, nil
+	}
+	return os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+}
+
+// wireDump 一个服务的转储目标，各连接持有它的指针，每次转储时选择目标，运行中开关立即生效
+type wireDump struct {
+	own wiredump.Dumper
+}
+
+// dumper 服务自己的目标开启时使用它，否则使用默认目标
+func (d *wireDump) dumper() *wiredump.Dumper {
+	if d.own.Enabled() {
+		return &d.own
+	}
+	return &wire
+}
+
+func (d *wireDump) enabled() bool {
+	return d.dumper().Enabled()
+}
+
+// frame 转储一条报文，dir 为 recv / send；未开启时什么都不做
+func (d *wireDump) frame(transport, conn, dir string, data []byte) {
+	d.dumper().Frame(transport, conn, dir, data)
+}
+
+// json 转储一个将要编码发送的值
+func (d *wireDump) json(transport, conn string, v interface{}) {
+	d.dumper().JSON(transport, conn, v)
+}
@@ -0,0 +1,38 @@
+package mcpserver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWireDumpPerServer(t *testing.T) {
+	var bufA, bufB, def bytes.Buffer
+	a := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	b := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	c := NewMcpServerWithTools(McpConf{}, NewToolRegistry())
+	a.SetWireDump(&bufA)
+	b.SetWireDump(&bufB)
+	SetWireDump(&def)
+	defer SetWireDump(nil)
+
+	a.wire.frame("http", "conn-a", "recv", []byte(`{"from":"a"}`))
+	b.wire.frame("http", "conn-b", "recv", []byte(`{"from":"b"}`))
+	c.wire.frame("http", "conn-c", "recv", []byte(`{"from":"c"}`))
+
+	if got := bufA.String(); !strings.Contains(got, "conn-a") || strings.Contains(got, "conn-b") {
+		t.Errorf("server a dump = %q", got)
+	}
+	if got := bufB.String(); !strings.Contains(got, "conn-b") || strings.Contains(got, "conn-a") {
+		t.Errorf("server b dump = %q", got)
+	}
+	if got := def.String(); !strings.Contains(got, "conn-c") || strings.Contains(got, "conn-a") {
+		t.Errorf("default dump = %q", got)
+	}
+
+	a.SetWireDump(nil)
+	a.wire.frame("http", "conn-a2", "recv", []byte(`{}`))
+	if !strings.Contains(def.String(), "conn-a2") {
+		t.Error("server a did not fall back to the default target")
+	}
+}
@@ -0,0 +1,235 @@
+//go:build !nowebsocket
+
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ---------------------- WebSocket MCP Handler ----------------------
+// WebSocket 传输依赖 gorilla/websocket。只需要 stdio / HTTP 的精简部署可以用 -tags nowebsocket 编译，
+// 不引入该依赖，WS 端点改为返回 501，见 ws_stub.go
+
+// wsSupported 是否编译了 WebSocket 传输
+const wsSupported = true
+
+// wsConn 一条 WS 连接
+type wsConn = websocket.Conn
+
+// upgrader 按配置构造握手参数
+func (s *McpServer) upgrader() *websocket.Upgrader {
+	u := &websocket.Upgrader{
+		ReadBufferSize:    s.conf.WSReadBufferSize,
+		WriteBufferSize:   s.conf.WSWriteBufferSize,
+		EnableCompression: s.conf.WSCompression,
+		CheckOrigin:       s.wsCheckOrigin,
+	}
+	if u.CheckOrigin == nil && len(s.conf.WSAllowedOrigins) > 0 {
+		u.CheckOrigin = func(r *http.Request) bool {
+			return originAllowed(r, s.conf.WSAllowedOrigins)
+		}
+	}
+	return u
+}
+
+// originAllowed 不带 Origin、与 Host 同源或匹配 allowed 中任一模式的握手请求允许通过
+func originAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(pattern), origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *McpServer) wsHandler(w http.ResponseWriter, r *http.Request) {
+	sess, resumed := s.acquireWSSession(r)
+	header := http.Header{}
+	header.Set("Mcp-Resume-Token", sess.token)
+	header.Set("Mcp-Session-Id", sess.id)
+
+	conn, err := s.upgrader().Upgrade(w, r, header)
+	if err != nil {
+		logf(LevelWarn, "WS upgrade error: %v", err)
+		s.detachWSSession(sess)
+		return
+	}
+	defer conn.Close()
+	defer s.detachWSSession(sess)
+	s.trackWSConn(conn, true)
+	defer s.trackWSConn(conn, false)
+
+	if resumed {
+		logf(LevelInfo, "WS session %s resumed", sess.id)
+	}
+	if err := sess.attach(conn); err != nil {
+		logf(LevelWarn, "WS write error: %v", err)
+		return
+	}
+	// 每个 WS 会话独立归集成本，断线恢复后沿用
+	costKey := sess.costKey
+	// 连接级 context：连接断开时取消，工具调用都在它之下执行
+	ctx := s.requestContext(r, "ws", sess)
+
+	// 读超时 = ping 间隔 + pong 超时，收到 pong 或请求后顺延；对端失联时 ReadMessage 返回错误
+	readWait := s.conf.WSPingInterval + s.conf.WSPongTimeout
+	extendDeadline := func() {
+		if s.conf.WSPongTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(readWait))
+		}
+	}
+	extendDeadline()
+	conn.SetPongHandler(func(string) error {
+		extendDeadline()
+		return nil
+	})
+	if s.conf.WSMaxMessageSize > 0 {
+		// 超过上限时 ReadMessage 返回错误，连接以 1009 关闭
+		conn.SetReadLimit(s.conf.WSMaxMessageSize)
+	}
+	// 空闲超时：客户端持续没有发来请求（pong 不算）时关闭连接；处理请求期间暂停计时
+	var idle *time.Timer
+	var idled, unresponsive int32 // 因空闲、客户端无响应而主动关闭
+	if s.conf.WSIdleTimeout > 0 {
+		idle = time.AfterFunc(s.conf.WSIdleTimeout, func() {
+			atomic.StoreInt32(&idled, 1)
+			logf(LevelInfo, "WS session %s idle for %v, closing", sess.id, s.conf.WSIdleTimeout)
+			msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsPingWriteTimeout))
+			conn.Close()
+		})
+		defer idle.Stop()
+	}
+
+	done := make(chan struct{}) // 用于通知 goroutine 停止
+	defer close(done)
+	// 启动心跳 goroutine
+	go func() {
+		ticker := time.NewTicker(s.conf.WSPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// WriteControl 可以与其他写操作并发调用
+				if err := conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(wsPingWriteTimeout)); err != nil {
+					logf(LevelInfo, "Ping error, closing: %v", err)
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+	// 请求并发处理，读循环不被长时间运行的工具阻塞：工具通过 SamplerFromContext 等向客户端发起请求时，
+	// 客户端的响应仍由这里读取。有请求在处理时暂停空闲计时
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// 连接断开时先取消进行中的请求（包括等待客户端响应的），再等待它们结束
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if s.conf.ClientPingInterval > 0 {
+		go s.keepAlive(ctx, sess, func(err error) {
+			atomic.StoreInt32(&unresponsive, 1)
+			logf(LevelInfo, "WS session %s: %v, closing", sess.id, err)
+			conn.Close()
+		})
+	}
+	var inflight int32
+	for {
+		var req RPCRequest
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			s.wire.frame("ws", sess.id, "recv", data)
+			err = json.Unmarshal(data, &req)
+		}
+		if err != nil {
+			// 非主动关闭连接
+			if !s.stopped() && atomic.LoadInt32(&idled) == 0 && atomic.LoadInt32(&unresponsive) == 0 && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logf(LevelWarn, "WS read error: %v", err)
+			}
+
+			break
+		}
+		extendDeadline()
+		if isClientResponse(&req) {
+			// 客户端对服务端请求的响应，不算作客户端的请求
+			sess.calls.resolve(data)
+			continue
+		}
+		if s.stopped() {
+			// 停止过程中不再处理新请求，连接随后以 going-away 关闭
+			continue
+		}
+		if idle != nil && atomic.AddInt32(&inflight, 1) == 1 && !idle.Stop() {
+			// 计时器已触发，连接正在关闭
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := s.dispatch(ctx, req, costKey); resp != nil {
+				if err := sess.writeJSON(resp); err != nil {
+					logf(LevelWarn, "WS write error: %v", err)
+					conn.Close()
+				}
+			}
+			if idle != nil && atomic.AddInt32(&inflight, -1) == 0 && atomic.LoadInt32(&idled) == 0 {
+				idle.Reset(s.conf.WSIdleTimeout)
+			}
+		}()
+	}
+}
+
+// closeWSConns 向所有 WS 连接发送 going-away 关闭帧并断开
+func (s *McpServer) closeWSConns() {
+	s.mu.Lock()
+	conns := make([]*websocket.Conn, 0, len(s.wsConns))
+	for conn := range s.wsConns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, conn := range conns {
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		conn.Close()
+	}
+}
+
+// writeWSJSON 与 conn.WriteJSON 相同，但按连接的速率写出
+func writeWSJSON(conn *websocket.Conn, t *connThrottle, v interface{}) error {
+	if t == nil {
+		return conn.WriteJSON(v)
+	}
+	w, err := conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(t.writer(w)).Encode(v)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
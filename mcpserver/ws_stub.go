@@ -0,0 +1,29 @@
+//go:build nowebsocket
+
+package mcpserver
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ---------------------- WebSocket 占位 ----------------------
+// 用 -tags nowebsocket 编译时没有 WebSocket 传输：WS 端点返回 501，其余传输不受影响
+
+// wsSupported 是否编译了 WebSocket 传输
+const wsSupported = false
+
+// wsConn 占位类型，不会有实例
+type wsConn struct{}
+
+var errWSUnavailable = errors.New("mcpserver: built without websocket support (nowebsocket)")
+
+func (s *McpServer) wsHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, errWSUnavailable.Error(), http.StatusNotImplemented)
+}
+
+func (s *McpServer) closeWSConns() {}
+
+func writeWSJSON(conn *wsConn, t *connThrottle, v interface{}) error {
+	return errWSUnavailable
+}
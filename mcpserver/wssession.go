@@ -0,0 +1,231 @@
+package mcpserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mcptool/mcpctx"
+	"mcptool/version"
+)
+
+// ---------------------- WS 会话与断线恢复 ----------------------
+
+// 断线期间每个会话最多缓存的通知数，超出后丢弃最旧的
+const wsMaxQueuedNotifications = 256
+
+// RPCNotification 服务端主动推送的 JSON-RPC 通知（没有 id）
+type RPCNotification struct {
+	JsonRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// wsSession 一个 WS 会话，连接断开后在恢复窗口内保留，客户端可凭 token 续接
+type wsSession struct {
+	id      string
+	token   string
+	costKey CostKey
+
+	mu       sync.Mutex // 保护 conn / queued，同时串行化写操作
+	conn     *wsConn
+	queued   []*RPCNotification
+	expires  *time.Timer
+	throttle *connThrottle
+	wire     *wireDump
+
+	// 正在写入（如限速发送大结果）时到达的通知先放在这里，由写入方释放写锁前补发，
+	// 避免广播被一个慢连接阻塞
+	deferredMu sync.Mutex
+	deferred   []*RPCNotification
+
+	st    sessionState
+	calls clientCalls // 服务端发往客户端、等待响应的请求
+}
+
+var _ mcpctx.Session = (*wsSession)(nil)
+
+var wsSessionSeq uint64
+
+func newResumeToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// acquireWSSession 根据请求中的 resume token 恢复会话，找不到（或未开启恢复）时新建
+func (s *McpServer) acquireWSSession(r *http.Request) (sess *wsSession, resumed bool) {
+	token := r.Header.Get("Mcp-Resume-Token")
+	if token == "" {
+		token = r.URL.Query().Get("resume")
+	}
+
+	s.wsSessionsMu.Lock()
+	defer s.wsSessionsMu.Unlock()
+	if token != "" && s.conf.ResumeWindow > 0 {
+		if sess, ok := s.wsSessions[token]; ok {
+			sess.mu.Lock()
+			attached := sess.conn != nil
+			if !attached && sess.expires != nil {
+				sess.expires.Stop()
+				sess.expires = nil
+			}
+			sess.mu.Unlock()
+			if !attached {
+				return sess, true
+			}
+		}
+	}
+
+	id := fmt.Sprintf("ws-%d", atomic.AddUint64(&wsSessionSeq, 1))
+	sess = &wsSession{
+		id:       id,
+		token:    newResumeToken(),
+		costKey:  s.costKeyFromRequest(r, id),
+		throttle: s.newThrottle(),
+		wire:     &s.wire,
+	}
+	s.wsSessions[sess.token] = sess
+	return sess, false
+}
+
+// attach 绑定新连接，并补发断线期间缓存的通知
+func (sess *wsSession) attach(conn *wsConn) error {
+	sess.mu.Lock()
+	defer sess.unlock()
+	sess.conn = conn
+	for len(sess.queued) > 0 {
+		if err := sess.write(conn, sess.queued[0]); err != nil {
+			return err
+		}
+		sess.queued = sess.queued[1:]
+	}
+	return nil
+}
+
+// detach 连接断开：开启恢复窗口时保留会话，否则直接删除
+func (s *McpServer) detachWSSession(sess *wsSession) {
+	sess.mu.Lock()
+	sess.conn = nil
+	if s.conf.ResumeWindow > 0 {
+		sess.expires = time.AfterFunc(s.conf.ResumeWindow, func() { s.removeWSSession(sess) })
+		sess.unlock()
+		return
+	}
+	sess.unlock()
+	s.removeWSSession(sess)
+}
+
+// removeWSSession 删除未重新连接的会话及其连接级规则
+func (s *McpServer) removeWSSession(sess *wsSession) {
+	s.wsSessionsMu.Lock()
+	defer s.wsSessionsMu.Unlock()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.conn == nil {
+		delete(s.wsSessions, sess.token)
+		s.forgetSession(sess.id)
+	}
+}
+
+// writeJSON 串行化写入；连接已断开时返回错误
+func (sess *wsSession) writeJSON(v interface{}) error {
+	sess.mu.Lock()
+	defer sess.unlock()
+	if sess.conn == nil {
+		return fmt.Errorf("ws session %s is detached", sess.id)
+	}
+	return sess.write(sess.conn, v)
+}
+
+// notify 推送通知；断线期间先缓存，恢复后补发；会话正在写入时推迟到写入完成后发送
+func (sess *wsSession) notify(n *RPCNotification) {
+	sess.deferredMu.Lock()
+	sess.deferred = append(sess.deferred, n)
+	sess.deferredMu.Unlock()
+	if sess.mu.TryLock() {
+		sess.unlock()
+	}
+}
+
+// unlock 释放写锁，释放前补发推迟的通知。释放后再检查一次，
+// 避免通知在补发之后、释放之前到达而无人发送
+func (sess *wsSession) unlock() {
+	for {
+		sess.deferredMu.Lock()
+		pending := sess.deferred
+		sess.deferred = nil
+		sess.deferredMu.Unlock()
+		for _, n := range pending {
+			sess.sendLocked(n)
+		}
+		sess.mu.Unlock()
+
+		sess.deferredMu.Lock()
+		more := len(sess.deferred) > 0
+		sess.deferredMu.Unlock()
+		if !more || !sess.mu.TryLock() {
+			return
+		}
+	}
+}
+
+// write 按会话的速率写出一条消息，调用方持有写锁
+func (sess *wsSession) write(conn *wsConn, v interface{}) error {
+	sess.wire.json("ws", sess.id, v)
+	return writeWSJSON(conn, sess.throttle, v)
+}
+
+// sendLocked 写出一条通知，连接不可用时缓存，调用方持有写锁
+func (sess *wsSession) sendLocked(n *RPCNotification) {
+	if sess.conn != nil {
+		if err := sess.write(sess.conn, n); err == nil {
+			return
+		}
+	}
+	if len(sess.queued) >= wsMaxQueuedNotifications {
+		sess.queued = sess.queued[1:]
+	}
+	sess.queued = append(sess.queued, n)
+}
+
+// newRPCNotification 构造带事件序号的通知，序号放在 params._meta.event
+func newRPCNotification(stamp EventStamp, method string, params interface{}) *RPCNotification {
+	payload, _ := json.Marshal(params)
+	return &RPCNotification{JsonRPC: version.JSONRPC, Method: method, Params: stampPayload(payload, stamp)}
+}
+
+// sendWS 向本服务的所有 WS 会话（包括等待恢复的）推送通知
+func (s *McpServer) sendWS(stamp EventStamp, method string, params interface{}) {
+	n := newRPCNotification(stamp, method, params)
+	s.wsSessionsMu.Lock()
+	sessions := make([]*wsSession, 0, len(s.wsSessions))
+	for _, sess := range s.wsSessions {
+		sessions = append(sessions, sess)
+	}
+	s.wsSessionsMu.Unlock()
+	for _, sess := range sessions {
+		if sess.st.wants(method, params) {
+			sess.notify(n)
+		}
+	}
+}
+
+// ID 实现 mcpctx.Session
+func (sess *wsSession) ID() string {
+	return sess.id
+}
+
+// State 会话状态，见 mcpctx.SessionStateFromContext
+func (sess *wsSession) State() mcpctx.SessionState {
+	return &sess.st
+}
+
+func (sess *wsSession) state() *sessionState {
+	return &sess.st
+}
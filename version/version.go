@@ -0,0 +1,116 @@
+// Package version 是 gomcp 的版本信息，服务端和客户端共用：JSON-RPC 版本、支持的 MCP 协议版本和库版本。
+// 服务端的 initialize 协商、system.version 和 serverInfo 都从这里取值，升级协议时只需修改这一处
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// Library gomcp 库的版本
+	Library = "1.0.0"
+	// JSONRPC 使用的 JSON-RPC 版本
+	JSONRPC = "2.0"
+)
+
+// ProtocolVersions 支持的 MCP 协议版本（修订日期），从新到旧排列，第一个为最新版本
+var ProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// Latest 最新的 MCP 协议版本
+func Latest() string {
+	return ProtocolVersions[0]
+}
+
+// Supports 是否支持指定的 MCP 协议版本
+func Supports(protocol string) bool {
+	for _, v := range ProtocolVersions {
+		if v == protocol {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate 按客户端请求的版本协商：支持时原样使用；否则使用最新版本，由客户端决定能否降级或断开。
+// 客户端请求的版本比服务端支持的都新时，最新版本即双方都支持的最高版本
+func Negotiate(requested string) string {
+	if Supports(requested) {
+		return requested
+	}
+	return Latest()
+}
+
+// Feature 随协议版本引入的功能
+type Feature string
+
+const (
+	// FeatureStreamableHTTP Streamable HTTP 传输，之前的版本使用 HTTP+SSE 传输
+	FeatureStreamableHTTP Feature = "streamable-http"
+	// FeatureCompletions completion/complete
+	FeatureCompletions Feature = "completions"
+	// FeatureStructuredContent 工具的 outputSchema 和结果的 structuredContent
+	FeatureStructuredContent Feature = "structured-content"
+	// FeatureElicitation elicitation/create
+	FeatureElicitation Feature = "elicitation"
+	// FeatureProtocolHeader HTTP 请求带 MCP-Protocol-Version 头
+	FeatureProtocolHeader Feature = "protocol-version-header"
+)
+
+// featureSince 功能 -> 引入它的协议版本
+var featureSince = map[Feature]string{
+	FeatureStreamableHTTP:    "2025-03-26",
+	FeatureCompletions:       "2025-03-26",
+	FeatureStructuredContent: "2025-06-18",
+	FeatureElicitation:       "2025-06-18",
+	FeatureProtocolHeader:    "2025-06-18",
+}
+
+// Has 协议版本是否包含某项功能。protocol 为空（未协商）时按最新版本处理；
+// 版本号是修订日期，可以按字符串比较
+func Has(protocol string, f Feature) bool {
+	since, ok := featureSince[f]
+	if !ok {
+		return false
+	}
+	if protocol == "" {
+		protocol = Latest()
+	}
+	return protocol >= since
+}
+
+// Info system.version 的响应
+type Info struct {
+	JSONRPC string `json:"jsonrpc"`
+	// Protocols 服务端支持的 MCP 协议版本，第一个为最新版本
+	Protocols []string `json:"protocolVersions"`
+	Library   string   `json:"library"`
+	// Capabilities 服务端启用的能力，与 initialize 返回的 capabilities 相同
+	Capabilities map[string]interface{} `json:"capabilities,omitempty"`
+}
+
+// Current 本库的版本信息，Capabilities 由服务端填写
+func Current() Info {
+	return Info{
+		JSONRPC:   JSONRPC,
+		Protocols: append([]string(nil), ProtocolVersions...),
+		Library:   Library,
+	}
+}
+
+// UnmarshalJSON 兼容旧版服务端：system.version 只返回 JSON-RPC 版本字符串（"2.0"），
+// 此时只有 JSONRPC 有值
+func (i *Info) UnmarshalJSON(data []byte) error {
+	var legacy string
+	if json.Unmarshal(data, &legacy) == nil {
+		*i = Info{JSONRPC: legacy}
+		return nil
+	}
+	type plain Info
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("version: %w", err)
+	}
+	*i = Info(p)
+	return nil
+}